@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// ANSI escape sequences for colored output.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// colorOutput enables colored output. It is set by setupLogger.
+var colorOutput bool
+
+// useColor reports whether output should be colored: never with
+// --no-color, a non-empty NO_COLOR, or TERM=dumb, and otherwise only if
+// stdout and stderr are terminals.
+func useColor(noColor bool, getenv func(string) string) bool {
+	if noColor || getenv("NO_COLOR") != "" || getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(int(os.Stdout.Fd())) && isTerminal(int(os.Stderr.Fd()))
+}
+
+// colorize wraps s in the given color if colored output is enabled.
+func colorize(color, s string) string {
+	if !colorOutput || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// levelColor returns the color of log lines at level: green for
+// successes, yellow for warnings, and red for errors.
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return ansiRed
+	case level >= slog.LevelWarn:
+		return ansiYellow
+	case level == levelSuccess:
+		return ansiGreen
+	default:
+		return ""
+	}
+}
+
+// colorHandler colors each line written by the wrapped handler by the
+// level of its record.
+type colorHandler struct {
+	slog.Handler
+	out *colorWriter
+}
+
+// colorWriter wraps every write in the color set by colorHandler.
+type colorWriter struct {
+	w     io.Writer
+	mu    sync.Mutex
+	color string
+}
+
+func (h *colorHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !colorOutput {
+		return h.Handler.Handle(ctx, r)
+	}
+
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+
+	h.out.color = levelColor(r.Level)
+	defer func() { h.out.color = "" }()
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *colorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &colorHandler{Handler: h.Handler.WithAttrs(attrs), out: h.out}
+}
+
+func (h *colorHandler) WithGroup(name string) slog.Handler {
+	return &colorHandler{Handler: h.Handler.WithGroup(name), out: h.out}
+}
+
+// Write colors p, keeping the trailing newline outside the color.
+func (w *colorWriter) Write(p []byte) (int, error) {
+	if w.color == "" {
+		return w.w.Write(p)
+	}
+
+	line := bytes.TrimSuffix(p, []byte("\n"))
+	colored := make([]byte, 0, len(p)+len(w.color)+len(ansiReset))
+	colored = append(colored, w.color...)
+	colored = append(colored, line...)
+	colored = append(colored, ansiReset...)
+	colored = append(colored, p[len(line):]...)
+
+	if _, err := w.w.Write(colored); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
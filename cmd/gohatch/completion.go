@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// flagValues lists the known values offered when completing a flag.
+var flagValues = map[string][]string{
+	"--host": {"github.com", "gitlab.com", "codeberg.org"},
+}
+
+// completeRoot suggests subcommands and flags like the default completion,
+// known values for flags such as --host, and local template directories
+// while the source argument is being completed.
+func completeRoot(ctx context.Context, cmd *cli.Command) {
+	w := cmd.Root().Writer
+
+	// The completion script passes the completed words followed by
+	// --generate-shell-completion, so the word before it is the last one
+	lastArg := ""
+	if len(os.Args) > 1 {
+		lastArg = os.Args[len(os.Args)-2]
+	}
+
+	if values, ok := flagValues[lastArg]; ok {
+		for _, v := range values {
+			fmt.Fprintln(w, v)
+		}
+		return
+	}
+
+	cli.DefaultCompleteWithFlags(ctx, cmd)
+
+	if cmd.Args().Len() == 0 && !strings.HasPrefix(lastArg, "-") {
+		printLocalDirs(w)
+	}
+}
+
+// printLocalDirs writes the non-hidden directories of the working
+// directory in the ./name form accepted as local template source.
+func printLocalDirs(w io.Writer) {
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			fmt.Fprintf(w, "./%s\n", e.Name())
+		}
+	}
+}
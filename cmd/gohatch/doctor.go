@@ -0,0 +1,238 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	gohatchcfg "github.com/oliverandrich/gohatch/internal/config"
+	"github.com/oliverandrich/gohatch/internal/source"
+	"github.com/urfave/cli/v3"
+)
+
+// probeTimeout bounds the reachability check of the template host.
+const probeTimeout = 10 * time.Second
+
+// checkStatus is the outcome of a single doctor check.
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+)
+
+// color returns the color the status is shown in.
+func (s checkStatus) color() string {
+	switch s {
+	case checkPass:
+		return ansiGreen
+	case checkWarn:
+		return ansiYellow
+	default:
+		return ansiRed
+	}
+}
+
+// checkResult describes the outcome of a doctor check.
+type checkResult struct {
+	Name   string
+	Status checkStatus
+	Detail string
+}
+
+// doctorEnv holds the dependencies of the doctor checks, so tests can
+// replace the environment, PATH lookup, and network access.
+type doctorEnv struct {
+	getenv   func(string) string
+	lookPath func(string) (string, error)
+	probe    func(ctx context.Context, url string) error
+	tempDir  string
+}
+
+// newDoctorEnv returns a doctorEnv backed by the real system.
+func newDoctorEnv() *doctorEnv {
+	return &doctorEnv{
+		getenv:   os.Getenv,
+		lookPath: exec.LookPath,
+		probe:    probeURL,
+		tempDir:  os.TempDir(),
+	}
+}
+
+// doctorCommand reports problems with the environment gohatch runs in.
+func doctorCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "doctor",
+		Usage: "diagnose the environment used to fetch templates",
+		Description: `Check git availability, proxy settings, reachability of the template
+host, configured access tokens, and whether the temporary directory used
+to fetch templates is writable. Exits with an error if any check fails.`,
+		Action: runDoctor,
+	}
+}
+
+func runDoctor(ctx context.Context, _ *cli.Command) error {
+	if err := setupLogger(); err != nil {
+		return err
+	}
+
+	results := runChecks(ctx, newDoctorEnv(), host)
+
+	failed := 0
+	for _, r := range results {
+		fmt.Printf("[%s] %-12s %s\n", colorize(r.Status.color(), string(r.Status)), r.Name, r.Detail)
+		if r.Status == checkFail {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// runChecks runs all doctor checks. The user config is loaded first, as
+// it provides the default host and the access token.
+func runChecks(ctx context.Context, env *doctorEnv, hostFlag string) []checkResult {
+	cfg, cfgResult := checkUserConfig()
+
+	templateHost := hostFlag
+	if templateHost == "" {
+		templateHost = cfg.DefaultHost
+	}
+	if templateHost == "" {
+		templateHost = source.DefaultHost
+	}
+
+	return []checkResult{
+		checkGit(env),
+		cfgResult,
+		checkProxy(env, templateHost),
+		checkReachability(ctx, env, templateHost),
+		checkToken(cfg),
+		checkTempDir(env),
+	}
+}
+
+// checkGit reports whether the git binary is available. gohatch clones
+// without it, so a missing binary is only a warning.
+func checkGit(env *doctorEnv) checkResult {
+	path, err := env.lookPath("git")
+	if err != nil {
+		return checkResult{"git", checkWarn, "git not found in PATH (not required for cloning)"}
+	}
+	return checkResult{"git", checkPass, path}
+}
+
+// checkUserConfig loads the user config. An unreadable config yields an
+// empty one, so the remaining checks still run.
+func checkUserConfig() (*gohatchcfg.UserConfig, checkResult) {
+	empty := &gohatchcfg.UserConfig{}
+
+	cfgPath, err := gohatchcfg.UserConfigPath()
+	if err != nil {
+		return empty, checkResult{"config", checkFail, fmt.Sprintf("locating user config: %v", err)}
+	}
+
+	cfg, err := gohatchcfg.LoadUser(cfgPath)
+	if err != nil {
+		return empty, checkResult{"config", checkFail, fmt.Sprintf("loading %s: %v", cfgPath, err)}
+	}
+
+	if _, err := os.Stat(cfgPath); err != nil {
+		return cfg, checkResult{"config", checkPass, cfgPath + " (not present)"}
+	}
+	return cfg, checkResult{"config", checkPass, cfgPath}
+}
+
+// checkProxy reports the proxy settings that apply to the template host
+// and the Go module proxy used for mod: sources.
+func checkProxy(env *doctorEnv, templateHost string) checkResult {
+	var settings []string
+	for _, key := range []string{"HTTPS_PROXY", "HTTP_PROXY", "NO_PROXY", "GOPROXY"} {
+		value := env.getenv(key)
+		if value == "" && key != "GOPROXY" {
+			value = env.getenv(strings.ToLower(key))
+		}
+		if value == "" {
+			continue
+		}
+
+		// Like net/http, accept proxy addresses without a scheme
+		if key == "HTTPS_PROXY" || key == "HTTP_PROXY" {
+			proxyURL := value
+			if !strings.Contains(proxyURL, "://") {
+				proxyURL = "http://" + proxyURL
+			}
+			if u, err := url.Parse(proxyURL); err != nil || u.Host == "" {
+				return checkResult{"proxy", checkFail, fmt.Sprintf("invalid %s %q", key, value)}
+			}
+		}
+		settings = append(settings, key+"="+value)
+	}
+
+	if len(settings) == 0 {
+		return checkResult{"proxy", checkPass, "no proxy configured, connecting to " + templateHost + " directly"}
+	}
+	return checkResult{"proxy", checkPass, strings.Join(settings, " ")}
+}
+
+// checkReachability probes the template host over HTTPS.
+func checkReachability(ctx context.Context, env *doctorEnv, templateHost string) checkResult {
+	target := "https://" + templateHost
+	if err := env.probe(ctx, target); err != nil {
+		return checkResult{"host", checkFail, fmt.Sprintf("%s unreachable: %v", target, err)}
+	}
+	return checkResult{"host", checkPass, target + " reachable"}
+}
+
+// checkToken reports whether an access token for private templates is
+// configured. Public templates work without one, so none is a warning.
+func checkToken(cfg *gohatchcfg.UserConfig) checkResult {
+	if len(hostTokens(cfg)) == 0 {
+		return checkResult{"token", checkWarn, "no token configured (private templates will fail to clone)"}
+	}
+	return checkResult{"token", checkPass, "token configured"}
+}
+
+// checkTempDir verifies that templates can be fetched into the
+// temporary directory.
+func checkTempDir(env *doctorEnv) checkResult {
+	f, err := os.CreateTemp(env.tempDir, "gohatch-doctor-*")
+	if err != nil {
+		return checkResult{"temp dir", checkFail, fmt.Sprintf("%s not writable: %v", env.tempDir, err)}
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	return checkResult{"temp dir", checkPass, env.tempDir + " writable"}
+}
+
+// probeURL sends a HEAD request to url. Any HTTP response counts as
+// reachable; only transport errors are reported.
+func probeURL(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
@@ -0,0 +1,265 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// logLevelVar holds the level of the default CLI logger.
+var logLevelVar = new(slog.LevelVar)
+
+// levelSuccess marks the final message of a successful run. It is shown
+// as INFO, but in green when output is colored.
+const levelSuccess = slog.LevelInfo + 2
+
+// logOutput receives the log lines of the CLI logger.
+var logOutput io.Writer = os.Stderr
+
+// logger receives all progress output of the scaffold steps. Embedders
+// and tests may replace it with a logger using their own handler.
+var logger = newLogger(logOutput, logLevelVar)
+
+// Events classify the records of the --json-logs output in its event
+// field. Records logged without an event are classified by level.
+const (
+	eventStageStart = "stage_start"
+	eventStageEnd   = "stage_end"
+	eventFile       = "file"
+	eventRename     = "rename"
+	eventWarning    = "warning"
+	eventError      = "error"
+	eventDone       = "done"
+	eventMessage    = "message"
+)
+
+// eventContextKey carries the event of a record in its context, so the
+// text output is unaffected.
+type eventContextKey struct{}
+
+// newLogger returns a text logger writing to w at the given level.
+// Records are written as plain lines such as "created project (dir
+// myapp)"; once the level includes debug records, as with --verbose, they
+// are written in key=value form instead. Timestamps are omitted since the
+// output is read interactively. Lines are colored by level if colored
+// output is enabled.
+func newLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	out := &colorWriter{w: w}
+	text := slog.NewTextHandler(out, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return a
+			}
+			switch {
+			case a.Key == slog.TimeKey:
+				return slog.Attr{}
+			case a.Key == slog.LevelKey && a.Value.Any() == levelSuccess:
+				return slog.String(slog.LevelKey, slog.LevelInfo.String())
+			}
+			return a
+		},
+	})
+	format := &formatHandler{
+		plain: &plainHandler{w: out, mu: new(sync.Mutex)},
+		text:  text,
+		level: level,
+	}
+	return slog.New(&colorHandler{Handler: format, out: out})
+}
+
+// formatHandler passes records to the key=value text handler if debug
+// records are enabled and to the plain handler otherwise.
+type formatHandler struct {
+	plain, text slog.Handler
+	level       slog.Leveler
+}
+
+func (h *formatHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *formatHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.level.Level() < slog.LevelInfo {
+		return h.text.Handle(ctx, r)
+	}
+	return h.plain.Handle(ctx, r)
+}
+
+func (h *formatHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &formatHandler{plain: h.plain.WithAttrs(attrs), text: h.text.WithAttrs(attrs), level: h.level}
+}
+
+func (h *formatHandler) WithGroup(name string) slog.Handler {
+	return &formatHandler{plain: h.plain.WithGroup(name), text: h.text.WithGroup(name), level: h.level}
+}
+
+// plainHandler writes each record as one human-readable line: the
+// message, prefixed for warnings and errors, followed by its attributes
+// in parentheses.
+type plainHandler struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	attrs  []slog.Attr
+	prefix string
+}
+
+func (h *plainHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *plainHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("error: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("warning: ")
+	}
+	b.WriteString(r.Message)
+
+	attrs := slices.Clone(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+		return true
+	})
+	for i, a := range attrs {
+		if i == 0 {
+			b.WriteString(" (")
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(a.Key + " " + a.Value.Resolve().String())
+	}
+	if len(attrs) > 0 {
+		b.WriteString(")")
+	}
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *plainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = slices.Clone(h.attrs)
+	for _, a := range attrs {
+		c.attrs = append(c.attrs, slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+	}
+	return &c
+}
+
+func (h *plainHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.prefix += name + "."
+	return &c
+}
+
+// newJSONLogger returns a logger writing one JSON object per record to w,
+// each with an event field for machine consumption.
+func newJSONLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.LevelKey && a.Value.Any() == levelSuccess {
+				return slog.String(slog.LevelKey, slog.LevelInfo.String())
+			}
+			return a
+		},
+	})
+	return slog.New(&eventHandler{Handler: h})
+}
+
+// eventHandler adds the event of each record to the wrapped handler's
+// output.
+type eventHandler struct {
+	slog.Handler
+}
+
+func (h *eventHandler) Handle(ctx context.Context, r slog.Record) error {
+	event, _ := ctx.Value(eventContextKey{}).(string)
+	if event == "" {
+		switch {
+		case r.Level >= slog.LevelError:
+			event = eventError
+		case r.Level >= slog.LevelWarn:
+			event = eventWarning
+		default:
+			event = eventMessage
+		}
+	}
+	r.AddAttrs(slog.String("event", event))
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *eventHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &eventHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *eventHandler) WithGroup(name string) slog.Handler {
+	return &eventHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// logEvent logs a record classified as event in the --json-logs output.
+func logEvent(event string, level slog.Level, msg string, args ...any) {
+	ctx := context.WithValue(context.Background(), eventContextKey{}, event)
+	logger.Log(ctx, level, msg, args...)
+}
+
+// logFile logs a file written by a scaffold step and records it for the
+// summary.
+func logFile(msg, file string) {
+	scaffoldChanges.addFile(file)
+	logEvent(eventFile, slog.LevelDebug, msg, "file", file)
+}
+
+// logStage logs the start of a scaffold stage and returns a function
+// logging its end.
+func logStage(name string) func() {
+	logEvent(eventStageStart, slog.LevelDebug, "stage started", "stage", name)
+	return func() { logEvent(eventStageEnd, slog.LevelDebug, "stage finished", "stage", name) }
+}
+
+// logSuccess logs the final message of a successful run.
+func logSuccess(msg string, args ...any) {
+	logEvent(eventDone, levelSuccess, msg, args...)
+}
+
+// logLevel maps the --quiet and --verbose flags to a log level.
+func logLevel(quiet, verbose bool) (slog.Level, error) {
+	switch {
+	case quiet && verbose:
+		return 0, fmt.Errorf("--quiet and --verbose are mutually exclusive")
+	case quiet:
+		return slog.LevelWarn, nil
+	case verbose:
+		return slog.LevelDebug, nil
+	default:
+		return slog.LevelInfo, nil
+	}
+}
+
+// setupLogger sets the level of the CLI logger from the command line flags.
+// With --json-logs it switches to JSON output at the same level.
+func setupLogger() error {
+	level, err := logLevel(quiet, verbose)
+	if err != nil {
+		return err
+	}
+	if jsonLogs {
+		logger = newJSONLogger(logOutput, logLevelVar)
+	}
+	logLevelVar.Set(level)
+	colorOutput = useColor(noColor, os.Getenv)
+	return nil
+}
@@ -4,37 +4,131 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	goversion "go/version"
+	"io/fs"
+	"log/slog"
+	"maps"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/oliverandrich/gohatch/internal/archive"
 	gohatchcfg "github.com/oliverandrich/gohatch/internal/config"
+	"github.com/oliverandrich/gohatch/internal/provenance"
 	"github.com/oliverandrich/gohatch/internal/rewrite"
 	"github.com/oliverandrich/gohatch/internal/source"
 	"github.com/urfave/cli/v3"
+	gomodule "golang.org/x/mod/module"
 )
 
 var version = "dev"
 
+// now returns the time used for the date variables and provenance
+// timestamps; replaced in tests.
+var now = time.Now
+
+// exitChangesPending is the exit code of a dry-run that found files the
+// template rewrite would modify or rename.
+const exitChangesPending = 2
+
+// errChangesPending is returned by runDryRun when changes are pending.
+var errChangesPending = errors.New("dry-run found pending changes")
+
+// errAborted is returned if the user declines the plan shown by --confirm.
+var errAborted = errors.New("aborted, nothing was written")
+
 var (
-	srcInput   string
-	module     string
-	directory  string
-	extensions []string
-	variables  []string
-	dryRun     bool
-	force      bool
-	noGitInit  bool
-	keepConfig bool
-	verbose    bool
+	srcInput       string
+	module         string
+	directory      string
+	extensions     []string
+	moduleExts     []string
+	varExts        []string
+	variables      []string
+	dryRun         bool
+	printConfig    bool
+	showTmplConfig bool
+	force          bool
+	noGitInit      bool
+	keepConfig     bool
+	verbose        bool
+	quiet          bool
+	skipUnreadable bool
+	jsonLogs       bool
+	gitAuthorName  string
+	gitAuthorEmail string
+	noEscape       bool
+	lfs            bool
+	goVersion      string
+	toolchain      string
+	rewriteVendor  bool
+	goimports      bool
+	noProvenance   bool
+	host           string
+	templateDir    string
+	tokens         map[string]string
+	mergeDir       bool
+	respectIgnore  bool
+	varsJSON       string
+	noModRewrite   bool
+	rewriteComment bool
+	fromModule     string
+	depth          int
+	fullHistory    bool
+	summaryFile    string
+	ref            string
+	skipDirs       []string
+	include        []string
+	expectCommit   string
+	workingDir     string
+	strictGo       bool
+	replaces       []string
+	noNetrc        bool
+	noGoImport     bool
+	benignEntries  = defaultBenignEntries
+	archivePath    string
+	outScratch     string
+	onConflict     string
+	confirm        bool
+	parents        bool
+	maxRewriteSize int64
+	maxFiles       int
+	setFiles       []string
+	resume         bool
+	nameFlag       string
+	initModule     bool
+	allowExec      bool
+	noColor        bool
+
+	// directoryDefaulted is set if no directory argument was given, so a
+	// template's output_name may choose the directory instead.
+	directoryDefaulted bool
+
+	// parentModule is set if the project is scaffolded into a directory of
+	// an existing module, which it becomes part of.
+	parentModule bool
+
+	// confirmedVars holds the variables shown in the --confirm preview,
+	// including prompted ones, so applying the changes uses them as well.
+	confirmedVars map[string]string
 )
 
 func main() {
@@ -44,7 +138,39 @@ func main() {
 		Usage: "print the version",
 	}
 
-	cmd := &cli.Command{
+	if err := newCommand().Run(context.Background(), os.Args); err != nil {
+		if !errors.Is(err, errChangesPending) {
+			reportError(err)
+		}
+		os.Exit(exitCode(err))
+	}
+}
+
+// reportError prints the error that ended the run to stderr, or logs it
+// as an error event with --json-logs.
+func reportError(err error) {
+	if jsonLogs {
+		logger.Error(err.Error())
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s %v\n", colorize(ansiRed, "error:"), err)
+}
+
+// exitCode maps an error returned by the command to the process exit code.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errChangesPending):
+		return exitChangesPending
+	default:
+		return 1
+	}
+}
+
+// newCommand builds the gohatch command line interface.
+func newCommand() *cli.Command {
+	return &cli.Command{
 		Name:      "gohatch",
 		Usage:     "A project scaffolding tool for Go",
 		Version:   version,
@@ -59,12 +185,18 @@ Source formats:
   user/repo@main                Specific branch
   user/repo@abc1234             Specific commit
   ./local-template              Local directory
+  mod:github.com/user/repo@v1   Go module proxy (GOPROXY)
+
+Module zips are checked against GOSUMDB unless the module matches
+GONOSUMDB or GOPRIVATE, or GONOSUMCHECK=1 is set. Only the lookup record
+is compared; sumdb signatures and tlog proofs are not verified.
 
 Examples:
   gohatch user/template github.com/me/myapp
   gohatch github.com/user/template@v1.0.0 github.com/me/myapp
   gohatch user/template@main github.com/me/myapp
   gohatch ./local-template github.com/me/myapp customdir
+  gohatch user/template ./cmd/tool
   gohatch -e toml -e justfile user/template github.com/me/myapp
   gohatch --var Author="Your Name" user/template github.com/me/myapp
   gohatch --dry-run user/template github.com/me/myapp
@@ -76,38 +208,290 @@ Examples:
 				Usage:       "additional file extensions or filenames for replacement (e.g., -e toml -e justfile)",
 				Destination: &extensions,
 			},
+			&cli.StringSliceFlag{
+				Name:        "module-ext",
+				Usage:       "file extensions or filenames for module path replacement only, instead of -e",
+				Destination: &moduleExts,
+			},
+			&cli.StringSliceFlag{
+				Name:        "var-ext",
+				Usage:       "file extensions or filenames for variable replacement only, instead of -e",
+				Destination: &varExts,
+			},
 			&cli.StringSliceFlag{
 				Name:        "var",
 				Aliases:     []string{"v"},
 				Usage:       "set template variable (e.g., --var Author=\"Name\")",
 				Destination: &variables,
 			},
+			&cli.StringFlag{
+				Name:        "name",
+				Usage:       "set ProjectName independently of the module path and output directory",
+				Destination: &nameFlag,
+			},
+			&cli.StringSliceFlag{
+				Name:        "set-file",
+				Usage:       "set template variable to the contents of a file (e.g., --set-file License=./LICENSE.txt)",
+				Destination: &setFiles,
+			},
+			&cli.StringSliceFlag{
+				Name:        "replace",
+				Usage:       "replace literal text in all rewritten files after the other passes (e.g., --replace Acme=Initech)",
+				Destination: &replaces,
+			},
+			&cli.StringFlag{
+				Name:        "vars-json",
+				Usage:       "set template variables from a JSON object (e.g., --vars-json '{\"Year\":2025}')",
+				Destination: &varsJSON,
+			},
+			&cli.StringFlag{
+				Name:        "host",
+				Usage:       "git host for user/repo shorthands (default: github.com)",
+				Destination: &host,
+			},
+			&cli.StringFlag{
+				Name:        "template-dir",
+				Usage:       "directory of named templates used as @name (default: ~/.gohatch/templates)",
+				Destination: &templateDir,
+			},
+			&cli.StringFlag{
+				Name:        "go-version",
+				Usage:       "set the go directive in go.mod (e.g., --go-version 1.23), or auto for the Go version gohatch was built with",
+				Destination: &goVersion,
+			},
+			&cli.BoolFlag{
+				Name:        "strict-go",
+				Usage:       "fail instead of warning if the local go toolchain is older than the template's go directive",
+				Destination: &strictGo,
+			},
+			&cli.StringFlag{
+				Name:        "toolchain",
+				Usage:       "set the toolchain directive in go.mod, or remove it with none (e.g., --toolchain go1.23.4)",
+				Destination: &toolchain,
+			},
+			&cli.StringFlag{
+				Name:        "from-module",
+				Usage:       "old import prefix to rewrite, in addition to the module in go.mod",
+				Destination: &fromModule,
+			},
+			&cli.BoolFlag{
+				Name:        "init-module",
+				Usage:       "create a go.mod for templates without one and rewrite imports of the detected or --from-module prefix",
+				Destination: &initModule,
+			},
+			&cli.BoolFlag{
+				Name:        "no-module-rewrite",
+				Usage:       "leave go.mod and imports untouched and only replace variables",
+				Destination: &noModRewrite,
+			},
+			&cli.BoolFlag{
+				Name:        "rewrite-vendor",
+				Usage:       "include the vendor directory in module rewriting",
+				Destination: &rewriteVendor,
+			},
+			&cli.StringSliceFlag{
+				Name:        "skip-dir",
+				Usage:       "directory name to skip in all rewrites, replacing the default .git (e.g., --skip-dir .git --skip-dir testdata)",
+				Destination: &skipDirs,
+			},
+			&cli.StringSliceFlag{
+				Name:        "include",
+				Usage:       "only scaffold paths matching this glob, replacing include from .gohatch.toml (e.g., --include 'cmd/**' --include go.mod)",
+				Destination: &include,
+			},
+			&cli.Int64Flag{
+				Name:        "max-rewrite-size",
+				Usage:       "skip rewriting files larger than this many bytes (0 for no limit)",
+				Destination: &maxRewriteSize,
+			},
+			&cli.BoolFlag{
+				Name:        "rewrite-comments",
+				Usage:       "rewrite the module path in all comments of .go files",
+				Destination: &rewriteComment,
+			},
+			&cli.BoolFlag{
+				Name:        "goimports",
+				Usage:       "tidy imports in all .go files after rewriting",
+				Destination: &goimports,
+			},
 			&cli.BoolFlag{
 				Name:        "dry-run",
 				Usage:       "show what would be done without making any changes",
 				Destination: &dryRun,
 			},
+			&cli.StringFlag{
+				Name:        "out-scratch",
+				Usage:       "with --dry-run, run the full pipeline into `DIR` instead, leaving the target untouched",
+				Destination: &outScratch,
+			},
+			&cli.BoolFlag{
+				Name:        "print-config",
+				Usage:       "print the merged effective configuration as TOML and exit",
+				Destination: &printConfig,
+			},
+			&cli.StringFlag{
+				Name:        "summary-file",
+				Usage:       "append a Markdown summary of the scaffold to this file (default: $GITHUB_STEP_SUMMARY)",
+				Destination: &summaryFile,
+			},
+			&cli.BoolFlag{
+				Name:        "show-template-config",
+				Usage:       "print the template's .gohatch.toml as parsed after fetching and continue",
+				Destination: &showTmplConfig,
+			},
 			&cli.BoolFlag{
 				Name:        "force",
 				Aliases:     []string{"f"},
 				Usage:       "proceed even if template has no go.mod",
 				Destination: &force,
 			},
+			&cli.BoolFlag{
+				Name:        "parents",
+				Aliases:     []string{"p"},
+				Usage:       "create missing parent directories of the output directory",
+				Destination: &parents,
+			},
+			&cli.BoolFlag{
+				Name:        "resume",
+				Usage:       "finish an interrupted scaffold in the output directory without fetching the template again",
+				Destination: &resume,
+			},
+			&cli.BoolFlag{
+				Name:        "merge",
+				Usage:       "scaffold into an existing non-empty directory without overwriting files",
+				Destination: &mergeDir,
+			},
+			&cli.StringFlag{
+				Name:        "on-conflict",
+				Usage:       "with --merge, handle existing files that differ from the template: `POLICY` fail, overwrite, skip, or backup (renames them to .bak)",
+				Value:       conflictFail,
+				Destination: &onConflict,
+			},
+			&cli.StringFlag{
+				Name:        "archive",
+				Usage:       "write the project as a gzip-compressed tarball to `FILE` instead of a directory",
+				Destination: &archivePath,
+			},
 			&cli.BoolFlag{
 				Name:        "no-git-init",
 				Usage:       "skip git repository initialization",
 				Destination: &noGitInit,
 			},
+			&cli.BoolFlag{
+				Name:        "no-provenance",
+				Usage:       "skip writing " + provenance.File,
+				Destination: &noProvenance,
+			},
+			&cli.BoolFlag{
+				Name:        "confirm",
+				Aliases:     []string{"interactive"},
+				Usage:       "print the planned changes and ask for confirmation before applying them",
+				Destination: &confirm,
+			},
+			&cli.StringFlag{
+				Name:        "git-author-name",
+				Usage:       "author name of the initial commit (default: GIT_AUTHOR_NAME or user.name from the global git config)",
+				Destination: &gitAuthorName,
+			},
+			&cli.StringFlag{
+				Name:        "git-author-email",
+				Usage:       "author email of the initial commit (default: GIT_AUTHOR_EMAIL or user.email from the global git config)",
+				Destination: &gitAuthorEmail,
+			},
 			&cli.BoolFlag{
 				Name:        "keep-config",
 				Usage:       "keep .gohatch.toml config file in output",
 				Destination: &keepConfig,
 			},
+			&cli.BoolFlag{
+				Name:        "skip-unreadable",
+				Usage:       "skip unreadable files in local templates instead of failing",
+				Destination: &skipUnreadable,
+			},
+			&cli.BoolFlag{
+				Name:        "no-escape",
+				Usage:       "fail on symlinks pointing outside local templates instead of copying them",
+				Destination: &noEscape,
+			},
+			&cli.IntFlag{
+				Name:        "max-files",
+				Usage:       "abort if a local template has more files than this (0 for no limit)",
+				Value:       source.DefaultMaxFiles,
+				Destination: &maxFiles,
+			},
+			&cli.BoolFlag{
+				Name:        "respect-gitignore",
+				Usage:       "skip files ignored by .gitignore or the global excludes file in local templates",
+				Destination: &respectIgnore,
+			},
+			&cli.IntFlag{
+				Name:        "depth",
+				Usage:       "history depth of git template clones (0 for full history)",
+				Value:       source.DefaultDepth,
+				Destination: &depth,
+			},
+			&cli.BoolFlag{
+				Name:        "full-history",
+				Usage:       "clone the full history of git templates, e.g. for changelog hooks",
+				Destination: &fullHistory,
+			},
+			&cli.StringFlag{
+				Name:        "ref",
+				Usage:       "clone a full git reference such as refs/pull/42/head instead of an @version",
+				Destination: &ref,
+			},
+			&cli.BoolFlag{
+				Name:        "no-netrc",
+				Usage:       "do not read credentials for the template host from ~/.netrc or $NETRC",
+				Destination: &noNetrc,
+			},
+			&cli.BoolFlag{
+				Name:        "no-go-import",
+				Usage:       "clone paths on custom domains directly instead of looking up their go-import meta tag",
+				Destination: &noGoImport,
+			},
+			&cli.StringFlag{
+				Name:        "expect-commit",
+				Usage:       "abort unless the cloned commit matches this hash, e.g. to pin @main",
+				Destination: &expectCommit,
+			},
+			&cli.BoolFlag{
+				Name:        "lfs",
+				Usage:       "download Git LFS objects for pointer files in git templates",
+				Destination: &lfs,
+			},
 			&cli.BoolFlag{
 				Name:        "verbose",
 				Usage:       "show detailed progress output",
 				Destination: &verbose,
 			},
+			&cli.BoolFlag{
+				Name:        "allow-exec",
+				Usage:       "run the external rewriter commands declared in the template config",
+				Destination: &allowExec,
+			},
+			&cli.BoolFlag{
+				Name:        "json-logs",
+				Usage:       "log one JSON object per event to stderr, including file events",
+				Destination: &jsonLogs,
+			},
+			&cli.BoolFlag{
+				Name:        "no-color",
+				Usage:       "disable colored output (also set by the NO_COLOR environment variable)",
+				Destination: &noColor,
+			},
+			&cli.StringFlag{
+				Name:        "working-dir",
+				Aliases:     []string{"C"},
+				Usage:       "change to this directory before resolving relative paths, like make -C",
+				Destination: &workingDir,
+			},
+			&cli.BoolFlag{
+				Name:        "quiet",
+				Aliases:     []string{"q"},
+				Usage:       "only show warnings and errors",
+				Destination: &quiet,
+			},
 		},
 		Arguments: []cli.Argument{
 			&cli.StringArg{
@@ -126,208 +510,1651 @@ Examples:
 				Destination: &directory,
 			},
 		},
-		Action: run,
+		Commands: []*cli.Command{
+			updateCommand(),
+			doctorCommand(),
+			sourcesCommand(),
+		},
+		EnableShellCompletion: true,
+		ConfigureShellCompletionCommand: func(c *cli.Command) {
+			c.Hidden = false
+		},
+		ShellComplete: completeRoot,
+		Before:        changeDir,
+		Action:        run,
 	}
+}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+// changeDir changes to --working-dir before any command runs, so that
+// relative sources and output directories resolve against it.
+func changeDir(ctx context.Context, _ *cli.Command) (context.Context, error) {
+	if workingDir == "" {
+		return ctx, nil
+	}
+	if err := os.Chdir(workingDir); err != nil {
+		return ctx, fmt.Errorf("changing working directory: %w", err)
 	}
+	return ctx, nil
 }
 
 func run(ctx context.Context, cmd *cli.Command) error {
-	// Show help if required arguments are missing
-	if srcInput == "" || module == "" {
+	// Show help if required arguments are missing. A single argument is
+	// the module if a default source is configured.
+	if srcInput == "" {
 		return cli.ShowAppHelp(cmd)
 	}
+	if module == "" {
+		defaultSrc, err := defaultSource()
+		if err != nil {
+			return err
+		}
+		if defaultSrc == "" {
+			return cli.ShowAppHelp(cmd)
+		}
+		module, srcInput = srcInput, defaultSrc
+	}
+
+	if err := setupLogger(); err != nil {
+		return err
+	}
+
+	parentModule = directory == "" && isDirectoryArg(module)
+	if parentModule {
+		modPath, err := parentModulePath(module)
+		if err != nil {
+			return err
+		}
+		logger.Info("derived module path from parent go.mod", "module", modPath)
+		module, directory = modPath, module
+		// The parent module's repository holds the project
+		noGitInit = true
+	}
+
+	directoryDefaulted = directory == ""
+	if directoryDefaulted {
+		directory = defaultDirectory(module)
+	}
+
+	warnConfusableModule(module)
+
+	if depth < 0 {
+		return fmt.Errorf("--depth must not be negative")
+	}
+	if fullHistory && cmd.IsSet("depth") && depth != 0 {
+		return fmt.Errorf("--full-history cannot be combined with --depth %d", depth)
+	}
+
+	if maxRewriteSize < 0 {
+		return fmt.Errorf("--max-rewrite-size must not be negative")
+	}
+
+	if maxFiles < 0 {
+		return fmt.Errorf("--max-files must not be negative")
+	}
+
+	if initModule && noModRewrite {
+		return fmt.Errorf("--init-module cannot be combined with --no-module-rewrite")
+	}
+
+	if goVersion == goVersionAuto {
+		v, err := autoGoVersion()
+		if err != nil {
+			return err
+		}
+		goVersion = v
+	}
+
+	if goVersion != "" {
+		if noModRewrite {
+			return fmt.Errorf("--go-version cannot be combined with --no-module-rewrite")
+		}
+		if err := rewrite.CheckGoVersion(goVersion); err != nil {
+			return err
+		}
+	}
+
+	if _, err := parseReplacements(replaces); err != nil {
+		return err
+	}
+
+	if toolchain != "" {
+		if noModRewrite {
+			return fmt.Errorf("--toolchain cannot be combined with --no-module-rewrite")
+		}
+		if err := rewrite.CheckToolchain(toolchain); err != nil {
+			return err
+		}
+	}
+
+	if outScratch != "" && !dryRun {
+		return fmt.Errorf("--out-scratch requires --dry-run")
+	}
+
+	if !slices.Contains(conflictPolicies, onConflict) {
+		return fmt.Errorf("invalid --on-conflict %q: must be one of %s", onConflict, strings.Join(conflictPolicies, ", "))
+	}
+	if onConflict != conflictFail && !mergeDir {
+		return fmt.Errorf("--on-conflict requires --merge")
+	}
+
+	if archivePath != "" {
+		if mergeDir {
+			return fmt.Errorf("--archive cannot be combined with --merge")
+		}
+		if resume {
+			return fmt.Errorf("--archive cannot be combined with --resume")
+		}
+	}
 
-	// Default directory to last element of module path
-	if directory == "" {
-		directory = path.Base(module)
+	if err := loadUserConfig(); err != nil {
+		return err
 	}
 
 	// Parse the source
-	src, err := source.Parse(srcInput)
+	src, err := parseSource(srcInput)
 	if err != nil {
 		return fmt.Errorf("parsing source: %w", err)
 	}
 
+	if ref != "" {
+		gs, ok := src.(*source.GitSource)
+		if !ok {
+			return fmt.Errorf("--ref is only supported for git templates")
+		}
+		if gs.Version != "" {
+			return fmt.Errorf("--ref cannot be combined with @%s", gs.Version)
+		}
+	}
+
+	if _, ok := src.(*source.GitSource); !ok {
+		switch {
+		case fullHistory:
+			return fmt.Errorf("--full-history is only supported for git templates")
+		case lfs:
+			return fmt.Errorf("--lfs is only supported for git templates")
+		}
+	}
+
+	if expectCommit != "" {
+		if _, ok := src.(*source.GitSource); !ok {
+			return fmt.Errorf("--expect-commit is only supported for git templates")
+		}
+		if err := source.CheckCommitHash(expectCommit); err != nil {
+			return err
+		}
+	}
+
+	if printConfig {
+		return runPrintConfig(ctx, src)
+	}
+
 	// Dry-run mode: show what would be done
 	if dryRun {
-		return runDryRun(src)
+		return runDryRun(ctx, src)
+	}
+
+	confirmedVars = nil
+	if confirm {
+		ok, err := confirmPlan(ctx, src)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errAborted
+		}
 	}
 
 	return executeScaffold(ctx, src)
 }
 
+// confirmPlan prints the changes rendering the template would make, as
+// in a dry-run, and asks whether to apply them. Declared variables are
+// prompted for before the preview. The answers and the commit of a git
+// template resolved for the preview are kept, so applying the changes
+// renders exactly what the user approved.
+func confirmPlan(ctx context.Context, src source.Source) (bool, error) {
+	if !interactive() {
+		return false, errors.New("--confirm needs a terminal to ask for confirmation")
+	}
+
+	vars, err := resolveVariables(projectName(directory))
+	if err != nil {
+		return false, err
+	}
+	plan, planErr := planChanges(ctx, src, vars, true)
+
+	fmt.Printf("Directory: %s\n", directory)
+	fmt.Printf("Module:    %s\n", module)
+	if plan != nil {
+		fmt.Printf("Variables: %s\n", formatVariables(redactVariables(vars, plan.Declared)))
+	}
+	pending, err := printPlan(plan, planErr)
+	if err != nil {
+		return false, err
+	}
+	if !pending {
+		fmt.Println("No template files would be modified or renamed.")
+	}
+	if gs, ok := src.(*source.GitSource); ok && gs.Commit != "" {
+		gs.ExpectCommit = gs.Commit
+	}
+	if !noGitInit && archivePath == "" {
+		fmt.Println("Would initialize git repository with initial commit.")
+	}
+	fmt.Println()
+
+	ok, err := promptConfirm("Apply these changes?")
+	if ok {
+		confirmedVars = vars
+	}
+	return ok, err
+}
+
 func executeScaffold(ctx context.Context, src source.Source) error {
-	if err := validateDirectory(directory); err != nil {
+	if mergeDir {
+		return executeMerge(ctx, src)
+	}
+	if archivePath != "" {
+		return executeArchive(ctx, src)
+	}
+
+	resuming := provenance.IsPending(directory)
+	switch {
+	case resuming && !resume:
+		return fmt.Errorf("directory %s contains an interrupted scaffold (use --resume to finish it)", directory)
+	case resuming:
+		logger.Info("resuming interrupted scaffold", "dir", directory)
+	default:
+		if err := validateDirectory(directory); err != nil {
+			return err
+		}
+		// Scaffold next to entries such as .git without replacing them
+		if entries, _ := os.ReadDir(directory); len(entries) > 0 {
+			logger.Info("keeping existing entries", "dir", directory)
+			return executeMerge(ctx, src)
+		}
+		if err := ensureParent(directory); err != nil {
+			return err
+		}
+	}
+
+	outputName, err := scaffold(ctx, src, directory, projectName(directory), !resuming)
+	if err != nil {
+		return err
+	}
+
+	if directoryDefaulted && outputName != "" {
+		if err := moveProject(outputName); err != nil {
+			return err
+		}
+	}
+
+	// A resumed run may have failed after initializing the repository
+	_, err = os.Stat(filepath.Join(directory, ".git"))
+	if !noGitInit && os.IsNotExist(err) {
+		if err := initGitRepo(directory, nil); err != nil {
+			return fmt.Errorf("initializing git repository: %w", err)
+		}
+	}
+
+	if err := provenance.ClearPending(directory); err != nil {
+		return fmt.Errorf("removing %s: %w", provenance.PendingFile, err)
+	}
+
+	if err := writeSummary(src, directory, directory); err != nil {
 		return err
 	}
 
-	if err := fetchTemplate(ctx, src); err != nil {
+	logSuccess("created project", "dir", directory)
+	return nil
+}
+
+// executeMerge scaffolds into an existing directory such as ".". The
+// template is rendered in a staging directory first and only copied
+// over if none of its files already exist in the target.
+func executeMerge(ctx context.Context, src source.Source) error {
+	info, err := os.Stat(directory)
+	if err != nil {
+		return fmt.Errorf("checking directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s exists and is not a directory", directory)
+	}
+
+	stageDir, err := os.MkdirTemp("", "gohatch-merge-*")
+	if err != nil {
+		return fmt.Errorf("creating temp directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(stageDir) }()
+
+	outDir := filepath.Join(stageDir, "out")
+	if _, err := scaffold(ctx, src, outDir, projectName(directory), true); err != nil {
 		return err
 	}
+	if err := provenance.ClearPending(outDir); err != nil {
+		return fmt.Errorf("removing %s: %w", provenance.PendingFile, err)
+	}
 
-	// Load template config
-	cfg, err := gohatchcfg.Load(directory)
+	if err := resolveConflicts(outDir, directory); err != nil {
+		return err
+	}
+	written, err := listFiles(outDir)
 	if err != nil {
-		return fmt.Errorf("loading config: %w", err)
+		return err
+	}
+
+	if err := (&source.LocalSource{Path: outDir}).Fetch(ctx, directory); err != nil {
+		return fmt.Errorf("copying into %s: %w", directory, err)
+	}
+
+	// Never re-initialize an existing repository, and leave files that
+	// were there before, such as .DS_Store, out of the initial commit
+	_, err = os.Stat(filepath.Join(directory, ".git"))
+	if !noGitInit && os.IsNotExist(err) && len(written) > 0 {
+		if err := initGitRepo(directory, written); err != nil {
+			return fmt.Errorf("initializing git repository: %w", err)
+		}
 	}
-	if gohatchcfg.Exists(directory) {
-		verboseLog("Found %s", gohatchcfg.ConfigFile)
+
+	if err := writeSummary(src, outDir, directory); err != nil {
+		return err
+	}
+
+	logSuccess("merged template", "dir", directory)
+	return nil
+}
+
+// executeArchive scaffolds into a temporary directory and writes the
+// result as a tarball rooted at the project directory's name. No git
+// repository is initialized.
+func executeArchive(ctx context.Context, src source.Source) error {
+	stageDir, err := os.MkdirTemp("", "gohatch-archive-*")
+	if err != nil {
+		return fmt.Errorf("creating temp directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(stageDir) }()
+
+	outDir := filepath.Join(stageDir, "out")
+	outputName, err := scaffold(ctx, src, outDir, projectName(directory), true)
+	if err != nil {
+		return err
+	}
+	if err := provenance.ClearPending(outDir); err != nil {
+		return fmt.Errorf("removing %s: %w", provenance.PendingFile, err)
+	}
+
+	// --name sets ProjectName, not the directory the archive unpacks to
+	prefix := dirName(directory)
+	if directoryDefaulted && outputName != "" {
+		if err := checkOutputName(outputName); err != nil {
+			return err
+		}
+		prefix = outputName
+	}
+
+	if err := archive.WriteTarGz(archivePath, outDir, prefix); err != nil {
+		return err
+	}
+
+	if err := writeSummary(src, outDir, archivePath); err != nil {
+		return err
+	}
+
+	logSuccess("created archive", "file", archivePath)
+	return nil
+}
+
+// scaffold fetches the template into dir and applies the rewrite pipeline.
+// The fetched tree is marked pending until the caller clears the marker,
+// so a failed rewrite can be resumed with fetch set to false.
+// Returns the template's output_name with variables replaced, if set.
+func scaffold(ctx context.Context, src source.Source, dir, name string, fetch bool) (string, error) {
+	vars := maps.Clone(confirmedVars)
+	if vars == nil {
+		var err error
+		if vars, err = resolveVariables(name); err != nil {
+			return "", err
+		}
+	}
+
+	if fetch {
+		if err := fetchTemplate(ctx, src, dir); err != nil {
+			return "", err
+		}
+		if err := provenance.MarkPending(dir); err != nil {
+			return "", fmt.Errorf("writing %s: %w", provenance.PendingFile, err)
+		}
+	}
+
+	cfg, err := gohatchcfg.Load(dir)
+	if err != nil {
+		return "", fmt.Errorf("loading config: %w", err)
+	}
+	// The dry-run plan already showed it for --out-scratch
+	if showTmplConfig && fetch && !dryRun {
+		if err := writeTemplateConfig(os.Stdout, dir, cfg); err != nil {
+			return "", err
+		}
+	}
+	// Variables confirmed in the preview were prompted for already
+	if confirmedVars == nil {
+		if err := askVariables(cfg.Variables, vars); err != nil {
+			return "", err
+		}
+	}
+
+	scaffoldChanges.reset()
+	if err := applyTemplate(dir, vars, false); err != nil {
+		return "", err
+	}
+
+	if !noProvenance {
+		recorded := filterVariables(vars, cfg.Variables, func(v gohatchcfg.VariableConfig) bool { return !v.Secret })
+		if err := writeProvenance(dir, src, recorded); err != nil {
+			return "", err
+		}
+	}
+
+	placeholders, err := rewrite.ParsePlaceholders(cfg.Placeholders.Delimiters, cfg.Placeholders.WordBoundary)
+	if err != nil {
+		return "", fmt.Errorf("loading config: %w", err)
+	}
+	return rewrite.NewName(cfg.OutputName, vars, nil, placeholders), nil
+}
+
+// defaultDirectory returns the last element of the module path, skipping
+// a major version suffix: github.com/me/app/v2 and gopkg.in/app.v2 both
+// yield app.
+func defaultDirectory(modPath string) string {
+	if prefix, _, ok := gomodule.SplitPathVersion(modPath); ok && prefix != "" {
+		modPath = prefix
+	}
+	return path.Base(modPath)
+}
+
+// isDirectoryArg reports whether the module argument is a directory such
+// as ./cmd/tool, which no module path can be.
+func isDirectoryArg(arg string) bool {
+	return arg == "." || arg == ".." || strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../") || filepath.IsAbs(arg)
+}
+
+// parentModulePath derives the module path of dir from the nearest go.mod
+// in dir or one of its parents: that module's path followed by the
+// relative path of dir, e.g. github.com/me/app/cmd/tool for app/cmd/tool.
+func parentModulePath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for d := abs; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			modPath, err := rewrite.ReadModulePath(d)
+			if err != nil {
+				return "", fmt.Errorf("deriving module path from %s: %w", d, err)
+			}
+			rel, err := filepath.Rel(d, abs)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(modPath, filepath.ToSlash(rel)), nil
+		}
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("no go.mod found in %s or its parents to derive the module path from", dir)
+		}
+	}
+}
+
+// dropNestedModule removes the template's go.mod and go.sum from dir, so
+// a project scaffolded into a directory of an existing module stays part
+// of it instead of splitting it off as a nested module.
+func dropNestedModule(dir string) error {
+	for _, name := range []string{"go.mod", "go.sum"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", name, err)
+		}
+	}
+	logger.Info("skipping the template's go.mod inside the parent module (run go mod tidy there)")
+	return nil
+}
+
+// warnConfusableModule warns if the new module path contains non-ASCII
+// characters, which may be typos or look-alikes of ASCII letters.
+func warnConfusableModule(modPath string) {
+	if chars := rewrite.ConfusableChars(modPath); len(chars) > 0 {
+		logger.Warn("module path contains non-ASCII characters", "module", modPath, "chars", strings.Join(chars, ", "))
+	}
+}
+
+// moveProject moves the scaffolded project to the directory named by the
+// template's output_name, next to the default directory.
+func moveProject(outputName string) error {
+	if err := checkOutputName(outputName); err != nil {
+		return err
+	}
+
+	target := filepath.Join(filepath.Dir(directory), outputName)
+	if target == filepath.Clean(directory) {
+		return nil
+	}
+	if err := validateDirectory(target); err != nil {
+		return err
+	}
+
+	// An existing empty directory is replaced
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("replacing %s: %w", target, err)
+	}
+	if err := os.Rename(directory, target); err != nil {
+		return fmt.Errorf("moving project to %s: %w", target, err)
+	}
+
+	logger.Debug("moved project", "from", directory, "to", target)
+	directory = target
+	return nil
+}
+
+// checkOutputName validates a template's output_name.
+func checkOutputName(outputName string) error {
+	if outputName == "." || outputName == ".." || strings.ContainsAny(outputName, `/\`) {
+		return fmt.Errorf("invalid output_name %q: must be a single directory name", outputName)
+	}
+	return nil
+}
+
+// projectName returns the default ProjectName: the --name flag if set,
+// otherwise the name of the output directory, resolving relative paths
+// such as "." to the directory's own name.
+func projectName(dir string) string {
+	if nameFlag != "" {
+		return nameFlag
+	}
+	return dirName(dir)
+}
+
+// dirName returns the last element of dir, made absolute first so that
+// "." names the current directory.
+func dirName(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return filepath.Base(abs)
+	}
+	return filepath.Base(dir)
+}
+
+// Policies for template files that --merge finds in the target directory
+// with different contents.
+const (
+	conflictFail      = "fail"
+	conflictOverwrite = "overwrite"
+	conflictSkip      = "skip"
+	conflictBackup    = "backup"
+)
+
+var conflictPolicies = []string{conflictFail, conflictOverwrite, conflictSkip, conflictBackup}
+
+// resolveConflicts applies the --on-conflict policy to the files in the
+// staging directory outDir that already exist in dir. Files identical to
+// the existing ones are not conflicts; they are dropped from outDir, as
+// are conflicting files kept by the skip policy. The backup policy
+// renames existing files to .bak, refusing to replace an earlier backup.
+func resolveConflicts(outDir, dir string) error {
+	overlaps, err := findOverlaps(outDir, dir)
+	if err != nil {
+		return err
+	}
+
+	var conflicts []string
+	for _, rel := range overlaps {
+		same, err := sameFile(filepath.Join(outDir, rel), filepath.Join(dir, rel))
+		if err != nil {
+			return err
+		}
+		if !same {
+			conflicts = append(conflicts, rel)
+			continue
+		}
+		if err := os.Remove(filepath.Join(outDir, rel)); err != nil {
+			return fmt.Errorf("removing %s: %w", rel, err)
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	switch onConflict {
+	case conflictOverwrite:
+		for _, rel := range conflicts {
+			logger.Warn("overwriting existing file", "file", rel)
+			// Writing through an existing symlink would change its
+			// target, and a template symlink cannot replace a file
+			if err := os.Remove(filepath.Join(dir, rel)); err != nil {
+				return fmt.Errorf("removing %s: %w", rel, err)
+			}
+		}
+	case conflictSkip:
+		for _, rel := range conflicts {
+			logger.Warn("keeping existing file", "file", rel)
+			if err := os.Remove(filepath.Join(outDir, rel)); err != nil {
+				return fmt.Errorf("removing %s: %w", rel, err)
+			}
+		}
+	case conflictBackup:
+		for _, rel := range conflicts {
+			if _, err := os.Lstat(filepath.Join(dir, rel+".bak")); err == nil {
+				return fmt.Errorf("cannot back up %s: %s.bak already exists", rel, rel)
+			}
+		}
+		for _, rel := range conflicts {
+			logger.Warn("backing up existing file", "file", rel, "backup", rel+".bak")
+			p := filepath.Join(dir, rel)
+			if err := os.Rename(p, p+".bak"); err != nil {
+				return fmt.Errorf("backing up %s: %w", rel, err)
+			}
+		}
+	default:
+		return fmt.Errorf("template files already exist in %s: %s (use --on-conflict to resolve)", dir, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// sameFile reports whether the existing file at dst is a regular file
+// with the same contents as the file at src.
+func sameFile(src, dst string) (bool, error) {
+	info, err := os.Lstat(dst)
+	if err != nil {
+		return false, fmt.Errorf("checking %s: %w", dst, err)
+	}
+	if !info.Mode().IsRegular() {
+		return false, nil
+	}
+
+	want, err := os.ReadFile(filepath.Clean(src))
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", src, err)
+	}
+	got, err := os.ReadFile(filepath.Clean(dst))
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", dst, err)
+	}
+	return bytes.Equal(want, got), nil
+}
+
+// findOverlaps lists the files in src that already exist in dst.
+func findOverlaps(src, dst string) ([]string, error) {
+	var overlaps []string
+	err := filepath.WalkDir(src, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(filepath.Join(dst, rel)); err == nil {
+			overlaps = append(overlaps, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checking %s: %w", dst, err)
+	}
+	return overlaps, nil
+}
+
+// listFiles returns the paths of all files and symlinks below dir,
+// relative to dir.
+func listFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		files = append(files, rel)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// applyTemplate runs the rewrite pipeline on a fetched template in dir.
+// A preview skips the external rewriters, so that planning changes never
+// executes template commands.
+func applyTemplate(dir string, vars map[string]string, preview bool) error {
+	defer logStage("rewrite")()
+
+	// Load template config
+	cfg, err := gohatchcfg.Load(dir)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if gohatchcfg.Exists(dir) {
+		logger.Debug("found config", "file", gohatchcfg.ConfigFile)
+	}
+
+	if err := includeFiles(dir, cfg.Include); err != nil {
+		return err
+	}
+
+	placeholders, err := rewrite.ParsePlaceholders(cfg.Placeholders.Delimiters, cfg.Placeholders.WordBoundary)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if vars, err = expandVariables(vars, placeholders); err != nil {
+		return err
+	}
+	vars = withoutBareBuiltins(vars, cfg.Variables, placeholders)
+
+	// Merge CLI extensions with config extensions, per rewrite pass
+	moduleExtensions := passExtensions(moduleExts, cfg.Extensions)
+	varExtensions := mergeExtensions(passExtensions(varExts, cfg.Extensions), variableFiles(cfg.VariableFiles))
+	if len(moduleExtensions) > 0 {
+		logger.Debug("module extensions", "list", moduleExtensions)
+	}
+	if len(varExtensions) > 0 {
+		logger.Debug("variable extensions", "list", varExtensions)
+	}
+
+	skip := resolveSkipDirs(skipDirs, cfg.SkipDirs)
+
+	oldModule := fromModule
+	if initModule && !rewrite.HasGoMod(dir) {
+		if oldModule, err = initGoMod(dir, skip); err != nil {
+			return err
+		}
+	}
+
+	if err := validateGoMod(dir); err != nil {
+		return err
+	}
+
+	if err := checkLocalGo(dir); err != nil {
+		return err
+	}
+
+	renameVars := filterVariables(vars, cfg.Variables, gohatchcfg.VariableConfig.RenamesPaths)
+	if err := renamePaths(dir, renameVars, cfg.RenameMap, skip, placeholders); err != nil {
+		return err
+	}
+
+	contentVars := filterVariables(vars, cfg.Variables, gohatchcfg.VariableConfig.ReplacesContent)
+	if err := renderTemplates(dir, contentVars, cfg, skip); err != nil {
+		return err
+	}
+
+	if !preview {
+		if err := runRewriters(dir, cfg.Rewriters, oldModule, contentVars, skip); err != nil {
+			return err
+		}
+	}
+
+	if err := warnLargeFiles(dir, mergeExtensions(moduleExtensions, varExtensions), skip); err != nil {
+		return err
+	}
+
+	nested, err := rewrite.ParseNestedScheme(cfg.NestedModules)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	opts := rewrite.Options{
+		ExtraExtensions: moduleExtensions,
+		GoExtensions:    cfg.GoExtensions,
+		TextExtensions:  cfg.TextExtensions,
+		AssetExtensions: cfg.AssetExtensions,
+		Nested:          nested,
+		GoVersion:       goVersion,
+		Toolchain:       toolchain,
+		RewriteVendor:   rewriteVendor,
+		RewriteComments: rewriteComment,
+		FromModule:      oldModule,
+		SkipDirs:        skip,
+		MaxFileSize:     maxRewriteSize,
+	}
+	if err := rewriteModule(dir, opts); err != nil {
+		return err
+	}
+
+	if err := replaceVariables(dir, contentVars, cfg.Variables, varExtensions, skip, placeholders); err != nil {
+		return err
+	}
+
+	if err := tidyImports(dir, skip); err != nil {
+		return err
+	}
+
+	if err := replaceText(dir, mergeExtensions(moduleExtensions, varExtensions), skip); err != nil {
+		return err
+	}
+
+	if err := writeEnvFile(dir, cfg.Env, vars); err != nil {
+		return err
+	}
+
+	if err := makeExecutable(dir, cfg.Executable); err != nil {
+		return err
+	}
+
+	if parentModule {
+		if err := dropNestedModule(dir); err != nil {
+			return err
+		}
+	}
+
+	// Remove config file unless --keep-config is set
+	if gohatchcfg.Exists(dir) && !keepConfig {
+		if err := gohatchcfg.Remove(dir); err != nil {
+			return fmt.Errorf("removing config: %w", err)
+		}
+		logger.Debug("removed config", "file", gohatchcfg.ConfigFile)
+	}
+
+	return nil
+}
+
+func fetchTemplate(ctx context.Context, src source.Source, dir string) error {
+	defer logStage("fetch")()
+	logger.Info("fetching template", "source", srcInput)
+	configureSource(src)
+
+	if err := src.Fetch(ctx, dir); err != nil {
+		if errors.Is(err, source.ErrTooManyFiles) {
+			return fmt.Errorf("fetching template: %w (check the source path; --respect-gitignore skips ignored files, --max-files raises the limit)", err)
+		}
+		return fmt.Errorf("fetching template: %w", err)
+	}
+
+	if ls, ok := src.(*source.LocalSource); ok {
+		for _, p := range ls.Skipped {
+			logger.Warn("skipped unreadable file", "path", p)
+		}
+	}
+
+	logger.Debug("removing template .git directory")
+	if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+		return fmt.Errorf("removing template .git: %w", err)
+	}
+
+	return nil
+}
+
+// writeProvenance records the template source and variables in the project.
+// It runs after all rewrites, so the record itself is never substituted.
+func writeProvenance(dir string, src source.Source, vars map[string]string) error {
+	rec := provenanceRecord(src, vars)
+	if err := provenance.Write(dir, rec); err != nil {
+		return fmt.Errorf("writing %s: %w", provenance.File, err)
+	}
+	logger.Debug("wrote provenance", "file", provenance.File)
+
+	return nil
+}
+
+// provenanceRecord describes the fetched template src and the project
+// generated from it with vars, including the flags that affect rendering.
+func provenanceRecord(src source.Source, vars map[string]string) *provenance.Record {
+	rec := &provenance.Record{
+		Source:             srcInput,
+		Module:             module,
+		Extensions:         extensions,
+		ModuleExtensions:   moduleExts,
+		VariableExtensions: varExts,
+		SkipDirs:           skipDirs,
+		Include:            include,
+		FromModule:         fromModule,
+		GoVersion:          goVersion,
+		Toolchain:          toolchain,
+		Replace:            replaces,
+		InitModule:         initModule,
+		NoModuleRewrite:    noModRewrite,
+		RewriteVendor:      rewriteVendor,
+		RewriteComments:    rewriteComment,
+		Goimports:          goimports,
+		KeepConfig:         keepConfig,
+		ParentModule:       parentModule,
+		MaxRewriteSize:     maxRewriteSize,
+		Timestamp:          now().UTC().Truncate(time.Second),
+		Variables:          vars,
+	}
+	switch s := src.(type) {
+	case *source.GitSource:
+		rec.URL, rec.Version, rec.Commit = s.URL, s.Version, s.Commit
+	case *source.LocalSource:
+		rec.Path = s.Path
+	case *source.ProxySource:
+		rec.Version = s.Version
+	}
+	return rec
+}
+
+// applyRecordFlags sets the flags that affect rendering to the values
+// recorded in rec, the counterpart of provenanceRecord.
+func applyRecordFlags(rec *provenance.Record) {
+	srcInput, module, extensions = rec.Source, rec.Module, rec.Extensions
+	moduleExts, varExts = rec.ModuleExtensions, rec.VariableExtensions
+	skipDirs, include, replaces = rec.SkipDirs, rec.Include, rec.Replace
+	fromModule, goVersion, toolchain = rec.FromModule, rec.GoVersion, rec.Toolchain
+	initModule, noModRewrite = rec.InitModule, rec.NoModuleRewrite
+	rewriteVendor, rewriteComment, goimports = rec.RewriteVendor, rec.RewriteComments, rec.Goimports
+	keepConfig, parentModule = rec.KeepConfig, rec.ParentModule
+	maxRewriteSize = rec.MaxRewriteSize
+}
+
+// configureSource applies source-specific CLI flags.
+func configureSource(src source.Source) {
+	switch s := src.(type) {
+	case *source.LocalSource:
+		s.SkipUnreadable = skipUnreadable
+		s.RespectGitignore = respectIgnore
+		s.NoEscape = noEscape
+		s.MaxFiles = maxFiles
+	case *source.GitSource:
+		s.LFS = lfs
+		s.Tokens = tokens
+		if !noNetrc {
+			s.Netrc = source.NetrcPath()
+		}
+		s.Depth = depth
+		if fullHistory {
+			s.Depth = 0
+		}
+		s.Ref = ref
+		s.Logger = logger
+		if noGoImport {
+			s.ImportPath = ""
+		}
+		// Keep a commit pinned by the --confirm preview
+		s.ExpectCommit = cmp.Or(s.ExpectCommit, expectCommit)
+	}
+}
+
+// loadUserConfig reads the user config and fills in defaults for
+// settings not given on the command line.
+func loadUserConfig() error {
+	cfgPath, err := gohatchcfg.UserConfigPath()
+	if err != nil {
+		return fmt.Errorf("locating user config: %w", err)
+	}
+
+	cfg, err := gohatchcfg.LoadUser(cfgPath)
+	if err != nil {
+		return fmt.Errorf("loading user config %s: %w", cfgPath, err)
+	}
+
+	applyUserConfig(cfg)
+	return nil
+}
+
+// defaultSource returns the default_source from .gohatch.toml in the
+// working directory, or else from the user config.
+func defaultSource() (string, error) {
+	cfg, err := gohatchcfg.Load(".")
+	if err != nil {
+		return "", fmt.Errorf("loading config: %w", err)
+	}
+	if cfg.DefaultSource != "" {
+		return cfg.DefaultSource, nil
+	}
+
+	cfgPath, err := gohatchcfg.UserConfigPath()
+	if err != nil {
+		return "", fmt.Errorf("locating user config: %w", err)
+	}
+	userCfg, err := gohatchcfg.LoadUser(cfgPath)
+	if err != nil {
+		return "", fmt.Errorf("loading user config %s: %w", cfgPath, err)
+	}
+	return userCfg.DefaultSource, nil
+}
+
+// applyUserConfig merges user defaults under the CLI flags.
+func applyUserConfig(cfg *gohatchcfg.UserConfig) {
+	extensions = mergeExtensions(extensions, cfg.Extensions)
+
+	if host == "" {
+		host = cfg.DefaultHost
+	}
+	if host == "" {
+		host = source.DefaultHost
+	}
+
+	tokens = hostTokens(cfg)
+
+	if templateDir == "" {
+		templateDir = cfg.TemplateDir
+	}
+
+	if cfg.BenignEntries != nil {
+		benignEntries = cfg.BenignEntries
+	}
+}
+
+// hostTokens returns the access tokens of the user config by host. The
+// top-level token belongs to the default host; [hosts."name"] tables
+// add tokens for other hosts.
+func hostTokens(cfg *gohatchcfg.UserConfig) map[string]string {
+	result := make(map[string]string)
+	if cfg.Token != "" {
+		result[strings.ToLower(cmp.Or(cfg.DefaultHost, source.DefaultHost))] = cfg.Token
+	}
+	for name, h := range cfg.Hosts {
+		if h.Token != "" {
+			result[strings.ToLower(name)] = h.Token
+		}
+	}
+	return result
+}
+
+// parseSource parses the template source. Named sources such as
+// @mytemplate are resolved against the templates directory.
+func parseSource(input string) (source.Source, error) {
+	name, ok := strings.CutPrefix(input, source.NamedPrefix)
+	if !ok {
+		return source.ParseWithHost(input, host)
+	}
+
+	root := templateDir
+	if root == "" {
+		var err error
+		if root, err = source.DefaultTemplateDir(); err != nil {
+			return nil, fmt.Errorf("locating templates directory: %w", err)
+		}
+	}
+
+	src, err := source.ParseNamed(name, root)
+	if err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+// initGoMod creates a go.mod for the new module in a template without
+// one. Returns the import prefix to rewrite: --from-module if set,
+// otherwise the prefix detected from the template's imports.
+func initGoMod(dir string, skip []string) (string, error) {
+	oldModule := fromModule
+	if oldModule == "" {
+		detected, err := rewrite.DetectModulePath(dir, skip)
+		if err != nil {
+			return "", fmt.Errorf("detecting module path: %w", err)
+		}
+		if detected != "" {
+			logger.Info("detected module path", "module", detected)
+		}
+		oldModule = detected
+	}
+
+	if err := rewrite.WriteGoMod(dir, module, goVersion); err != nil {
+		return "", fmt.Errorf("creating go.mod: %w", err)
+	}
+	logger.Info("created go.mod", "module", module)
+
+	return oldModule, nil
+}
+
+// goVersionAuto selects the go directive from runtimeVersion.
+const goVersionAuto = "auto"
+
+// runtimeVersion reports the Go version gohatch was built with; replaced
+// in tests.
+var runtimeVersion = runtime.Version
+
+// autoGoVersion returns the language version, e.g. 1.23, of
+// runtimeVersion for --go-version auto.
+func autoGoVersion() (string, error) {
+	v := runtimeVersion()
+	lang := goversion.Lang(v)
+	if lang == "" {
+		return "", fmt.Errorf("--go-version auto: cannot determine the Go version from %q", v)
+	}
+	return strings.TrimPrefix(lang, "go"), nil
+}
+
+// localGoVersion reports the version of the go command in PATH, such as
+// go1.23.4. Tests replace it with a stub.
+var localGoVersion = func() (string, error) {
+	out, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// checkLocalGo compares the go directive the project will have against
+// the local toolchain. An older toolchain is a warning, or an error with
+// --strict-go. Development builds are not compared.
+func checkLocalGo(dir string) error {
+	required := goVersion
+	if required == "" && rewrite.HasGoMod(dir) {
+		var err error
+		if required, err = rewrite.ReadGoVersion(dir); err != nil {
+			return err
+		}
+	}
+	if required == "" {
+		return nil
+	}
+
+	local, err := localGoVersion()
+	if err != nil {
+		if strictGo {
+			return fmt.Errorf("checking local go version: %w", err)
+		}
+		logger.Debug("cannot determine local go version", "error", err)
+		return nil
+	}
+	if !goversion.IsValid(local) {
+		logger.Debug("not comparing go versions", "local", local)
+		return nil
+	}
+
+	if goversion.Compare(local, "go"+required) >= 0 {
+		return nil
+	}
+	if strictGo {
+		return fmt.Errorf("template requires go %s, but the local toolchain is %s", required, local)
+	}
+	logger.Warn("local go toolchain is older than the template requires", "required", required, "local", local)
+	return nil
+}
+
+func validateGoMod(dir string) error {
+	if rewrite.HasGoMod(dir) {
+		return nil
+	}
+
+	if !force {
+		_ = os.RemoveAll(dir)
+		return fmt.Errorf("template has no go.mod (use --force to proceed anyway)")
+	}
+
+	logger.Warn("template has no go.mod, skipping module rewrite")
+	return nil
+}
+
+func renamePaths(dir string, vars, renameMap map[string]string, skip []string, placeholders rewrite.Placeholders) error {
+	if len(vars) == 0 && len(renameMap) == 0 {
+		return nil
+	}
+
+	renamedPaths, err := rewrite.RenamePaths(dir, vars, renameMap, skip, placeholders)
+	if err != nil {
+		return fmt.Errorf("renaming paths: %w", err)
+	}
+
+	scaffoldChanges.addRenames(renamedPaths)
+	if len(renamedPaths) > 0 {
+		logger.Info("renaming paths", "count", len(renamedPaths))
+		for _, r := range renamedPaths {
+			logEvent(eventRename, slog.LevelDebug, "renamed", "path", r)
+		}
+	}
+
+	return nil
+}
+
+// renderTemplates renders the template's .tmpl files with text/template
+// if the template config enables it with render_templates. Files matched
+// by its text_extensions are code generation templates and left alone.
+func renderTemplates(dir string, vars map[string]string, cfg *gohatchcfg.Config, skip []string) error {
+	if !cfg.RenderTemplates {
+		return nil
+	}
+
+	rendered, err := rewrite.RenderTemplates(dir, vars, cfg.TextExtensions, skip)
+	if err != nil {
+		return fmt.Errorf("rendering templates: %w", err)
+	}
+
+	if len(rendered) > 0 {
+		logger.Info("rendering templates", "count", len(rendered))
+		for _, f := range rendered {
+			logFile("rendered", f)
+		}
+	}
+
+	return nil
+}
+
+// runRewriters applies the template's external rewriters, which require
+// --allow-exec since they run commands chosen by the template. They run
+// before the module rewrite, so OLD_MODULE is the path the template's
+// go.mod declares, or oldModule for templates without one.
+func runRewriters(dir string, rewriters []gohatchcfg.RewriterConfig, oldModule string, vars map[string]string, skip []string) error {
+	if len(rewriters) == 0 {
+		return nil
+	}
+	if !allowExec {
+		return fmt.Errorf("template declares external rewriters; use --allow-exec to run them")
+	}
+
+	if rewrite.HasGoMod(dir) {
+		declared, err := rewrite.ReadModulePath(dir)
+		if err != nil {
+			return fmt.Errorf("reading module path: %w", err)
+		}
+		// A go.mod created by --init-module already declares the new module
+		if declared != module || oldModule == "" {
+			oldModule = declared
+		}
+	}
+	env := rewrite.CommandEnv(oldModule, module, vars)
+
+	list := make([]rewrite.Rewriter, 0, len(rewriters))
+	for _, r := range rewriters {
+		if len(r.Command) == 0 {
+			return fmt.Errorf("loading config: rewriter for %q has no command", r.Pattern)
+		}
+		list = append(list, rewrite.CommandRewriter(dir, r.Pattern, r.Command, env))
+	}
+
+	logger.Info("running rewriters", "count", len(list))
+	modifiedFiles, err := rewrite.ApplyRewriters(dir, list, skip, maxRewriteSize)
+	if err != nil {
+		return fmt.Errorf("running rewriters: %w", err)
+	}
+
+	for _, f := range modifiedFiles {
+		logFile("rewritten", f)
+	}
+
+	return nil
+}
+
+// warnLargeFiles logs the files the rewrites skip because they exceed
+// --max-rewrite-size. They are still copied unchanged.
+func warnLargeFiles(dir string, exts, skip []string) error {
+	large, err := rewrite.LargeFiles(dir, maxRewriteSize, exts, skip)
+	if err != nil {
+		return fmt.Errorf("checking file sizes: %w", err)
+	}
+	for _, f := range large {
+		logger.Warn("skipping rewrite of large file", "file", f, "max", maxRewriteSize)
+	}
+	return nil
+}
+
+func rewriteModule(dir string, opts rewrite.Options) error {
+	if noModRewrite {
+		logger.Info("skipping module rewrite (--no-module-rewrite)")
+		return nil
+	}
+	if !rewrite.HasGoMod(dir) {
+		return nil
+	}
+
+	oldModule, err := rewrite.ReadModulePath(dir)
+	if err != nil {
+		return fmt.Errorf("reading module path: %w", err)
+	}
+	logger.Debug("found go.mod", "module", oldModule)
+
+	fromOther := opts.FromModule != "" && opts.FromModule != oldModule && opts.FromModule != module
+	if oldModule == module && opts.GoVersion == "" && opts.Toolchain == "" && !fromOther {
+		return nil
+	}
+
+	if oldModule != module {
+		logger.Info("rewriting module", "from", oldModule, "to", module)
+
+		selfRequires, err := rewrite.SelfRequires(dir, opts.SkipDirs, opts.RewriteVendor)
+		if err != nil {
+			return fmt.Errorf("checking go.mod requirements: %w", err)
+		}
+		for _, f := range selfRequires {
+			logger.Info("dropping self-require", "file", f)
+		}
+	}
+	if fromOther {
+		logger.Info("rewriting imports", "from", opts.FromModule, "to", module)
+	}
+	if opts.GoVersion != "" {
+		logger.Info("setting go version", "version", opts.GoVersion)
+	}
+	if opts.Toolchain == rewrite.ToolchainNone {
+		logger.Info("removing toolchain directive")
+	} else if opts.Toolchain != "" {
+		logger.Info("setting toolchain", "toolchain", opts.Toolchain)
+	}
+	modifiedFiles, err := rewrite.Module(dir, module, opts)
+	if err != nil {
+		return fmt.Errorf("rewriting module: %w", err)
 	}
 
-	// Merge CLI extensions with config extensions
-	mergedExtensions := mergeExtensions(extensions, cfg.Extensions)
-	if len(mergedExtensions) > 0 {
-		verboseLog("Extensions: %v", mergedExtensions)
+	for _, f := range modifiedFiles {
+		logFile("rewritten", f)
 	}
 
-	if err := validateGoMod(); err != nil {
-		return err
+	return nil
+}
+
+func replaceVariables(dir string, vars map[string]string, declared []gohatchcfg.VariableConfig, exts, skip []string, placeholders rewrite.Placeholders) error {
+	if len(vars) == 0 {
+		return nil
 	}
 
-	vars := parseVariables(variables, path.Base(directory))
+	logger.Info("replacing variables", "vars", formatVariables(redactVariables(vars, declared)))
+	modifiedFiles, err := rewrite.Variables(dir, vars, exts, skip, placeholders, maxRewriteSize)
+	if err != nil {
+		return fmt.Errorf("replacing variables: %w", err)
+	}
 
-	if err := renamePaths(vars); err != nil {
-		return err
+	for _, f := range modifiedFiles {
+		logFile("replaced variables", f)
 	}
 
-	if err := rewriteModule(mergedExtensions); err != nil {
-		return err
+	return nil
+}
+
+// parseReplacements parses the --replace flags.
+func parseReplacements(values []string) ([]rewrite.Replacement, error) {
+	replacements := make([]rewrite.Replacement, 0, len(values))
+	for _, v := range values {
+		r, err := rewrite.ParseReplacement(v)
+		if err != nil {
+			return nil, fmt.Errorf("--replace: %w", err)
+		}
+		replacements = append(replacements, r)
 	}
+	return replacements, nil
+}
 
-	if err := replaceVariables(vars, mergedExtensions); err != nil {
+func replaceText(dir string, exts, skip []string) error {
+	replacements, err := parseReplacements(replaces)
+	if err != nil || len(replacements) == 0 {
 		return err
 	}
 
-	// Remove config file unless --keep-config is set
-	if gohatchcfg.Exists(directory) && !keepConfig {
-		if err := gohatchcfg.Remove(directory); err != nil {
-			return fmt.Errorf("removing config: %w", err)
-		}
-		verboseLog("Removed %s", gohatchcfg.ConfigFile)
+	logger.Info("replacing text", "count", len(replacements))
+	modifiedFiles, err := rewrite.Replace(dir, replacements, exts, skip, maxRewriteSize)
+	if err != nil {
+		return fmt.Errorf("replacing text: %w", err)
 	}
 
-	if !noGitInit {
-		if err := initGitRepo(directory); err != nil {
-			return fmt.Errorf("initializing git repository: %w", err)
-		}
+	for _, f := range modifiedFiles {
+		logFile("replaced text", f)
 	}
 
-	fmt.Printf("Created %s\n", directory)
 	return nil
 }
 
-func fetchTemplate(ctx context.Context, src source.Source) error {
-	fmt.Printf("Fetching template from %s...\n", srcInput)
-	if err := src.Fetch(ctx, directory); err != nil {
-		return fmt.Errorf("fetching template: %w", err)
+func tidyImports(dir string, skip []string) error {
+	if !goimports {
+		return nil
 	}
 
-	verboseLog("Removing template .git directory")
-	if err := os.RemoveAll(filepath.Join(directory, ".git")); err != nil {
-		return fmt.Errorf("removing template .git: %w", err)
+	logger.Info("tidying imports")
+	modifiedFiles, err := rewrite.Imports(dir, module, rewriteVendor, skip)
+	if err != nil {
+		return fmt.Errorf("tidying imports: %w", err)
+	}
+
+	for _, f := range modifiedFiles {
+		logFile("tidied", f)
 	}
 
 	return nil
 }
 
-func validateGoMod() error {
-	if rewrite.HasGoMod(directory) {
+func writeEnvFile(dir string, env gohatchcfg.EnvConfig, vars map[string]string) error {
+	if env.Path == "" {
 		return nil
 	}
 
-	if !force {
-		_ = os.RemoveAll(directory)
-		return fmt.Errorf("template has no go.mod (use --force to proceed anyway)")
+	if err := rewrite.WriteEnv(dir, env.Path, vars, env.Keys); err != nil {
+		return fmt.Errorf("writing %s: %w", env.Path, err)
 	}
+	logger.Debug("wrote env file", "file", env.Path)
 
-	fmt.Println("Warning: template has no go.mod, skipping module rewrite")
 	return nil
 }
 
-func renamePaths(vars map[string]string) error {
-	if len(vars) == 0 {
-		return nil
-	}
-
-	renamedPaths, err := rewrite.RenamePaths(directory, vars)
+// makeExecutable sets the executable bits of the generated files that
+// match the executable patterns, which some copies lose on the way.
+func makeExecutable(dir string, patterns []string) error {
+	changed, err := rewrite.Executable(dir, patterns)
 	if err != nil {
-		return fmt.Errorf("renaming paths: %w", err)
+		return fmt.Errorf("applying executable patterns: %w", err)
+	}
+	for _, f := range changed {
+		logFile("made executable", f)
 	}
+	return nil
+}
 
-	if len(renamedPaths) > 0 {
-		fmt.Println("Renaming paths...")
-		for _, r := range renamedPaths {
-			verboseLog("Renamed: %s", r)
+// filterVariables returns a copy of vars without the declared variables
+// for which use reports false.
+func filterVariables(vars map[string]string, declared []gohatchcfg.VariableConfig, use func(gohatchcfg.VariableConfig) bool) map[string]string {
+	result := maps.Clone(vars)
+	for _, v := range declared {
+		if !use(v) {
+			delete(result, v.Name)
 		}
 	}
+	return result
+}
 
-	return nil
+// redactedValue replaces the values of secret variables in log output.
+const redactedValue = "***"
+
+// redactVariables returns a copy of vars with the values of the declared
+// secret variables replaced by redactedValue.
+func redactVariables(vars map[string]string, declared []gohatchcfg.VariableConfig) map[string]string {
+	result := maps.Clone(vars)
+	for _, v := range declared {
+		if _, ok := result[v.Name]; ok && v.Secret {
+			result[v.Name] = redactedValue
+		}
+	}
+	return result
 }
 
-func rewriteModule(exts []string) error {
-	if !rewrite.HasGoMod(directory) {
+// includeFiles removes the template files that match none of the
+// include patterns. Patterns from the command line replace those of the
+// template config, which itself is always kept.
+func includeFiles(dir string, config []string) error {
+	patterns := config
+	if len(include) > 0 {
+		patterns = include
+	}
+	if len(patterns) == 0 {
 		return nil
 	}
 
-	oldModule, err := rewrite.ReadModulePath(directory)
+	logger.Info("including only matching files", "patterns", patterns)
+	removed, err := rewrite.Include(dir, append(slices.Clone(patterns), gohatchcfg.ConfigFile))
 	if err != nil {
-		return fmt.Errorf("reading module path: %w", err)
+		return fmt.Errorf("applying include patterns: %w", err)
+	}
+	for _, f := range removed {
+		logger.Debug("excluded", "file", f)
 	}
-	verboseLog("Found go.mod with module: %s", oldModule)
+	return nil
+}
 
-	if oldModule == module {
+// resolveSkipDirs returns the directory names skipped by the rewrites.
+// --skip-dir flags replace the template's skip_dirs, which in turn replace
+// rewrite.DefaultSkipDirs (selected by nil). Empty names are dropped, so
+// --skip-dir "" or skip_dirs = [] clears the list.
+func resolveSkipDirs(cli, config []string) []string {
+	names := config
+	if len(cli) > 0 {
+		names = cli
+	}
+	if names == nil {
 		return nil
 	}
 
-	fmt.Printf("Rewriting module %s → %s\n", oldModule, module)
-	modifiedFiles, err := rewrite.Module(directory, module, exts)
-	if err != nil {
-		return fmt.Errorf("rewriting module: %w", err)
+	result := []string{}
+	for _, name := range names {
+		if name != "" {
+			result = append(result, name)
+		}
 	}
+	return result
+}
 
-	for _, f := range modifiedFiles {
-		verboseLog("Rewritten: %s", f)
+// builtinVariables returns the variables every template receives:
+// ProjectName and the current Year, Date, and DateTime.
+func builtinVariables(projectName string) map[string]string {
+	t := now()
+	return map[string]string{
+		"ProjectName": projectName,
+		"Year":        t.Format("2006"),
+		"Date":        t.Format(time.DateOnly),
+		"DateTime":    t.Format(time.RFC3339),
 	}
+}
 
-	return nil
+// cliVariables converts CLI key=value pairs to a map. Entries without
+// "=" name variables to prompt for and are left out.
+func cliVariables(vars []string) map[string]string {
+	result := make(map[string]string, len(vars))
+	for _, v := range vars {
+		if key, value, ok := strings.Cut(v, "="); ok {
+			result[key] = value
+		}
+	}
+	return result
 }
 
-func replaceVariables(vars map[string]string, exts []string) error {
-	if len(vars) == 0 {
-		return nil
+// maxVariableFileSize limits the size of files read with --set-file.
+const maxVariableFileSize = 1 << 20
+
+// fileVariables reads Key=path pairs into a map of variables holding the
+// verbatim file contents. Only text files up to maxVariableFileSize are
+// accepted.
+func fileVariables(entries []string) (map[string]string, error) {
+	result := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		key, path, ok := strings.Cut(entry, "=")
+		if !ok || key == "" || path == "" {
+			return nil, fmt.Errorf("invalid --set-file %q: expected Key=path", entry)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading --set-file %s: %w", key, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("reading --set-file %s: %s is not a regular file", key, path)
+		}
+		if info.Size() > maxVariableFileSize {
+			return nil, fmt.Errorf("reading --set-file %s: %s is too large (%d bytes, limit %d)", key, path, info.Size(), maxVariableFileSize)
+		}
+
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return nil, fmt.Errorf("reading --set-file %s: %w", key, err)
+		}
+		if bytes.IndexByte(data, 0) != -1 || !utf8.Valid(data) {
+			return nil, fmt.Errorf("reading --set-file %s: %s is not a UTF-8 text file", key, path)
+		}
+
+		result[key] = string(data)
 	}
+	return result, nil
+}
 
-	fmt.Printf("Replacing variables: %v\n", formatVariables(vars))
-	modifiedFiles, err := rewrite.Variables(directory, vars, exts)
+// resolveVariables combines the built-in variables, --vars-json, the -v
+// flags, and --set-file, each taking precedence over the former.
+func resolveVariables(defaultProjectName string) (map[string]string, error) {
+	jsonVars, err := parseJSONVariables(varsJSON)
 	if err != nil {
-		return fmt.Errorf("replacing variables: %w", err)
+		return nil, fmt.Errorf("parsing --vars-json: %w", err)
 	}
 
-	for _, f := range modifiedFiles {
-		verboseLog("Replaced variables in: %s", f)
+	fileVars, err := fileVariables(setFiles)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	result := builtinVariables(defaultProjectName)
+	maps.Copy(result, jsonVars)
+	maps.Copy(result, cliVariables(variables))
+	maps.Copy(result, fileVars)
+
+	return result, nil
 }
 
-// parseVariables converts CLI key=value pairs to a map.
-// Sets ProjectName to defaultProjectName if not overridden.
-func parseVariables(vars []string, defaultProjectName string) map[string]string {
-	result := map[string]string{
-		"ProjectName": defaultProjectName,
+// withoutBareBuiltins returns vars without the built-in variables the
+// template does not declare, if placeholders have no delimiters: bare
+// names such as Date also occur in Go code, as in time.Date.
+func withoutBareBuiltins(vars map[string]string, declared []gohatchcfg.VariableConfig, placeholders rewrite.Placeholders) map[string]string {
+	if placeholders.Left != "" || placeholders.Right != "" {
+		return vars
 	}
-	for _, v := range vars {
-		if key, value, ok := strings.Cut(v, "="); ok {
-			result[key] = value
+	result := maps.Clone(vars)
+	for key := range builtinVariables("") {
+		if !slices.ContainsFunc(declared, func(v gohatchcfg.VariableConfig) bool { return v.Name == key }) {
+			delete(result, key)
 		}
 	}
 	return result
 }
 
+// expandVariables resolves references to other variables in the values
+// of vars, e.g. Module=github.com/me/__ProjectName__. The values are
+// recorded in the provenance as given, so an update expands them again.
+func expandVariables(vars map[string]string, placeholders rewrite.Placeholders) (map[string]string, error) {
+	expanded, err := rewrite.ExpandVariables(vars, placeholders)
+	if err != nil {
+		return nil, fmt.Errorf("expanding variables: %w", err)
+	}
+	return expanded, nil
+}
+
+// parseJSONVariables decodes a JSON object of variables. Numbers and
+// booleans are converted to their canonical string form.
+func parseJSONVariables(data string) (map[string]string, error) {
+	result := make(map[string]string)
+	if data == "" {
+		return result, nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+
+	var raw map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			result[key] = v
+		case bool:
+			result[key] = strconv.FormatBool(v)
+		case json.Number:
+			s, err := canonicalNumber(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			result[key] = s
+		default:
+			return nil, fmt.Errorf("%s: value must be a string, number, or boolean", key)
+		}
+	}
+
+	return result, nil
+}
+
+// canonicalNumber keeps integers exactly and formats other numbers in
+// their shortest decimal form, so 2025 stays "2025" and 1.50 becomes "1.5".
+func canonicalNumber(n json.Number) (string, error) {
+	if !strings.ContainsAny(n.String(), ".eE") {
+		return n.String(), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
+
 // formatVariables formats variables for display.
 func formatVariables(vars map[string]string) string {
 	parts := make([]string, 0, len(vars))
@@ -337,6 +2164,25 @@ func formatVariables(vars map[string]string) string {
 	return strings.Join(parts, ", ")
 }
 
+// passExtensions returns the extra patterns of one rewrite pass: the
+// pass's own flag values, or the -e values if none were given, merged
+// with the config extensions.
+func passExtensions(pass, config []string) []string {
+	if len(pass) == 0 {
+		pass = extensions
+	}
+	return mergeExtensions(pass, config)
+}
+
+// variableFiles returns the dotfiles given in the template config, or
+// rewrite.DefaultVariableFiles if none are set.
+func variableFiles(config []string) []string {
+	if config == nil {
+		return rewrite.DefaultVariableFiles
+	}
+	return config
+}
+
 // mergeExtensions combines CLI extensions with config extensions.
 // CLI extensions are added to config extensions (union).
 func mergeExtensions(cli, config []string) []string {
@@ -362,14 +2208,7 @@ func mergeExtensions(cli, config []string) []string {
 	return result
 }
 
-// verboseLog prints a message only if verbose mode is enabled.
-func verboseLog(format string, args ...any) {
-	if verbose {
-		fmt.Printf("  "+format+"\n", args...)
-	}
-}
-
-func runDryRun(src source.Source) error {
+func runDryRun(ctx context.Context, src source.Source) error {
 	fmt.Println("Dry-run mode: no changes will be made")
 	fmt.Println()
 
@@ -380,12 +2219,26 @@ func runDryRun(src source.Source) error {
 		if s.Version != "" {
 			fmt.Printf("Version:   %s\n", s.Version)
 		}
+		if ref != "" {
+			fmt.Printf("Ref:       %s\n", ref)
+		}
+		if expectCommit != "" {
+			fmt.Printf("Commit:    %s (expected)\n", expectCommit)
+		}
 	case *source.LocalSource:
 		fmt.Printf("Source:    %s (local)\n", s.Path)
+	case *source.ProxySource:
+		fmt.Printf("Source:    %s (module proxy)\n", s.Module)
+		if s.Version != "" {
+			fmt.Printf("Version:   %s\n", s.Version)
+		}
 	}
 
 	// Show target info
 	fmt.Printf("Directory: %s\n", directory)
+	if outScratch != "" {
+		fmt.Printf("Scratch:   %s\n", outScratch)
+	}
 	fmt.Printf("Module:    %s\n", module)
 
 	// Show extensions if any
@@ -394,42 +2247,334 @@ func runDryRun(src source.Source) error {
 	}
 
 	// Show variables
-	vars := parseVariables(variables, path.Base(directory))
+	vars, err := resolveVariables(projectName(directory))
+	if err != nil {
+		return err
+	}
 	fmt.Printf("Variables: %s\n", formatVariables(vars))
 
+	if goVersion != "" {
+		fmt.Printf("Go:        %s\n", goVersion)
+	}
+	if toolchain != "" {
+		fmt.Printf("Toolchain: %s\n", toolchain)
+	}
+
 	// Show force flag
 	if force {
 		fmt.Println("Force:     --force (skip go.mod validation)")
 	}
 
+	if mergeDir {
+		fmt.Printf("Merge:     --merge (on conflict: %s)\n", onConflict)
+	}
+
+	if archivePath != "" {
+		fmt.Printf("Archive:   %s\n", archivePath)
+	}
+
 	// Show git init status
 	if noGitInit {
 		fmt.Println("Git:       --no-git-init (skip initialization)")
 	}
 
+	if noProvenance {
+		fmt.Printf("Provenance: --no-provenance (skip %s)\n", provenance.File)
+	}
+
 	// Show keep-config flag
 	if keepConfig {
 		fmt.Println("Config:    --keep-config (keep .gohatch.toml)")
 	}
 
 	fmt.Println()
-	fmt.Println("Would fetch template and rewrite module path in all .go files.")
+	if noModRewrite {
+		fmt.Println("Would fetch template; module path is left unchanged (--no-module-rewrite).")
+	} else {
+		fmt.Println("Would fetch template and rewrite module path in all .go files.")
+	}
 	fmt.Println("Would read .gohatch.toml from template (if present) for additional extensions.")
-	if len(extensions) > 0 {
+	if len(extensions) > 0 && !noModRewrite {
 		fmt.Println("Would also replace module path in files with specified extensions.")
 	}
 	fmt.Println("Would replace template variables (__Key__ → Value).")
+	pending, err := printPlannedChanges(ctx, src, vars)
+	if err != nil {
+		return err
+	}
 	if !keepConfig {
 		fmt.Println("Would remove .gohatch.toml from output (use --keep-config to keep).")
 	}
-	if !noGitInit {
+	if !noProvenance {
+		fmt.Printf("Would record template provenance in %s.\n", provenance.File)
+	}
+	switch {
+	case archivePath != "":
+		fmt.Printf("Would write the project to %s instead of a directory.\n", archivePath)
+	case !noGitInit:
 		fmt.Println("Would initialize git repository with initial commit.")
 	}
 
+	if outScratch != "" {
+		if err := scaffoldScratch(ctx, src); err != nil {
+			return err
+		}
+		fmt.Println()
+		fmt.Printf("Scaffolded into scratch directory %s; %s was left untouched.\n", outScratch, directory)
+	}
+
+	if pending {
+		return errChangesPending
+	}
+	return nil
+}
+
+// scaffoldScratch runs the full pipeline into the --out-scratch
+// directory, creating it and its parents. ProjectName still defaults to
+// the name of the real target.
+func scaffoldScratch(ctx context.Context, src source.Source) error {
+	if err := validateDirectory(outScratch); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outScratch), 0o750); err != nil {
+		return fmt.Errorf("creating scratch directory: %w", err)
+	}
+
+	if _, err := scaffold(ctx, src, outScratch, projectName(directory), true); err != nil {
+		return err
+	}
+	if err := provenance.ClearPending(outScratch); err != nil {
+		return fmt.Errorf("removing %s: %w", provenance.PendingFile, err)
+	}
+
+	_, err := os.Stat(filepath.Join(outScratch, ".git"))
+	if !noGitInit && os.IsNotExist(err) {
+		if err := initGitRepo(outScratch, nil); err != nil {
+			return fmt.Errorf("initializing git repository: %w", err)
+		}
+	}
 	return nil
 }
 
-// validateDirectory checks that the target directory doesn't exist or is empty.
+// printPlannedChanges fetches the template into a temporary directory
+// and lists the path renames, file modifications, and external rewriters
+// that would be performed. It reports whether any are pending. If the template cannot
+// be fetched or rendered, the renames found so far are listed and the
+// error is returned.
+func printPlannedChanges(ctx context.Context, src source.Source, vars map[string]string) (bool, error) {
+	plan, err := planChanges(ctx, src, vars, false)
+	return printPlan(plan, err)
+}
+
+// printPlan lists the changes in plan and reports whether any are
+// pending. If planning failed with err, the renames found so far are
+// listed and err is returned.
+func printPlan(plan *changePlan, err error) (bool, error) {
+	if plan != nil && len(plan.Renames) > 0 {
+		fmt.Println("Would rename paths:")
+		for _, r := range plan.Renames {
+			fmt.Printf("  %s\n", r)
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("previewing changes: %w", err)
+	}
+
+	if len(plan.Modified) > 0 {
+		fmt.Println("Would modify files:")
+		for _, f := range plan.Modified {
+			fmt.Printf("  %s\n", f)
+		}
+	}
+
+	printVariableUsage(plan.Usage)
+
+	for _, r := range plan.Rewriters {
+		note := ""
+		if !allowExec {
+			note = " (requires --allow-exec)"
+		}
+		fmt.Printf("Would run %s on %s%s.\n", strings.Join(r.Command, " "), r.Pattern, note)
+	}
+
+	return len(plan.Renames) > 0 || len(plan.Modified) > 0 || len(plan.Rewriters) > 0, nil
+}
+
+// printVariableUsage lists how often each variable occurs in the
+// template's file contents. Unused built-in variables are left out.
+func printVariableUsage(usage map[string]rewrite.VariableUsage) {
+	builtin := builtinVariables("")
+	var lines []string
+	for _, key := range slices.Sorted(maps.Keys(usage)) {
+		u := usage[key]
+		switch {
+		case u.Occurrences > 0:
+			lines = append(lines, fmt.Sprintf("  %s: %s in %s", key, pluralize(u.Occurrences, "occurrence"), pluralize(u.Files, "file")))
+		case builtin[key] == "":
+			lines = append(lines, fmt.Sprintf("  %s: not used", key))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Println("Would replace variables:")
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
+
+// pluralize formats n with the noun, pluralized with an "s" unless n is 1.
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// changePlan lists the changes rendering a template would make and the
+// variable placeholders found in the template's file contents.
+type changePlan struct {
+	Renames   []string
+	Modified  []string
+	Usage     map[string]rewrite.VariableUsage
+	Rewriters []gohatchcfg.RewriterConfig
+	Declared  []gohatchcfg.VariableConfig
+}
+
+// planChanges fetches the template into a temporary directory, renders
+// it there, and returns the renames and the modified template files.
+// With ask set, the declared variables are prompted for first and the
+// answers are added to vars. External rewriters are listed in the plan
+// but not run, so their changes are not included. If rendering fails,
+// the plan holds the renames alongside the error.
+func planChanges(ctx context.Context, src source.Source, vars map[string]string, ask bool) (*changePlan, error) {
+	tmpDir, err := os.MkdirTemp("", "gohatch-dry-run-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	configureSource(src)
+	if err := src.Fetch(ctx, tmpDir); err != nil {
+		return nil, fmt.Errorf("fetching template: %w", err)
+	}
+	if err := os.RemoveAll(filepath.Join(tmpDir, ".git")); err != nil {
+		return nil, fmt.Errorf("removing template .git: %w", err)
+	}
+
+	cfg, err := gohatchcfg.Load(tmpDir)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if showTmplConfig {
+		if err := writeTemplateConfig(os.Stdout, tmpDir, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if ask {
+		if err := askVariables(cfg.Variables, vars); err != nil {
+			return nil, err
+		}
+	}
+	if err := includeFiles(tmpDir, cfg.Include); err != nil {
+		return nil, err
+	}
+	placeholders, err := rewrite.ParsePlaceholders(cfg.Placeholders.Delimiters, cfg.Placeholders.WordBoundary)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if vars, err = expandVariables(vars, placeholders); err != nil {
+		return nil, err
+	}
+	vars = withoutBareBuiltins(vars, cfg.Variables, placeholders)
+	skip := resolveSkipDirs(skipDirs, cfg.SkipDirs)
+	renameVars := filterVariables(vars, cfg.Variables, gohatchcfg.VariableConfig.RenamesPaths)
+
+	renames, err := rewrite.PlanRenames(tmpDir, renameVars, cfg.RenameMap, skip, placeholders)
+	if err != nil {
+		return nil, err
+	}
+	plan := &changePlan{Renames: renames, Rewriters: cfg.Rewriters, Declared: cfg.Variables}
+
+	contentVars := filterVariables(vars, cfg.Variables, gohatchcfg.VariableConfig.ReplacesContent)
+	varExtensions := mergeExtensions(passExtensions(varExts, cfg.Extensions), variableFiles(cfg.VariableFiles))
+	plan.Usage, err = rewrite.CountVariables(tmpDir, contentVars, varExtensions, skip, placeholders, maxRewriteSize)
+	if err != nil {
+		return plan, fmt.Errorf("counting variables: %w", err)
+	}
+
+	before, err := readTree(tmpDir)
+	if err != nil {
+		return plan, err
+	}
+
+	// The rewrite steps log their progress; keep the dry-run output clean
+	defer func(l *slog.Logger) { logger = l }(logger)
+	logger = slog.New(slog.DiscardHandler)
+
+	if err := applyTemplate(tmpDir, vars, true); err != nil {
+		return plan, err
+	}
+
+	after, err := readTree(tmpDir)
+	if err != nil {
+		return plan, err
+	}
+
+	for rel, data := range before {
+		if rendered, ok := after[renamedPath(rel, renameVars, cfg.RenameMap, skip, placeholders)]; ok && !bytes.Equal(data, rendered) {
+			plan.Modified = append(plan.Modified, rel)
+		}
+	}
+	sort.Strings(plan.Modified)
+
+	return plan, nil
+}
+
+// readTree returns the contents of all regular files below dir, keyed
+// by their relative path.
+func readTree(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[rel] = data
+		return nil
+	})
+	return files, err
+}
+
+// renamedPath returns the path rel ends up at after RenamePaths has
+// renamed its elements. Paths below skipped directories are not renamed.
+func renamedPath(rel string, vars, renameMap map[string]string, skip []string, placeholders rewrite.Placeholders) string {
+	if skip == nil {
+		skip = rewrite.DefaultSkipDirs
+	}
+
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		if i < len(parts)-1 && slices.Contains(skip, part) {
+			break
+		}
+		parts[i] = rewrite.NewName(part, vars, renameMap, placeholders)
+	}
+	return filepath.Join(parts...)
+}
+
+// defaultBenignEntries are the directory entries that do not make a
+// target directory count as non-empty, unless the user config sets
+// benign_entries.
+var defaultBenignEntries = []string{".git", ".DS_Store", ".idea"}
+
+// validateDirectory checks that the target directory doesn't exist or is
+// empty apart from benign entries such as .git.
 func validateDirectory(dir string) error {
 	info, err := os.Stat(dir)
 	if os.IsNotExist(err) {
@@ -447,15 +2592,44 @@ func validateDirectory(dir string) error {
 	if err != nil {
 		return fmt.Errorf("reading directory: %w", err)
 	}
-	if len(entries) > 0 {
-		return fmt.Errorf("directory %s is not empty", dir)
+	for _, e := range entries {
+		if !slices.Contains(benignEntries, e.Name()) {
+			return fmt.Errorf("directory %s is not empty (use --merge to scaffold into it)", dir)
+		}
+	}
+
+	return nil
+}
+
+// ensureParent checks that the parent of the target directory exists.
+// A missing parent is created if --parents is set.
+func ensureParent(dir string) error {
+	parent := filepath.Dir(dir)
+	info, err := os.Stat(parent)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s exists and is not a directory", parent)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("checking parent directory: %w", err)
+	}
+	if !parents {
+		return fmt.Errorf("parent directory %s does not exist (use --parents to create it)", parent)
 	}
 
+	logger.Debug("creating parent directory", "dir", parent)
+	if err := os.MkdirAll(parent, 0o750); err != nil {
+		return fmt.Errorf("creating parent directory: %w", err)
+	}
 	return nil
 }
 
-// initGitRepo initializes a git repository and creates an initial commit.
-func initGitRepo(dir string) error {
+// initGitRepo initializes a git repository and creates an initial commit
+// of files, given relative to dir, or of all files if files is nil.
+func initGitRepo(dir string, files []string) error {
+	defer logStage("git")()
 	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
 		InitOptions: git.InitOptions{
 			DefaultBranch: plumbing.NewBranchReferenceName("main"),
@@ -470,8 +2644,15 @@ func initGitRepo(dir string) error {
 		return fmt.Errorf("getting worktree: %w", err)
 	}
 
-	// Add all files
-	if err = worktree.AddGlob("."); err != nil {
+	if files == nil {
+		err = worktree.AddGlob(".")
+	}
+	for _, f := range files {
+		if _, err = worktree.Add(filepath.ToSlash(f)); err != nil {
+			break
+		}
+	}
+	if err != nil {
 		return fmt.Errorf("staging files: %w", err)
 	}
 
@@ -483,24 +2664,24 @@ func initGitRepo(dir string) error {
 		return fmt.Errorf("creating commit: %w", err)
 	}
 
-	fmt.Println("Initialized git repository with initial commit")
+	logger.Info("initialized git repository with initial commit")
 	return nil
 }
 
-// getGitAuthor reads the git author from the user's global git config.
-// Falls back to gohatch defaults if not configured.
+// getGitAuthor resolves the author of the initial commit like git does:
+// from --git-author-name/--git-author-email, then GIT_AUTHOR_NAME and
+// GIT_AUTHOR_EMAIL, then user.name and user.email of the user's global
+// git config, falling back to gohatch defaults. Name and email are
+// resolved independently.
 func getGitAuthor() *object.Signature {
-	cfg, err := config.LoadConfig(config.GlobalScope)
-	if err == nil && cfg.User.Name != "" && cfg.User.Email != "" {
-		return &object.Signature{
-			Name:  cfg.User.Name,
-			Email: cfg.User.Email,
-			When:  time.Now(),
-		}
+	var cfgName, cfgEmail string
+	if cfg, err := config.LoadConfig(config.GlobalScope); err == nil {
+		cfgName, cfgEmail = cfg.User.Name, cfg.User.Email
 	}
+
 	return &object.Signature{
-		Name:  "gohatch",
-		Email: "gohatch@localhost",
+		Name:  cmp.Or(gitAuthorName, os.Getenv("GIT_AUTHOR_NAME"), cfgName, "gohatch"),
+		Email: cmp.Or(gitAuthorEmail, os.Getenv("GIT_AUTHOR_EMAIL"), cfgEmail, "gohatch@localhost"),
 		When:  time.Now(),
 	}
 }
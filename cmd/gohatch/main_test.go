@@ -4,17 +4,50 @@
 package main
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
-
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/oliverandrich/gohatch"
+	gohatchcfg "github.com/oliverandrich/gohatch/internal/config"
+	"github.com/oliverandrich/gohatch/internal/provenance"
+	"github.com/oliverandrich/gohatch/internal/rewrite"
 	"github.com/oliverandrich/gohatch/internal/source"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
 )
 
+// TestMain keeps the tests independent of the local go toolchain, which
+// may also write telemetry data into the per-test config directories
+// while they are being removed.
+func TestMain(m *testing.M) {
+	localGoVersion = func() (string, error) { return runtime.Version(), nil }
+	// Keep CLI tests from appending to the summary of a CI job
+	_ = os.Unsetenv(stepSummaryEnv)
+	os.Exit(m.Run())
+}
+
 func TestValidateDirectory_NotExists(t *testing.T) {
 	dir := filepath.Join(t.TempDir(), "nonexistent")
 	err := validateDirectory(dir)
@@ -36,6 +69,95 @@ func TestValidateDirectory_NotEmpty(t *testing.T) {
 	assert.Contains(t, err.Error(), "not empty")
 }
 
+func TestValidateDirectory_BenignEntries(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".DS_Store"), nil, 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".idea"), 0o755))
+	assert.NoError(t, validateDirectory(dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644))
+	err := validateDirectory(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not empty")
+}
+
+func TestCLI_BenignEntries(t *testing.T) {
+	templateDir := localTemplate(t)
+
+	t.Run("scaffolds next to .git", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		dir := filepath.Join(t.TempDir(), "myapp")
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0o644))
+
+		_, err := runCLI(t, templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		assert.FileExists(t, filepath.Join(dir, "go.mod"))
+		data, err := os.ReadFile(filepath.Join(dir, ".git", "HEAD"))
+		require.NoError(t, err)
+		assert.Equal(t, "ref: refs/heads/main\n", string(data), "existing repository is kept")
+	})
+
+	t.Run("commits only the template files", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		dir := filepath.Join(t.TempDir(), "myapp")
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, ".idea"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".idea", "workspace.xml"), []byte("<project/>\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".DS_Store"), []byte("x"), 0o644))
+
+		_, err := runCLI(t, "--no-provenance", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		repo, err := git.PlainOpen(dir)
+		require.NoError(t, err)
+		head, err := repo.Head()
+		require.NoError(t, err)
+		commit, err := repo.CommitObject(head.Hash())
+		require.NoError(t, err)
+		tree, err := commit.Tree()
+		require.NoError(t, err)
+		var committed []string
+		require.NoError(t, tree.Files().ForEach(func(f *object.File) error {
+			committed = append(committed, f.Name)
+			return nil
+		}))
+		assert.ElementsMatch(t, []string{"go.mod", "main.go"}, committed)
+		assert.FileExists(t, filepath.Join(dir, ".DS_Store"))
+	})
+
+	t.Run("real content still fails", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		dir := filepath.Join(t.TempDir(), "myapp")
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("mine\n"), 0o644))
+
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is not empty")
+		assert.NoFileExists(t, filepath.Join(dir, "go.mod"))
+	})
+
+	t.Run("configured in user config", func(t *testing.T) {
+		oldBenign := benignEntries
+		t.Cleanup(func() { benignEntries = oldBenign })
+
+		xdg := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", xdg)
+		require.NoError(t, os.MkdirAll(filepath.Join(xdg, "gohatch"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(xdg, "gohatch", gohatchcfg.UserConfigFile),
+			[]byte("benign_entries = [\".keep\"]\n"), 0o600))
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0o755))
+
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is not empty")
+	})
+}
+
 func TestValidateDirectory_IsFile(t *testing.T) {
 	file := filepath.Join(t.TempDir(), "file.txt")
 	require.NoError(t, os.WriteFile(file, []byte("content"), 0o644))
@@ -72,18 +194,30 @@ func TestRunDryRun_GitSource(t *testing.T) {
 	module = "github.com/me/myapp"
 	extensions = nil
 
+	workDir := t.TempDir()
+	repo, err := git.PlainInit(workDir, false)
+	require.NoError(t, err)
+	commitTemplateFiles(t, repo, workDir, map[string]string{
+		"go.mod":  "module github.com/old/tmpl\n\ngo 1.21\n",
+		"main.go": "package main\n",
+	})
+	head, err := repo.Head()
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1.0.0", head.Hash(), nil)
+	require.NoError(t, err)
+
 	src := &source.GitSource{
-		URL:     "https://github.com/user/template",
+		URL:     "file://" + workDir,
 		Version: "v1.0.0",
 	}
 
 	output := captureOutput(func() {
-		err := runDryRun(src)
-		assert.NoError(t, err)
+		err := runDryRun(context.Background(), src)
+		assert.ErrorIs(t, err, errChangesPending)
 	})
 
 	assert.Contains(t, output, "Dry-run mode")
-	assert.Contains(t, output, "https://github.com/user/template")
+	assert.Contains(t, output, "file://"+workDir)
 	assert.Contains(t, output, "v1.0.0")
 	assert.Contains(t, output, "myapp")
 	assert.Contains(t, output, "github.com/me/myapp")
@@ -99,20 +233,41 @@ func TestRunDryRun_LocalSource(t *testing.T) {
 	module = "github.com/me/myapp"
 	extensions = nil
 
-	src := &source.LocalSource{
-		Path: "./my-template",
-	}
+	src := &source.LocalSource{Path: localTemplate(t)}
 
 	output := captureOutput(func() {
-		err := runDryRun(src)
-		assert.NoError(t, err)
+		err := runDryRun(context.Background(), src)
+		assert.ErrorIs(t, err, errChangesPending)
 	})
 
 	assert.Contains(t, output, "Dry-run mode")
-	assert.Contains(t, output, "./my-template (local)")
+	assert.Contains(t, output, src.Path+" (local)")
 	assert.Contains(t, output, "customdir")
 }
 
+func TestRunDryRun_PreviewError(t *testing.T) {
+	oldDir, oldMod, oldExt := directory, module, extensions
+	defer func() {
+		directory, module, extensions = oldDir, oldMod, oldExt
+	}()
+
+	directory = "myapp"
+	module = "github.com/me/myapp"
+	extensions = nil
+
+	src := &source.LocalSource{Path: filepath.Join(t.TempDir(), "missing")}
+
+	var err error
+	captureOutput(func() {
+		err = runDryRun(context.Background(), src)
+	})
+
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, errChangesPending)
+	assert.Contains(t, err.Error(), "previewing changes: fetching template")
+	assert.Equal(t, 1, exitCode(err))
+}
+
 func TestRunDryRun_WithExtensions(t *testing.T) {
 	oldDir, oldMod, oldExt := directory, module, extensions
 	defer func() {
@@ -123,61 +278,296 @@ func TestRunDryRun_WithExtensions(t *testing.T) {
 	module = "github.com/me/myapp"
 	extensions = []string{"toml", "yaml"}
 
-	src := &source.GitSource{
-		URL: "https://github.com/user/template",
-	}
+	src := &source.LocalSource{Path: localTemplate(t)}
 
 	output := captureOutput(func() {
-		err := runDryRun(src)
-		assert.NoError(t, err)
+		err := runDryRun(context.Background(), src)
+		assert.ErrorIs(t, err, errChangesPending)
 	})
 
 	assert.Contains(t, output, "CLI Extensions: [toml yaml]")
 	assert.Contains(t, output, "files with specified extensions")
 }
 
-func TestParseVariables_DefaultProjectName(t *testing.T) {
-	vars := parseVariables(nil, "myapp")
+// resolveCLIVariables runs resolveVariables with vars as the -v flags and
+// no other variable sources.
+func resolveCLIVariables(t *testing.T, vars []string, defaultProjectName string) map[string]string {
+	t.Helper()
+	oldVars, oldJSON, oldFiles := variables, varsJSON, setFiles
+	t.Cleanup(func() { variables, varsJSON, setFiles = oldVars, oldJSON, oldFiles })
+
+	variables, varsJSON, setFiles = vars, "", nil
+	result, err := resolveVariables(defaultProjectName)
+	require.NoError(t, err)
+	return result
+}
+
+func TestResolveVariables_DefaultProjectName(t *testing.T) {
+	vars := resolveCLIVariables(t, nil, "myapp")
 
 	assert.Equal(t, "myapp", vars["ProjectName"])
-	assert.Len(t, vars, 1)
+	assert.Len(t, vars, len(builtinVariables("")))
 }
 
-func TestParseVariables_WithVars(t *testing.T) {
+func TestResolveVariables_WithVars(t *testing.T) {
 	input := []string{"Author=Oliver Andrich", "License=MIT"}
-	vars := parseVariables(input, "myapp")
+	vars := resolveCLIVariables(t, input, "myapp")
 
 	assert.Equal(t, "myapp", vars["ProjectName"])
 	assert.Equal(t, "Oliver Andrich", vars["Author"])
 	assert.Equal(t, "MIT", vars["License"])
-	assert.Len(t, vars, 3)
+	assert.Len(t, vars, len(builtinVariables(""))+2)
 }
 
-func TestParseVariables_OverrideProjectName(t *testing.T) {
+func TestResolveVariables_OverrideProjectName(t *testing.T) {
 	input := []string{"ProjectName=CustomName"}
-	vars := parseVariables(input, "myapp")
+	vars := resolveCLIVariables(t, input, "myapp")
 
 	assert.Equal(t, "CustomName", vars["ProjectName"])
-	assert.Len(t, vars, 1)
+	assert.Len(t, vars, len(builtinVariables("")))
+}
+
+func TestResolveVariables_DateVariables(t *testing.T) {
+	oldNow := now
+	defer func() { now = oldNow }()
+	now = func() time.Time { return time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC) }
+
+	vars := resolveCLIVariables(t, nil, "myapp")
+
+	assert.Equal(t, "2025", vars["Year"])
+	assert.Equal(t, "2025-12-31", vars["Date"])
+	assert.Equal(t, "2025-12-31T23:59:59Z", vars["DateTime"])
+}
+
+func TestResolveVariables_OverrideYear(t *testing.T) {
+	oldNow := now
+	defer func() { now = oldNow }()
+	now = func() time.Time { return time.Date(2025, 3, 14, 9, 26, 53, 0, time.UTC) }
+
+	vars := resolveCLIVariables(t, []string{"Year=2030"}, "myapp")
+
+	assert.Equal(t, "2030", vars["Year"])
+	assert.Equal(t, "2025-03-14", vars["Date"])
+	assert.Equal(t, "2025-03-14T09:26:53Z", vars["DateTime"])
+}
+
+func TestResolveVariables_NamePrecedence(t *testing.T) {
+	oldName := nameFlag
+	defer func() { nameFlag = oldName }()
+
+	tests := []struct {
+		name string
+		flag string
+		vars []string
+		want string
+	}{
+		{"directory default", "", nil, "myapp"},
+		{"--name over default", "My App", nil, "My App"},
+		{"-v over --name", "My App", []string{"ProjectName=Other"}, "Other"},
+		{"-v over default", "", []string{"ProjectName=Other"}, "Other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nameFlag = tt.flag
+			vars := resolveCLIVariables(t, tt.vars, projectName(filepath.Join("out", "myapp")))
+			assert.Equal(t, tt.want, vars["ProjectName"])
+		})
+	}
+}
+
+func TestCLI_Name(t *testing.T) {
+	oldName := nameFlag
+	t.Cleanup(func() { nameFlag = oldName })
+
+	dir := filepath.Join(t.TempDir(), "myapp")
+	_, err := runCLI(t, "--no-git-init", "--name", "My App", localTemplate(t), "github.com/me/myapp", dir)
+	require.NoError(t, err)
+
+	// --name only sets ProjectName, the directory keeps its name
+	data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `const Name = "My App"`)
+}
+
+func TestExecuteScaffold_YearVariable(t *testing.T) {
+	oldSrc, oldMod, oldDir, oldVars, oldNoGit, oldNow := srcInput, module, directory, variables, noGitInit, now
+	defer func() {
+		srcInput, module, directory, variables, noGitInit, now = oldSrc, oldMod, oldDir, oldVars, oldNoGit, oldNow
+	}()
+	now = func() time.Time { return time.Date(2025, 3, 14, 9, 26, 53, 0, time.UTC) }
+
+	tests := []struct {
+		name string
+		vars []string
+		want string
+	}{
+		{"current year", nil, "Copyright (c) 2025 Someone"},
+		{"explicit override", []string{"Year=2030"}, "Copyright (c) 2030 Someone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templateDir := localTemplate(t)
+			require.NoError(t, os.WriteFile(filepath.Join(templateDir, "license.go"),
+				[]byte("// Copyright (c) __Year__ Someone\npackage main\n"), 0o644))
+
+			srcInput, module, variables, noGitInit = templateDir, "github.com/me/myapp", tt.vars, true
+			directory = filepath.Join(t.TempDir(), "myapp")
+			captureOutput(func() {
+				require.NoError(t, executeScaffold(context.Background(), &source.LocalSource{Path: templateDir}))
+			})
+
+			data, err := os.ReadFile(filepath.Join(directory, "license.go"))
+			require.NoError(t, err)
+			assert.Contains(t, string(data), tt.want)
+		})
+	}
 }
 
-func TestParseVariables_ValueWithEquals(t *testing.T) {
+func TestResolveVariables_ValueWithEquals(t *testing.T) {
 	// strings.Cut splits only on the first =, so value keeps the rest
 	input := []string{"Equation=a=b+c"}
-	vars := parseVariables(input, "myapp")
+	vars := resolveCLIVariables(t, input, "myapp")
 
 	assert.Equal(t, "a=b+c", vars["Equation"])
 }
 
-func TestParseVariables_InvalidEntry(t *testing.T) {
+func TestResolveVariables_InvalidEntry(t *testing.T) {
 	input := []string{"NoEqualsSign"}
-	vars := parseVariables(input, "myapp")
+	vars := resolveCLIVariables(t, input, "myapp")
 
-	// Should only have default ProjectName
-	assert.Len(t, vars, 1)
+	// Should only have the built-in variables
+	assert.Len(t, vars, len(builtinVariables("")))
 	assert.Equal(t, "myapp", vars["ProjectName"])
 }
 
+func TestParseJSONVariables(t *testing.T) {
+	vars, err := parseJSONVariables(`{"Author":"x","Year":2025,"Ratio":1.50,"Exp":1e3,"Big":12345678901234567890,"Debug":true,"Public":false}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"Author": "x",
+		"Year":   "2025",
+		"Ratio":  "1.5",
+		"Exp":    "1000",
+		"Big":    "12345678901234567890",
+		"Debug":  "true",
+		"Public": "false",
+	}, vars)
+}
+
+func TestParseJSONVariables_Empty(t *testing.T) {
+	vars, err := parseJSONVariables("")
+	require.NoError(t, err)
+	assert.Empty(t, vars)
+}
+
+func TestParseJSONVariables_Invalid(t *testing.T) {
+	_, err := parseJSONVariables(`{"Author":`)
+	assert.Error(t, err)
+
+	_, err = parseJSONVariables(`["x"]`)
+	assert.Error(t, err)
+
+	_, err = parseJSONVariables(`{"Tags":["a","b"]}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Tags")
+}
+
+func TestResolveVariables(t *testing.T) {
+	oldVars, oldJSON := variables, varsJSON
+	defer func() { variables, varsJSON = oldVars, oldJSON }()
+
+	varsJSON = `{"Author":"json","Year":2025,"ProjectName":"fromjson"}`
+	variables = []string{"Author=flag"}
+
+	vars, err := resolveVariables("myapp")
+	require.NoError(t, err)
+
+	// -v overrides JSON, JSON overrides the default ProjectName
+	assert.Equal(t, "flag", vars["Author"])
+	assert.Equal(t, "2025", vars["Year"])
+	assert.Equal(t, "fromjson", vars["ProjectName"])
+}
+
+func TestFileVariables(t *testing.T) {
+	dir := t.TempDir()
+	license := "MIT License\n\nCopyright (c) 2025\n"
+	licensePath := filepath.Join(dir, "LICENSE.txt")
+	require.NoError(t, os.WriteFile(licensePath, []byte(license), 0o644))
+
+	t.Run("reads file contents verbatim", func(t *testing.T) {
+		vars, err := fileVariables([]string{"License=" + licensePath})
+		require.NoError(t, err)
+		assert.Equal(t, license, vars["License"])
+	})
+
+	t.Run("rejects invalid entries", func(t *testing.T) {
+		tests := map[string]func(t *testing.T) string{
+			"expected Key=path": func(*testing.T) string { return licensePath },
+			"no such file": func(*testing.T) string {
+				return "License=" + filepath.Join(dir, "missing.txt")
+			},
+			"not a UTF-8 text file": func(t *testing.T) string {
+				path := filepath.Join(dir, "logo.png")
+				require.NoError(t, os.WriteFile(path, []byte("\x89PNG\r\n\x1a\n\x00\x00"), 0o644))
+				return "Logo=" + path
+			},
+			"too large": func(t *testing.T) string {
+				path := filepath.Join(dir, "big.txt")
+				require.NoError(t, os.WriteFile(path, bytes.Repeat([]byte("x"), maxVariableFileSize+1), 0o644))
+				return "Big=" + path
+			},
+		}
+
+		for want, entry := range tests {
+			t.Run(want, func(t *testing.T) {
+				_, err := fileVariables([]string{entry(t)})
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), want)
+			})
+		}
+	})
+}
+
+func TestExecuteScaffold_SetFile(t *testing.T) {
+	oldSetFiles := setFiles
+	defer func() { setFiles = oldSetFiles }()
+
+	license := "MIT License\n\nPermission is hereby granted.\n"
+	licensePath := filepath.Join(t.TempDir(), "LICENSE.txt")
+	require.NoError(t, os.WriteFile(licensePath, []byte(license), 0o644))
+	setFiles = []string{"License=" + licensePath}
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "LICENSE"), []byte("__License__"), 0o644))
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	oldExt := extensions
+	defer func() { extensions = oldExt }()
+	extensions = []string{"LICENSE"}
+	require.NoError(t, scaffoldInto(t, templateDir, projectDir, false))
+
+	data, err := os.ReadFile(filepath.Join(projectDir, "LICENSE"))
+	require.NoError(t, err)
+	assert.Equal(t, license, string(data))
+}
+
+func TestExecuteScaffold_VarsJSON(t *testing.T) {
+	oldJSON := varsJSON
+	t.Cleanup(func() { varsJSON = oldJSON })
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	_, err := runCLI(t, "--no-git-init", "--vars-json", `{"ProjectName":7,"Debug":true}`,
+		localTemplate(t), "github.com/me/myapp", projectDir)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(projectDir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `const Name = "7"`)
+}
+
 func TestFormatVariables(t *testing.T) {
 	vars := map[string]string{
 		"Author": "Oliver",
@@ -211,13 +601,11 @@ func TestRunDryRun_WithForce(t *testing.T) {
 	extensions = nil
 	force = true
 
-	src := &source.GitSource{
-		URL: "https://github.com/user/template",
-	}
+	src := &source.LocalSource{Path: localTemplate(t)}
 
 	output := captureOutput(func() {
-		err := runDryRun(src)
-		assert.NoError(t, err)
+		err := runDryRun(context.Background(), src)
+		assert.ErrorIs(t, err, errChangesPending)
 	})
 
 	assert.Contains(t, output, "Dry-run mode")
@@ -235,13 +623,11 @@ func TestRunDryRun_WithNoGitInit(t *testing.T) {
 	extensions = nil
 	noGitInit = true
 
-	src := &source.GitSource{
-		URL: "https://github.com/user/template",
-	}
+	src := &source.LocalSource{Path: localTemplate(t)}
 
 	output := captureOutput(func() {
-		err := runDryRun(src)
-		assert.NoError(t, err)
+		err := runDryRun(context.Background(), src)
+		assert.ErrorIs(t, err, errChangesPending)
 	})
 
 	assert.Contains(t, output, "--no-git-init")
@@ -259,115 +645,3396 @@ func TestRunDryRun_DefaultGitInit(t *testing.T) {
 	extensions = nil
 	noGitInit = false
 
-	src := &source.GitSource{
-		URL: "https://github.com/user/template",
-	}
+	src := &source.LocalSource{Path: localTemplate(t)}
 
 	output := captureOutput(func() {
-		err := runDryRun(src)
-		assert.NoError(t, err)
+		err := runDryRun(context.Background(), src)
+		assert.ErrorIs(t, err, errChangesPending)
 	})
 
 	assert.Contains(t, output, "Would initialize git repository with initial commit")
 }
 
-func TestVerboseLog_Enabled(t *testing.T) {
-	oldVerbose := verbose
-	defer func() { verbose = oldVerbose }()
+func TestRunDryRun_ExitCodes(t *testing.T) {
+	oldDir, oldMod, oldExt, oldVars := directory, module, extensions, variables
+	defer func() {
+		directory, module, extensions, variables = oldDir, oldMod, oldExt, oldVars
+	}()
+
+	directory = filepath.Join(t.TempDir(), "myapp")
+	module = "github.com/me/myapp"
+	extensions = nil
+	variables = nil
+
+	t.Run("no changes", func(t *testing.T) {
+		templateDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "go.mod"), []byte("module github.com/me/myapp\n\ngo 1.21\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "main.go"), []byte("package main\n"), 0o644))
+
+		var err error
+		output := captureOutput(func() {
+			err = runDryRun(context.Background(), &source.LocalSource{Path: templateDir})
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, exitCode(err))
+		assert.NotContains(t, output, "Would modify files:")
+		assert.NotContains(t, output, "Would rename paths:")
+	})
+
+	t.Run("pending rewrites", func(t *testing.T) {
+		templateDir := localTemplate(t)
 
-	verbose = true
+		var err error
+		output := captureOutput(func() {
+			err = runDryRun(context.Background(), &source.LocalSource{Path: templateDir})
+		})
 
-	output := captureOutput(func() {
-		verboseLog("Test message: %s", "value")
+		require.ErrorIs(t, err, errChangesPending)
+		assert.Equal(t, exitChangesPending, exitCode(err))
+		assert.Contains(t, output, "Would modify files:\n  go.mod\n  main.go\n")
+
+		// The template must be left untouched
+		data, readErr := os.ReadFile(filepath.Join(templateDir, "go.mod"))
+		require.NoError(t, readErr)
+		assert.Contains(t, string(data), "github.com/old/tmpl")
 	})
+}
 
-	assert.Contains(t, output, "  Test message: value")
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, exitCode(nil))
+	assert.Equal(t, exitChangesPending, exitCode(fmt.Errorf("wrapped: %w", errChangesPending)))
+	assert.Equal(t, 1, exitCode(errors.New("boom")))
 }
 
-func TestVerboseLog_Disabled(t *testing.T) {
-	oldVerbose := verbose
-	defer func() { verbose = oldVerbose }()
+func TestCLI_DryRunPendingChanges(t *testing.T) {
+	templateDir := localTemplate(t)
+	dir := filepath.Join(t.TempDir(), "myapp")
 
-	verbose = false
+	output, err := runCLI(t, "--dry-run", templateDir, "github.com/me/myapp", dir)
 
-	output := captureOutput(func() {
-		verboseLog("Test message: %s", "value")
+	require.ErrorIs(t, err, errChangesPending)
+	assert.Contains(t, output, "Would modify files:")
+	assert.NoDirExists(t, dir)
+}
+
+func TestCLI_DryRunVariableUsage(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "main.go"),
+		[]byte("package main\n\nconst Name = \"__ProjectName__\"\nconst Bin = \"__ProjectName__-server\"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "doc.go"),
+		[]byte("// Package main is __ProjectName__ by __Author__.\npackage main\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "README.md"), []byte("__ProjectName__\n"), 0o644))
+
+	dir := filepath.Join(t.TempDir(), "myapp")
+	output, err := runCLI(t, "--dry-run", "-v", "Author=Jane", "-v", "License=MIT", templateDir, "github.com/me/myapp", dir)
+	require.ErrorIs(t, err, errChangesPending)
+
+	assert.Contains(t, output, "Would replace variables:\n"+
+		"  Author: 1 occurrence in 1 file\n"+
+		"  License: not used\n"+
+		"  ProjectName: 3 occurrences in 2 files\n")
+	assert.NotContains(t, output, "Year:", "unused built-in variables are left out")
+
+	// The scan is read-only
+	data, err := os.ReadFile(filepath.Join(templateDir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "__ProjectName__")
+}
+
+func TestCLI_SameModule(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "app.toml"),
+		[]byte("name = \"__ProjectName__\"\nimport = \"github.com/legacy/app\"\n"), 0o644))
+
+	t.Run("variables are replaced", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "-e", "toml", templateDir, "github.com/old/tmpl", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `const Name = "myapp"`)
+		data, err = os.ReadFile(filepath.Join(dir, "app.toml"))
+		require.NoError(t, err)
+		assert.Equal(t, "name = \"myapp\"\nimport = \"github.com/legacy/app\"\n", string(data))
 	})
 
-	assert.Empty(t, output)
+	t.Run("extra files are normalized with --from-module", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "-e", "toml", "--from-module", "github.com/legacy/app", templateDir, "github.com/old/tmpl", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "app.toml"))
+		require.NoError(t, err)
+		assert.Equal(t, "name = \"myapp\"\nimport = \"github.com/old/tmpl\"\n", string(data))
+	})
 }
 
-func TestMergeExtensions_Empty(t *testing.T) {
-	result := mergeExtensions(nil, nil)
-	assert.Empty(t, result)
+func TestCLI_Confirm(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+
+	t.Run("no aborts without writing", func(t *testing.T) {
+		prompts := fakePrompts(t, "no\n", true)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		out, err := runCLI(t, "--confirm", templateDir, "github.com/me/myapp", dir)
+		require.ErrorIs(t, err, errAborted)
+
+		assert.Contains(t, out, "Would modify files:\n  go.mod\n  main.go\n")
+		assert.Equal(t, "Apply these changes? [y/N]: ", prompts.String())
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("empty answer aborts", func(t *testing.T) {
+		fakePrompts(t, "\n", true)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--confirm", templateDir, "github.com/me/myapp", dir)
+		require.ErrorIs(t, err, errAborted)
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("yes proceeds", func(t *testing.T) {
+		fakePrompts(t, "yes\n", true)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--interactive", "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "module github.com/me/myapp")
+	})
+
+	t.Run("prompts before the preview", func(t *testing.T) {
+		templateDir := localTemplate(t)
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(`render_templates = true
+
+[[variables]]
+name = "Author"
+
+[[variables]]
+name = "Token"
+secret = true
+`), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "doc.go.tmpl"), []byte("// Package main by {{.Author}}.\npackage main\n"), 0o644))
+		prompts := fakePrompts(t, "Jane\ns3cret\ny\n", true)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		out, err := runCLI(t, "--confirm", "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		// Each variable is asked for once, before the changes are shown
+		assert.Equal(t, "Author: Token: Apply these changes? [y/N]: ", prompts.String())
+		assert.Contains(t, out, "Author=Jane")
+		assert.NotContains(t, out, "s3cret")
+
+		data, err := os.ReadFile(filepath.Join(dir, "doc.go"))
+		require.NoError(t, err)
+		assert.Equal(t, "// Package main by Jane.\npackage main\n", string(data))
+	})
+
+	t.Run("failed preview aborts without asking", func(t *testing.T) {
+		prompts := fakePrompts(t, "yes\n", true)
+		broken := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(broken, "main.go"), []byte("package main\n"), 0o644))
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--confirm", broken, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "previewing changes: template has no go.mod")
+		assert.Empty(t, prompts.String())
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("applies the previewed commit", func(t *testing.T) {
+		workDir := t.TempDir()
+		repo, err := git.PlainInit(workDir, false)
+		require.NoError(t, err)
+		commitTemplateFiles(t, repo, workDir, map[string]string{
+			"go.mod":  "module github.com/old/tmpl\n\ngo 1.21\n",
+			"main.go": "package main\n",
+		})
+		head, err := repo.Head()
+		require.NoError(t, err)
+
+		fakePrompts(t, "", true)
+		// The template moves on while the user reads the preview
+		promptIn = &readHook{r: strings.NewReader("yes\n"), hook: func() {
+			commitTemplateFiles(t, repo, workDir, map[string]string{"extra.go": "package main\n"})
+		}}
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err = runCLI(t, "--confirm", "--no-git-init", "file://"+workDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "commit mismatch: expected "+head.Hash().String())
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("requires a terminal", func(t *testing.T) {
+		fakePrompts(t, "yes\n", false)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--confirm", templateDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--confirm needs a terminal")
+		assert.NoDirExists(t, dir)
+	})
 }
 
-func TestMergeExtensions_CLIOnly(t *testing.T) {
-	result := mergeExtensions([]string{"toml", "yaml"}, nil)
-	assert.Equal(t, []string{"toml", "yaml"}, result)
+func TestDefaultDirectory(t *testing.T) {
+	tests := []struct {
+		module string
+		want   string
+	}{
+		{"github.com/me/app", "app"},
+		{"github.com/me/app/v2", "app"},
+		{"github.com/me/app/v3", "app"},
+		{"gopkg.in/yaml.v3", "yaml"},
+		{"myapp", "myapp"},
+		{"github.com/me/v2app", "v2app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.module, func(t *testing.T) {
+			assert.Equal(t, tt.want, defaultDirectory(tt.module))
+		})
+	}
 }
 
-func TestMergeExtensions_ConfigOnly(t *testing.T) {
-	result := mergeExtensions(nil, []string{"toml", "yaml"})
-	assert.Equal(t, []string{"toml", "yaml"}, result)
+func TestCLI_DefaultDirectoryVersionedModule(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+	t.Chdir(t.TempDir())
+
+	_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/app/v2")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join("app", "go.mod"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "module github.com/me/app/v2")
+	assert.NoDirExists(t, "v2")
+
+	data, err = os.ReadFile(filepath.Join("app", "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `const Name = "app"`)
 }
 
-func TestMergeExtensions_Both(t *testing.T) {
-	result := mergeExtensions([]string{"md", "txt"}, []string{"toml", "yaml"})
-	// Config extensions first, then CLI extensions
-	assert.Equal(t, []string{"toml", "yaml", "md", "txt"}, result)
+func TestParentModulePath(t *testing.T) {
+	root := t.TempDir()
+	appDir := filepath.Join(root, "app")
+	require.NoError(t, os.MkdirAll(filepath.Join(appDir, "cmd"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(appDir, "go.mod"), []byte("module github.com/me/app\n\ngo 1.21\n"), 0o644))
+
+	t.Run("subdirectory", func(t *testing.T) {
+		modPath, err := parentModulePath(filepath.Join(appDir, "cmd", "tool"))
+		require.NoError(t, err)
+		assert.Equal(t, "github.com/me/app/cmd/tool", modPath)
+	})
+
+	t.Run("relative path", func(t *testing.T) {
+		t.Chdir(root)
+		modPath, err := parentModulePath("./app/cmd/tool")
+		require.NoError(t, err)
+		assert.Equal(t, "github.com/me/app/cmd/tool", modPath)
+	})
+
+	t.Run("module root", func(t *testing.T) {
+		modPath, err := parentModulePath(appDir)
+		require.NoError(t, err)
+		assert.Equal(t, "github.com/me/app", modPath)
+	})
+
+	t.Run("no parent module", func(t *testing.T) {
+		_, err := parentModulePath(filepath.Join(root, "other"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no go.mod found")
+	})
 }
 
-func TestMergeExtensions_Deduplication(t *testing.T) {
-	result := mergeExtensions([]string{"toml", "yaml"}, []string{"toml", "md"})
-	// toml should only appear once (from config)
-	assert.Equal(t, []string{"toml", "md", "yaml"}, result)
+func TestCLI_ModuleFromParent(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(templateDir, "greet"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "go.mod"), []byte("module github.com/old/tmpl\n\ngo 1.21\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "go.sum"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "main.go"),
+		[]byte("package main\n\nimport \"github.com/old/tmpl/greet\"\n\nconst Name = \"__ProjectName__\"\n\nfunc main() { greet.Hello(Name) }\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "greet", "greet.go"),
+		[]byte("package greet\n\nimport \"fmt\"\n\nfunc Hello(name string) { fmt.Println(\"hello\", name) }\n"), 0o644))
+
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll(filepath.Join("app", "cmd"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join("app", "go.mod"), []byte("module github.com/me/app\n\ngo 1.21\n"), 0o644))
+
+	_, err := runCLI(t, templateDir, "./app/cmd/tool")
+	require.NoError(t, err)
+
+	// The project joins the parent module instead of splitting it
+	assert.NoFileExists(t, filepath.Join("app", "cmd", "tool", "go.mod"))
+	assert.NoFileExists(t, filepath.Join("app", "cmd", "tool", "go.sum"))
+	assert.NoDirExists(t, filepath.Join("app", "cmd", "tool", ".git"))
+
+	data, err := os.ReadFile(filepath.Join("app", "cmd", "tool", "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"github.com/me/app/cmd/tool/greet"`)
+	assert.Contains(t, string(data), `const Name = "tool"`)
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not found")
+	}
+	build := exec.Command(goBin, "build", "./...")
+	build.Dir = "app"
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, "parent module must still build: %s", out)
 }
 
-func TestRunDryRun_WithKeepConfig(t *testing.T) {
-	oldDir, oldMod, oldExt, oldKeepConfig := directory, module, extensions, keepConfig
-	defer func() {
-		directory, module, extensions, keepConfig = oldDir, oldMod, oldExt, oldKeepConfig
-	}()
+func TestCLI_Parents(t *testing.T) {
+	templateDir := localTemplate(t)
 
-	directory = "myapp"
-	module = "github.com/me/myapp"
-	extensions = nil
-	keepConfig = true
+	t.Run("creates missing parents", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "nested", "deep", "myapp")
 
-	src := &source.GitSource{
-		URL: "https://github.com/user/template",
-	}
+		_, err := runCLI(t, "--parents", "--no-git-init", templateDir, "github.com/me/myapp", dir)
 
-	output := captureOutput(func() {
-		err := runDryRun(src)
-		assert.NoError(t, err)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "go.mod"))
 	})
 
-	assert.Contains(t, output, "--keep-config")
-	assert.NotContains(t, output, "Would remove .gohatch.toml")
+	t.Run("fails without parents", func(t *testing.T) {
+		parent := filepath.Join(t.TempDir(), "nested", "deep")
+		dir := filepath.Join(parent, "myapp")
+
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "parent directory "+parent+" does not exist")
+		assert.NoDirExists(t, parent)
+	})
 }
 
-func TestRunDryRun_ConfigRemovalMessage(t *testing.T) {
-	oldDir, oldMod, oldExt, oldKeepConfig := directory, module, extensions, keepConfig
-	defer func() {
-		directory, module, extensions, keepConfig = oldDir, oldMod, oldExt, oldKeepConfig
-	}()
+func TestCLI_Ref(t *testing.T) {
+	t.Run("rejects local templates", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--ref", "refs/pull/42/head", localTemplate(t), "github.com/me/myapp", dir)
 
-	directory = "myapp"
-	module = "github.com/me/myapp"
-	extensions = nil
-	keepConfig = false
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--ref is only supported for git templates")
+		assert.NoDirExists(t, dir)
+	})
 
-	src := &source.GitSource{
-		URL: "https://github.com/user/template",
+	t.Run("rejects version", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--ref", "refs/pull/42/head", "user/repo@v1.0.0", "github.com/me/myapp", dir)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--ref cannot be combined with @v1.0.0")
+	})
+
+	t.Run("passed to git source", func(t *testing.T) {
+		oldRef := ref
+		t.Cleanup(func() { ref = oldRef })
+		ref = "refs/pull/42/head"
+
+		src, err := source.Parse("user/repo")
+		require.NoError(t, err)
+		configureSource(src)
+		assert.Equal(t, "refs/pull/42/head", src.(*source.GitSource).Ref)
+	})
+}
+
+func TestConfigureSource_NoGoImport(t *testing.T) {
+	oldNoGoImport := noGoImport
+	t.Cleanup(func() { noGoImport = oldNoGoImport })
+
+	for _, disabled := range []bool{false, true} {
+		noGoImport = disabled
+		src, err := source.Parse("go.example.com/app")
+		require.NoError(t, err)
+		configureSource(src)
+		assert.Equal(t, disabled, src.(*source.GitSource).ImportPath == "")
 	}
+}
 
-	output := captureOutput(func() {
-		err := runDryRun(src)
-		assert.NoError(t, err)
+func TestCLI_FullHistory(t *testing.T) {
+	t.Run("clones with depth zero", func(t *testing.T) {
+		oldFullHistory, oldDepth, oldLFS := fullHistory, depth, lfs
+		t.Cleanup(func() { fullHistory, depth, lfs = oldFullHistory, oldDepth, oldLFS })
+		fullHistory, depth, lfs = true, source.DefaultDepth, true
+
+		src, err := source.Parse("user/repo")
+		require.NoError(t, err)
+		configureSource(src)
+		assert.Equal(t, 0, src.(*source.GitSource).Depth)
+		assert.True(t, src.(*source.GitSource).LFS)
 	})
 
-	assert.Contains(t, output, "Would remove .gohatch.toml")
-	assert.Contains(t, output, "Would read .gohatch.toml")
+	t.Run("rejects depth", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--full-history", "--depth", "5", "user/repo", "github.com/me/myapp", dir)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--full-history cannot be combined with --depth 5")
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("rejects local templates", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--full-history", localTemplate(t), "github.com/me/myapp", dir)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--full-history is only supported for git templates")
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("rejects lfs for local templates", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--lfs", localTemplate(t), "github.com/me/myapp", dir)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--lfs is only supported for git templates")
+		assert.NoDirExists(t, dir)
+	})
+}
+
+func TestCLI_ExpectCommit(t *testing.T) {
+	workDir := t.TempDir()
+	repo, err := git.PlainInit(workDir, false)
+	require.NoError(t, err)
+	commitTemplateFiles(t, repo, workDir, map[string]string{
+		"go.mod":  "module github.com/old/tmpl\n\ngo 1.21\n",
+		"main.go": "package main\n",
+	})
+	head, err := repo.Head()
+	require.NoError(t, err)
+	commit := head.Hash().String()
+
+	t.Run("matching commit proceeds", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--expect-commit", commit[:12], "file://"+workDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "main.go"))
+	})
+
+	t.Run("mismatch aborts and cleans up", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--expect-commit", strings.Repeat("0", 40), "file://"+workDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "commit mismatch: expected "+strings.Repeat("0", 40)+", got "+commit)
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("rejects local templates", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--expect-commit", commit, localTemplate(t), "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--expect-commit is only supported for git templates")
+	})
+
+	t.Run("rejects invalid hash", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--expect-commit", "main", "file://"+workDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid commit hash "main"`)
+	})
+}
+
+func TestCLI_Summary(t *testing.T) {
+	workDir := t.TempDir()
+	repo, err := git.PlainInit(workDir, false)
+	require.NoError(t, err)
+	commitTemplateFiles(t, repo, workDir, map[string]string{
+		"go.mod":                 "module github.com/old/tmpl\n\ngo 1.21\n",
+		"main.go":                "package main\n\nimport _ \"github.com/old/tmpl/cmd\"\n",
+		"cmd/__ProjectName__.go": "package cmd\n",
+	})
+	head, err := repo.Head()
+	require.NoError(t, err)
+
+	t.Run("summary file", func(t *testing.T) {
+		summary := filepath.Join(t.TempDir(), "summary.md")
+		require.NoError(t, os.WriteFile(summary, []byte("# Earlier step\n\n"), 0o644))
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--summary-file", summary, "file://"+workDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(summary)
+		require.NoError(t, err)
+		md := string(data)
+		assert.True(t, strings.HasPrefix(md, "# Earlier step\n\n## gohatch: github.com/me/myapp\n"), md)
+		assert.Contains(t, md, "| Commit | `"+head.Hash().String()+"` |\n")
+		assert.Contains(t, md, "| Module | `github.com/me/myapp` |\n")
+		assert.Contains(t, md, "| Files | 4 (2 rewritten) |\n")
+		assert.Contains(t, md, "### Renamed paths\n\n| From | To |\n|---|---|\n"+
+			"| `cmd/__ProjectName__.go` | `cmd/myapp.go` |\n")
+	})
+
+	t.Run("GITHUB_STEP_SUMMARY", func(t *testing.T) {
+		summary := filepath.Join(t.TempDir(), "step_summary.md")
+		t.Setenv(stepSummaryEnv, summary)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", localTemplate(t), "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(summary)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "## gohatch: github.com/me/myapp\n")
+		assert.NotContains(t, string(data), "| Commit |")
+		assert.NotContains(t, string(data), "### Renamed paths")
+	})
+
+	t.Run("not written without a path", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", localTemplate(t), "github.com/me/myapp", dir)
+		require.NoError(t, err)
+		assert.Empty(t, summaryPath())
+	})
+}
+
+func TestCLI_WorkingDir(t *testing.T) {
+	// Restores the working directory changed by -C
+	t.Chdir(t.TempDir())
+
+	workDir := t.TempDir()
+	templateDir := localTemplate(t)
+	require.NoError(t, os.Rename(templateDir, filepath.Join(workDir, "tmpl")))
+
+	_, err := runCLI(t, "--no-git-init", "-C", workDir, "-p", "./tmpl", "github.com/me/myapp", "out/myapp")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(workDir, "out", "myapp", "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"myapp"`)
+
+	t.Run("defaults output directory", func(t *testing.T) {
+		_, err := runCLI(t, "--no-git-init", "--working-dir", workDir, "./tmpl", "github.com/me/other")
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(workDir, "other", "go.mod"))
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing")
+		_, err := runCLI(t, "-C", missing, "./tmpl", "github.com/me/myapp")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "changing working directory")
+	})
+}
+
+// stubLocalGo replaces the local go version for the duration of the test.
+func stubLocalGo(t *testing.T, version string, err error) {
+	t.Helper()
+
+	old := localGoVersion
+	t.Cleanup(func() { localGoVersion = old })
+	localGoVersion = func() (string, error) { return version, err }
+}
+
+func stubRuntimeVersion(t *testing.T, version string) {
+	t.Helper()
+
+	old := runtimeVersion
+	t.Cleanup(func() { runtimeVersion = old })
+	runtimeVersion = func() string { return version }
+}
+
+func TestAutoGoVersion(t *testing.T) {
+	tests := []struct {
+		runtime string
+		want    string
+		wantErr bool
+	}{
+		{"go1.23.4", "1.23", false},
+		{"go1.24rc1", "1.24", false},
+		{"go1.21.0", "1.21", false},
+		{"devel go1.25-abcdef", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.runtime, func(t *testing.T) {
+			stubRuntimeVersion(t, tt.runtime)
+
+			got, err := autoGoVersion()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCLI_GoVersionAuto(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+
+	t.Run("uses the runtime version", func(t *testing.T) {
+		stubRuntimeVersion(t, "go1.23.4")
+		stubLocalGo(t, "go1.23.4", nil)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--go-version", "auto", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "\ngo 1.23\n")
+	})
+
+	t.Run("development builds", func(t *testing.T) {
+		stubRuntimeVersion(t, "devel go1.25-abcdef")
+
+		_, err := runCLI(t, "--no-git-init", "--go-version", "auto", templateDir, "github.com/me/myapp", filepath.Join(t.TempDir(), "myapp"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot determine the Go version")
+	})
+}
+
+func TestCLI_LocalGoCheck(t *testing.T) {
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "go.mod"), []byte("module github.com/old/tmpl\n\ngo 1.23.0\n"), 0o644))
+
+	t.Run("warns if local toolchain is older", func(t *testing.T) {
+		logs := captureLogs(t)
+		stubLocalGo(t, "go1.22.5", nil)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "go.mod"))
+		assert.Contains(t, logs.messages(slog.LevelWarn),
+			"local go toolchain is older than the template requires required=1.23.0 local=go1.22.5")
+	})
+
+	t.Run("strict fails and keeps the directory", func(t *testing.T) {
+		oldResume := resume
+		t.Cleanup(func() { resume = oldResume })
+		stubLocalGo(t, "go1.22.5", nil)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		require.NoError(t, os.Mkdir(dir, 0o755))
+		_, err := runCLI(t, "--no-git-init", "--strict-go", templateDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "template requires go 1.23.0, but the local toolchain is go1.22.5")
+		assert.DirExists(t, dir)
+		assert.True(t, provenance.IsPending(dir))
+
+		// After upgrading go, the interrupted scaffold can be resumed
+		stubLocalGo(t, "go1.24.1", nil)
+		_, err = runCLI(t, "--no-git-init", "--strict-go", "--resume", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+		assert.False(t, provenance.IsPending(dir))
+		assert.FileExists(t, filepath.Join(dir, "main.go"))
+	})
+
+	t.Run("newer toolchain passes strict", func(t *testing.T) {
+		logs := captureLogs(t)
+		stubLocalGo(t, "go1.24.1", nil)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--strict-go", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+		assert.Empty(t, logs.messages(slog.LevelWarn))
+	})
+
+	t.Run("go-version replaces the template directive", func(t *testing.T) {
+		stubLocalGo(t, "go1.22.5", nil)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--strict-go", "--go-version", "1.22", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+	})
+
+	t.Run("development builds are not compared", func(t *testing.T) {
+		stubLocalGo(t, "devel go1.25-abcdef", nil)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--strict-go", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+	})
+
+	t.Run("strict fails without go", func(t *testing.T) {
+		stubLocalGo(t, "", exec.ErrNotFound)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--strict-go", templateDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checking local go version")
+	})
+}
+
+func TestCLI_DefaultSource(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	templateDir := localTemplate(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(xdg, "gohatch"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(xdg, "gohatch", gohatchcfg.UserConfigFile),
+		[]byte(fmt.Sprintf("default_source = %q\n", templateDir)), 0o600))
+
+	t.Run("from user config", func(t *testing.T) {
+		cwd := t.TempDir()
+		t.Chdir(cwd)
+
+		_, err := runCLI(t, "--no-git-init", "github.com/me/myapp")
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(cwd, "myapp", "go.mod"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "module github.com/me/myapp")
+	})
+
+	t.Run("working directory config takes precedence", func(t *testing.T) {
+		other := localTemplate(t)
+		require.NoError(t, os.WriteFile(filepath.Join(other, "OTHER.md"), []byte("other\n"), 0o644))
+
+		cwd := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(cwd, gohatchcfg.ConfigFile),
+			[]byte(fmt.Sprintf("default_source = %q\n", other)), 0o644))
+		t.Chdir(cwd)
+
+		_, err := runCLI(t, "--no-git-init", "github.com/me/myapp")
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(cwd, "myapp", "OTHER.md"))
+	})
+
+	t.Run("omitting both shows help", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+
+		out, err := runCLI(t)
+		require.NoError(t, err)
+		assert.Contains(t, out, "USAGE:")
+	})
+}
+
+func TestCLI_MissingModuleShowsHelp(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+
+	out, err := runCLI(t, "--no-git-init", localTemplate(t))
+	require.NoError(t, err)
+	assert.Contains(t, out, "USAGE:")
+	entries, err := os.ReadDir(cwd)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCLI_Executable(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte("executable = [\"scripts/*.sh\"]\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(templateDir, "scripts"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "scripts", "__ProjectName__.sh"), []byte("#!/bin/sh\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "scripts", "README.md"), []byte("# Scripts\n"), 0o644))
+
+	dir := filepath.Join(t.TempDir(), "myapp")
+	_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+	require.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(dir, "scripts", "myapp.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm(), "patterns match the renamed path")
+
+	info, err = os.Stat(filepath.Join(dir, "scripts", "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+}
+
+func TestCLI_VariableDefaults(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "license.go"), []byte("package main\n\nconst License = \"__License|MIT__\"\n"), 0o644))
+
+	t.Run("default when unset", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "license.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `const License = "MIT"`)
+	})
+
+	t.Run("overridden when set", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--var", "License=Apache-2.0", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "license.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `const License = "Apache-2.0"`)
+	})
+}
+
+func TestCLI_NestedVariables(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "meta.go"),
+		[]byte("package main\n\nconst (\n\tRepo = \"__Repo__\"\n\tDocs = \"__Docs__\"\n)\n"), 0o644))
+
+	t.Run("one and two levels", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "-v", "Repo=github.com/me/__ProjectName__", "-v", "Docs=https://__Repo__/docs",
+			templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "meta.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `Repo = "github.com/me/myapp"`)
+		assert.Contains(t, string(data), `Docs = "https://github.com/me/myapp/docs"`)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "-v", "Repo=__Docs__", "-v", "Docs=__Repo__", templateDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cyclic variable reference: Docs -> Repo -> Docs")
+	})
+}
+
+// fakeGitConfig points the global git config at a fresh home directory
+// with the given contents and clears the GIT_AUTHOR_* variables.
+func fakeGitConfig(t *testing.T, content string) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "")
+	t.Setenv("GIT_AUTHOR_EMAIL", "")
+	if content != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(content), 0o644))
+	}
+}
+
+func TestGetGitAuthor(t *testing.T) {
+	const gitconfig = "[user]\n\tname = Config User\n\temail = config@example.com\n"
+
+	t.Run("global git config", func(t *testing.T) {
+		fakeGitConfig(t, gitconfig)
+
+		author := getGitAuthor()
+		assert.Equal(t, "Config User", author.Name)
+		assert.Equal(t, "config@example.com", author.Email)
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		fakeGitConfig(t, "")
+
+		author := getGitAuthor()
+		assert.Equal(t, "gohatch", author.Name)
+		assert.Equal(t, "gohatch@localhost", author.Email)
+	})
+
+	t.Run("environment overrides config", func(t *testing.T) {
+		fakeGitConfig(t, gitconfig)
+		t.Setenv("GIT_AUTHOR_NAME", "Env User")
+
+		author := getGitAuthor()
+		assert.Equal(t, "Env User", author.Name)
+		assert.Equal(t, "config@example.com", author.Email, "name and email are resolved independently")
+	})
+
+	t.Run("flags override environment", func(t *testing.T) {
+		fakeGitConfig(t, gitconfig)
+		t.Setenv("GIT_AUTHOR_NAME", "Env User")
+		t.Setenv("GIT_AUTHOR_EMAIL", "env@example.com")
+		gitAuthorName, gitAuthorEmail = "Flag User", "flag@example.com"
+		t.Cleanup(func() { gitAuthorName, gitAuthorEmail = "", "" })
+
+		author := getGitAuthor()
+		assert.Equal(t, "Flag User", author.Name)
+		assert.Equal(t, "flag@example.com", author.Email)
+	})
+}
+
+func TestCLI_GitAuthor(t *testing.T) {
+	fakeGitConfig(t, "[user]\n\tname = Config User\n\temail = config@example.com\n")
+	templateDir := localTemplate(t)
+
+	author := func(t *testing.T, args ...string) object.Signature {
+		t.Helper()
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, append(args, templateDir, "github.com/me/myapp", dir)...)
+		require.NoError(t, err)
+
+		repo, err := git.PlainOpen(dir)
+		require.NoError(t, err)
+		head, err := repo.Head()
+		require.NoError(t, err)
+		commit, err := repo.CommitObject(head.Hash())
+		require.NoError(t, err)
+		return commit.Author
+	}
+
+	t.Run("global git config", func(t *testing.T) {
+		got := author(t)
+		assert.Equal(t, "Config User", got.Name)
+		assert.Equal(t, "config@example.com", got.Email)
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		got := author(t, "--git-author-name", "Flag User", "--git-author-email", "flag@example.com")
+		assert.Equal(t, "Flag User", got.Name)
+		assert.Equal(t, "flag@example.com", got.Email)
+	})
+}
+
+func TestCLI_MaxFiles(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+
+	t.Run("exceeding the limit fails", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--max-files", "1", templateDir, "github.com/me/myapp", dir)
+		require.ErrorIs(t, err, source.ErrTooManyFiles)
+		assert.Contains(t, err.Error(), "--respect-gitignore")
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("dry-run previews with the limit", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--dry-run", "--max-files", "1", templateDir, "github.com/me/myapp", dir)
+		require.ErrorIs(t, err, source.ErrTooManyFiles)
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("under the limit proceeds", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--max-files", "10", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "go.mod"))
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		_, err := runCLI(t, "--max-files", "-1", templateDir, "github.com/me/myapp", filepath.Join(t.TempDir(), "myapp"))
+		require.EqualError(t, err, "--max-files must not be negative")
+	})
+}
+
+func TestCLI_VariableFiles(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	t.Run("ignore files by default", func(t *testing.T) {
+		templateDir := localTemplate(t)
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, ".gitignore"), []byte("__ProjectName__/bin\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, ".dockerignore"), []byte("__ProjectName__\n"), 0o644))
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+		require.NoError(t, err)
+		assert.Equal(t, "myapp/bin\n", string(data))
+
+		data, err = os.ReadFile(filepath.Join(dir, ".dockerignore"))
+		require.NoError(t, err)
+		assert.Equal(t, "myapp\n", string(data))
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		templateDir := localTemplate(t)
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte("variable_files = [\".npmignore\"]\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, ".gitignore"), []byte("__ProjectName__/bin\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, ".npmignore"), []byte("__ProjectName__/dist\n"), 0o644))
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+		require.NoError(t, err)
+		assert.Equal(t, "__ProjectName__/bin\n", string(data), "the configured list replaces the default")
+
+		data, err = os.ReadFile(filepath.Join(dir, ".npmignore"))
+		require.NoError(t, err)
+		assert.Equal(t, "myapp/dist\n", string(data))
+	})
+}
+
+func TestCLI_Replace(t *testing.T) {
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "main.go"),
+		[]byte("package main\n\nconst Company = \"Acme\"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "app.toml"), []byte("owner = \"Acme\"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "README.md"), []byte("Acme\n"), 0o644))
+	logo := []byte("\x89PNG\r\n\x1a\n\x00Acme")
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "logo.toml"), logo, 0o644))
+
+	dir := filepath.Join(t.TempDir(), "myapp")
+	_, err := runCLI(t, "--no-git-init", "-e", "toml", "--replace", "Acme=Initech", templateDir, "github.com/me/myapp", dir)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `const Company = "Initech"`)
+
+	data, err = os.ReadFile(filepath.Join(dir, "app.toml"))
+	require.NoError(t, err)
+	assert.Equal(t, "owner = \"Initech\"\n", string(data))
+
+	data, err = os.ReadFile(filepath.Join(dir, "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "Acme\n", string(data), "files outside the rewrite set are left alone")
+
+	data, err = os.ReadFile(filepath.Join(dir, "logo.toml"))
+	require.NoError(t, err)
+	assert.Equal(t, logo, data, "binary files are left alone")
+
+	t.Run("invalid", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--replace", "Acme", templateDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `--replace: invalid replacement "Acme": expected OLD=NEW`)
+		assert.NoDirExists(t, dir)
+	})
+}
+
+func TestCLI_Netrc(t *testing.T) {
+	t.Setenv("NETRC", "/home/me/.netrc")
+
+	oldNoNetrc := noNetrc
+	t.Cleanup(func() { noNetrc = oldNoNetrc })
+
+	for _, tt := range []struct {
+		noNetrc bool
+		want    string
+	}{
+		{false, "/home/me/.netrc"},
+		{true, ""},
+	} {
+		src, err := source.Parse("user/repo")
+		require.NoError(t, err)
+
+		noNetrc = tt.noNetrc
+		configureSource(src)
+		assert.Equal(t, tt.want, src.(*source.GitSource).Netrc, "noNetrc=%v", tt.noNetrc)
+	}
+}
+
+func TestCLI_PrintConfig(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	require.NoError(t, os.MkdirAll(filepath.Join(xdg, "gohatch"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(xdg, "gohatch", gohatchcfg.UserConfigFile),
+		[]byte("extensions = [\"yaml\"]\ndefault_host = \"codeberg.org\"\n"), 0o600))
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(`extensions = ["toml"]
+skip_dirs = ["testdata"]
+
+[[variables]]
+name = "Author"
+default = "Template"
+
+[[variables]]
+name = "License"
+default = "MIT"
+
+[[variables]]
+name = "APIKey"
+default = "hidden"
+secret = true
+`), 0o644))
+
+	dir := filepath.Join(t.TempDir(), "myapp")
+	out, err := runCLI(t, "--print-config", "--host", "example.org", "-e", "md", "-v", "Author=Cli",
+		templateDir, "github.com/me/myapp", dir)
+	require.NoError(t, err)
+
+	var eff effectiveConfig
+	_, err = toml.Decode(out, &eff)
+	require.NoError(t, err, out)
+
+	// CLI flags win over the user config, variables over template defaults
+	assert.Equal(t, "example.org", eff.Host)
+	assert.Equal(t, "Cli", eff.Variables["Author"])
+	assert.Equal(t, "MIT", eff.Variables["License"])
+	assert.Equal(t, "myapp", eff.Variables["ProjectName"])
+	assert.NotContains(t, eff.Variables, "APIKey")
+
+	// Extensions from all three sources are merged
+	assert.Equal(t, []string{"toml", "yaml", "md"}, eff.Extensions)
+	assert.Equal(t, eff.Extensions, eff.ModuleExts)
+	assert.Equal(t, eff.Extensions, eff.VarExts)
+	assert.Equal(t, []string{"testdata"}, eff.SkipDirs)
+
+	// Nothing is scaffolded
+	assert.NoDirExists(t, dir)
+
+	// The template config is overridden by CLI skip dirs
+	out, err = runCLI(t, "--print-config", "--skip-dir", "fixtures", templateDir, "github.com/me/myapp", dir)
+	require.NoError(t, err)
+	eff = effectiveConfig{}
+	_, err = toml.Decode(out, &eff)
+	require.NoError(t, err, out)
+	assert.Equal(t, []string{"fixtures"}, eff.SkipDirs)
+	assert.Equal(t, "codeberg.org", eff.Host)
+}
+
+func TestCLI_ShowTemplateConfig(t *testing.T) {
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(`version = 1
+extensions = ["toml", "yaml"]
+skip_dirs = ["testdata"]
+
+[[variables]]
+name = "License"
+default = "MIT"
+`), 0o644))
+	want, err := gohatchcfg.Load(templateDir)
+	require.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "myapp")
+	out, err := runCLI(t, "--no-git-init", "--show-template-config", "-v", "License=EUPL", templateDir, "github.com/me/myapp", dir)
+	require.NoError(t, err)
+
+	header := "# Template config (" + gohatchcfg.ConfigFile + ")\n"
+	require.Contains(t, out, header)
+	var got gohatchcfg.Config
+	_, err = toml.Decode(out[strings.Index(out, header):], &got)
+	require.NoError(t, err, out)
+	assert.Equal(t, *want, got)
+	assert.Equal(t, 1, got.Version)
+	assert.Equal(t, []string{"toml", "yaml"}, got.Extensions)
+
+	// Scaffolding continues normally
+	assert.FileExists(t, filepath.Join(dir, "go.mod"))
+
+	t.Run("without config", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "plain")
+		out, err := runCLI(t, "--no-git-init", "--show-template-config", localTemplate(t), "github.com/me/plain", dir)
+		require.NoError(t, err)
+		assert.Contains(t, out, "# Template config (no "+gohatchcfg.ConfigFile+", defaults)")
+		assert.FileExists(t, filepath.Join(dir, "go.mod"))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		out, err := runCLI(t, "--dry-run", "--show-template-config", "-v", "License=EUPL", templateDir, "github.com/me/myapp", dir)
+		require.ErrorIs(t, err, errChangesPending)
+		assert.Equal(t, 1, strings.Count(out, header))
+		assert.NoDirExists(t, dir)
+	})
+}
+
+func TestCLI_Include(t *testing.T) {
+	templateDir := localTemplate(t)
+	for _, f := range []string{"cmd/app/main.go", "internal/app/app.go", "README.md"} {
+		path := filepath.Join(templateDir, filepath.FromSlash(f))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte("package app\n"), 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile),
+		[]byte(`include = ["cmd/**", "go.mod"]`+"\n"), 0o644))
+
+	t.Run("from config", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		assert.FileExists(t, filepath.Join(dir, "go.mod"))
+		assert.FileExists(t, filepath.Join(dir, "cmd", "app", "main.go"))
+		assert.NoFileExists(t, filepath.Join(dir, "main.go"))
+		assert.NoFileExists(t, filepath.Join(dir, "README.md"))
+		assert.NoDirExists(t, filepath.Join(dir, "internal"))
+	})
+
+	t.Run("flag replaces config", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--include", "internal/**", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		assert.FileExists(t, filepath.Join(dir, "go.mod"))
+		assert.FileExists(t, filepath.Join(dir, "internal", "app", "app.go"))
+		assert.NoDirExists(t, filepath.Join(dir, "cmd"))
+		assert.NoFileExists(t, filepath.Join(dir, "main.go"))
+	})
+}
+
+func TestCLI_RenderTemplates(t *testing.T) {
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte("render_templates = true\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(templateDir, "cmd"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "cmd", "main.go.tmpl"), []byte(`package main
+
+import "github.com/old/tmpl/internal/app"
+
+func main() {
+{{- if eq .Database "postgres"}}
+	app.Connect("postgres://localhost/{{.ProjectName}}")
+{{- end}}
+{{- range split .Commands ","}}
+	app.Register("{{.}}")
+{{- end}}
+}
+`), 0o644))
+
+	dir := filepath.Join(t.TempDir(), "myapp")
+	_, err := runCLI(t, "--no-git-init", "-v", "Database=postgres", "--vars-json", `{"Commands": "serve,migrate"}`,
+		templateDir, "github.com/me/myapp", dir)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "cmd", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, `package main
+
+import "github.com/me/myapp/internal/app"
+
+func main() {
+	app.Connect("postgres://localhost/myapp")
+	app.Register("serve")
+	app.Register("migrate")
+}
+`, string(data))
+	assert.NoFileExists(t, filepath.Join(dir, "cmd", "main.go.tmpl"))
+}
+
+func TestCLI_TextExtensions(t *testing.T) {
+	templateDir := localTemplate(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(templateDir, "gen"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "gen", "handler.go.tmpl"),
+		[]byte("package {{.Package}}\n\nimport \"github.com/old/tmpl/internal/app\"\n"), 0o644))
+
+	dir := filepath.Join(t.TempDir(), "myapp")
+	_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+	require.NoError(t, err)
+
+	// The code generation template keeps its actions but gets the new module
+	data, err := os.ReadFile(filepath.Join(dir, "gen", "handler.go.tmpl"))
+	require.NoError(t, err)
+	assert.Equal(t, "package {{.Package}}\n\nimport \"github.com/me/myapp/internal/app\"\n", string(data))
+}
+
+func TestCLI_RenderTemplatesOptIn(t *testing.T) {
+	codegen := "package gen\n\n// {{.Module}} {{.Anything}}\n"
+
+	t.Run("disabled by default", func(t *testing.T) {
+		templateDir := localTemplate(t)
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "handler.go.tmpl"), []byte(codegen), 0o644))
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "handler.go.tmpl"))
+		require.NoError(t, err)
+		assert.Equal(t, codegen, string(data))
+	})
+
+	t.Run("text_extensions are left alone", func(t *testing.T) {
+		templateDir := localTemplate(t)
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile),
+			[]byte("render_templates = true\ntext_extensions = [\"go.tmpl\"]\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "handler.go.tmpl"), []byte(codegen), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "NOTES.md.tmpl"), []byte("# {{.ProjectName}}\n"), 0o644))
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "handler.go.tmpl"))
+		require.NoError(t, err)
+		assert.Equal(t, codegen, string(data))
+
+		data, err = os.ReadFile(filepath.Join(dir, "NOTES.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "# myapp\n", string(data))
+	})
+}
+
+func TestCLI_NamedTemplate(t *testing.T) {
+	root := t.TempDir()
+	templateDir := filepath.Join(root, "mytemplate")
+	require.NoError(t, os.CopyFS(templateDir, os.DirFS(localTemplate(t))))
+
+	t.Run("resolves against template dir", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--template-dir", root, "@mytemplate", "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "module github.com/me/myapp")
+	})
+
+	t.Run("defaults to home directory", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		require.NoError(t, os.CopyFS(filepath.Join(home, ".gohatch", "templates", "mytemplate"), os.DirFS(templateDir)))
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "@mytemplate", "github.com/me/myapp", dir)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "main.go"))
+	})
+
+	t.Run("missing template", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--template-dir", root, "@other", "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `template "other" not found in `+root)
+		assert.NoDirExists(t, dir)
+	})
+}
+
+func TestCLI_DropsSelfRequire(t *testing.T) {
+	logs := captureLogs(t)
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "go.mod"),
+		[]byte("module github.com/old/tmpl\n\ngo 1.21\n\nrequire github.com/old/tmpl v0.0.0\n"), 0o644))
+
+	dir := filepath.Join(t.TempDir(), "myapp")
+	_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "require")
+	assert.Contains(t, logs.messages(slog.LevelInfo), "dropping self-require file=go.mod")
+}
+
+func TestCLI_Toolchain(t *testing.T) {
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "go.mod"),
+		[]byte("module github.com/old/tmpl\n\ngo 1.21\n\ntoolchain go1.21.3\n"), 0o644))
+
+	t.Run("sets toolchain", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--go-version", "1.23.0", "--toolchain", "go1.23.4", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		require.NoError(t, err)
+		assert.Equal(t, "module github.com/me/myapp\n\ngo 1.23.0\n\ntoolchain go1.23.4\n", string(data))
+	})
+
+	t.Run("removes toolchain", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--toolchain", "none", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		require.NoError(t, err)
+		assert.Equal(t, "module github.com/me/myapp\n\ngo 1.21\n", string(data))
+	})
+
+	t.Run("rejects invalid toolchain", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--toolchain", "1.23.4", templateDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid toolchain")
+		assert.NoDirExists(t, dir)
+	})
+}
+
+func TestCLI_Rewriters(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "app.json"),
+		[]byte(`{"module": "github.com/old/tmpl", "author": "__Author__"}`+"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(`[[rewriters]]
+pattern = "*.json"
+command = ["sh", "-c", "sed -e \"s|$OLD_MODULE|$NEW_MODULE|\" -e \"s|__Author__|$GOHATCH_VAR_Author|\""]
+`), 0o644))
+
+	t.Run("requires allow-exec", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", templateDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "use --allow-exec")
+	})
+
+	t.Run("runs command", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--allow-exec", "-v", "Author=Jane", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "app.json"))
+		require.NoError(t, err)
+		assert.Equal(t, `{"module": "github.com/me/myapp", "author": "Jane"}`+"\n", string(data))
+	})
+
+	t.Run("dry-run lists commands", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "ran")
+		templateDir := localTemplate(t)
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "app.json"), []byte("{}\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(`[[rewriters]]
+pattern = "*.json"
+command = ["sh", "-c", "touch `+marker+`; cat"]
+`), 0o644))
+
+		out, err := runCLI(t, "--dry-run", templateDir, "github.com/me/myapp", filepath.Join(t.TempDir(), "myapp"))
+		require.ErrorIs(t, err, errChangesPending)
+		assert.Contains(t, out, "Would run sh -c touch "+marker+"; cat on *.json (requires --allow-exec).")
+		assert.NoFileExists(t, marker)
+	})
+
+	t.Run("runs relative commands from the template root", func(t *testing.T) {
+		templateDir := localTemplate(t)
+		require.NoError(t, os.MkdirAll(filepath.Join(templateDir, "tools"), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(templateDir, "web"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "tools", "upper.sh"), []byte("#!/bin/sh\ntr a-z A-Z\n"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "app.json"), []byte("{\"a\": 1}\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "web", "app.json"), []byte("{\"b\": 2}\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(`[[rewriters]]
+pattern = "*.json"
+command = ["./tools/upper.sh"]
+`), 0o644))
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--allow-exec", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		for name, want := range map[string]string{"app.json": "{\"A\": 1}\n", "web/app.json": "{\"B\": 2}\n"} {
+			data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			require.NoError(t, err)
+			assert.Equal(t, want, string(data), name)
+		}
+	})
+}
+
+func TestWarnConfusableModule(t *testing.T) {
+	logs := captureLogs(t)
+
+	warnConfusableModule("github.com/me/myapp")
+	assert.Empty(t, logs.messages(slog.LevelWarn))
+
+	// Cyrillic а (U+0430) instead of a Latin a
+	warnConfusableModule("github.com/me/my\u0430pp")
+	assert.Equal(t, []string{`module path contains non-ASCII characters module=github.com/me/myаpp chars='а' (U+0430 Cyrillic)`},
+		logs.messages(slog.LevelWarn))
+}
+
+func TestCLI_PassExtensions(t *testing.T) {
+	templateDir := localTemplate(t)
+	content := "module: github.com/old/tmpl\nname: __ProjectName__\n"
+	for _, name := range []string{"README.md", "config.toml"} {
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, name), []byte(content), 0o644))
+	}
+
+	scaffold := func(t *testing.T, args ...string) (readme, config string) {
+		t.Helper()
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, append(args, "--no-git-init", templateDir, "github.com/me/myapp", dir)...)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "README.md"))
+		require.NoError(t, err)
+		readme = string(data)
+		data, err = os.ReadFile(filepath.Join(dir, "config.toml"))
+		require.NoError(t, err)
+		return readme, string(data)
+	}
+
+	t.Run("separate passes", func(t *testing.T) {
+		readme, config := scaffold(t, "--var-ext", "md", "--module-ext", "toml")
+		assert.Equal(t, "module: github.com/old/tmpl\nname: myapp\n", readme)
+		assert.Equal(t, "module: github.com/me/myapp\nname: __ProjectName__\n", config)
+	})
+
+	t.Run("falls back to extension", func(t *testing.T) {
+		readme, config := scaffold(t, "-e", "md", "--module-ext", "toml")
+		assert.Equal(t, "module: github.com/old/tmpl\nname: myapp\n", readme)
+		assert.Equal(t, "module: github.com/me/myapp\nname: __ProjectName__\n", config)
+
+		readme, config = scaffold(t, "-e", "md,toml")
+		assert.Equal(t, "module: github.com/me/myapp\nname: myapp\n", readme)
+		assert.Equal(t, "module: github.com/me/myapp\nname: myapp\n", config)
+	})
+}
+
+func TestCLI_OutputName(t *testing.T) {
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile),
+		[]byte(`output_name = "__Slug__-service"`+"\n"), 0o644))
+
+	t.Run("names the default directory", func(t *testing.T) {
+		cwd := t.TempDir()
+		t.Chdir(cwd)
+
+		_, err := runCLI(t, "--no-git-init", "-v", "Slug=billing", templateDir, "github.com/me/myapp")
+
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(cwd, "billing-service", "go.mod"))
+		assert.NoDirExists(t, filepath.Join(cwd, "myapp"))
+	})
+
+	t.Run("explicit directory wins", func(t *testing.T) {
+		cwd := t.TempDir()
+		t.Chdir(cwd)
+
+		_, err := runCLI(t, "--no-git-init", "-v", "Slug=billing", templateDir, "github.com/me/myapp", "custom")
+
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(cwd, "custom", "go.mod"))
+		assert.NoDirExists(t, filepath.Join(cwd, "billing-service"))
+	})
+
+	t.Run("target must be empty", func(t *testing.T) {
+		cwd := t.TempDir()
+		t.Chdir(cwd)
+		require.NoError(t, os.MkdirAll(filepath.Join(cwd, "billing-service", "docs"), 0o755))
+
+		_, err := runCLI(t, "--no-git-init", "-v", "Slug=billing", templateDir, "github.com/me/myapp")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "billing-service is not empty")
+	})
+}
+
+// logRecorder is a slog.Handler that records all log records.
+type logRecorder struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *logRecorder) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *logRecorder) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *logRecorder) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *logRecorder) WithGroup(string) slog.Handler { return h }
+
+// messages returns the recorded messages at or above level,
+// each followed by its attributes as key=value.
+func (h *logRecorder) messages(level slog.Level) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var msgs []string
+	for _, r := range h.records {
+		if r.Level < level {
+			continue
+		}
+		msg := r.Message
+		r.Attrs(func(a slog.Attr) bool {
+			msg += " " + a.String()
+			return true
+		})
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// captureLogs replaces the package logger with a recorder for the test.
+func captureLogs(t *testing.T) *logRecorder {
+	t.Helper()
+
+	old := logger
+	t.Cleanup(func() { logger = old })
+
+	rec := &logRecorder{}
+	logger = slog.New(rec)
+	return rec
+}
+
+func TestLogLevel(t *testing.T) {
+	level, err := logLevel(false, false)
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelInfo, level)
+
+	level, err = logLevel(false, true)
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	level, err = logLevel(true, false)
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+
+	_, err = logLevel(true, true)
+	assert.Error(t, err)
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, slog.LevelInfo)
+
+	l.Debug("hidden")
+	l.Info("rewriting module", "from", "github.com/old/tmpl", "to", "github.com/me/myapp")
+
+	l.Warn("template has no go.mod")
+
+	assert.Equal(t, "rewriting module (from github.com/old/tmpl, to github.com/me/myapp)\nwarning: template has no go.mod\n", buf.String())
+
+	// Debug output uses key=value pairs
+	buf.Reset()
+	l = newLogger(&buf, slog.LevelDebug)
+	l.Debug("renamed", "file", "main.go")
+	l.Info("rewriting module", "from", "github.com/old/tmpl", "to", "github.com/me/myapp")
+
+	assert.NotContains(t, buf.String(), "time=")
+	assert.Contains(t, buf.String(), `level=DEBUG msg=renamed file=main.go`)
+	assert.Contains(t, buf.String(), `level=INFO msg="rewriting module" from=github.com/old/tmpl to=github.com/me/myapp`)
+}
+
+// fakeColorTerminal makes stdout and stderr look like a color terminal.
+func fakeColorTerminal(t *testing.T) {
+	t.Helper()
+
+	oldIsTerminal, oldColor := isTerminal, colorOutput
+	t.Cleanup(func() { isTerminal, colorOutput = oldIsTerminal, oldColor })
+
+	isTerminal = func(int) bool { return true }
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm-256color")
+}
+
+func TestUseColor(t *testing.T) {
+	fakeColorTerminal(t)
+
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	assert.True(t, useColor(false, env(nil)))
+	assert.False(t, useColor(true, env(nil)))
+	assert.False(t, useColor(false, env(map[string]string{"NO_COLOR": "1"})))
+	assert.False(t, useColor(false, env(map[string]string{"TERM": "dumb"})))
+
+	isTerminal = func(int) bool { return false }
+	assert.False(t, useColor(false, env(nil)))
+}
+
+func TestNewLogger_Color(t *testing.T) {
+	fakeColorTerminal(t)
+
+	var buf bytes.Buffer
+	l := newLogger(&buf, slog.LevelInfo)
+
+	colorOutput = true
+	l.Warn("template has no go.mod")
+	l.Log(context.Background(), levelSuccess, "created project")
+	l.Info("rewriting module")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, ansiYellow+"warning: template has no go.mod"+ansiReset, lines[0])
+	assert.Equal(t, ansiGreen+"created project"+ansiReset, lines[1])
+	assert.Equal(t, "rewriting module", lines[2])
+
+	buf.Reset()
+	colorOutput = false
+	l.Warn("template has no go.mod")
+	l.Error("failed")
+	assert.NotContains(t, buf.String(), "\x1b")
+}
+
+func TestCLI_NoColor(t *testing.T) {
+	fakeColorTerminal(t)
+
+	old := logger
+	t.Cleanup(func() { logger = old })
+
+	run := func(t *testing.T, args ...string) string {
+		t.Helper()
+
+		var buf bytes.Buffer
+		logger = newLogger(&buf, logLevelVar)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		out, err := runCLI(t, append(args, "--no-git-init", localTemplate(t), "github.com/me/myapp", dir)...)
+		require.NoError(t, err)
+		return buf.String() + out
+	}
+
+	assert.Contains(t, run(t), ansiGreen+"created project (dir ")
+
+	out := run(t, "--no-color")
+	assert.Contains(t, out, "created project (dir ")
+	assert.NotContains(t, out, "\x1b")
+
+	t.Setenv("NO_COLOR", "1")
+	assert.NotContains(t, run(t), "\x1b")
+}
+
+// jsonEvents parses the --json-logs output into one map per line.
+func jsonEvents(t *testing.T, out string) []map[string]any {
+	t.Helper()
+
+	var events []map[string]any
+	for line := range strings.Lines(out) {
+		var event map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &event), "invalid JSON line %q", line)
+		for _, key := range []string{"time", "level", "msg", "event"} {
+			require.Contains(t, event, key, "line %q", line)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// findEvent returns the first event of the given type matching the
+// given fields.
+func findEvent(events []map[string]any, event string, fields map[string]any) map[string]any {
+	for _, e := range events {
+		if e["event"] != event {
+			continue
+		}
+		match := true
+		for k, v := range fields {
+			if e[k] != v {
+				match = false
+			}
+		}
+		if match {
+			return e
+		}
+	}
+	return nil
+}
+
+func TestCLI_SecretVariablesNotLogged(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	oldLogger, oldOutput, oldLevel := logger, logOutput, logLevelVar.Level()
+	t.Cleanup(func() {
+		logger, logOutput, jsonLogs = oldLogger, oldOutput, false
+		logLevelVar.Set(oldLevel)
+	})
+	var buf bytes.Buffer
+	logOutput = &buf
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "key.go"), []byte("package main\n\nconst Key = \"__APIKey__\"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(`[[variables]]
+name = "APIKey"
+secret = true
+`), 0o644))
+
+	for _, args := range [][]string{{"--verbose"}, {"--json-logs"}, {"--json-logs", "--verbose"}} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			buf.Reset()
+			logger = newLogger(logOutput, logLevelVar)
+
+			dir := filepath.Join(t.TempDir(), "myapp")
+			args := append(args, "--no-git-init", "-v", "APIKey=s3cr3t-value", templateDir, "github.com/me/myapp", dir)
+			_, err := runCLI(t, args...)
+			require.NoError(t, err)
+
+			data, err := os.ReadFile(filepath.Join(dir, "key.go"))
+			require.NoError(t, err)
+			assert.Contains(t, string(data), "s3cr3t-value")
+
+			assert.Contains(t, buf.String(), "APIKey="+redactedValue)
+			assert.NotContains(t, buf.String(), "s3cr3t-value")
+		})
+	}
+}
+
+func TestCLI_JSONLogs(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	oldLogger, oldOutput, oldLevel := logger, logOutput, logLevelVar.Level()
+	t.Cleanup(func() {
+		logger, logOutput, jsonLogs = oldLogger, oldOutput, false
+		logLevelVar.Set(oldLevel)
+	})
+	var buf bytes.Buffer
+	logOutput = &buf
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.Mkdir(filepath.Join(templateDir, "cmd"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "cmd", "__ProjectName__.go"), []byte("package cmd\n"), 0o644))
+
+	t.Run("events", func(t *testing.T) {
+		buf.Reset()
+		dir := filepath.Join(t.TempDir(), "myapp")
+		out, err := runCLI(t, "--json-logs", "--verbose", templateDir, "github.com/me/myäpp", dir)
+		require.NoError(t, err)
+		assert.NotContains(t, out, `"event"`, "stdout carries no log events")
+
+		events := jsonEvents(t, buf.String())
+		for _, stage := range []string{"fetch", "rewrite", "git"} {
+			assert.NotNil(t, findEvent(events, eventStageStart, map[string]any{"stage": stage, "level": "DEBUG"}), "missing start of %s", stage)
+			assert.NotNil(t, findEvent(events, eventStageEnd, map[string]any{"stage": stage}), "missing end of %s", stage)
+		}
+		assert.NotNil(t, findEvent(events, eventFile, map[string]any{"msg": "rewritten", "file": "go.mod"}))
+		assert.NotNil(t, findEvent(events, eventRename, map[string]any{"msg": "renamed", "path": "cmd/__ProjectName__.go → cmd/myapp.go"}))
+		assert.NotNil(t, findEvent(events, eventWarning, map[string]any{"level": "WARN", "module": "github.com/me/myäpp"}))
+		assert.NotNil(t, findEvent(events, eventMessage, map[string]any{"msg": "fetching template"}))
+		assert.NotNil(t, findEvent(events, eventDone, map[string]any{"level": "INFO", "dir": dir}))
+	})
+
+	t.Run("default level", func(t *testing.T) {
+		buf.Reset()
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--json-logs", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		events := jsonEvents(t, buf.String())
+		assert.Nil(t, findEvent(events, eventStageStart, nil), "debug events need --verbose")
+		assert.Nil(t, findEvent(events, eventFile, nil))
+		assert.NotNil(t, findEvent(events, eventMessage, map[string]any{"msg": "fetching template"}))
+		assert.NotNil(t, findEvent(events, eventDone, map[string]any{"level": "INFO", "dir": dir}))
+	})
+
+	t.Run("quiet", func(t *testing.T) {
+		buf.Reset()
+		_, err := runCLI(t, "--json-logs", "--quiet", templateDir, "github.com/me/myapp", filepath.Join(t.TempDir(), "myapp"))
+		require.NoError(t, err)
+		assert.Nil(t, findEvent(jsonEvents(t, buf.String()), eventMessage, nil))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		buf.Reset()
+		_, err := runCLI(t, "--json-logs", filepath.Join(t.TempDir(), "missing"), "github.com/me/myapp", filepath.Join(t.TempDir(), "myapp"))
+		require.Error(t, err)
+		reportError(err)
+
+		events := jsonEvents(t, buf.String())
+		assert.NotNil(t, findEvent(events, eventError, map[string]any{"level": "ERROR", "msg": err.Error()}))
+	})
+}
+
+func TestExecuteScaffold_LogsEvents(t *testing.T) {
+	logs := captureLogs(t)
+
+	_, err := runCLI(t, "--no-git-init", localTemplate(t), "github.com/me/myapp", filepath.Join(t.TempDir(), "myapp"))
+	require.NoError(t, err)
+
+	info := logs.messages(slog.LevelInfo)
+	assert.Contains(t, info, "rewriting module from=github.com/old/tmpl to=github.com/me/myapp")
+	assert.True(t, slices.ContainsFunc(info, func(msg string) bool {
+		return strings.HasPrefix(msg, "replacing variables vars=") && strings.Contains(msg, "ProjectName=myapp")
+	}), "missing replacing variables message in %v", info)
+
+	debug := logs.messages(slog.LevelDebug)
+	assert.Contains(t, debug, "found go.mod module=github.com/old/tmpl")
+	assert.Contains(t, debug, "rewritten file=go.mod")
+	assert.Contains(t, debug, "replaced variables file=main.go")
+}
+
+func TestMergeExtensions_Empty(t *testing.T) {
+	result := mergeExtensions(nil, nil)
+	assert.Empty(t, result)
+}
+
+func TestMergeExtensions_CLIOnly(t *testing.T) {
+	result := mergeExtensions([]string{"toml", "yaml"}, nil)
+	assert.Equal(t, []string{"toml", "yaml"}, result)
+}
+
+func TestMergeExtensions_ConfigOnly(t *testing.T) {
+	result := mergeExtensions(nil, []string{"toml", "yaml"})
+	assert.Equal(t, []string{"toml", "yaml"}, result)
+}
+
+func TestMergeExtensions_Both(t *testing.T) {
+	result := mergeExtensions([]string{"md", "txt"}, []string{"toml", "yaml"})
+	// Config extensions first, then CLI extensions
+	assert.Equal(t, []string{"toml", "yaml", "md", "txt"}, result)
+}
+
+func TestMergeExtensions_Deduplication(t *testing.T) {
+	result := mergeExtensions([]string{"toml", "yaml"}, []string{"toml", "md"})
+	// toml should only appear once (from config)
+	assert.Equal(t, []string{"toml", "md", "yaml"}, result)
+}
+
+func TestRunDryRun_WithKeepConfig(t *testing.T) {
+	oldDir, oldMod, oldExt, oldKeepConfig := directory, module, extensions, keepConfig
+	defer func() {
+		directory, module, extensions, keepConfig = oldDir, oldMod, oldExt, oldKeepConfig
+	}()
+
+	directory = "myapp"
+	module = "github.com/me/myapp"
+	extensions = nil
+	keepConfig = true
+
+	src := &source.LocalSource{Path: localTemplate(t)}
+
+	output := captureOutput(func() {
+		err := runDryRun(context.Background(), src)
+		assert.ErrorIs(t, err, errChangesPending)
+	})
+
+	assert.Contains(t, output, "--keep-config")
+	assert.NotContains(t, output, "Would remove .gohatch.toml")
+}
+
+func TestRunDryRun_ConfigRemovalMessage(t *testing.T) {
+	oldDir, oldMod, oldExt, oldKeepConfig := directory, module, extensions, keepConfig
+	defer func() {
+		directory, module, extensions, keepConfig = oldDir, oldMod, oldExt, oldKeepConfig
+	}()
+
+	directory = "myapp"
+	module = "github.com/me/myapp"
+	extensions = nil
+	keepConfig = false
+
+	src := &source.LocalSource{Path: localTemplate(t)}
+
+	output := captureOutput(func() {
+		err := runDryRun(context.Background(), src)
+		assert.ErrorIs(t, err, errChangesPending)
+	})
+
+	assert.Contains(t, output, "Would remove .gohatch.toml")
+	assert.Contains(t, output, "Would read .gohatch.toml")
+}
+
+func TestRunDryRun_PathRenames(t *testing.T) {
+	oldDir, oldMod, oldExt, oldVars := directory, module, extensions, variables
+	defer func() {
+		directory, module, extensions, variables = oldDir, oldMod, oldExt, oldVars
+	}()
+
+	templateDir := t.TempDir()
+	cmdDir := filepath.Join(templateDir, "cmd", "__ProjectName__")
+	require.NoError(t, os.MkdirAll(cmdDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(cmdDir, "main.go"), []byte("package main"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "go.mod"), []byte("module github.com/old/tmpl\n\ngo 1.21\n"), 0o644))
+
+	directory = filepath.Join(t.TempDir(), "myapp")
+	module = "github.com/me/myapp"
+	extensions = nil
+	variables = nil
+
+	src := &source.LocalSource{Path: templateDir}
+
+	output := captureOutput(func() {
+		err := runDryRun(context.Background(), src)
+		assert.ErrorIs(t, err, errChangesPending)
+	})
+
+	assert.Contains(t, output, "Would rename paths:")
+	assert.Contains(t, output, filepath.Join("cmd", "__ProjectName__")+" → "+filepath.Join("cmd", "myapp"))
+
+	// Template and target must be left untouched
+	assert.DirExists(t, cmdDir)
+	assert.NoDirExists(t, directory)
+}
+
+// commitTemplateFiles writes files into the template repository and commits them.
+func commitTemplateFiles(t *testing.T, repo *git.Repository, workDir string, files map[string]string) {
+	t.Helper()
+
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	for name, content := range files {
+		path := filepath.Join(workDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		_, err = worktree.Add(name)
+		require.NoError(t, err)
+	}
+
+	_, err = worktree.Commit("Update template", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+}
+
+// scaffoldForUpdate creates a template repository with the extra files
+// and scaffolds a project from it. Returns the template repository, its
+// path, and the project path.
+func scaffoldForUpdate(t *testing.T, extra map[string]string) (*git.Repository, string, string) {
+	t.Helper()
+
+	oldSrc, oldMod, oldDir, oldVars, oldNoGit, oldForce := srcInput, module, directory, variables, noGitInit, force
+	t.Cleanup(func() {
+		srcInput, module, directory, variables, noGitInit, force = oldSrc, oldMod, oldDir, oldVars, oldNoGit, oldForce
+		updateDir, updateRef = "", ""
+	})
+
+	workDir := t.TempDir()
+	repo, err := git.PlainInit(workDir, false)
+	require.NoError(t, err)
+
+	files := map[string]string{
+		"go.mod":     "module github.com/old/tmpl\n\ngo 1.21\n",
+		"main.go":    "package main\n\nimport _ \"github.com/old/tmpl/internal/app\"\n\nfunc main() {}\n",
+		"README.md":  "# __ProjectName__\n\nIntro\n\nUsage\n\nLicense\n",
+		"config.txt": "debug = false\n",
+	}
+	maps.Copy(files, extra)
+	commitTemplateFiles(t, repo, workDir, files)
+
+	srcInput = workDir
+	module = "github.com/me/myapp"
+	directory = filepath.Join(t.TempDir(), "myapp")
+	variables = nil
+	noGitInit = true
+
+	captureOutput(func() {
+		err = executeScaffold(context.Background(), &source.GitSource{URL: workDir})
+	})
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(directory, ".gohatch", "provenance.toml"))
+
+	return repo, workDir, directory
+}
+
+func TestUpdate_CleanMerge(t *testing.T) {
+	repo, workDir, projectDir := scaffoldForUpdate(t, nil)
+
+	// Local change in the project
+	readme := filepath.Join(projectDir, "README.md")
+	require.NoError(t, os.WriteFile(readme, []byte("# myapp\n\nIntro (local)\n\nUsage\n\nLicense\n"), 0o644))
+
+	// Upstream changes in the template
+	commitTemplateFiles(t, repo, workDir, map[string]string{
+		"README.md": "# __ProjectName__\n\nIntro\n\nUsage\n\nLicense: MIT\n",
+		"main.go":   "package main\n\nimport _ \"github.com/old/tmpl/internal/app\"\n\nfunc main() { run() }\n",
+		"NEW.md":    "new file\n",
+	})
+
+	oldNow := now
+	t.Cleanup(func() { now = oldNow })
+	now = func() time.Time { return time.Date(2025, 3, 14, 9, 26, 53, 0, time.UTC) }
+
+	updateDir = projectDir
+	var err error
+	captureOutput(func() {
+		err = runUpdate(context.Background(), nil)
+	})
+	require.NoError(t, err)
+
+	rec, err := provenance.Read(projectDir)
+	require.NoError(t, err)
+	assert.Equal(t, now(), rec.Timestamp)
+
+	data, err := os.ReadFile(readme)
+	require.NoError(t, err)
+	assert.Equal(t, "# myapp\n\nIntro (local)\n\nUsage\n\nLicense: MIT\n", string(data))
+
+	data, err = os.ReadFile(filepath.Join(projectDir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"github.com/me/myapp/internal/app"`)
+	assert.Contains(t, string(data), "run()")
+
+	assert.FileExists(t, filepath.Join(projectDir, "NEW.md"))
+}
+
+func TestUpdate_Conflict(t *testing.T) {
+	repo, workDir, projectDir := scaffoldForUpdate(t, nil)
+
+	configPath := filepath.Join(projectDir, "config.txt")
+	require.NoError(t, os.WriteFile(configPath, []byte("debug = true\n"), 0o644))
+
+	commitTemplateFiles(t, repo, workDir, map[string]string{
+		"config.txt": "debug = \"off\"\n",
+	})
+
+	updateDir = projectDir
+	logs := captureLogs(t)
+	var err error
+	captureOutput(func() {
+		err = runUpdate(context.Background(), nil)
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "conflicting")
+	assert.Contains(t, logs.messages(slog.LevelWarn), "conflict file=config.txt")
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "<<<<<<< current\ndebug = true\n=======\ndebug = \"off\"\n>>>>>>> template\n", string(data))
+}
+
+func TestUpdate_RecordedFlags(t *testing.T) {
+	oldExts := moduleExts
+	t.Cleanup(func() { moduleExts = oldExts })
+	moduleExts = []string{"txt"}
+
+	repo, workDir, projectDir := scaffoldForUpdate(t, map[string]string{"version.txt": "github.com/old/tmpl v1\n"})
+	require.FileExists(t, filepath.Join(projectDir, "version.txt"))
+
+	commitTemplateFiles(t, repo, workDir, map[string]string{"version.txt": "github.com/old/tmpl v2\n"})
+
+	// A later run does not repeat the flags
+	moduleExts = nil
+	updateDir = projectDir
+	var err error
+	captureOutput(func() {
+		err = runUpdate(context.Background(), nil)
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(projectDir, "version.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/me/myapp v2\n", string(data))
+
+	// The recorded flags are only used for the update
+	assert.Nil(t, moduleExts)
+	assert.False(t, force)
+
+	rec, err := provenance.Read(projectDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"txt"}, rec.ModuleExtensions)
+}
+
+func TestUpdate_SecretVariables(t *testing.T) {
+	setup := func(t *testing.T) string {
+		fakePrompts(t, "", false)
+		repo, workDir, projectDir := scaffoldForUpdate(t, map[string]string{
+			gohatchcfg.ConfigFile: "[[variables]]\nname = \"Token\"\nsecret = true\ndefault = \"abc\"\n",
+			"token.go":            "package main\n\nconst Token = \"__Token__\"\n",
+		})
+		commitTemplateFiles(t, repo, workDir, map[string]string{"NEW.md": "new file\n"})
+		updateDir = projectDir
+		return projectDir
+	}
+
+	t.Run("fails without a terminal", func(t *testing.T) {
+		setup(t)
+
+		var err error
+		captureOutput(func() {
+			err = runUpdate(context.Background(), nil)
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "secret variable Token is not recorded")
+	})
+
+	t.Run("prompts on a terminal", func(t *testing.T) {
+		projectDir := setup(t)
+		prompts := fakePrompts(t, "abc\n", true)
+
+		var err error
+		captureOutput(func() {
+			err = runUpdate(context.Background(), nil)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Token: ", prompts.String())
+
+		data, err := os.ReadFile(filepath.Join(projectDir, "token.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `const Token = "abc"`)
+		assert.FileExists(t, filepath.Join(projectDir, "NEW.md"))
+
+		rec, err := provenance.Read(projectDir)
+		require.NoError(t, err)
+		assert.NotContains(t, rec.Variables, "Token")
+	})
+}
+
+// localTemplate creates a minimal Go template directory.
+func localTemplate(t *testing.T) string {
+	t.Helper()
+
+	templateDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "go.mod"), []byte("module github.com/old/tmpl\n\ngo 1.21\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "main.go"), []byte("package main\n\nconst Name = \"__ProjectName__\"\n"), 0o644))
+	return templateDir
+}
+
+func TestExecuteScaffold_WritesProvenance(t *testing.T) {
+	oldVars, oldNow := variables, now
+	t.Cleanup(func() { variables, now = oldVars, oldNow })
+	now = func() time.Time { return time.Date(2025, 3, 14, 9, 26, 53, 500, time.FixedZone("CET", 3600)) }
+
+	templateDir := localTemplate(t)
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	_, err := runCLI(t, "--no-git-init", "--var", "Greeting=__ProjectName__", templateDir, "github.com/me/myapp", projectDir)
+	require.NoError(t, err)
+
+	var rec struct {
+		Source    string            `toml:"source"`
+		Path      string            `toml:"path"`
+		Module    string            `toml:"module"`
+		Timestamp time.Time         `toml:"timestamp"`
+		Variables map[string]string `toml:"variables"`
+	}
+	_, err = toml.DecodeFile(filepath.Join(projectDir, ".gohatch", "provenance.toml"), &rec)
+	require.NoError(t, err)
+
+	assert.Equal(t, templateDir, rec.Source)
+	assert.Equal(t, templateDir, rec.Path)
+	assert.Equal(t, "github.com/me/myapp", rec.Module)
+	assert.Equal(t, time.Date(2025, 3, 14, 8, 26, 53, 0, time.UTC), rec.Timestamp)
+	assert.Equal(t, "myapp", rec.Variables["ProjectName"])
+
+	// Recorded values are not subject to variable substitution
+	assert.Equal(t, "__ProjectName__", rec.Variables["Greeting"])
+}
+
+func TestExecuteScaffold_NoProvenance(t *testing.T) {
+	oldNoProvenance := noProvenance
+	t.Cleanup(func() { noProvenance = oldNoProvenance })
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	_, err := runCLI(t, "--no-git-init", "--no-provenance", localTemplate(t), "github.com/me/myapp", projectDir)
+	require.NoError(t, err)
+
+	assert.NoDirExists(t, filepath.Join(projectDir, ".gohatch"))
+}
+
+func TestExecuteScaffold_MaxRewriteSize(t *testing.T) {
+	oldMax := maxRewriteSize
+	defer func() { maxRewriteSize = oldMax }()
+	maxRewriteSize = 100
+
+	logs := captureLogs(t)
+
+	templateDir := localTemplate(t)
+	large := "package main\n\nconst Data = \"__ProjectName__" + strings.Repeat("x", 100) + "\"\n"
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "data.go"), []byte(large), 0o644))
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	require.NoError(t, scaffoldInto(t, templateDir, projectDir, false))
+
+	// The large file is copied unchanged, the small one is rewritten
+	data, err := os.ReadFile(filepath.Join(projectDir, "data.go"))
+	require.NoError(t, err)
+	assert.Equal(t, large, string(data))
+
+	data, err = os.ReadFile(filepath.Join(projectDir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"myapp"`)
+
+	assert.Contains(t, logs.messages(slog.LevelWarn), "skipping rewrite of large file file=data.go max=100")
+}
+
+func TestCLI_NegativeMaxRewriteSize(t *testing.T) {
+	_, err := runCLI(t, "--max-rewrite-size", "-1", localTemplate(t), "github.com/me/myapp", filepath.Join(t.TempDir(), "myapp"))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--max-rewrite-size must not be negative")
+}
+
+func TestExecuteScaffold_Resume(t *testing.T) {
+	oldResume := resume
+	defer func() { resume = oldResume }()
+	resume = false
+
+	// An invalid placeholder config makes the rewrite fail after fetching
+	templateDir := localTemplate(t)
+	configPath := filepath.Join(templateDir, gohatchcfg.ConfigFile)
+	require.NoError(t, os.WriteFile(configPath, []byte("[placeholders]\ndelimiters = [\"{{\"]\n"), 0o644))
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	err := scaffoldInto(t, templateDir, projectDir, false)
+	require.Error(t, err)
+	assert.FileExists(t, filepath.Join(projectDir, filepath.FromSlash(provenance.PendingFile)))
+
+	err = scaffoldInto(t, templateDir, projectDir, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "use --resume")
+
+	// Fix the fetched config and remove the template, so resuming must
+	// work on the fetched content alone
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, gohatchcfg.ConfigFile), nil, 0o644))
+	require.NoError(t, os.RemoveAll(templateDir))
+
+	resume = true
+	require.NoError(t, scaffoldInto(t, templateDir, projectDir, false))
+
+	data, err := os.ReadFile(filepath.Join(projectDir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"myapp"`)
+
+	assert.NoFileExists(t, filepath.Join(projectDir, filepath.FromSlash(provenance.PendingFile)))
+	assert.NoFileExists(t, filepath.Join(projectDir, gohatchcfg.ConfigFile))
+	assert.FileExists(t, filepath.Join(projectDir, filepath.FromSlash(provenance.File)))
+}
+
+func TestExecuteScaffold_ResumeWithoutMarker(t *testing.T) {
+	oldResume := resume
+	defer func() { resume = oldResume }()
+	resume = true
+
+	// Without a pending marker --resume scaffolds as usual
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	require.NoError(t, scaffoldInto(t, localTemplate(t), projectDir, false))
+	assert.NoFileExists(t, filepath.Join(projectDir, filepath.FromSlash(provenance.PendingFile)))
+
+	// and still refuses to touch a non-empty directory
+	err := scaffoldInto(t, localTemplate(t), projectDir, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not empty")
+}
+
+func TestExecuteScaffold_InitModule(t *testing.T) {
+	oldInit, oldFrom := initModule, fromModule
+	defer func() { initModule, fromModule = oldInit, oldFrom }()
+	initModule = true
+
+	snippetTemplate := func(t *testing.T) string {
+		t.Helper()
+		templateDir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(templateDir, "internal", "app"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "main.go"),
+			[]byte("package main\n\nimport \"example.com/old/internal/app\"\n\nvar _ = app.Run\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "internal", "app", "app.go"),
+			[]byte("package app\n\nfunc Run() {}\n"), 0o644))
+		return templateDir
+	}
+
+	for _, from := range []string{"example.com/old", ""} {
+		t.Run("from-module "+strconv.Quote(from), func(t *testing.T) {
+			fromModule = from
+			projectDir := filepath.Join(t.TempDir(), "myapp")
+			require.NoError(t, scaffoldInto(t, snippetTemplate(t), projectDir, false))
+
+			modPath, err := rewrite.ReadModulePath(projectDir)
+			require.NoError(t, err)
+			assert.Equal(t, "github.com/me/myapp", modPath)
+
+			data, err := os.ReadFile(filepath.Join(projectDir, "main.go"))
+			require.NoError(t, err)
+			assert.Contains(t, string(data), `import "github.com/me/myapp/internal/app"`)
+		})
+	}
+}
+
+func TestExecuteScaffold_ConfigExtensions(t *testing.T) {
+	oldExt := extensions
+	defer func() { extensions = oldExt }()
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile),
+		[]byte(`extensions = ["toml"]`+"\n"), 0o644))
+	content := []byte("module = \"github.com/old/tmpl\"\nname = \"__ProjectName__\"\n")
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "app.toml"), content, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "app.yaml"), content, 0o644))
+
+	// The config's toml is combined with -e yaml from the command line
+	for _, cli := range [][]string{nil, {"yaml"}} {
+		t.Run(fmt.Sprintf("cli %v", cli), func(t *testing.T) {
+			extensions = cli
+			projectDir := filepath.Join(t.TempDir(), "myapp")
+			require.NoError(t, scaffoldInto(t, templateDir, projectDir, false))
+
+			want := "module = \"github.com/me/myapp\"\nname = \"myapp\"\n"
+			data, err := os.ReadFile(filepath.Join(projectDir, "app.toml"))
+			require.NoError(t, err)
+			assert.Equal(t, want, string(data))
+
+			data, err = os.ReadFile(filepath.Join(projectDir, "app.yaml"))
+			require.NoError(t, err)
+			if cli == nil {
+				assert.Equal(t, string(content), string(data))
+			} else {
+				assert.Equal(t, want, string(data))
+			}
+		})
+	}
+}
+
+func TestExecuteScaffold_Reproducible(t *testing.T) {
+	oldNoProvenance := noProvenance
+	t.Cleanup(func() { noProvenance = oldNoProvenance })
+
+	templateDir := localTemplate(t)
+
+	var hashes []string
+	for range 2 {
+		projectDir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "--no-provenance", templateDir, "github.com/me/myapp", projectDir)
+		require.NoError(t, err)
+
+		hash, err := gohatch.HashTree(projectDir)
+		require.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+
+	assert.Equal(t, hashes[0], hashes[1])
+}
+
+func TestApplyUserConfig(t *testing.T) {
+	oldExt, oldHost, oldTokens := extensions, host, tokens
+	defer func() { extensions, host, tokens = oldExt, oldHost, oldTokens }()
+
+	t.Run("fills in defaults", func(t *testing.T) {
+		extensions, host, tokens = nil, "", nil
+
+		applyUserConfig(&gohatchcfg.UserConfig{
+			Extensions:  []string{"toml"},
+			DefaultHost: "codeberg.org",
+			Token:       "secret",
+		})
+
+		assert.Equal(t, []string{"toml"}, extensions)
+		assert.Equal(t, "codeberg.org", host)
+		assert.Equal(t, map[string]string{"codeberg.org": "secret"}, tokens)
+	})
+
+	t.Run("flags take precedence", func(t *testing.T) {
+		extensions, host, tokens = []string{"md", "toml"}, "gitlab.com", nil
+
+		applyUserConfig(&gohatchcfg.UserConfig{
+			Extensions:  []string{"toml", "yaml"},
+			DefaultHost: "codeberg.org",
+		})
+
+		assert.Equal(t, []string{"toml", "yaml", "md"}, extensions)
+		assert.Equal(t, "gitlab.com", host)
+	})
+
+	t.Run("defaults host to github.com", func(t *testing.T) {
+		extensions, host, tokens = nil, "", nil
+
+		applyUserConfig(&gohatchcfg.UserConfig{})
+
+		assert.Equal(t, source.DefaultHost, host)
+	})
+}
+
+func TestLoadUserConfig(t *testing.T) {
+	oldExt, oldHost, oldTokens := extensions, host, tokens
+	defer func() { extensions, host, tokens = oldExt, oldHost, oldTokens }()
+
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	require.NoError(t, os.MkdirAll(filepath.Join(xdg, "gohatch"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(xdg, "gohatch", gohatchcfg.UserConfigFile),
+		[]byte("default_host = \"codeberg.org\"\ntoken = \"secret\"\n"), 0o600))
+
+	extensions, host, tokens = nil, "", nil
+	require.NoError(t, loadUserConfig())
+	assert.Equal(t, "codeberg.org", host)
+
+	src, err := source.ParseWithHost("user/repo", host)
+	require.NoError(t, err)
+	configureSource(src)
+	gitSrc, ok := src.(*source.GitSource)
+	require.True(t, ok)
+	assert.Equal(t, "https://codeberg.org/user/repo", gitSrc.URL)
+	assert.Equal(t, map[string]string{"codeberg.org": "secret"}, gitSrc.Tokens)
+}
+
+func TestHostTokens(t *testing.T) {
+	assert.Empty(t, hostTokens(&gohatchcfg.UserConfig{}))
+
+	// The top-level token only belongs to the default host
+	assert.Equal(t, map[string]string{"github.com": "secret"},
+		hostTokens(&gohatchcfg.UserConfig{Token: "secret"}))
+
+	assert.Equal(t, map[string]string{"codeberg.org": "secret", "gitlab.example.com": "gl"},
+		hostTokens(&gohatchcfg.UserConfig{
+			DefaultHost: "codeberg.org",
+			Token:       "secret",
+			Hosts:       map[string]gohatchcfg.HostConfig{"GitLab.example.com": {Token: "gl"}, "empty.example": {}},
+		}))
+}
+
+// scaffoldInto runs executeScaffold for a local template into dir.
+func scaffoldInto(t *testing.T, templateDir, dir string, merge bool) error {
+	t.Helper()
+
+	oldSrc, oldMod, oldDir, oldVars, oldNoGit, oldMerge := srcInput, module, directory, variables, noGitInit, mergeDir
+	t.Cleanup(func() {
+		srcInput, module, directory, variables, noGitInit, mergeDir = oldSrc, oldMod, oldDir, oldVars, oldNoGit, oldMerge
+	})
+
+	srcInput, module, directory, variables, noGitInit, mergeDir = templateDir, "github.com/me/myapp", dir, nil, true, merge
+
+	var err error
+	captureOutput(func() {
+		err = executeScaffold(context.Background(), &source.LocalSource{Path: templateDir})
+	})
+	return err
+}
+
+func TestExecuteScaffold_EmptyCurrentDir(t *testing.T) {
+	templateDir := localTemplate(t)
+	cwd := filepath.Join(t.TempDir(), "cwdapp")
+	require.NoError(t, os.Mkdir(cwd, 0o755))
+	t.Chdir(cwd)
+
+	require.NoError(t, scaffoldInto(t, templateDir, ".", false))
+
+	data, err := os.ReadFile(filepath.Join(cwd, "main.go"))
+	require.NoError(t, err)
+	// ProjectName is derived from the directory name, not "."
+	assert.Contains(t, string(data), `"cwdapp"`)
+	assert.FileExists(t, filepath.Join(cwd, "go.mod"))
+}
+
+func TestExecuteScaffold_NonEmptyRequiresMerge(t *testing.T) {
+	templateDir := localTemplate(t)
+	cwd := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, "README.md"), []byte("mine\n"), 0o644))
+	t.Chdir(cwd)
+
+	err := scaffoldInto(t, templateDir, ".", false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--merge")
+}
+
+func TestExecuteScaffold_MergeIntoNonEmpty(t *testing.T) {
+	templateDir := localTemplate(t)
+	cwd := filepath.Join(t.TempDir(), "mergeapp")
+	require.NoError(t, os.MkdirAll(filepath.Join(cwd, ".git"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, "README.md"), []byte("mine\n"), 0o644))
+	t.Chdir(cwd)
+
+	oldNoProvenance := noProvenance
+	defer func() { noProvenance = oldNoProvenance }()
+	noProvenance = true
+
+	require.NoError(t, scaffoldInto(t, templateDir, ".", true))
+
+	readme, err := os.ReadFile(filepath.Join(cwd, "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "mine\n", string(readme))
+
+	data, err := os.ReadFile(filepath.Join(cwd, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"mergeapp"`)
+
+	goMod, err := os.ReadFile(filepath.Join(cwd, "go.mod"))
+	require.NoError(t, err)
+	assert.Contains(t, string(goMod), "module github.com/me/myapp")
+}
+
+func TestExecuteScaffold_MergeRefusesOverlap(t *testing.T) {
+	templateDir := localTemplate(t)
+	cwd := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, "main.go"), []byte("package mine\n"), 0o644))
+	t.Chdir(cwd)
+
+	err := scaffoldInto(t, templateDir, ".", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "main.go")
+
+	// Nothing is copied when files overlap
+	data, err := os.ReadFile(filepath.Join(cwd, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package mine\n", string(data))
+	assert.NoFileExists(t, filepath.Join(cwd, "go.mod"))
+}
+
+func TestExecuteScaffold_MergeOnConflict(t *testing.T) {
+	const mine = "package mine\n"
+
+	// setup creates a target with a conflicting main.go and a go.mod
+	// identical to the scaffolded one.
+	setup := func(t *testing.T, policy string) string {
+		t.Helper()
+
+		oldPolicy := onConflict
+		t.Cleanup(func() { onConflict = oldPolicy })
+		onConflict = policy
+
+		cwd := filepath.Join(t.TempDir(), "mergeapp")
+		require.NoError(t, os.Mkdir(cwd, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(cwd, "main.go"), []byte(mine), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(cwd, "go.mod"), []byte("module github.com/me/myapp\n\ngo 1.21\n"), 0o644))
+		t.Chdir(cwd)
+		return cwd
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		cwd := setup(t, conflictFail)
+
+		err := scaffoldInto(t, localTemplate(t), ".", true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "main.go")
+		assert.NotContains(t, err.Error(), "go.mod", "identical files are not conflicts")
+		assert.Contains(t, err.Error(), "--on-conflict")
+
+		data, err := os.ReadFile(filepath.Join(cwd, "main.go"))
+		require.NoError(t, err)
+		assert.Equal(t, mine, string(data))
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		cwd := setup(t, conflictOverwrite)
+		logs := captureLogs(t)
+
+		require.NoError(t, scaffoldInto(t, localTemplate(t), ".", true))
+
+		data, err := os.ReadFile(filepath.Join(cwd, "main.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"mergeapp"`)
+		assert.NoFileExists(t, filepath.Join(cwd, "main.go.bak"))
+		assert.Contains(t, logs.messages(slog.LevelWarn), "overwriting existing file file=main.go")
+	})
+
+	t.Run("overwrite replaces a symlink instead of its target", func(t *testing.T) {
+		cwd := setup(t, conflictOverwrite)
+		outside := filepath.Join(t.TempDir(), "outside.go")
+		require.NoError(t, os.WriteFile(outside, []byte(mine), 0o644))
+		require.NoError(t, os.Remove(filepath.Join(cwd, "main.go")))
+		require.NoError(t, os.Symlink(outside, filepath.Join(cwd, "main.go")))
+
+		require.NoError(t, scaffoldInto(t, localTemplate(t), ".", true))
+
+		info, err := os.Lstat(filepath.Join(cwd, "main.go"))
+		require.NoError(t, err)
+		assert.True(t, info.Mode().IsRegular())
+		data, err := os.ReadFile(outside)
+		require.NoError(t, err)
+		assert.Equal(t, mine, string(data))
+	})
+
+	t.Run("overwrite replaces a file with a template symlink", func(t *testing.T) {
+		cwd := setup(t, conflictOverwrite)
+		require.NoError(t, os.WriteFile(filepath.Join(cwd, "LICENSE"), []byte("mine\n"), 0o644))
+		templateDir := localTemplate(t)
+		require.NoError(t, os.WriteFile(filepath.Join(templateDir, "COPYING"), []byte("EUPL\n"), 0o644))
+		require.NoError(t, os.Symlink("COPYING", filepath.Join(templateDir, "LICENSE")))
+
+		require.NoError(t, scaffoldInto(t, templateDir, ".", true))
+
+		target, err := os.Readlink(filepath.Join(cwd, "LICENSE"))
+		require.NoError(t, err)
+		assert.Equal(t, "COPYING", target)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		cwd := setup(t, conflictSkip)
+		logs := captureLogs(t)
+
+		require.NoError(t, scaffoldInto(t, localTemplate(t), ".", true))
+
+		data, err := os.ReadFile(filepath.Join(cwd, "main.go"))
+		require.NoError(t, err)
+		assert.Equal(t, mine, string(data))
+		assert.FileExists(t, filepath.Join(cwd, provenance.File), "non-conflicting files are still copied")
+		assert.Contains(t, logs.messages(slog.LevelWarn), "keeping existing file file=main.go")
+	})
+
+	t.Run("backup", func(t *testing.T) {
+		cwd := setup(t, conflictBackup)
+
+		require.NoError(t, scaffoldInto(t, localTemplate(t), ".", true))
+
+		data, err := os.ReadFile(filepath.Join(cwd, "main.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"mergeapp"`)
+
+		data, err = os.ReadFile(filepath.Join(cwd, "main.go.bak"))
+		require.NoError(t, err)
+		assert.Equal(t, mine, string(data))
+		assert.NoFileExists(t, filepath.Join(cwd, "go.mod.bak"))
+	})
+
+	t.Run("backup refuses to replace a backup", func(t *testing.T) {
+		cwd := setup(t, conflictBackup)
+		require.NoError(t, os.WriteFile(filepath.Join(cwd, "main.go.bak"), []byte("older\n"), 0o644))
+
+		err := scaffoldInto(t, localTemplate(t), ".", true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "main.go.bak already exists")
+
+		data, err := os.ReadFile(filepath.Join(cwd, "main.go"))
+		require.NoError(t, err)
+		assert.Equal(t, mine, string(data))
+	})
+}
+
+func TestCLI_OnConflictValidation(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+
+	_, err := runCLI(t, "--merge", "--on-conflict", "merge", templateDir, "github.com/me/myapp", t.TempDir())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid --on-conflict "merge"`)
+
+	_, err = runCLI(t, "--on-conflict", "skip", templateDir, "github.com/me/myapp", filepath.Join(t.TempDir(), "myapp"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--on-conflict requires --merge")
+}
+
+func TestExecuteScaffold_NoModuleRewrite(t *testing.T) {
+	oldNoModRewrite := noModRewrite
+	defer func() { noModRewrite = oldNoModRewrite }()
+	noModRewrite = true
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(templateDir, "internal", "app"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "internal", "app", "app.go"),
+		[]byte("package app\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "cmd.go"),
+		[]byte("package main\n\nimport _ \"github.com/old/tmpl/internal/app\"\n"), 0o644))
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	require.NoError(t, scaffoldInto(t, templateDir, projectDir, false))
+
+	goMod, err := os.ReadFile(filepath.Join(projectDir, "go.mod"))
+	require.NoError(t, err)
+	assert.Equal(t, "module github.com/old/tmpl\n\ngo 1.21\n", string(goMod))
+
+	cmdGo, err := os.ReadFile(filepath.Join(projectDir, "cmd.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(cmdGo), `"github.com/old/tmpl/internal/app"`)
+
+	// Variables are still replaced
+	mainGo, err := os.ReadFile(filepath.Join(projectDir, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(mainGo), `const Name = "myapp"`)
+}
+
+func TestResolveSkipDirs(t *testing.T) {
+	assert.Nil(t, resolveSkipDirs(nil, nil))
+	assert.Nil(t, resolveSkipDirs([]string{}, nil))
+	assert.Equal(t, []string{"vendor"}, resolveSkipDirs(nil, []string{"vendor"}))
+	assert.Equal(t, []string{".git", "testdata"}, resolveSkipDirs([]string{".git", "testdata"}, []string{"vendor"}))
+	assert.Equal(t, []string{}, resolveSkipDirs([]string{""}, []string{"vendor"}))
+	assert.Equal(t, []string{}, resolveSkipDirs(nil, []string{}))
+}
+
+func TestExecuteScaffold_SkipDirs(t *testing.T) {
+	oldSkipDirs := skipDirs
+	defer func() { skipDirs = oldSkipDirs }()
+
+	newTemplate := func(t *testing.T, config string) string {
+		templateDir := localTemplate(t)
+		for _, dir := range []string{"vendor", "testdata"} {
+			require.NoError(t, os.MkdirAll(filepath.Join(templateDir, dir), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(templateDir, dir, "name.go"),
+				[]byte("package x\n\nconst Name = \"__ProjectName__\"\n"), 0o644))
+		}
+		if config != "" {
+			require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(config), 0o644))
+		}
+		return templateDir
+	}
+
+	rendered := func(t *testing.T, projectDir, dir string) bool {
+		data, err := os.ReadFile(filepath.Join(projectDir, dir, "name.go"))
+		require.NoError(t, err)
+		return bytes.Contains(data, []byte(`"myapp"`))
+	}
+
+	t.Run("default processes vendor", func(t *testing.T) {
+		skipDirs = nil
+		projectDir := filepath.Join(t.TempDir(), "myapp")
+		require.NoError(t, scaffoldInto(t, newTemplate(t, ""), projectDir, false))
+
+		assert.True(t, rendered(t, projectDir, "vendor"))
+		assert.True(t, rendered(t, projectDir, "testdata"))
+	})
+
+	t.Run("template config", func(t *testing.T) {
+		skipDirs = nil
+		projectDir := filepath.Join(t.TempDir(), "myapp")
+		require.NoError(t, scaffoldInto(t, newTemplate(t, "skip_dirs = [\"vendor\"]\n"), projectDir, false))
+
+		assert.False(t, rendered(t, projectDir, "vendor"))
+		assert.True(t, rendered(t, projectDir, "testdata"))
+	})
+
+	t.Run("flag replaces config", func(t *testing.T) {
+		skipDirs = []string{"testdata"}
+		projectDir := filepath.Join(t.TempDir(), "myapp")
+		require.NoError(t, scaffoldInto(t, newTemplate(t, "skip_dirs = [\"vendor\"]\n"), projectDir, false))
+
+		assert.True(t, rendered(t, projectDir, "vendor"))
+		assert.False(t, rendered(t, projectDir, "testdata"))
+	})
+}
+
+func TestExecuteScaffold_BarePlaceholders(t *testing.T) {
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile),
+		[]byte("[[variables]]\nname = \"ProjectName\"\n\n[placeholders]\ndelimiters = [\"\", \"\"]\nword_boundary = true\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "names.go"),
+		[]byte("package main\n\nimport \"time\"\n\nconst App = \"ProjectName\"\nconst Binary = \"MyProjectName\"\n\nvar Epoch = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).Year()\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(templateDir, "cmd", "ProjectName"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "cmd", "ProjectName", "main.go"), []byte("package main\n"), 0o644))
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	require.NoError(t, scaffoldInto(t, templateDir, projectDir, false))
+
+	data, err := os.ReadFile(filepath.Join(projectDir, "names.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `const App = "myapp"`)
+	assert.Contains(t, string(data), `const Binary = "MyProjectName"`)
+	// Undeclared built-ins such as Date and Year are not bare placeholders
+	assert.Contains(t, string(data), "time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).Year()")
+	assert.FileExists(t, filepath.Join(projectDir, "cmd", "myapp", "main.go"))
+}
+
+// readHook calls hook before the first read from r.
+type readHook struct {
+	r    io.Reader
+	hook func()
+	done bool
+}
+
+func (h *readHook) Read(p []byte) (int, error) {
+	if !h.done {
+		h.done = true
+		h.hook()
+	}
+	return h.r.Read(p)
+}
+
+// fakePrompts feeds input to the variable prompts and returns their output.
+func fakePrompts(t *testing.T, input string, tty bool) *bytes.Buffer {
+	t.Helper()
+
+	oldIn, oldOut, oldInteractive := promptIn, promptOut, interactive
+	t.Cleanup(func() { promptIn, promptOut, interactive = oldIn, oldOut, oldInteractive })
+
+	var out bytes.Buffer
+	promptIn, promptOut = strings.NewReader(input), &out
+	interactive = func() bool { return tty }
+	return &out
+}
+
+func TestPromptVariables_OrderAndGroups(t *testing.T) {
+	out := fakePrompts(t, "Jane\n\nAGPL\n", true)
+
+	declared := []gohatchcfg.VariableConfig{
+		{Name: "Author", Prompt: "Author name", Group: "Project"},
+		{Name: "Description", Default: "A Go project", Group: "Project"},
+		{Name: "Org", Group: "Project"},
+		{Name: "License", Default: "MIT", Group: "Legal"},
+	}
+	vars := map[string]string{"Org": "acme"}
+
+	require.NoError(t, promptVariables(declared, vars))
+
+	assert.Equal(t, "\nProject\nAuthor name: Description [A Go project]: \nLegal\nLicense [MIT]: ", out.String())
+	assert.Equal(t, map[string]string{
+		"Author":      "Jane",
+		"Description": "A Go project",
+		"Org":         "acme",
+		"License":     "AGPL",
+	}, vars)
+}
+
+func TestPromptVariables_NonInteractive(t *testing.T) {
+	out := fakePrompts(t, "", false)
+
+	vars := map[string]string{}
+	declared := []gohatchcfg.VariableConfig{{Name: "License", Default: "MIT"}}
+	require.NoError(t, promptVariables(declared, vars))
+
+	assert.Empty(t, out.String())
+	assert.Equal(t, "MIT", vars["License"])
+}
+
+func TestPromptVariables_InputEnds(t *testing.T) {
+	fakePrompts(t, "", true)
+
+	err := promptVariables([]gohatchcfg.VariableConfig{{Name: "Author"}}, map[string]string{})
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestWithPromptKeys(t *testing.T) {
+	declared := []gohatchcfg.VariableConfig{
+		{Name: "License", Default: "MIT"},
+		{Name: "Author", Prompt: "Author name"},
+	}
+	vars := map[string]string{"ProjectName": "myapp", "License": "AGPL"}
+
+	got := withPromptKeys(declared, []string{"Author", "ProjectName", "Team"}, vars)
+	assert.Equal(t, []gohatchcfg.VariableConfig{
+		{Name: "License", Default: "MIT"},
+		{Name: "Author", Prompt: "Author name"},
+		{Name: "ProjectName", Default: "myapp"},
+		{Name: "Team"},
+	}, got)
+	assert.Equal(t, map[string]string{"License": "AGPL"}, vars)
+	assert.Equal(t, "MIT", declared[0].Default, "declared variables are not modified")
+}
+
+func TestCLI_PromptForVariable(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "main.go"),
+		[]byte("package main\n\nconst Author = \"__Author__\"\nconst License = \"__License__\"\n"), 0o644))
+
+	t.Run("prompts for keys without a value", func(t *testing.T) {
+		out := fakePrompts(t, "Jane Doe\n", true)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "-v", "Author", "-v", "License=MIT", templateDir, "github.com/me/myapp", dir)
+		require.NoError(t, err)
+
+		assert.Equal(t, "Author: ", out.String(), "License is taken as given")
+		data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `const Author = "Jane Doe"`)
+		assert.Contains(t, string(data), `const License = "MIT"`)
+	})
+
+	t.Run("requires a terminal", func(t *testing.T) {
+		fakePrompts(t, "", false)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		_, err := runCLI(t, "--no-git-init", "-v", "Author", templateDir, "github.com/me/myapp", dir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot prompt for Author without a terminal")
+	})
+}
+
+func TestPromptVariables_Secret(t *testing.T) {
+	declared := []gohatchcfg.VariableConfig{
+		{Name: "APIKey", Default: "changeme", Secret: true},
+	}
+
+	t.Run("reads lines without a terminal", func(t *testing.T) {
+		out := fakePrompts(t, "s3cret\n", true)
+
+		vars := map[string]string{}
+		require.NoError(t, promptVariables(declared, vars))
+
+		assert.Equal(t, "s3cret", vars["APIKey"])
+		// The default of a secret is never shown
+		assert.Equal(t, "APIKey: ", out.String())
+	})
+
+	t.Run("disables echo on a terminal", func(t *testing.T) {
+		out := fakePrompts(t, "", true)
+		promptIn = os.Stdin
+
+		oldIsTerminal, oldReadPassword := isTerminal, readPassword
+		t.Cleanup(func() { isTerminal, readPassword = oldIsTerminal, oldReadPassword })
+
+		var readFd int
+		isTerminal = func(int) bool { return true }
+		readPassword = func(fd int) ([]byte, error) {
+			readFd = fd
+			return []byte("s3cret"), nil
+		}
+
+		vars := map[string]string{}
+		require.NoError(t, promptVariables(declared, vars))
+
+		assert.Equal(t, "s3cret", vars["APIKey"])
+		assert.Equal(t, int(os.Stdin.Fd()), readFd)
+		assert.Equal(t, "APIKey: \n", out.String())
+	})
+}
+
+func TestExecuteScaffold_SecretNotRecorded(t *testing.T) {
+	fakePrompts(t, "", false)
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(`[[variables]]
+name = "APIKey"
+default = "s3cret"
+secret = true
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "key.go"),
+		[]byte("package main\n\nconst APIKey = \"__APIKey__\"\n"), 0o644))
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	require.NoError(t, scaffoldInto(t, templateDir, projectDir, false))
+
+	data, err := os.ReadFile(filepath.Join(projectDir, "key.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `const APIKey = "s3cret"`)
+
+	rec, err := provenance.Read(projectDir)
+	require.NoError(t, err)
+	assert.NotContains(t, rec.Variables, "APIKey")
+	assert.Contains(t, rec.Variables, "ProjectName")
+}
+
+func TestExecuteScaffold_DeclaredVariables(t *testing.T) {
+	out := fakePrompts(t, "Jane\n", true)
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(`[[variables]]
+name = "Author"
+group = "About"
+
+[[variables]]
+name = "ProjectName"
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "author.go"),
+		[]byte("package main\n\nconst Author = \"__Author__\"\n"), 0o644))
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	require.NoError(t, scaffoldInto(t, templateDir, projectDir, false))
+
+	// ProjectName is set by default and not prompted for
+	assert.Equal(t, "\nAbout\nAuthor: ", out.String())
+
+	data, err := os.ReadFile(filepath.Join(projectDir, "author.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `const Author = "Jane"`)
+}
+
+func TestExecuteScaffold_RenameMap(t *testing.T) {
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile),
+		[]byte(`rename_map = { "dot.env" = ".env", "dot.gitignore" = ".gitignore" }`+"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "dot.env"), []byte("NAME=app\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "dot.gitignore"), []byte("/build\n"), 0o644))
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	require.NoError(t, scaffoldInto(t, templateDir, projectDir, false))
+
+	assert.FileExists(t, filepath.Join(projectDir, ".env"))
+	assert.FileExists(t, filepath.Join(projectDir, ".gitignore"))
+	assert.NoFileExists(t, filepath.Join(projectDir, "dot.env"))
+	assert.NoFileExists(t, filepath.Join(projectDir, "dot.gitignore"))
+}
+
+func TestExecuteScaffold_VariablePasses(t *testing.T) {
+	fakePrompts(t, "", false)
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, gohatchcfg.ConfigFile), []byte(`[[variables]]
+name = "Name"
+default = "widget"
+rename = false
+
+[[variables]]
+name = "Kind"
+default = "tool"
+content = false
+`), 0o644))
+	content := []byte("package main\n\nconst Name = \"__Name__\"\nconst Kind = \"__Kind__\"\n")
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "__Name__.go"), content, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "__Kind__.go"), content, 0o644))
+
+	projectDir := filepath.Join(t.TempDir(), "myapp")
+	require.NoError(t, scaffoldInto(t, templateDir, projectDir, false))
+
+	// Name is replaced in content but keeps its placeholder file name
+	data, err := os.ReadFile(filepath.Join(projectDir, "__Name__.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `const Name = "widget"`)
+
+	// Kind renames the file but leaves content placeholders alone
+	data, err = os.ReadFile(filepath.Join(projectDir, "tool.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `const Kind = "__Kind__"`)
+
+	assert.NoFileExists(t, filepath.Join(projectDir, "widget.go"))
+	assert.NoFileExists(t, filepath.Join(projectDir, "__Kind__.go"))
+}
+
+// runCLI runs the gohatch command with args and returns its output.
+func runCLI(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	cmd := newCommand()
+	cmd.Writer = &buf
+	cmd.ErrWriter = &buf
+	cmd.ExitErrHandler = func(context.Context, *cli.Command, error) {}
+
+	// Subcommands write to stdout rather than the root writer
+	var err error
+	stdout := captureOutput(func() {
+		err = cmd.Run(context.Background(), append([]string{"gohatch"}, args...))
+	})
+	return buf.String() + stdout, err
+}
+
+func TestCompletion_Shells(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  string
+	}{
+		{"bash", "complete -o bashdefault -o default -o nospace -F __gohatch_bash_autocomplete gohatch"},
+		{"zsh", "compdef _gohatch gohatch"},
+		{"fish", "complete -c gohatch -n '__fish_gohatch_no_subcommand' -f -l extension"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			output, err := runCLI(t, "completion", tt.shell)
+			require.NoError(t, err)
+			assert.Contains(t, output, tt.want)
+		})
+	}
+}
+
+func TestCompletion_UnknownShell(t *testing.T) {
+	_, err := runCLI(t, "completion", "tcsh")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown shell tcsh")
+}
+
+func TestCompleteRoot(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cwd := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(cwd, "my-template"), 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(cwd, ".hidden"), 0o755))
+	t.Chdir(cwd)
+
+	t.Run("source suggests local directories", func(t *testing.T) {
+		os.Args = []string{"gohatch", "--generate-shell-completion"}
+		output, err := runCLI(t, "--generate-shell-completion")
+		require.NoError(t, err)
+		assert.Contains(t, output, "./my-template\n")
+		assert.Contains(t, output, "update\n")
+		assert.NotContains(t, output, ".hidden")
+	})
+
+	t.Run("flag values", func(t *testing.T) {
+		os.Args = []string{"gohatch", "--host", "--generate-shell-completion"}
+		output, err := runCLI(t, "--host", "--generate-shell-completion")
+		require.NoError(t, err)
+		assert.Equal(t, "github.com\ngitlab.com\ncodeberg.org\n", output)
+	})
+}
+
+// fakeDoctorEnv returns a doctorEnv with the given environment, a git
+// binary in PATH, and a reachability probe that reports probeErr.
+func fakeDoctorEnv(t *testing.T, vars map[string]string, probeErr error) (*doctorEnv, *[]string) {
+	t.Helper()
+
+	var probed []string
+	return &doctorEnv{
+		getenv:   func(key string) string { return vars[key] },
+		lookPath: func(string) (string, error) { return "/usr/bin/git", nil },
+		probe: func(_ context.Context, url string) error {
+			probed = append(probed, url)
+			return probeErr
+		},
+		tempDir: t.TempDir(),
+	}, &probed
+}
+
+func TestCheckGit(t *testing.T) {
+	env, _ := fakeDoctorEnv(t, nil, nil)
+	assert.Equal(t, checkPass, checkGit(env).Status)
+
+	env.lookPath = func(string) (string, error) { return "", exec.ErrNotFound }
+	assert.Equal(t, checkWarn, checkGit(env).Status)
+}
+
+func TestCheckProxy(t *testing.T) {
+	env, _ := fakeDoctorEnv(t, nil, nil)
+	result := checkProxy(env, "github.com")
+	assert.Equal(t, checkPass, result.Status)
+	assert.Contains(t, result.Detail, "no proxy configured")
+
+	env, _ = fakeDoctorEnv(t, map[string]string{"https_proxy": "proxy.local:3128", "GOPROXY": "off"}, nil)
+	result = checkProxy(env, "github.com")
+	assert.Equal(t, checkPass, result.Status)
+	assert.Equal(t, "HTTPS_PROXY=proxy.local:3128 GOPROXY=off", result.Detail)
+
+	env, _ = fakeDoctorEnv(t, map[string]string{"HTTP_PROXY": "http://"}, nil)
+	assert.Equal(t, checkFail, checkProxy(env, "github.com").Status)
+}
+
+func TestCheckReachability(t *testing.T) {
+	env, probed := fakeDoctorEnv(t, nil, nil)
+	result := checkReachability(context.Background(), env, "codeberg.org")
+	assert.Equal(t, checkPass, result.Status)
+	assert.Equal(t, []string{"https://codeberg.org"}, *probed)
+
+	env, _ = fakeDoctorEnv(t, nil, errors.New("connection refused"))
+	result = checkReachability(context.Background(), env, "codeberg.org")
+	assert.Equal(t, checkFail, result.Status)
+	assert.Contains(t, result.Detail, "connection refused")
+}
+
+func TestCheckToken(t *testing.T) {
+	assert.Equal(t, checkWarn, checkToken(&gohatchcfg.UserConfig{}).Status)
+	assert.Equal(t, checkPass, checkToken(&gohatchcfg.UserConfig{Token: "secret"}).Status)
+}
+
+func TestCheckTempDir(t *testing.T) {
+	env, _ := fakeDoctorEnv(t, nil, nil)
+	assert.Equal(t, checkPass, checkTempDir(env).Status)
+
+	env.tempDir = filepath.Join(t.TempDir(), "missing")
+	assert.Equal(t, checkFail, checkTempDir(env).Status)
+}
+
+func TestRunChecks_UsesConfiguredHost(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	require.NoError(t, os.MkdirAll(filepath.Join(xdg, "gohatch"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(xdg, "gohatch", gohatchcfg.UserConfigFile),
+		[]byte("default_host = \"gitlab.com\"\ntoken = \"secret\"\n"), 0o600))
+
+	env, probed := fakeDoctorEnv(t, nil, nil)
+	results := runChecks(context.Background(), env, "")
+
+	assert.Equal(t, []string{"https://gitlab.com"}, *probed)
+	for _, r := range results {
+		assert.NotEqual(t, checkFail, r.Status, "%s: %s", r.Name, r.Detail)
+	}
+
+	// The --host flag takes precedence over the config
+	env, probed = fakeDoctorEnv(t, nil, nil)
+	runChecks(context.Background(), env, "codeberg.org")
+	assert.Equal(t, []string{"https://codeberg.org"}, *probed)
+}
+
+func TestCLI_SourcesJSON(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	out, err := runCLI(t, "--template-dir", "/tmp/templates", "sources", "--json")
+	require.NoError(t, err)
+
+	var formats []sourceFormat
+	require.NoError(t, json.Unmarshal([]byte(out), &formats))
+	require.NotEmpty(t, formats)
+
+	kinds := make(map[string]bool)
+	for _, f := range formats {
+		require.NotEmpty(t, f.Examples, f.Name)
+		for _, ex := range f.Examples {
+			assert.Equal(t, f.Kind, ex.Kind, ex.Input)
+			assert.NotEmpty(t, ex.Location, ex.Input)
+		}
+		kinds[f.Kind] = true
+	}
+
+	// Every source type Parse can produce has an entry
+	for _, src := range []source.Source{&source.GitSource{}, &source.LocalSource{}, &source.ProxySource{}} {
+		assert.True(t, kinds[source.Kind(src)], "missing %T", src)
+	}
+
+	assert.Contains(t, out, `"location": "https://github.com/user/repo"`)
+	assert.Contains(t, out, `"location": "/tmp/templates/my-template"`)
+}
+
+func TestCLI_SourcesHost(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	out, err := runCLI(t, "--host", "codeberg.org", "sources")
+	require.NoError(t, err)
+	assert.Contains(t, out, "GitHub shorthand (git)")
+	assert.Contains(t, out, "-> https://codeberg.org/user/repo\n")
+	assert.Contains(t, out, "-> github.com/user/repo @ v1.0.0")
+}
+
+// extractTarGz extracts the gzip-compressed tarball at file into dir.
+func extractTarGz(t *testing.T, file, dir string) {
+	t.Helper()
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		require.NoError(t, err)
+
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			require.NoError(t, os.MkdirAll(target, hdr.FileInfo().Mode().Perm()))
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(target, data, hdr.FileInfo().Mode().Perm()))
+			require.NoError(t, os.Chmod(target, hdr.FileInfo().Mode().Perm()))
+		default:
+			t.Fatalf("unexpected entry %s of type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+func TestCLI_Archive(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	templateDir := localTemplate(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(templateDir, "scripts"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(templateDir, "scripts", "build.sh"), []byte("#!/bin/sh\necho __ProjectName__\n"), 0o755))
+
+	work := t.TempDir()
+	dirOut := filepath.Join(work, "dir", "myapp")
+	require.NoError(t, os.MkdirAll(filepath.Dir(dirOut), 0o755))
+	_, err := runCLI(t, "--no-git-init", "--no-provenance", templateDir, "github.com/me/myapp", dirOut)
+	require.NoError(t, err)
+
+	archiveFile := filepath.Join(work, "myapp.tar.gz")
+	_, err = runCLI(t, "--no-provenance", "--archive", archiveFile, templateDir, "github.com/me/myapp", filepath.Join(work, "unused", "myapp"))
+	require.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(work, "unused"), "no directory is written")
+
+	extracted := filepath.Join(work, "extracted")
+	require.NoError(t, os.MkdirAll(extracted, 0o755))
+	extractTarGz(t, archiveFile, extracted)
+
+	want, err := readTree(dirOut)
+	require.NoError(t, err)
+	got, err := readTree(filepath.Join(extracted, "myapp"))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Contains(t, string(got["main.go"]), `const Name = "myapp"`)
+	assert.NoDirExists(t, filepath.Join(extracted, "myapp", ".git"))
+
+	info, err := os.Stat(filepath.Join(extracted, "myapp", "scripts", "build.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	named := filepath.Join(work, "named.tar.gz")
+	_, err = runCLI(t, "--no-provenance", "--name", "Service", "--archive", named, templateDir, "github.com/me/myapp", filepath.Join(work, "unused", "myapp"))
+	require.NoError(t, err)
+	extractTarGz(t, named, filepath.Join(work, "named"))
+	data, err := os.ReadFile(filepath.Join(work, "named", "myapp", "main.go"))
+	require.NoError(t, err, "--name does not change the archive root")
+	assert.Contains(t, string(data), `const Name = "Service"`)
+}
+
+func TestCLI_ArchiveConflicts(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+
+	for _, flag := range []string{"--merge", "--resume"} {
+		_, err := runCLI(t, flag, "--archive", filepath.Join(t.TempDir(), "out.tar.gz"), templateDir, "github.com/me/myapp")
+		require.Error(t, err, flag)
+		assert.Contains(t, err.Error(), "--archive cannot be combined with "+flag)
+	}
+}
+
+func TestCLI_OutScratch(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+
+	work := t.TempDir()
+	target := filepath.Join(work, "myapp")
+	scratch := filepath.Join(work, "scratch", "inspect")
+
+	out, err := runCLI(t, "--dry-run", "--out-scratch", scratch, "--no-git-init", templateDir, "github.com/me/myapp", target)
+	if err != nil {
+		require.ErrorIs(t, err, errChangesPending)
+	}
+	assert.Contains(t, out, "Scratch:   "+scratch)
+	assert.Contains(t, out, "Scaffolded into scratch directory "+scratch)
+
+	assert.NoDirExists(t, target, "the nominal target is not created")
+
+	data, err := os.ReadFile(filepath.Join(scratch, "go.mod"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "module github.com/me/myapp")
+	data, err = os.ReadFile(filepath.Join(scratch, "main.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `const Name = "myapp"`, "ProjectName comes from the nominal target")
+	assert.FileExists(t, filepath.Join(scratch, provenance.File))
+	assert.NoFileExists(t, filepath.Join(scratch, provenance.PendingFile))
+}
+
+func TestCLI_OutScratchRequiresDryRun(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	templateDir := localTemplate(t)
+
+	scratch := filepath.Join(t.TempDir(), "scratch")
+	_, err := runCLI(t, "--out-scratch", scratch, templateDir, "github.com/me/myapp")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--out-scratch requires --dry-run")
+	assert.NoDirExists(t, scratch)
 }
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	gohatchcfg "github.com/oliverandrich/gohatch/internal/config"
+	"github.com/oliverandrich/gohatch/internal/rewrite"
+	"github.com/oliverandrich/gohatch/internal/source"
+)
+
+// effectiveConfig is the configuration a run would use after merging the
+// user config, the template's .gohatch.toml, and the command line.
+type effectiveConfig struct {
+	Source        string                       `toml:"source"`
+	Module        string                       `toml:"module"`
+	Directory     string                       `toml:"directory"`
+	Host          string                       `toml:"host,omitempty"`
+	Extensions    []string                     `toml:"extensions"`
+	ModuleExts    []string                     `toml:"module_extensions"`
+	VarExts       []string                     `toml:"var_extensions"`
+	GoExtensions  []string                     `toml:"go_extensions,omitempty"`
+	TextExts      []string                     `toml:"text_extensions"`
+	AssetExts     []string                     `toml:"asset_extensions"`
+	VarFiles      []string                     `toml:"variable_files"`
+	SkipDirs      []string                     `toml:"skip_dirs"`
+	Include       []string                     `toml:"include,omitempty"`
+	NestedModules string                       `toml:"nested_modules,omitempty"`
+	OutputName    string                       `toml:"output_name,omitempty"`
+	RenameMap     map[string]string            `toml:"rename_map,omitempty"`
+	Placeholders  gohatchcfg.PlaceholderConfig `toml:"placeholders"`
+	Variables     map[string]string            `toml:"variables"`
+}
+
+// runPrintConfig fetches the template into a temporary directory and
+// prints the effective configuration as TOML without scaffolding.
+func runPrintConfig(ctx context.Context, src source.Source) error {
+	tmpDir, err := os.MkdirTemp("", "gohatch-print-config-*")
+	if err != nil {
+		return fmt.Errorf("creating temp directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	configureSource(src)
+	if err := src.Fetch(ctx, tmpDir); err != nil {
+		return fmt.Errorf("fetching template: %w", err)
+	}
+
+	cfg, err := gohatchcfg.Load(tmpDir)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	eff, err := mergeConfig(cfg)
+	if err != nil {
+		return err
+	}
+	return writeEffectiveConfig(os.Stdout, eff)
+}
+
+// mergeConfig combines the template config with the command line flags
+// and the user config already applied to them. Declared variables that
+// are not set take their defaults; secret variables are left out.
+func mergeConfig(cfg *gohatchcfg.Config) (*effectiveConfig, error) {
+	vars, err := resolveVariables(projectName(directory))
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range cfg.Variables {
+		if _, ok := vars[v.Name]; !ok {
+			vars[v.Name] = v.Default
+		}
+	}
+	placeholders, err := rewrite.ParsePlaceholders(cfg.Placeholders.Delimiters, cfg.Placeholders.WordBoundary)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if vars, err = expandVariables(vars, placeholders); err != nil {
+		return nil, err
+	}
+
+	skip := resolveSkipDirs(skipDirs, cfg.SkipDirs)
+	if skip == nil {
+		skip = rewrite.DefaultSkipDirs
+	}
+
+	includes := cfg.Include
+	if len(include) > 0 {
+		includes = include
+	}
+
+	return &effectiveConfig{
+		Source:        srcInput,
+		Module:        module,
+		Directory:     directory,
+		Host:          host,
+		Extensions:    mergeExtensions(extensions, cfg.Extensions),
+		ModuleExts:    passExtensions(moduleExts, cfg.Extensions),
+		VarExts:       passExtensions(varExts, cfg.Extensions),
+		GoExtensions:  cfg.GoExtensions,
+		TextExts:      textExtensions(cfg.TextExtensions),
+		AssetExts:     assetExtensions(cfg.AssetExtensions),
+		VarFiles:      variableFiles(cfg.VariableFiles),
+		SkipDirs:      skip,
+		Include:       includes,
+		NestedModules: cfg.NestedModules,
+		OutputName:    cfg.OutputName,
+		RenameMap:     cfg.RenameMap,
+		Placeholders:  cfg.Placeholders,
+		Variables:     filterVariables(vars, cfg.Variables, func(v gohatchcfg.VariableConfig) bool { return !v.Secret }),
+	}, nil
+}
+
+// textExtensions returns the extensions given in the template config,
+// or rewrite.DefaultTextExtensions if none are set.
+func textExtensions(config []string) []string {
+	if config == nil {
+		return rewrite.DefaultTextExtensions
+	}
+	return config
+}
+
+// assetExtensions returns the extensions given in the template config,
+// or rewrite.DefaultAssetExtensions if none are set.
+func assetExtensions(config []string) []string {
+	if config == nil {
+		return rewrite.DefaultAssetExtensions
+	}
+	return config
+}
+
+// writeEffectiveConfig encodes the effective configuration as TOML.
+func writeEffectiveConfig(w io.Writer, eff *effectiveConfig) error {
+	if err := toml.NewEncoder(w).Encode(eff); err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	return nil
+}
+
+// writeTemplateConfig prints the template's .gohatch.toml in dir as
+// parsed by config.Load, including fields left at their zero value.
+func writeTemplateConfig(w io.Writer, dir string, cfg *gohatchcfg.Config) error {
+	if gohatchcfg.Exists(dir) {
+		_, _ = fmt.Fprintf(w, "# Template config (%s)\n", gohatchcfg.ConfigFile)
+	} else {
+		_, _ = fmt.Fprintf(w, "# Template config (no %s, defaults)\n", gohatchcfg.ConfigFile)
+	}
+	if err := toml.NewEncoder(w).Encode(cfg); err != nil {
+		return fmt.Errorf("encoding template config: %w", err)
+	}
+	return nil
+}
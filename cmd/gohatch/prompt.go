@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	gohatchcfg "github.com/oliverandrich/gohatch/internal/config"
+	"golang.org/x/term"
+)
+
+// Prompts read from promptIn and write to promptOut; replaced in tests.
+var (
+	promptIn  io.Reader = os.Stdin
+	promptOut io.Writer = os.Stderr
+
+	// interactive reports whether the user can answer prompts.
+	interactive = func() bool {
+		info, err := os.Stdin.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0
+	}
+
+	// Terminal access for secret prompts.
+	isTerminal   = term.IsTerminal
+	readPassword = term.ReadPassword
+)
+
+// promptVariables asks for the declared variables that are not set yet,
+// in declaration order. A group header is printed whenever the group
+// changes. Without a terminal, the declared defaults are used.
+func promptVariables(declared []gohatchcfg.VariableConfig, vars map[string]string) error {
+	ask := interactive()
+	reader := bufio.NewReader(byteReader{promptIn})
+	group := ""
+
+	for _, v := range declared {
+		if v.Name == "" {
+			return errors.New("declared variable without a name")
+		}
+		if _, ok := vars[v.Name]; ok {
+			continue
+		}
+		if !ask {
+			vars[v.Name] = v.Default
+			continue
+		}
+
+		if v.Group != "" && v.Group != group {
+			fmt.Fprintf(promptOut, "\n%s\n", v.Group)
+			group = v.Group
+		}
+
+		value, err := promptVariable(reader, v)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", v.Name, err)
+		}
+		vars[v.Name] = value
+	}
+
+	return nil
+}
+
+// askVariables prompts for the declared variables and for the keys passed
+// to --var without a value, filling the answers into vars.
+func askVariables(declared []gohatchcfg.VariableConfig, vars map[string]string) error {
+	if keys := promptKeys(variables); len(keys) > 0 {
+		if !interactive() {
+			return fmt.Errorf("cannot prompt for %s without a terminal (use --var Key=value)", strings.Join(keys, ", "))
+		}
+		declared = withPromptKeys(declared, keys, vars)
+	}
+	return promptVariables(declared, vars)
+}
+
+// promptKeys returns the names given to --var without a value, in flag
+// order. These variables are prompted for.
+func promptKeys(vars []string) []string {
+	var keys []string
+	for _, v := range vars {
+		if !strings.Contains(v, "=") && v != "" && !slices.Contains(keys, v) {
+			keys = append(keys, v)
+		}
+	}
+	return keys
+}
+
+// withPromptKeys returns the declared variables followed by the keys not
+// declared, and removes all keys from vars so promptVariables asks for
+// them. A value a key already has, e.g. from --vars-json or a built-in
+// variable, becomes its default.
+func withPromptKeys(declared []gohatchcfg.VariableConfig, keys []string, vars map[string]string) []gohatchcfg.VariableConfig {
+	result := slices.Clone(declared)
+	for _, key := range keys {
+		i := slices.IndexFunc(result, func(v gohatchcfg.VariableConfig) bool { return v.Name == key })
+		if i == -1 {
+			result = append(result, gohatchcfg.VariableConfig{Name: key})
+			i = len(result) - 1
+		}
+		if value, ok := vars[key]; ok {
+			result[i].Default = value
+			delete(vars, key)
+		}
+	}
+	return result
+}
+
+// promptVariable asks for a single variable. An empty answer selects
+// the default. Defaults of secret variables are not shown.
+func promptVariable(reader *bufio.Reader, v gohatchcfg.VariableConfig) (string, error) {
+	label := v.Prompt
+	if label == "" {
+		label = v.Name
+	}
+	if v.Default != "" && !v.Secret {
+		label += " [" + v.Default + "]"
+	}
+	fmt.Fprintf(promptOut, "%s: ", label)
+
+	read := readLine
+	if v.Secret {
+		read = readSecret
+	}
+	line, err := read(reader)
+	if err != nil {
+		return "", err
+	}
+
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return v.Default, nil
+}
+
+// promptConfirm asks a yes/no question; only y or yes confirm. The answer
+// is read without buffering, so input meant for later prompts is kept.
+func promptConfirm(question string) (bool, error) {
+	fmt.Fprintf(promptOut, "%s [y/N]: ", question)
+
+	var answer []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := promptIn.Read(buf)
+		if n == 1 {
+			if buf[0] == '\n' {
+				break
+			}
+			answer = append(answer, buf[0])
+		}
+		if errors.Is(err, io.EOF) && len(answer) > 0 {
+			break
+		}
+		if err != nil {
+			return false, fmt.Errorf("reading confirmation: %w", err)
+		}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(answer))) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// byteReader reads one byte at a time, so a bufio.Reader on top of it
+// stops at the end of a line and keeps the input for later prompts.
+type byteReader struct {
+	r io.Reader
+}
+
+func (b byteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return b.r.Read(p)
+}
+
+// readLine reads a single line. A final line without a newline is
+// accepted, but no input at all is an error.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	return line, nil
+}
+
+// readSecret reads a line with echo disabled if prompts are read from a
+// terminal and falls back to readLine otherwise.
+func readSecret(reader *bufio.Reader) (string, error) {
+	stdin, ok := promptIn.(*os.File)
+	if !ok || !isTerminal(int(stdin.Fd())) {
+		return readLine(reader)
+	}
+
+	secret, err := readPassword(int(stdin.Fd()))
+	// The newline typed by the user is not echoed either
+	fmt.Fprintln(promptOut)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
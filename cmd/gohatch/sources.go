@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/oliverandrich/gohatch/internal/source"
+	"github.com/urfave/cli/v3"
+)
+
+var sourcesJSON bool
+
+// sourceFormat is a recognized source syntax with its examples resolved
+// by the same parser the scaffolding run uses.
+type sourceFormat struct {
+	Name     string          `json:"name"`
+	Syntax   string          `json:"syntax"`
+	Kind     string          `json:"kind"`
+	Examples []sourceExample `json:"examples"`
+}
+
+// sourceExample is an example input and the source it resolves to.
+type sourceExample struct {
+	Input    string `json:"input"`
+	Kind     string `json:"kind"`
+	Location string `json:"location"`
+	Version  string `json:"version,omitempty"`
+}
+
+// sourcesCommand lists the source syntaxes gohatch accepts.
+func sourcesCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "sources",
+		Usage: "list the supported template source formats",
+		Description: `List the source syntaxes gohatch recognizes, with examples resolved
+by the source parser against the configured host and templates directory.
+Use --json for a machine-readable list, e.g. for editor integrations.`,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "json",
+				Usage:       "print the formats as JSON",
+				Destination: &sourcesJSON,
+			},
+		},
+		Action: runSources,
+	}
+}
+
+func runSources(_ context.Context, _ *cli.Command) error {
+	if err := setupLogger(); err != nil {
+		return err
+	}
+	if err := loadUserConfig(); err != nil {
+		return err
+	}
+
+	formats, err := sourceFormats()
+	if err != nil {
+		return err
+	}
+	if sourcesJSON {
+		return writeSourcesJSON(os.Stdout, formats)
+	}
+	writeSources(os.Stdout, formats)
+	return nil
+}
+
+// sourceFormats resolves the examples of every format recognized by
+// source.Parse, followed by named templates.
+func sourceFormats() ([]sourceFormat, error) {
+	formats := make([]sourceFormat, 0, len(source.Formats)+1)
+	for _, f := range source.Formats {
+		format := sourceFormat{Name: f.Name, Syntax: f.Syntax}
+		for _, input := range f.Examples {
+			src, err := source.ParseWithHost(input, host)
+			if err != nil {
+				return nil, fmt.Errorf("resolving example %q: %w", input, err)
+			}
+			format.Examples = append(format.Examples, describeSource(input, src))
+		}
+		format.Kind = format.Examples[0].Kind
+		formats = append(formats, format)
+	}
+
+	named, err := namedFormat()
+	if err != nil {
+		return nil, err
+	}
+	return append(formats, named), nil
+}
+
+// namedFormat describes @name templates. The example is resolved
+// without requiring the template to exist.
+func namedFormat() (sourceFormat, error) {
+	root := templateDir
+	if root == "" {
+		var err error
+		if root, err = source.DefaultTemplateDir(); err != nil {
+			return sourceFormat{}, fmt.Errorf("locating templates directory: %w", err)
+		}
+	}
+
+	const name = "my-template"
+	path, err := source.NamedPath(name, root)
+	if err != nil {
+		return sourceFormat{}, err
+	}
+
+	example := describeSource(source.NamedPrefix+name, &source.LocalSource{Path: path})
+	return sourceFormat{
+		Name:     "Named template",
+		Syntax:   source.NamedPrefix + "name",
+		Kind:     example.Kind,
+		Examples: []sourceExample{example},
+	}, nil
+}
+
+// describeSource reports what input resolved to.
+func describeSource(input string, src source.Source) sourceExample {
+	location, version := source.Location(src)
+	return sourceExample{
+		Input:    input,
+		Kind:     source.Kind(src),
+		Location: location,
+		Version:  version,
+	}
+}
+
+// writeSourcesJSON encodes the formats as indented JSON.
+func writeSourcesJSON(w io.Writer, formats []sourceFormat) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(formats); err != nil {
+		return fmt.Errorf("encoding source formats: %w", err)
+	}
+	return nil
+}
+
+// writeSources prints the formats with their resolved examples.
+func writeSources(w io.Writer, formats []sourceFormat) {
+	for _, f := range formats {
+		fmt.Fprintf(w, "%s (%s)\n  %s\n", f.Name, f.Kind, f.Syntax)
+		for _, ex := range f.Examples {
+			location := ex.Location
+			if ex.Version != "" {
+				location += " @ " + ex.Version
+			}
+			fmt.Fprintf(w, "    %-40s -> %s\n", ex.Input, location)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oliverandrich/gohatch/internal/source"
+)
+
+// stepSummaryEnv names the file GitHub Actions renders as the summary of
+// a job step.
+const stepSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// scaffoldChanges collects the files written and the paths renamed by
+// the scaffold steps of the current run for the summary.
+var scaffoldChanges changeLog
+
+// changeLog records the changes of the scaffold steps.
+type changeLog struct {
+	files   map[string]bool
+	renames []string
+}
+
+// reset discards the changes recorded so far.
+func (c *changeLog) reset() {
+	*c = changeLog{}
+}
+
+// addFile records a file written by a scaffold step.
+func (c *changeLog) addFile(file string) {
+	if c.files == nil {
+		c.files = make(map[string]bool)
+	}
+	c.files[file] = true
+}
+
+// addRenames records renamed paths formatted as "old → new".
+func (c *changeLog) addRenames(renames []string) {
+	c.renames = append(c.renames, renames...)
+}
+
+// summaryPath returns the file the summary is appended to: --summary-file,
+// or else $GITHUB_STEP_SUMMARY. Empty disables the summary.
+func summaryPath() string {
+	return cmp.Or(summaryFile, os.Getenv(stepSummaryEnv))
+}
+
+// writeSummary appends a Markdown summary of the scaffold to the summary
+// file, if any. projectDir holds the generated files; target is the
+// directory or archive they were written to.
+func writeSummary(src source.Source, projectDir, target string) error {
+	path := summaryPath()
+	if path == "" {
+		return nil
+	}
+
+	files, err := countFiles(projectDir)
+	if err != nil {
+		return fmt.Errorf("writing summary: %w", err)
+	}
+
+	var buf bytes.Buffer
+	writeSummaryMarkdown(&buf, src, target, files)
+
+	f, err := os.OpenFile(filepath.Clean(path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("writing summary: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing summary: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing summary: %w", err)
+	}
+
+	logger.Debug("wrote summary", "file", path)
+	return nil
+}
+
+// writeSummaryMarkdown writes the template source as recorded in the
+// provenance, the file counts, and the renamed paths as Markdown tables.
+func writeSummaryMarkdown(w io.Writer, src source.Source, target string, files int) {
+	rec := provenanceRecord(src, nil)
+
+	_, _ = fmt.Fprintf(w, "## gohatch: %s\n\n", markdownCell(rec.Module))
+	_, _ = fmt.Fprintln(w, "| | |")
+	_, _ = fmt.Fprintln(w, "|---|---|")
+	row := func(key, value string) {
+		if value != "" {
+			_, _ = fmt.Fprintf(w, "| %s | `%s` |\n", key, markdownCell(value))
+		}
+	}
+	row("Source", rec.Source)
+	row("URL", rec.URL)
+	row("Version", rec.Version)
+	row("Commit", rec.Commit)
+	row("Module", rec.Module)
+	row("Output", target)
+	_, _ = fmt.Fprintf(w, "| Files | %d (%d rewritten) |\n", files, len(scaffoldChanges.files))
+	_, _ = fmt.Fprintf(w, "| Renamed paths | %d |\n", len(scaffoldChanges.renames))
+
+	if len(scaffoldChanges.renames) > 0 {
+		_, _ = fmt.Fprintln(w, "\n### Renamed paths\n\n| From | To |\n|---|---|")
+		for _, r := range scaffoldChanges.renames {
+			from, to, _ := strings.Cut(r, " → ")
+			_, _ = fmt.Fprintf(w, "| `%s` | `%s` |\n", markdownCell(from), markdownCell(to))
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// markdownCell escapes s for a Markdown table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "`", "'").Replace(s)
+}
+
+// countFiles returns the number of regular files below dir, outside .git.
+func countFiles(dir string) (int, error) {
+	n := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
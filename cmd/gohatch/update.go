@@ -0,0 +1,319 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	gohatchcfg "github.com/oliverandrich/gohatch/internal/config"
+	"github.com/oliverandrich/gohatch/internal/merge"
+	"github.com/oliverandrich/gohatch/internal/provenance"
+	"github.com/oliverandrich/gohatch/internal/source"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	updateDir string
+	updateRef string
+)
+
+// updateCommand re-applies a newer template version to an existing project.
+func updateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "update",
+		Usage: "re-apply a newer template version to an existing project",
+		Description: `Read the provenance recorded in .gohatch/provenance.toml, render the
+recorded and the new template version with the same module and variables,
+and merge the upstream changes into the project. Conflicting hunks are
+marked with conflict markers and reported instead of being overwritten.`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "ref",
+				Usage:       "template tag, branch, or commit to update to (default: recorded version)",
+				Destination: &updateRef,
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:        "directory",
+				UsageText:   "project directory (default: current directory)",
+				Destination: &updateDir,
+			},
+		},
+		Action: runUpdate,
+	}
+}
+
+func runUpdate(ctx context.Context, _ *cli.Command) error {
+	if err := setupLogger(); err != nil {
+		return err
+	}
+
+	dir := updateDir
+	if dir == "" {
+		dir = "."
+	}
+
+	rec, err := provenance.Read(dir)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", provenance.File, err)
+	}
+	if rec.URL == "" || rec.Commit == "" {
+		return fmt.Errorf("update requires a git template with a recorded commit")
+	}
+
+	if err := loadUserConfig(); err != nil {
+		return err
+	}
+
+	// Render with the recorded settings. Templates without a go.mod were
+	// generated with --force, which is not recorded.
+	saved, savedForce := provenanceRecord(nil, nil), force
+	defer func() {
+		applyRecordFlags(saved)
+		force = savedForce
+	}()
+	applyRecordFlags(rec)
+	force = true
+
+	vars := maps.Clone(rec.Variables)
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+
+	ref := updateRef
+	if ref == "" {
+		ref = rec.Version
+	}
+
+	tmpDir, err := os.MkdirTemp("", "gohatch-update-*")
+	if err != nil {
+		return fmt.Errorf("creating temp directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	baseDir := filepath.Join(tmpDir, "base")
+	base := &source.GitSource{URL: rec.URL, Version: rec.Commit}
+	if _, err := renderTemplate(ctx, base, baseDir, vars); err != nil {
+		return fmt.Errorf("rendering recorded version: %w", err)
+	}
+
+	newDir := filepath.Join(tmpDir, "new")
+	target := &source.GitSource{URL: rec.URL, Version: ref}
+	cfg, err := renderTemplate(ctx, target, newDir, vars)
+	if err != nil {
+		return fmt.Errorf("rendering new version: %w", err)
+	}
+
+	if target.Commit == rec.Commit {
+		logger.Info("already up to date")
+		return nil
+	}
+
+	result, err := mergeTemplate(baseDir, dir, newDir)
+	if err != nil {
+		return fmt.Errorf("merging template changes: %w", err)
+	}
+
+	for _, f := range result.Updated {
+		logFile("updated", f)
+	}
+
+	rec.Version, rec.Commit = ref, target.Commit
+	rec.Variables = filterVariables(vars, cfg.Variables, func(v gohatchcfg.VariableConfig) bool { return !v.Secret })
+	rec.Timestamp = now().UTC().Truncate(time.Second)
+	if err := provenance.Write(dir, rec); err != nil {
+		return fmt.Errorf("writing %s: %w", provenance.File, err)
+	}
+
+	if len(result.Conflicts) > 0 {
+		for _, f := range result.Conflicts {
+			logger.Warn("conflict", "file", f)
+		}
+		return fmt.Errorf("update finished with %d conflicting files", len(result.Conflicts))
+	}
+
+	logSuccess("updated project", "dir", dir, "commit", shortCommit(target.Commit), "changed", len(result.Updated))
+	return nil
+}
+
+// renderTemplate fetches src into dir and applies the rewrite pipeline.
+// Declared variables missing from vars, such as secret ones, which are
+// not recorded, or ones added by a newer template version, are prompted
+// for and added to vars. Returns the template config.
+func renderTemplate(ctx context.Context, src source.Source, dir string, vars map[string]string) (*gohatchcfg.Config, error) {
+	if err := fetchTemplate(ctx, src, dir); err != nil {
+		return nil, err
+	}
+
+	cfg, err := gohatchcfg.Load(dir)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if !interactive() {
+		for _, v := range cfg.Variables {
+			if _, ok := vars[v.Name]; !ok && v.Secret {
+				return nil, fmt.Errorf("secret variable %s is not recorded in %s; run update in a terminal to enter it", v.Name, provenance.File)
+			}
+		}
+	}
+	if err := promptVariables(cfg.Variables, vars); err != nil {
+		return nil, err
+	}
+
+	if err := applyTemplate(dir, vars, false); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// mergeResult lists the files changed by mergeTemplate.
+type mergeResult struct {
+	Updated   []string
+	Conflicts []string
+}
+
+// mergeTemplate applies the changes between the rendered base and new
+// template trees to the project in oursDir. Files the project changed in
+// the same places as the template are written with conflict markers; files
+// that cannot be merged line by line are left untouched and reported.
+func mergeTemplate(baseDir, oursDir, newDir string) (*mergeResult, error) {
+	paths, err := templateFiles(baseDir, newDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &mergeResult{}
+	for _, rel := range paths {
+		base, err := readOptional(filepath.Join(baseDir, rel))
+		if err != nil {
+			return nil, err
+		}
+		theirs, err := readOptional(filepath.Join(newDir, rel))
+		if err != nil {
+			return nil, err
+		}
+		oursPath := filepath.Join(oursDir, rel)
+		ours, err := readOptional(oursPath)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case sameContent(base, theirs), sameContent(ours, theirs):
+			continue
+		case sameContent(ours, base):
+			if err := applyFile(oursPath, filepath.Join(newDir, rel), theirs); err != nil {
+				return nil, err
+			}
+			result.Updated = append(result.Updated, rel)
+			continue
+		case ours == nil, theirs == nil, isBinary(ours), isBinary(theirs):
+			result.Conflicts = append(result.Conflicts, rel)
+			continue
+		}
+
+		merged, conflict := merge.ThreeWay(base, ours, theirs)
+		if err := os.WriteFile(oursPath, merged, 0o600); err != nil {
+			return nil, err
+		}
+		if conflict {
+			result.Conflicts = append(result.Conflicts, rel)
+		} else {
+			result.Updated = append(result.Updated, rel)
+		}
+	}
+
+	return result, nil
+}
+
+// templateFiles returns the sorted union of file paths in the given trees.
+func templateFiles(dirs ...string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" || d.Name() == provenance.Dir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, path)
+			seen[rel] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// readOptional reads a file, returning nil if it does not exist.
+func readOptional(path string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if data == nil && err == nil {
+		data = []byte{}
+	}
+	return data, err
+}
+
+// sameContent compares file contents, treating nil as a missing file.
+func sameContent(a, b []byte) bool {
+	if (a == nil) != (b == nil) {
+		return false
+	}
+	return bytes.Equal(a, b)
+}
+
+// applyFile writes the new template version of a file, or removes it
+// if the template no longer contains it.
+func applyFile(path, srcPath string, data []byte) error {
+	if data == nil {
+		return os.Remove(path)
+	}
+
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, info.Mode())
+}
+
+// isBinary reports whether data looks like binary content.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data, 0) != -1
+}
+
+// shortCommit abbreviates a commit hash for display.
+func shortCommit(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+// Package gohatch provides helpers for testing gohatch templates.
+package gohatch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// HashTree computes a stable hash of the tree below dir, covering the
+// relative path, type and permission bits of every entry and the contents
+// of regular files and symlink targets. The .git directory is ignored.
+//
+// Scaffold with --no-git-init and --no-provenance to get reproducible
+// hashes, since provenance records the generation time.
+func HashTree(dir string) (string, error) {
+	var lines []string
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var data []byte
+		switch {
+		case info.Mode().IsRegular():
+			data, err = os.ReadFile(filepath.Clean(path))
+		case info.Mode()&fs.ModeSymlink != 0:
+			var target string
+			target, err = os.Readlink(path)
+			data = []byte(filepath.ToSlash(target))
+		}
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		lines = append(lines, fmt.Sprintf("%x %s %s\n", sum, info.Mode().Type()|info.Mode().Perm(), filepath.ToSlash(relPath)))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("hashing %s: %w", dir, err)
+	}
+
+	sort.Strings(lines)
+
+	h := sha256.New()
+	for _, line := range lines {
+		h.Write([]byte(line))
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package gohatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree creates a small project tree and returns its root.
+func writeTree(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cmd", "app"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/me/app\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cmd", "app", "main.go"), []byte("package main\n"), 0o644))
+	return dir
+}
+
+func TestHashTree(t *testing.T) {
+	t.Run("identical trees hash equal", func(t *testing.T) {
+		a, err := HashTree(writeTree(t))
+		require.NoError(t, err)
+		b, err := HashTree(writeTree(t))
+		require.NoError(t, err)
+
+		assert.Equal(t, a, b)
+		assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, a)
+	})
+
+	t.Run("ignores the git directory", func(t *testing.T) {
+		dir := writeTree(t)
+		want, err := HashTree(dir)
+		require.NoError(t, err)
+
+		require.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0o644))
+
+		got, err := HashTree(dir)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	changes := map[string]func(t *testing.T, dir string){
+		"content": func(t *testing.T, dir string) {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/me/apq\n"), 0o644))
+		},
+		"mode": func(t *testing.T, dir string) {
+			require.NoError(t, os.Chmod(filepath.Join(dir, "go.mod"), 0o755))
+		},
+		"path": func(t *testing.T, dir string) {
+			require.NoError(t, os.Rename(filepath.Join(dir, "cmd", "app"), filepath.Join(dir, "cmd", "apq")))
+		},
+		"empty directory": func(t *testing.T, dir string) {
+			require.NoError(t, os.Mkdir(filepath.Join(dir, "docs"), 0o755))
+		},
+	}
+
+	for name, change := range changes {
+		t.Run(name+" changes the hash", func(t *testing.T) {
+			dir := writeTree(t)
+			before, err := HashTree(dir)
+			require.NoError(t, err)
+
+			change(t, dir)
+
+			after, err := HashTree(dir)
+			require.NoError(t, err)
+			assert.NotEqual(t, before, after)
+		})
+	}
+
+	t.Run("missing directory", func(t *testing.T) {
+		_, err := HashTree(filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+}
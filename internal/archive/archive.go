@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+// Package archive writes scaffolded projects as compressed tarballs.
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// WriteTarGz writes the tree at dir as a gzip-compressed tarball to dest.
+// Entries are named by their slash-separated path relative to dir below
+// prefix, keep their file modes and modification times, and are owned by
+// the extracting user. Symlinks are stored as links. The archive is
+// written to a temporary file next to dest and renamed into place, so a
+// failed write never leaves a partial archive behind.
+func WriteTarGz(dest, dir, prefix string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(dest), ".gohatch-archive-*")
+	if err != nil {
+		return fmt.Errorf("creating archive: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	if err := addTree(tw, dir, prefix); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+
+	// CreateTemp restricts the file to its owner
+	if err := os.Chmod(f.Name(), 0o644); err != nil { //nolint:gosec // archives are shared like any build output
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	if err := os.Rename(f.Name(), dest); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+	return nil
+}
+
+// addTree writes a tar entry for every file, directory, and symlink in
+// dir, in lexical order.
+func addTree(tw *tar.Writer, dir, prefix string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(prefix, filepath.ToSlash(relPath))
+		if name == "." {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return fmt.Errorf("reading link %s: %w", relPath, err)
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("archiving %s: %w", relPath, err)
+		}
+		hdr.Name = name
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("archiving %s: %w", relPath, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(tw, p, relPath)
+	})
+}
+
+// copyFile writes the contents of the file at p to tw.
+func copyFile(tw *tar.Writer, p, relPath string) error {
+	f, err := os.Open(filepath.Clean(p))
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", relPath, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("archiving %s: %w", relPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEntries returns the headers and file contents of a tarball.
+func readEntries(t *testing.T, file string) ([]*tar.Header, map[string]string) {
+	t.Helper()
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	var headers []*tar.Header
+	contents := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return headers, contents
+		}
+		require.NoError(t, err)
+		headers = append(headers, hdr)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		contents[hdr.Name] = string(data)
+	}
+}
+
+func TestWriteTarGz(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "scripts"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "scripts", "run.sh"), []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, os.Symlink("scripts/run.sh", filepath.Join(dir, "run")))
+
+	dest := filepath.Join(t.TempDir(), "app.tar.gz")
+	require.NoError(t, WriteTarGz(dest, dir, "app"))
+
+	headers, contents := readEntries(t, dest)
+	names := make([]string, 0, len(headers))
+	modes := make(map[string]int64)
+	for _, hdr := range headers {
+		names = append(names, hdr.Name)
+		modes[hdr.Name] = hdr.Mode
+		assert.Zero(t, hdr.Uid, hdr.Name)
+		assert.Empty(t, hdr.Uname, hdr.Name)
+		if hdr.Name == "app/run" {
+			assert.Equal(t, byte(tar.TypeSymlink), hdr.Typeflag)
+			assert.Equal(t, "scripts/run.sh", hdr.Linkname)
+		}
+	}
+
+	assert.Equal(t, []string{"app/", "app/go.mod", "app/run", "app/scripts/", "app/scripts/run.sh"}, names)
+	assert.Equal(t, "module example.com/app\n", contents["app/go.mod"])
+	assert.Equal(t, int64(0o755), modes["app/scripts/run.sh"]&0o777)
+	assert.Equal(t, int64(0o644), modes["app/go.mod"]&0o777)
+
+	info, err := os.Stat(dest)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+}
+
+func TestWriteTarGz_NoPrefix(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644))
+
+	dest := filepath.Join(t.TempDir(), "app.tar.gz")
+	require.NoError(t, WriteTarGz(dest, dir, ""))
+
+	_, contents := readEntries(t, dest)
+	assert.Equal(t, map[string]string{"main.go": "package main\n"}, contents)
+}
+
+func TestWriteTarGz_LeavesNoPartialArchive(t *testing.T) {
+	destDir := t.TempDir()
+	dest := filepath.Join(destDir, "app.tar.gz")
+
+	err := WriteTarGz(dest, filepath.Join(t.TempDir(), "missing"), "app")
+	require.Error(t, err)
+
+	entries, err := os.ReadDir(destDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
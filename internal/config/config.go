@@ -8,6 +8,73 @@ const ConfigFile = ".gohatch.toml"
 
 // Config represents the template configuration.
 type Config struct {
-	Extensions []string `toml:"extensions"`
-	Version    int      `toml:"version"`
+	Extensions      []string          `toml:"extensions"`
+	GoExtensions    []string          `toml:"go_extensions"`
+	TextExtensions  []string          `toml:"text_extensions"`
+	AssetExtensions []string          `toml:"asset_extensions"`
+	VariableFiles   []string          `toml:"variable_files"`
+	Version         int               `toml:"version"`
+	NestedModules   string            `toml:"nested_modules"`
+	SkipDirs        []string          `toml:"skip_dirs"`
+	Include         []string          `toml:"include"`
+	Executable      []string          `toml:"executable"`
+	RenderTemplates bool              `toml:"render_templates"`
+	RenameMap       map[string]string `toml:"rename_map"`
+	OutputName      string            `toml:"output_name"`
+	Env             EnvConfig         `toml:"env"`
+	Placeholders    PlaceholderConfig `toml:"placeholders"`
+	Variables       []VariableConfig  `toml:"variables"`
+	Rewriters       []RewriterConfig  `toml:"rewriters"`
+
+	// DefaultSource is the template used when gohatch runs in this
+	// directory with only a module; it takes precedence over the user
+	// config. It has no effect inside templates.
+	DefaultSource string `toml:"default_source"`
+}
+
+// VariableConfig declares a variable the user is prompted for if it is
+// not set on the command line. Prompts follow the declaration order.
+// Rename and Content exclude the variable from path renaming or content
+// replacement when set to false. Secret values are read without echo and
+// are not recorded in the provenance file.
+type VariableConfig struct {
+	Name    string `toml:"name"`
+	Prompt  string `toml:"prompt"`
+	Default string `toml:"default"`
+	Group   string `toml:"group"`
+	Rename  *bool  `toml:"rename"`
+	Content *bool  `toml:"content"`
+	Secret  bool   `toml:"secret"`
+}
+
+// RenamesPaths reports whether the variable is substituted in path names.
+func (v VariableConfig) RenamesPaths() bool {
+	return v.Rename == nil || *v.Rename
+}
+
+// ReplacesContent reports whether the variable is substituted in file contents.
+func (v VariableConfig) ReplacesContent() bool {
+	return v.Content == nil || *v.Content
+}
+
+// PlaceholderConfig describes how variables are written in template files.
+// Nil Delimiters select the dunder-style __Name__ placeholders.
+type PlaceholderConfig struct {
+	Delimiters   []string `toml:"delimiters"`
+	WordBoundary bool     `toml:"word_boundary"`
+}
+
+// RewriterConfig maps files whose name matches Pattern to an external
+// command. The command receives the file on stdin and writes the
+// rewritten content to stdout. Command is run directly, not by a shell.
+type RewriterConfig struct {
+	Pattern string   `toml:"pattern"`
+	Command []string `toml:"command"`
+}
+
+// EnvConfig describes an optional .env file generated from variables.
+// An empty Path disables generation; empty Keys writes all variables.
+type EnvConfig struct {
+	Path string   `toml:"path"`
+	Keys []string `toml:"keys"`
 }
@@ -76,6 +76,228 @@ extensions = []
 		assert.Equal(t, 1, cfg.Version)
 		assert.Equal(t, []string{"toml", "yaml"}, cfg.Extensions)
 	})
+
+	t.Run("loads env section", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		content := `[env]
+path = ".env"
+keys = ["ProjectName", "Author"]
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, ".env", cfg.Env.Path)
+		assert.Equal(t, []string{"ProjectName", "Author"}, cfg.Env.Keys)
+	})
+
+	t.Run("loads placeholders section", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		content := `[placeholders]
+delimiters = ["{{", "}}"]
+word_boundary = true
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"{{", "}}"}, cfg.Placeholders.Delimiters)
+		assert.True(t, cfg.Placeholders.WordBoundary)
+	})
+
+	t.Run("keeps declared variables in file order", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		content := `[[variables]]
+name = "Author"
+prompt = "Author name"
+group = "Project"
+
+[[variables]]
+name = "License"
+default = "MIT"
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []VariableConfig{
+			{Name: "Author", Prompt: "Author name", Group: "Project"},
+			{Name: "License", Default: "MIT"},
+		}, cfg.Variables)
+	})
+
+	t.Run("defaults variables to all passes", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		content := `[[variables]]
+name = "Both"
+
+[[variables]]
+name = "ContentOnly"
+rename = false
+
+[[variables]]
+name = "RenameOnly"
+content = false
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		require.Len(t, cfg.Variables, 3)
+		assert.True(t, cfg.Variables[0].RenamesPaths())
+		assert.True(t, cfg.Variables[0].ReplacesContent())
+		assert.False(t, cfg.Variables[1].RenamesPaths())
+		assert.True(t, cfg.Variables[1].ReplacesContent())
+		assert.True(t, cfg.Variables[2].RenamesPaths())
+		assert.False(t, cfg.Variables[2].ReplacesContent())
+	})
+
+	t.Run("loads rename_map", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		content := `rename_map = { "dot.gitignore" = ".gitignore", "dot.env" = ".env" }
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"dot.gitignore": ".gitignore", "dot.env": ".env"}, cfg.RenameMap)
+	})
+
+	t.Run("returns error listing unknown keys", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		content := `extension = ["toml"]
+
+[[variables]]
+name = "Author"
+defualt = "Jane"
+
+[placeholders]
+delimiters = ["{{", "}}"]
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o644))
+
+		_, err := Load(dir)
+		require.Error(t, err)
+		assert.Equal(t, "unknown keys in .gohatch.toml: extension, variables.defualt", err.Error())
+	})
+
+	t.Run("accepts arbitrary rename_map keys", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		content := `extensions = ["toml"]
+rename_map = { "anything.txt" = "other.txt" }
+
+[env]
+path = ".env"
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"toml"}, cfg.Extensions)
+		assert.Equal(t, ".env", cfg.Env.Path)
+	})
+
+	t.Run("loads rewriters", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		content := `[[rewriters]]
+pattern = "*.json"
+command = ["jq", "."]
+`
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []RewriterConfig{{Pattern: "*.json", Command: []string{"jq", "."}}}, cfg.Rewriters)
+	})
+
+	t.Run("distinguishes empty text_extensions", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		require.NoError(t, os.WriteFile(configPath, []byte("text_extensions = []\n"), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.NotNil(t, cfg.TextExtensions)
+		assert.Empty(t, cfg.TextExtensions)
+	})
+
+	t.Run("distinguishes empty variable_files", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		require.NoError(t, os.WriteFile(configPath, []byte("variable_files = []\n"), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.NotNil(t, cfg.VariableFiles)
+		assert.Empty(t, cfg.VariableFiles)
+	})
+
+	t.Run("loads include", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		require.NoError(t, os.WriteFile(configPath, []byte(`include = ["cmd/**", "go.mod"]`+"\n"), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"cmd/**", "go.mod"}, cfg.Include)
+	})
+
+	t.Run("loads executable", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		require.NoError(t, os.WriteFile(configPath, []byte(`executable = ["scripts/*.sh", "gradlew"]`+"\n"), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"scripts/*.sh", "gradlew"}, cfg.Executable)
+	})
+
+	t.Run("loads output_name", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		require.NoError(t, os.WriteFile(configPath, []byte(`output_name = "__ProjectName__-svc"`+"\n"), 0o644))
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "__ProjectName__-svc", cfg.OutputName)
+	})
+
+	t.Run("distinguishes unset and empty skip_dirs", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, ConfigFile)
+
+		cfg, err := Load(dir)
+		require.NoError(t, err)
+		assert.Nil(t, cfg.SkipDirs)
+
+		require.NoError(t, os.WriteFile(configPath, []byte("skip_dirs = []\n"), 0o644))
+
+		cfg, err = Load(dir)
+		require.NoError(t, err)
+		assert.NotNil(t, cfg.SkipDirs)
+		assert.Empty(t, cfg.SkipDirs)
+	})
 }
 
 func TestExists(t *testing.T) {
@@ -113,3 +335,60 @@ func TestRemove(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestUserConfigPath(t *testing.T) {
+	t.Run("uses XDG_CONFIG_HOME", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", "/xdg")
+
+		path, err := UserConfigPath()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join("/xdg", "gohatch", UserConfigFile), path)
+	})
+
+	t.Run("falls back to ~/.config", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", "")
+		t.Setenv("HOME", home)
+
+		path, err := UserConfigPath()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(home, ".config", "gohatch", UserConfigFile), path)
+	})
+}
+
+func TestLoadUser(t *testing.T) {
+	t.Run("loads valid config", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), UserConfigFile)
+		content := `extensions = ["toml", "justfile"]
+default_host = "codeberg.org"
+token = "secret"
+template_dir = "/srv/templates"
+
+[hosts."gitlab.com"]
+token = "gitlab-secret"
+`
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		cfg, err := LoadUser(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"toml", "justfile"}, cfg.Extensions)
+		assert.Equal(t, "codeberg.org", cfg.DefaultHost)
+		assert.Equal(t, "secret", cfg.Token)
+		assert.Equal(t, "/srv/templates", cfg.TemplateDir)
+		assert.Equal(t, map[string]HostConfig{"gitlab.com": {Token: "gitlab-secret"}}, cfg.Hosts)
+	})
+
+	t.Run("returns empty config when file not found", func(t *testing.T) {
+		cfg, err := LoadUser(filepath.Join(t.TempDir(), UserConfigFile))
+		require.NoError(t, err)
+		assert.Equal(t, &UserConfig{}, cfg)
+	})
+
+	t.Run("returns error for invalid TOML", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), UserConfigFile)
+		require.NoError(t, os.WriteFile(path, []byte("extensions = ["), 0o600))
+
+		_, err := LoadUser(path)
+		assert.Error(t, err)
+	})
+}
@@ -5,15 +5,18 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
 
 // Load reads the config from a .gohatch.toml file in the given directory.
 // Returns an empty Config if no config file exists.
-// Version defaults to 1 if not specified.
+// Version defaults to 1 if not specified. Unknown keys are an error, so
+// misspelled settings do not go unnoticed.
 func Load(dir string) (*Config, error) {
 	configPath := filepath.Join(dir, ConfigFile)
 
@@ -26,9 +29,17 @@ func Load(dir string) (*Config, error) {
 	}
 
 	var cfg Config
-	if err := toml.Unmarshal(data, &cfg); err != nil {
+	md, err := toml.Decode(string(data), &cfg)
+	if err != nil {
 		return nil, err
 	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return nil, fmt.Errorf("unknown keys in %s: %s", ConfigFile, strings.Join(keys, ", "))
+	}
 
 	// Default version to 1 if not specified
 	if cfg.Version == 0 {
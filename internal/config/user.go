@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// UserConfigFile is the name of the user configuration file
+// inside the gohatch config directory.
+const UserConfigFile = "config.toml"
+
+// UserConfig holds user-level defaults that apply to every run.
+type UserConfig struct {
+	Extensions    []string `toml:"extensions"`
+	DefaultHost   string   `toml:"default_host"`
+	Token         string   `toml:"token"`
+	TemplateDir   string   `toml:"template_dir"`
+	DefaultSource string   `toml:"default_source"`
+	BenignEntries []string `toml:"benign_entries"`
+
+	// Hosts holds per-host settings keyed by host name.
+	Hosts map[string]HostConfig `toml:"hosts"`
+}
+
+// HostConfig holds the settings for a single Git host.
+type HostConfig struct {
+	Token string `toml:"token"`
+}
+
+// UserConfigPath returns the path of the user config file,
+// $XDG_CONFIG_HOME/gohatch/config.toml or ~/.config/gohatch/config.toml.
+func UserConfigPath() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "gohatch", UserConfigFile), nil
+}
+
+// LoadUser reads the user config from path.
+// Returns an empty UserConfig if the file does not exist.
+func LoadUser(path string) (*UserConfig, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is the user's own config file
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &UserConfig{}, nil
+		}
+		return nil, err
+	}
+
+	var cfg UserConfig
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package merge
+
+import (
+	"bytes"
+)
+
+// Conflict markers written around conflicting hunks.
+const (
+	markerCurrent  = "<<<<<<< current\n"
+	markerSplit    = "=======\n"
+	markerTemplate = ">>>>>>> template\n"
+)
+
+// maxLCSCells bounds the LCS table size to keep memory use predictable.
+// Larger inputs are treated as a single conflicting hunk.
+const maxLCSCells = 16 << 20
+
+// ThreeWay merges the changes from base to theirs into ours, line by line.
+// Returns the merged content and whether conflicts were found. Conflicting
+// hunks are wrapped in Git-style conflict markers.
+func ThreeWay(base, ours, theirs []byte) ([]byte, bool) {
+	b, o, t := splitLines(base), splitLines(ours), splitLines(theirs)
+
+	mo := matchLines(b, o)
+	mt := matchLines(b, t)
+	if mo == nil || mt == nil {
+		return conflictHunk(ours, theirs), true
+	}
+
+	var out bytes.Buffer
+	conflict := false
+	ib, io, it := 0, 0, 0
+
+	for ib < len(b) || io < len(o) || it < len(t) {
+		// Stable line: unchanged in both ours and theirs
+		if ib < len(b) && mo[ib] == io && mt[ib] == it {
+			out.Write(b[ib])
+			ib, io, it = ib+1, io+1, it+1
+			continue
+		}
+
+		// Find the next base line matched in both versions
+		jb := ib
+		for jb < len(b) && (mo[jb] < 0 || mt[jb] < 0) {
+			jb++
+		}
+		jo, jt := len(o), len(t)
+		if jb < len(b) {
+			jo, jt = mo[jb], mt[jb]
+		}
+
+		hb, ho, ht := b[ib:jb], o[io:jo], t[it:jt]
+		switch {
+		case equalLines(ho, hb):
+			writeLines(&out, ht)
+		case equalLines(ht, hb), equalLines(ho, ht):
+			writeLines(&out, ho)
+		default:
+			conflict = true
+			out.WriteString(markerCurrent)
+			writeLines(&out, ho)
+			out.WriteString(markerSplit)
+			writeLines(&out, ht)
+			out.WriteString(markerTemplate)
+		}
+		ib, io, it = jb, jo, jt
+	}
+
+	return out.Bytes(), conflict
+}
+
+// splitLines splits data into lines, keeping line terminators.
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, data)
+			break
+		}
+		lines = append(lines, data[:i+1])
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// matchLines computes a longest common subsequence between a and b and
+// returns, for each line of a, the index of its matching line in b or -1.
+// Returns nil if the inputs are too large to compare.
+func matchLines(a, b [][]byte) []int {
+	n, m := len(a), len(b)
+	if (n+1)*(m+1) > maxLCSCells {
+		return nil
+	}
+
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if bytes.Equal(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	match := make([]int, n)
+	i, j := 0, 0
+	for i < n {
+		switch {
+		case j < m && bytes.Equal(a[i], b[j]):
+			match[i] = j
+			i, j = i+1, j+1
+		case j < m && lcs[i][j+1] >= lcs[i+1][j]:
+			j++
+		default:
+			match[i] = -1
+			i++
+		}
+	}
+	return match
+}
+
+func equalLines(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(buf *bytes.Buffer, lines [][]byte) {
+	for _, l := range lines {
+		buf.Write(l)
+	}
+}
+
+// conflictHunk wraps complete files in conflict markers.
+func conflictHunk(ours, theirs []byte) []byte {
+	var out bytes.Buffer
+	out.WriteString(markerCurrent)
+	out.Write(ours)
+	if len(ours) > 0 && ours[len(ours)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+	out.WriteString(markerSplit)
+	out.Write(theirs)
+	if len(theirs) > 0 && theirs[len(theirs)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+	out.WriteString(markerTemplate)
+	return out.Bytes()
+}
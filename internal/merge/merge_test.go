@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThreeWay_NonOverlappingChanges(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\n"
+	ours := "one\ntwo (local)\nthree\nfour\n"
+	theirs := "one\ntwo\nthree\nfour (upstream)\nfive\n"
+
+	merged, conflict := ThreeWay([]byte(base), []byte(ours), []byte(theirs))
+	assert.False(t, conflict)
+	assert.Equal(t, "one\ntwo (local)\nthree\nfour (upstream)\nfive\n", string(merged))
+}
+
+func TestThreeWay_OnlyTheirsChanged(t *testing.T) {
+	base := "a\nb\n"
+	theirs := "a\nb\nc\n"
+
+	merged, conflict := ThreeWay([]byte(base), []byte(base), []byte(theirs))
+	assert.False(t, conflict)
+	assert.Equal(t, theirs, string(merged))
+}
+
+func TestThreeWay_SameChangeOnBothSides(t *testing.T) {
+	base := "a\nb\n"
+	changed := "a\nB\n"
+
+	merged, conflict := ThreeWay([]byte(base), []byte(changed), []byte(changed))
+	assert.False(t, conflict)
+	assert.Equal(t, changed, string(merged))
+}
+
+func TestThreeWay_Conflict(t *testing.T) {
+	base := "one\ntwo\nthree\n"
+	ours := "one\nlocal\nthree\n"
+	theirs := "one\nupstream\nthree\n"
+
+	merged, conflict := ThreeWay([]byte(base), []byte(ours), []byte(theirs))
+	assert.True(t, conflict)
+	assert.Equal(t, "one\n<<<<<<< current\nlocal\n=======\nupstream\n>>>>>>> template\nthree\n", string(merged))
+}
+
+func TestThreeWay_EmptyBase(t *testing.T) {
+	merged, conflict := ThreeWay(nil, []byte("ours\n"), []byte("theirs\n"))
+	assert.True(t, conflict)
+	assert.Contains(t, string(merged), "ours\n=======\ntheirs\n")
+}
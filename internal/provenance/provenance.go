@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package provenance
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Dir is the directory holding gohatch metadata in generated projects.
+const Dir = ".gohatch"
+
+// File is the provenance file path relative to the project root.
+const File = Dir + "/provenance.toml"
+
+// PendingFile marks a project whose template was fetched but not yet
+// completely rewritten, so an interrupted run can be resumed.
+const PendingFile = Dir + "/pending"
+
+// Record describes how a project was generated from a template. Besides
+// the source and variables, it keeps the command line settings that
+// change the rendered files, so the template can be rendered again the
+// same way.
+type Record struct {
+	Source             string            `toml:"source"`
+	URL                string            `toml:"url,omitempty"`
+	Path               string            `toml:"path,omitempty"`
+	Version            string            `toml:"version,omitempty"`
+	Commit             string            `toml:"commit,omitempty"`
+	Module             string            `toml:"module"`
+	Extensions         []string          `toml:"extensions,omitempty"`
+	ModuleExtensions   []string          `toml:"module_extensions,omitempty"`
+	VariableExtensions []string          `toml:"variable_extensions,omitempty"`
+	SkipDirs           []string          `toml:"skip_dirs,omitempty"`
+	Include            []string          `toml:"include,omitempty"`
+	FromModule         string            `toml:"from_module,omitempty"`
+	GoVersion          string            `toml:"go_version,omitempty"`
+	Toolchain          string            `toml:"toolchain,omitempty"`
+	Replace            []string          `toml:"replace,omitempty"`
+	InitModule         bool              `toml:"init_module,omitempty"`
+	NoModuleRewrite    bool              `toml:"no_module_rewrite,omitempty"`
+	RewriteVendor      bool              `toml:"rewrite_vendor,omitempty"`
+	RewriteComments    bool              `toml:"rewrite_comments,omitempty"`
+	Goimports          bool              `toml:"goimports,omitempty"`
+	KeepConfig         bool              `toml:"keep_config,omitempty"`
+	ParentModule       bool              `toml:"parent_module,omitempty"`
+	MaxRewriteSize     int64             `toml:"max_rewrite_size,omitempty"`
+	Timestamp          time.Time         `toml:"timestamp"`
+	Variables          map[string]string `toml:"variables"`
+}
+
+// Write stores the record in the given project directory.
+func Write(dir string, r *Record) error {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(r); err != nil {
+		return fmt.Errorf("encoding provenance: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, Dir), 0o750); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, filepath.FromSlash(File)), buf.Bytes(), 0o600)
+}
+
+// Read loads the record from the given project directory.
+func Read(dir string) (*Record, error) {
+	path := filepath.Join(dir, filepath.FromSlash(File))
+
+	var r Record
+	if _, err := toml.DecodeFile(path, &r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// MarkPending creates the PendingFile marker in the given project directory.
+func MarkPending(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, Dir), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, filepath.FromSlash(PendingFile)), nil, 0o600)
+}
+
+// IsPending reports whether the given project directory carries the
+// PendingFile marker.
+func IsPending(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(PendingFile)))
+	return err == nil
+}
+
+// ClearPending removes the PendingFile marker and the metadata directory
+// if nothing else is left in it.
+func ClearPending(dir string) error {
+	if err := os.Remove(filepath.Join(dir, filepath.FromSlash(PendingFile))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	metaDir := filepath.Join(dir, Dir)
+	entries, err := os.ReadDir(metaDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(entries) == 0 {
+		return os.Remove(metaDir)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package provenance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRead(t *testing.T) {
+	dir := t.TempDir()
+
+	want := &Record{
+		Source:     "user/template@v1.0.0",
+		URL:        "https://github.com/user/template",
+		Version:    "v1.0.0",
+		Commit:     "0123456789abcdef0123456789abcdef01234567",
+		Module:     "github.com/me/myapp",
+		Extensions: []string{"toml"},
+		SkipDirs:   []string{""},
+		GoVersion:  "1.23.0",
+		Replace:    []string{"Acme=Example"},
+		Goimports:  true,
+		Timestamp:  time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC),
+		Variables:  map[string]string{"ProjectName": "myapp", "Author": "Oliver Andrich"},
+	}
+	require.NoError(t, Write(dir, want))
+
+	got, err := Read(dir)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestReadMissing(t *testing.T) {
+	_, err := Read(t.TempDir())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPending(t *testing.T) {
+	dir := t.TempDir()
+	assert.False(t, IsPending(dir))
+
+	require.NoError(t, MarkPending(dir))
+	assert.True(t, IsPending(dir))
+
+	require.NoError(t, ClearPending(dir))
+	assert.False(t, IsPending(dir))
+	assert.NoDirExists(t, filepath.Join(dir, Dir), "empty metadata directory is removed")
+
+	// Clearing keeps the provenance record and tolerates a missing marker
+	require.NoError(t, MarkPending(dir))
+	require.NoError(t, Write(dir, &Record{Source: "user/template"}))
+	require.NoError(t, ClearPending(dir))
+	require.NoError(t, ClearPending(dir))
+	assert.FileExists(t, filepath.Join(dir, filepath.FromSlash(File)))
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ConfusableChars describes the non-ASCII characters in a module path,
+// such as a Cyrillic а that is indistinguishable from a Latin a. Each
+// character is listed once, in order of appearance. Pure-ASCII paths
+// return nil.
+func ConfusableChars(path string) []string {
+	var chars []string
+	seen := make(map[rune]bool)
+	for _, r := range path {
+		if r < utf8.RuneSelf || seen[r] {
+			continue
+		}
+		seen[r] = true
+		chars = append(chars, fmt.Sprintf("%q (U+%04X %s)", r, r, scriptName(r)))
+	}
+	return chars
+}
+
+// scriptName returns the name of the Unicode script r belongs to.
+func scriptName(r rune) string {
+	for name, table := range unicode.Scripts {
+		if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+			return name
+		}
+	}
+	return "symbol"
+}
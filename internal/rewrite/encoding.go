@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"unicode/utf16"
+)
+
+// textEncoding identifies how a text file is encoded.
+type textEncoding int
+
+const (
+	encodingUTF8 textEncoding = iota
+	encodingUTF16LE
+	encodingUTF16BE
+)
+
+var (
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// byteOrder returns the byte order of a UTF-16 encoding.
+func (e textEncoding) byteOrder() binary.ByteOrder {
+	if e == encodingUTF16BE {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// decodeText converts data to UTF-8 for matching and replacement. Files
+// starting with a UTF-16 byte order mark are decoded; all other files are
+// returned unchanged. ok is false if the UTF-16 content would not survive
+// re-encoding, e.g. because of a truncated code unit or a lone surrogate.
+func decodeText(data []byte) (text []byte, enc textEncoding, ok bool) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF16LE):
+		enc = encodingUTF16LE
+	case bytes.HasPrefix(data, bomUTF16BE):
+		enc = encodingUTF16BE
+	default:
+		return data, encodingUTF8, true
+	}
+
+	body := data[len(bomUTF16LE):]
+	if len(body)%2 != 0 {
+		return nil, enc, false
+	}
+
+	order := enc.byteOrder()
+	units := make([]uint16, len(body)/2)
+	for i := range units {
+		units[i] = order.Uint16(body[2*i:])
+	}
+
+	runes := utf16.Decode(units)
+	if !slices.Equal(utf16.Encode(runes), units) {
+		return nil, enc, false
+	}
+	return []byte(string(runes)), enc, true
+}
+
+// encodeText converts UTF-8 text back to enc, including its byte order mark.
+func encodeText(text []byte, enc textEncoding) []byte {
+	if enc == encodingUTF8 {
+		return text
+	}
+
+	units := utf16.Encode([]rune(string(text)))
+	order := enc.byteOrder()
+
+	bom := bomUTF16LE
+	if enc == encodingUTF16BE {
+		bom = bomUTF16BE
+	}
+
+	data := make([]byte, len(bom)+2*len(units))
+	copy(data, bom)
+	for i, u := range units {
+		order.PutUint16(data[len(bom)+2*i:], u)
+	}
+	return data
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// WriteEnv writes variables as KEY=value lines to envPath inside dir.
+// If keys is empty, all variables are written in sorted order.
+// Keys without a value are written with an empty value.
+func WriteEnv(dir, envPath string, vars map[string]string, keys []string) error {
+	if !filepath.IsLocal(envPath) {
+		return fmt.Errorf("env path %s must be relative to the project", envPath)
+	}
+
+	if len(keys) == 0 {
+		keys = make([]string, 0, len(vars))
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+	}
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key + "=" + quoteEnvValue(vars[key]) + "\n")
+	}
+
+	target := filepath.Join(dir, envPath)
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", envPath, err)
+	}
+
+	return os.WriteFile(target, []byte(b.String()), 0o600)
+}
+
+// quoteEnvValue wraps values containing whitespace or shell-sensitive
+// characters in double quotes, escaping backslashes and quotes.
+func quoteEnvValue(value string) string {
+	if !strings.ContainsAny(value, " \t\n\"'#$\\") {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(value) + `"`
+}
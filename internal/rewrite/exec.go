@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Rewriter transforms the content of files whose name matches Pattern,
+// a filepath.Match pattern such as *.json.
+type Rewriter struct {
+	Pattern string
+	Rewrite func(path string, content []byte) ([]byte, error)
+}
+
+// VarEnvPrefix prefixes variables passed to rewriter commands, so that
+// a variable named PATH does not replace the real one.
+const VarEnvPrefix = "GOHATCH_VAR_"
+
+// CommandEnv returns the environment entries passed to rewriter
+// commands: OLD_MODULE, NEW_MODULE, and each variable prefixed with
+// VarEnvPrefix.
+func CommandEnv(oldModule, newModule string, vars map[string]string) []string {
+	env := []string{"OLD_MODULE=" + oldModule, "NEW_MODULE=" + newModule}
+	for key, value := range vars {
+		env = append(env, VarEnvPrefix+key+"="+value)
+	}
+	return env
+}
+
+// CommandRewriter returns a Rewriter that runs command with the file on
+// stdin and env added to the environment, and uses its stdout as the new
+// content. The command runs in dir, the root of the template, so a
+// relative command such as ./tools/rewrite.sh resolves the same way for
+// every file.
+func CommandRewriter(dir, pattern string, command, env []string) Rewriter {
+	return Rewriter{
+		Pattern: pattern,
+		Rewrite: func(_ string, content []byte) ([]byte, error) {
+			cmd := exec.Command(command[0], command[1:]...) //nolint:gosec // commands are opted into by the user
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), env...)
+			cmd.Stdin = bytes.NewReader(content)
+
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				if msg := strings.TrimSpace(stderr.String()); msg != "" {
+					return nil, fmt.Errorf("%s: %w: %s", command[0], err, msg)
+				}
+				return nil, fmt.Errorf("%s: %w", command[0], err)
+			}
+			return stdout.Bytes(), nil
+		},
+	}
+}
+
+// ApplyRewriters runs the first matching rewriter on every file in dir,
+// skipping directories named in skipDirs (nil selects DefaultSkipDirs)
+// and files larger than maxSize bytes unless maxSize is 0.
+// Returns the list of modified files.
+func ApplyRewriters(dir string, rewriters []Rewriter, skipDirs []string, maxSize int64) ([]string, error) {
+	if len(rewriters) == 0 {
+		return nil, nil
+	}
+	for _, r := range rewriters {
+		if _, err := filepath.Match(r.Pattern, ""); err != nil || r.Pattern == "" {
+			return nil, fmt.Errorf("invalid rewriter pattern %q", r.Pattern)
+		}
+	}
+
+	var modifiedFiles []string
+
+	skip := skipDirSet(skipDirs)
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if skip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		r, ok := matchRewriter(rewriters, d.Name())
+		if !ok {
+			return nil
+		}
+		if tooLarge, err := exceedsSize(d, maxSize); err != nil || tooLarge {
+			return err
+		}
+
+		modified, err := rewriteWith(path, r)
+		if err != nil {
+			return err
+		}
+		if modified {
+			relPath, _ := filepath.Rel(dir, path)
+			modifiedFiles = append(modifiedFiles, relPath)
+		}
+		return nil
+	})
+
+	return modifiedFiles, err
+}
+
+// matchRewriter returns the first rewriter whose pattern matches name.
+func matchRewriter(rewriters []Rewriter, name string) (Rewriter, bool) {
+	for _, r := range rewriters {
+		if ok, _ := filepath.Match(r.Pattern, name); ok {
+			return r, true
+		}
+	}
+	return Rewriter{}, false
+}
+
+// rewriteWith replaces the content of the file at path with the output
+// of r. Returns true if the file was modified.
+func rewriteWith(path string, r Rewriter) (bool, error) {
+	cleanPath := filepath.Clean(path)
+	data, err := os.ReadFile(cleanPath)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", cleanPath, err)
+	}
+
+	newData, err := r.Rewrite(cleanPath, data)
+	if err != nil {
+		return false, fmt.Errorf("rewriting %s: %w", cleanPath, err)
+	}
+	if bytes.Equal(data, newData) {
+		return false, nil
+	}
+
+	info, err := os.Stat(cleanPath)
+	if err != nil {
+		return false, err
+	}
+
+	return true, os.WriteFile(cleanPath, newData, info.Mode())
+}
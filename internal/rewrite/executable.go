@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Executable adds the executable bits to every regular file in dir whose
+// slash-separated relative path matches one of patterns, using the same
+// syntax as Include. Each execute bit is set where the matching read bit
+// is, so a 0644 file becomes 0755. Directories and symlinks are left
+// alone. Returns the list of changed files.
+func Executable(dir string, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	for _, p := range patterns {
+		if err := checkGlob(p); err != nil {
+			return nil, err
+		}
+	}
+
+	var changed []string
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if !matchesAny(relPath, patterns) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode().Perm()
+		exec := mode | mode&0o444>>2
+		if exec == mode {
+			return nil
+		}
+		if err := os.Chmod(p, exec); err != nil {
+			return fmt.Errorf("making %s executable: %w", relPath, err)
+		}
+		changed = append(changed, relPath)
+		return nil
+	})
+
+	return changed, err
+}
+
+// matchesAny reports whether relPath matches one of patterns.
+func matchesAny(relPath string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
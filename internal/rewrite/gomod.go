@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// WriteGoMod creates a go.mod declaring newModule in dir. The go directive
+// is set to goVersion, or to the running toolchain's version if empty.
+func WriteGoMod(dir, newModule, goVersion string) error {
+	if err := module.CheckImportPath(newModule); err != nil {
+		return fmt.Errorf("invalid module path: %w", err)
+	}
+
+	if goVersion == "" {
+		goVersion = strings.TrimPrefix(runtime.Version(), "go")
+	}
+
+	f := new(modfile.File)
+	if err := f.AddModuleStmt(newModule); err != nil {
+		return err
+	}
+	// Development toolchains report versions such as devel +abc123
+	if CheckGoVersion(goVersion) == nil {
+		if err := f.AddGoStmt(goVersion); err != nil {
+			return err
+		}
+	}
+
+	data, err := f.Format()
+	if err != nil {
+		return fmt.Errorf("formatting go.mod: %w", err)
+	}
+
+	goModPath := filepath.Join(dir, "go.mod")
+	if err := os.WriteFile(goModPath, data, 0o600); err != nil {
+		return fmt.Errorf("writing %s: %w", goModPath, err)
+	}
+	return nil
+}
+
+// DetectModulePath guesses the module path the Go files in dir were
+// written for. An import such as example.com/old/internal/app, where
+// internal/app is a package directory of the template, votes for
+// example.com/old; the prefix with the most votes wins. Imports of
+// standard library packages are ignored. Directories named in skipDirs
+// (nil selects DefaultSkipDirs) and vendor are skipped.
+// Returns "" if no prefix is found.
+func DetectModulePath(dir string, skipDirs []string) (string, error) {
+	skip := moduleSkipDirSet(skipDirs, false)
+
+	pkgDirs := make(map[string]bool)
+	var importPaths []string
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if skip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		relDir, _ := filepath.Rel(dir, filepath.Dir(path))
+		pkgDirs[filepath.ToSlash(relDir)] = true
+
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Clean(path), nil, parser.ImportsOnly)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		for _, imp := range f.Imports {
+			if p, err := strconv.Unquote(imp.Path.Value); err == nil {
+				importPaths = append(importPaths, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	votes := make(map[string]int)
+	for _, p := range importPaths {
+		for pkgDir := range pkgDirs {
+			if pkgDir == "." {
+				continue
+			}
+			prefix, ok := strings.CutSuffix(p, "/"+pkgDir)
+			// Standard library paths have no dot in their first element
+			first, _, _ := strings.Cut(prefix, "/")
+			if ok && strings.Contains(first, ".") {
+				votes[prefix]++
+			}
+		}
+	}
+
+	best := ""
+	for prefix, n := range votes {
+		if n > votes[best] || n == votes[best] && prefix < best {
+			best = prefix
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/imports"
+)
+
+// Imports tidies imports in all .go files the way goimports does:
+// standard library imports are grouped first, imports are sorted, and
+// unused imports are removed. Imports below localPrefix are placed in
+// a separate group after third-party imports. Directories named in
+// skipDirs (nil selects DefaultSkipDirs) are skipped, as is vendor
+// unless rewriteVendor is set.
+// Returns the list of modified files.
+func Imports(dir, localPrefix string, rewriteVendor bool, skipDirs []string) ([]string, error) {
+	var modifiedFiles []string
+
+	skip := moduleSkipDirSet(skipDirs, rewriteVendor)
+
+	imports.LocalPrefix = localPrefix
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if skip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		modified, err := tidyImports(path)
+		if err != nil {
+			return err
+		}
+		if modified {
+			relPath, _ := filepath.Rel(dir, path)
+			modifiedFiles = append(modifiedFiles, relPath)
+		}
+		return nil
+	})
+
+	return modifiedFiles, err
+}
+
+// tidyImports runs goimports on a single file.
+// Returns true if the file was modified.
+func tidyImports(filePath string) (bool, error) {
+	cleanPath := filepath.Clean(filePath)
+	data, err := os.ReadFile(cleanPath)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", cleanPath, err)
+	}
+
+	newData, err := imports.Process(cleanPath, data, &imports.Options{
+		Comments:  true,
+		TabIndent: true,
+		TabWidth:  8,
+	})
+	if err != nil {
+		return false, fmt.Errorf("formatting %s: %w", cleanPath, err)
+	}
+
+	if bytes.Equal(data, newData) {
+		return false, nil
+	}
+
+	info, err := os.Stat(cleanPath)
+	if err != nil {
+		return false, err
+	}
+
+	return true, os.WriteFile(cleanPath, newData, info.Mode())
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// RequiredFiles are kept by Include regardless of the patterns, since
+// the module rewrite depends on them.
+var RequiredFiles = []string{"go.mod", "go.sum"}
+
+// Include removes every file in dir whose slash-separated relative path
+// matches none of patterns, except RequiredFiles. Patterns use
+// path.Match syntax per path element, plus ** for any number of
+// elements, e.g. cmd/** or internal/*/doc.go. A file is also included
+// if one of its parent directories matches. Directories left empty are
+// removed. Empty patterns include everything.
+// Returns the list of removed files.
+func Include(dir string, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	for _, p := range patterns {
+		if err := checkGlob(p); err != nil {
+			return nil, err
+		}
+	}
+
+	var removed []string
+	var dirs []string
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if d.IsDir() {
+			dirs = append(dirs, relPath)
+			return nil
+		}
+		if slices.Contains(RequiredFiles, relPath) || includedPath(relPath, patterns) {
+			return nil
+		}
+
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("removing %s: %w", relPath, err)
+		}
+		removed = append(removed, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove emptied directories deepest first, so parents become empty
+	// before they are checked
+	for _, d := range slices.Backward(dirs) {
+		if includedPath(d, patterns) {
+			continue
+		}
+		p := filepath.Join(dir, filepath.FromSlash(d))
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", d, err)
+		}
+		if len(entries) == 0 {
+			if err := os.Remove(p); err != nil {
+				return nil, fmt.Errorf("removing %s: %w", d, err)
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// checkGlob validates an include or executable pattern.
+func checkGlob(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("invalid pattern %q", pattern)
+	}
+	for _, elem := range strings.Split(pattern, "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// includedPath reports whether relPath or one of its parent directories
+// matches one of patterns.
+func includedPath(relPath string, patterns []string) bool {
+	for p := relPath; p != "."; p = path.Dir(p) {
+		for _, pattern := range patterns {
+			if matchGlob(pattern, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether the slash-separated name matches pattern,
+// where ** matches any number of path elements.
+func matchGlob(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
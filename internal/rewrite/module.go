@@ -5,68 +5,193 @@ package rewrite
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"go/ast"
 	"go/format"
 	"go/parser"
 	"go/token"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
 )
 
+// Options configures a module rewrite.
+type Options struct {
+	// ExtraExtensions lists additional file extensions or filenames
+	// that receive simple string replacement.
+	ExtraExtensions []string
+
+	// TextExtensions lists extensions of files that always receive
+	// simple string replacement, such as embedded code generation
+	// templates that do not parse as Go. Nil selects
+	// DefaultTextExtensions.
+	TextExtensions []string
+
+	// AssetExtensions lists extensions of frontend assets, such as
+	// generated TypeScript clients, that always receive simple string
+	// replacement. Nil selects DefaultAssetExtensions.
+	AssetExtensions []string
+
+	// GoExtensions lists additional extensions, such as go.golden or
+	// go.tmpl, of files that are rewritten like .go files. These files
+	// must parse as Go and are excluded from simple string replacement.
+	GoExtensions []string
+
+	// Nested selects how nested modules are renamed.
+	Nested NestedScheme
+
+	// GoVersion sets the go directive of every go.mod if non-empty.
+	GoVersion string
+
+	// Toolchain sets the toolchain directive of every go.mod if
+	// non-empty. ToolchainNone removes the directive.
+	Toolchain string
+
+	// RewriteVendor includes the vendor directory in the rewrite.
+	RewriteVendor bool
+
+	// SkipDirs lists directory names excluded from the rewrite. Nil
+	// selects DefaultSkipDirs. The vendor directory is skipped as well
+	// unless RewriteVendor is set.
+	SkipDirs []string
+
+	// RewriteComments rewrites module paths in all comments of .go
+	// files, not only in //go:generate directives.
+	RewriteComments bool
+
+	// FromModule is the import prefix to rewrite to the new module in
+	// addition to the module path declared in go.mod.
+	FromModule string
+
+	// MaxFileSize skips .go, .proto, and extra files larger than this
+	// many bytes. Zero disables the limit. go.mod files are always
+	// rewritten.
+	MaxFileSize int64
+}
+
+// DefaultTextExtensions lists the extensions of code generation
+// templates, whose import strings are rewritten like extra extensions.
+var DefaultTextExtensions = []string{"tmpl", "tpl"}
+
+// DefaultAssetExtensions lists the extensions of frontend assets of
+// full-stack templates, whose module path strings are rewritten like
+// extra extensions.
+var DefaultAssetExtensions = []string{"ts", "js", "html"}
+
+// CheckGoVersion validates a go directive version such as 1.23 or 1.23.0.
+func CheckGoVersion(version string) error {
+	if !modfile.GoVersionRE.MatchString(version) {
+		return fmt.Errorf("invalid go version %q: must match format 1.23.0", version)
+	}
+	return nil
+}
+
+// ToolchainNone removes the toolchain directive from go.mod.
+const ToolchainNone = "none"
+
+// CheckToolchain validates a toolchain directive such as go1.23.0, or
+// ToolchainNone.
+func CheckToolchain(toolchain string) error {
+	version, ok := strings.CutPrefix(toolchain, "go")
+	if toolchain != ToolchainNone && (!ok || !modfile.GoVersionRE.MatchString(version)) {
+		return fmt.Errorf("invalid toolchain %q: must match format go1.23.0 or be %q", toolchain, ToolchainNone)
+	}
+	return nil
+}
+
 // Module rewrites the module path in the given directory.
-// It updates go.mod, all import paths in .go files, and performs
-// string replacement in files with the specified extra extensions.
+// It updates every go.mod (the root and any nested modules), all import
+// paths and cgo preambles in .go files, the go_package option of .proto
+// files, and performs string replacement in files with the
+// configured extra extensions.
+// If no module path changes and neither GoVersion nor Toolchain is set,
+// no file is touched; to normalize references to another module path in
+// a template that already has the new module, set FromModule. Variable
+// substitution and path renaming are separate passes that callers run
+// regardless.
 // Returns the list of modified files.
-func Module(dir, newModule string, extraExtensions []string) ([]string, error) {
-	var modifiedFiles []string
-
-	// Read and parse go.mod
-	goModPath := filepath.Clean(filepath.Join(dir, "go.mod"))
-	data, err := os.ReadFile(goModPath)
-	if err != nil {
-		return nil, fmt.Errorf("reading go.mod: %w", err)
+func Module(dir, newModule string, opts Options) ([]string, error) {
+	if opts.GoVersion != "" {
+		if err := CheckGoVersion(opts.GoVersion); err != nil {
+			return nil, err
+		}
 	}
 
-	f, err := modfile.ParseLax(goModPath, data, nil)
-	if err != nil {
-		return nil, fmt.Errorf("parsing go.mod: %w", err)
+	if opts.Toolchain != "" {
+		if err := CheckToolchain(opts.Toolchain); err != nil {
+			return nil, err
+		}
 	}
 
-	oldModule := f.Module.Mod.Path
-	if oldModule == newModule {
-		return nil, nil // Nothing to do
+	if opts.FromModule != "" {
+		if err := module.CheckImportPath(opts.FromModule); err != nil {
+			return nil, fmt.Errorf("invalid old module path: %w", err)
+		}
 	}
 
-	// Update go.mod
-	err = f.AddModuleStmt(newModule)
+	skip := moduleSkipDirSet(opts.SkipDirs, opts.RewriteVendor)
+
+	mods, err := resolveModules(dir, newModule, opts.Nested, skip)
 	if err != nil {
-		return nil, fmt.Errorf("updating module statement: %w", err)
+		return nil, err
 	}
 
-	newData, err := f.Format()
-	if err != nil {
-		return nil, fmt.Errorf("formatting go.mod: %w", err)
+	changed := changedModules(mods)
+	if opts.FromModule != "" && opts.FromModule != newModule {
+		changed = append(changed, moduleInfo{Dir: ".", Old: opts.FromModule, New: newModule})
+		sort.SliceStable(changed, func(i, j int) bool {
+			return len(changed[i].Old) > len(changed[j].Old)
+		})
+	}
+	if len(changed) == 0 && opts.GoVersion == "" && opts.Toolchain == "" {
+		return nil, nil // Nothing to do
 	}
 
-	err = os.WriteFile(goModPath, newData, 0o600)
-	if err != nil {
-		return nil, fmt.Errorf("writing go.mod: %w", err)
+	var modifiedFiles []string
+
+	// Update all go.mod files
+	for _, m := range mods {
+		modified, err := rewriteGoMod(filepath.Join(dir, m.Dir), m, changed, opts.GoVersion, opts.Toolchain)
+		if err != nil {
+			return nil, err
+		}
+		if modified {
+			modifiedFiles = append(modifiedFiles, filepath.Join(m.Dir, "go.mod"))
+		}
 	}
-	modifiedFiles = append(modifiedFiles, "go.mod")
 
 	// Rewrite imports in all .go files
-	goFiles, err := rewriteGoFiles(dir, oldModule, newModule)
+	goFiles, err := rewriteGoFiles(dir, changed, opts.GoExtensions, skip, opts.RewriteComments, opts.MaxFileSize)
 	if err != nil {
 		return nil, fmt.Errorf("rewriting imports: %w", err)
 	}
 	modifiedFiles = append(modifiedFiles, goFiles...)
 
+	// Rewrite go_package options in .proto files
+	protoFiles, err := rewriteProtoFiles(dir, changed, opts.ExtraExtensions, skip, opts.MaxFileSize)
+	if err != nil {
+		return nil, fmt.Errorf("rewriting proto files: %w", err)
+	}
+	modifiedFiles = append(modifiedFiles, protoFiles...)
+
 	// Rewrite extra extension files with simple string replacement
-	if len(extraExtensions) > 0 {
-		extraFiles, err := rewriteExtraFiles(dir, oldModule, newModule, extraExtensions)
+	textExtensions := opts.TextExtensions
+	if textExtensions == nil {
+		textExtensions = DefaultTextExtensions
+	}
+	assetExtensions := opts.AssetExtensions
+	if assetExtensions == nil {
+		assetExtensions = DefaultAssetExtensions
+	}
+	if extra := slices.Concat(opts.ExtraExtensions, textExtensions); len(extra) > 0 || len(assetExtensions) > 0 {
+		extraFiles, err := rewriteExtraFiles(dir, changed, extra, assetExtensions, opts.GoExtensions, skip, opts.MaxFileSize)
 		if err != nil {
 			return nil, fmt.Errorf("rewriting extra files: %w", err)
 		}
@@ -76,9 +201,278 @@ func Module(dir, newModule string, extraExtensions []string) ([]string, error) {
 	return modifiedFiles, nil
 }
 
-// rewriteGoFiles walks through all .go files and rewrites import paths.
+// SelfRequires lists the go.mod files below dir, relative to dir, that
+// require their own module. Module drops these requirements when it
+// renames the module. Directories named in skipDirs (nil selects
+// DefaultSkipDirs) are skipped, as is vendor unless rewriteVendor is set.
+func SelfRequires(dir string, skipDirs []string, rewriteVendor bool) ([]string, error) {
+	dirs, err := findModuleDirs(dir, moduleSkipDirSet(skipDirs, rewriteVendor))
+	if err != nil {
+		return nil, fmt.Errorf("finding modules: %w", err)
+	}
+
+	var files []string
+	for _, rel := range dirs {
+		goModPath := filepath.Clean(filepath.Join(dir, rel, "go.mod"))
+		data, err := os.ReadFile(goModPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", goModPath, err)
+		}
+		f, err := modfile.ParseLax(goModPath, data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", goModPath, err)
+		}
+		if f.Module == nil {
+			continue
+		}
+		if slices.ContainsFunc(f.Require, func(r *modfile.Require) bool { return r.Mod.Path == f.Module.Mod.Path }) {
+			files = append(files, filepath.Join(rel, "go.mod"))
+		}
+	}
+	return files, nil
+}
+
+// rewriteGoMod updates the module statement of the go.mod in modDir,
+// any require, replace, or tool directives that refer to rewritten modules,
+// the go directive if goVersion is set, and the toolchain directive if
+// toolchain is set.
+// Returns true if the file was modified.
+func rewriteGoMod(modDir string, self moduleInfo, changed []moduleInfo, goVersion, toolchain string) (bool, error) {
+	goModPath := filepath.Clean(filepath.Join(modDir, "go.mod"))
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", goModPath, err)
+	}
+
+	f, err := parseMainGoMod(goModPath, data)
+	if err != nil {
+		return false, fmt.Errorf("parsing %s: %w", goModPath, err)
+	}
+
+	modified := false
+
+	// AddModuleStmt updates the existing directive in place, so inline
+	// and above-line comments on the module line are kept
+	if self.Old != self.New {
+		if err := f.AddModuleStmt(self.New); err != nil {
+			return false, fmt.Errorf("updating module statement: %w", err)
+		}
+		modified = true
+	}
+
+	if goVersion != "" && (f.Go == nil || f.Go.Version != goVersion) {
+		if err := f.AddGoStmt(goVersion); err != nil {
+			return false, fmt.Errorf("updating go directive: %w", err)
+		}
+		modified = true
+	}
+
+	switch {
+	case toolchain == ToolchainNone:
+		if f.Toolchain != nil {
+			f.DropToolchainStmt()
+			modified = true
+		}
+	case toolchain != "" && (f.Toolchain == nil || f.Toolchain.Name != toolchain):
+		if err := f.AddToolchainStmt(toolchain); err != nil {
+			return false, fmt.Errorf("updating toolchain directive: %w", err)
+		}
+		modified = true
+	}
+
+	for _, r := range slices.Clone(f.Require) {
+		mod := r.Mod
+
+		// A module cannot require itself; drop the requirement rather
+		// than carry it over to the new path
+		if mod.Path == self.Old && self.Old != self.New {
+			if err := f.DropRequire(mod.Path); err != nil {
+				return false, fmt.Errorf("dropping self-require %s: %w", mod.Path, err)
+			}
+			modified = true
+			continue
+		}
+
+		newPath, ok := lookupModule(changed, mod.Path)
+		if !ok {
+			continue
+		}
+		if err := f.DropRequire(mod.Path); err != nil {
+			return false, fmt.Errorf("updating require %s: %w", mod.Path, err)
+		}
+		if err := f.AddRequire(newPath, mod.Version); err != nil {
+			return false, fmt.Errorf("updating require %s: %w", mod.Path, err)
+		}
+		modified = true
+	}
+
+	for _, r := range slices.Clone(f.Replace) {
+		oldMod, newMod := r.Old, r.New
+		oldPath, oldOK := lookupModule(changed, oldMod.Path)
+		newPath, newOK := lookupModule(changed, newMod.Path)
+		if !oldOK && !newOK {
+			continue
+		}
+		if !oldOK {
+			oldPath = oldMod.Path
+		}
+		if !newOK {
+			newPath = newMod.Path
+		}
+		if err := f.DropReplace(oldMod.Path, oldMod.Version); err != nil {
+			return false, fmt.Errorf("updating replace %s: %w", oldMod.Path, err)
+		}
+		if err := f.AddReplace(oldPath, oldMod.Version, newPath, newMod.Version); err != nil {
+			return false, fmt.Errorf("updating replace %s: %w", oldMod.Path, err)
+		}
+		modified = true
+	}
+
+	// Excluded versions of rewritten modules are updated in place like
+	// tools below, so the directives keep their position in the file
+	for _, x := range f.Exclude {
+		newPath, ok := lookupModule(changed, x.Mod.Path)
+		if !ok {
+			continue
+		}
+		x.Mod.Path = newPath
+		x.Syntax.Token[len(x.Syntax.Token)-2] = modfile.AutoQuote(newPath)
+		modified = true
+	}
+
+	// Tool packages of rewritten modules move with them. The path is
+	// updated in place: AddTool sorts every block, which would reorder
+	// godebug settings
+	for _, tool := range f.Tool {
+		newPath := rewriteImportPath(tool.Path, changed)
+		if newPath == tool.Path {
+			continue
+		}
+		tool.Path = newPath
+		tool.Syntax.Token[len(tool.Syntax.Token)-1] = modfile.AutoQuote(newPath)
+		modified = true
+	}
+
+	if !modified {
+		return false, nil
+	}
+
+	f.Cleanup()
+	newData, err := f.Format()
+	if err != nil {
+		return false, fmt.Errorf("formatting %s: %w", goModPath, err)
+	}
+
+	if err := os.WriteFile(goModPath, newData, 0o600); err != nil {
+		return false, fmt.Errorf("writing %s: %w", goModPath, err)
+	}
+
+	return true, nil
+}
+
+// parseMainGoMod parses the go.mod of a main module. Files with
+// directives newer than x/mod are read with ParseLax instead, which skips
+// the directives that only apply to the main module; the ones gohatch
+// rewrites are read back from the syntax tree.
+func parseMainGoMod(path string, data []byte) (*modfile.File, error) {
+	f, err := modfile.Parse(path, data, nil)
+	if err == nil || !onlyUnknownDirectives(err) {
+		return f, err
+	}
+	f, err = modfile.ParseLax(path, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	addMainModuleDirectives(f)
+	return f, nil
+}
+
+// onlyUnknownDirectives reports whether every error in err is about an
+// unknown directive.
+func onlyUnknownDirectives(err error) bool {
+	var errs modfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) == 0 {
+		return false
+	}
+	for _, e := range errs {
+		if e.Err == nil || !strings.HasPrefix(e.Err.Error(), "unknown directive") {
+			return false
+		}
+	}
+	return true
+}
+
+// addMainModuleDirectives fills in the toolchain, replace, exclude, and
+// tool directives ParseLax leaves out from the syntax tree of f, so they
+// can be updated like the other directives. Lines that cannot be read are
+// kept as written.
+func addMainModuleDirectives(f *modfile.File) {
+	for _, stmt := range f.Syntax.Stmt {
+		switch x := stmt.(type) {
+		case *modfile.Line:
+			if len(x.Token) > 0 {
+				addMainModuleDirective(f, x, x.Token[0], x.Token[1:])
+			}
+		case *modfile.LineBlock:
+			if len(x.Token) > 0 {
+				for _, line := range x.Line {
+					addMainModuleDirective(f, line, x.Token[0], line.Token)
+				}
+			}
+		}
+	}
+}
+
+// addMainModuleDirective adds the directive verb with args on line to f.
+func addMainModuleDirective(f *modfile.File, line *modfile.Line, verb string, args []string) {
+	switch verb {
+	case "toolchain":
+		if len(args) == 1 {
+			f.Toolchain = &modfile.Toolchain{Name: args[0], Syntax: line}
+		}
+	case "tool":
+		if len(args) == 1 {
+			f.Tool = append(f.Tool, &modfile.Tool{Path: unquoteToken(args[0]), Syntax: line})
+		}
+	case "exclude":
+		if len(args) == 2 {
+			x := &modfile.Exclude{Syntax: line}
+			x.Mod.Path = unquoteToken(args[0])
+			x.Mod.Version = unquoteToken(args[1])
+			f.Exclude = append(f.Exclude, x)
+		}
+	case "replace":
+		// old [version] => new [version]
+		arrow := slices.Index(args, "=>")
+		if arrow != 1 && arrow != 2 || len(args) < arrow+2 || len(args) > arrow+3 {
+			return
+		}
+		r := &modfile.Replace{Syntax: line}
+		r.Old.Path = unquoteToken(args[0])
+		if arrow == 2 {
+			r.Old.Version = unquoteToken(args[1])
+		}
+		r.New.Path = unquoteToken(args[arrow+1])
+		if len(args) == arrow+3 {
+			r.New.Version = unquoteToken(args[arrow+2])
+		}
+		f.Replace = append(f.Replace, r)
+	}
+}
+
+// unquoteToken returns the go.mod token tok without its quotes, if any.
+func unquoteToken(tok string) string {
+	if s, err := strconv.Unquote(tok); err == nil {
+		return s
+	}
+	return tok
+}
+
+// rewriteGoFiles walks through all .go files and files with one of the
+// goExtensions and rewrites import paths, skipping directories named in
+// skip and files larger than maxSize.
 // Returns the list of modified files.
-func rewriteGoFiles(dir, oldModule, newModule string) ([]string, error) {
+func rewriteGoFiles(dir string, mods []moduleInfo, goExtensions []string, skip map[string]bool, allComments bool, maxSize int64) ([]string, error) {
 	var modifiedFiles []string
 
 	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
@@ -88,19 +482,25 @@ func rewriteGoFiles(dir, oldModule, newModule string) ([]string, error) {
 
 		// Skip directories
 		if d.IsDir() {
-			// Skip vendor directory
-			if d.Name() == "vendor" {
+			if skip[d.Name()] {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		// Only process .go files
-		if !strings.HasSuffix(path, ".go") {
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		// Only process .go files and Go fixtures
+		if !strings.HasSuffix(path, ".go") && !matchesSuffix(d.Name(), goExtensions) {
 			return nil
 		}
+		if tooLarge, err := exceedsSize(d, maxSize); err != nil || tooLarge {
+			return err
+		}
 
-		modified, err := rewriteGoFile(path, oldModule, newModule)
+		modified, err := rewriteGoFile(path, mods, allComments)
 		if err != nil {
 			return err
 		}
@@ -115,8 +515,10 @@ func rewriteGoFiles(dir, oldModule, newModule string) ([]string, error) {
 }
 
 // rewriteGoFile rewrites import paths in a single .go file using AST.
-// Returns true if the file was modified.
-func rewriteGoFile(filePath, oldModule, newModule string) (bool, error) {
+// Each import is matched against the longest module path it belongs to.
+// With allComments set, every comment is rewritten instead of only
+// //go:generate directives. Returns true if the file was modified.
+func rewriteGoFile(filePath string, mods []moduleInfo, allComments bool) (bool, error) {
 	cleanPath := filepath.Clean(filePath)
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, cleanPath, nil, parser.ParseComments)
@@ -128,15 +530,33 @@ func rewriteGoFile(filePath, oldModule, newModule string) (bool, error) {
 
 	// Rewrite imports
 	for _, imp := range f.Imports {
-		importPath := strings.Trim(imp.Path.Value, `"`)
+		// Import paths may be written as raw strings
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
 
-		if importPath == oldModule || strings.HasPrefix(importPath, oldModule+"/") {
-			newPath := newModule + strings.TrimPrefix(importPath, oldModule)
-			imp.Path.Value = `"` + newPath + `"`
+		if newPath := rewriteImportPath(importPath, mods); newPath != importPath {
+			imp.Path.Value = strconv.Quote(newPath)
 			modified = true
 		}
 	}
 
+	// Rewrite //go:generate directives, which invoke tools by import path,
+	// and the cgo preamble, which may include headers by module path
+	preambles := cgoPreambles(f)
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if !allComments && !preambles[cg] && !strings.HasPrefix(c.Text, "//go:generate ") {
+				continue
+			}
+			if text := replaceModulePaths(c.Text, mods); text != c.Text {
+				c.Text = text
+				modified = true
+			}
+		}
+	}
+
 	if !modified {
 		return false, nil
 	}
@@ -156,13 +576,99 @@ func rewriteGoFile(filePath, oldModule, newModule string) (bool, error) {
 	return true, os.WriteFile(cleanPath, buf.Bytes(), info.Mode())
 }
 
+// cgoPreambles returns the comments preceding import "C" in f.
+func cgoPreambles(f *ast.File) map[*ast.CommentGroup]bool {
+	preambles := make(map[*ast.CommentGroup]bool)
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			imp := spec.(*ast.ImportSpec)
+			if imp.Path.Value != `"C"` {
+				continue
+			}
+			// The preamble is the doc comment of the import spec, or of
+			// the declaration for an unparenthesized import
+			doc := imp.Doc
+			if doc == nil && !gen.Lparen.IsValid() {
+				doc = gen.Doc
+			}
+			if doc != nil {
+				preambles[doc] = true
+			}
+		}
+	}
+	return preambles
+}
+
+// replaceModulePaths replaces occurrences of the old module paths in text
+// that form a complete module path or a prefix of a package path below
+// it, so that e.g. "example.com/foo" is not rewritten inside
+// "example.com/foobar". Each occurrence is rewritten once, by the longest
+// module it belongs to, so a new path that extends the old one such as
+// example.com/foo/v2 is not rewritten again. mods must be ordered
+// longest old path first.
+func replaceModulePaths(text string, mods []moduleInfo) string {
+	return replacePaths(text, mods, true)
+}
+
+// replaceAssetPaths is like replaceModulePaths but also rewrites module
+// paths that follow a path character, such as in URLs like
+// https://example.com/foo or route prefixes like /example.com/foo.
+// Only the end of each occurrence must be bounded.
+func replaceAssetPaths(text string, mods []moduleInfo) string {
+	return replacePaths(text, mods, false)
+}
+
+// replacePaths implements replaceModulePaths and replaceAssetPaths.
+// leading additionally requires each occurrence to start at a path
+// boundary.
+func replacePaths(text string, mods []moduleInfo, leading bool) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if leading && i > 0 && isPathChar(text[i-1]) {
+			continue
+		}
+		for _, m := range mods {
+			rest, ok := strings.CutPrefix(text[i:], m.Old)
+			if !ok || rest != "" && rest[0] != '/' && isPathChar(rest[0]) {
+				continue
+			}
+			b.WriteString(text[start:i])
+			b.WriteString(m.New)
+			i += len(m.Old) - 1
+			start = i + 1
+			break
+		}
+	}
+	if start == 0 {
+		return text
+	}
+	b.WriteString(text[start:])
+	return b.String()
+}
+
+// isPathChar reports whether c can appear inside a module path element.
+func isPathChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '.' || c == '-' || c == '_' || c == '~' || c == '/'
+}
+
 // rewriteExtraFiles walks through files with specified extensions or filenames
-// and performs simple string replacement.
+// and performs simple string replacement, skipping directories named in skip
+// and files larger than maxSize. Files matching only assetPatterns are
+// rewritten with replaceAssetPaths instead, so that a module path is not
+// replaced inside a longer one. Files with one of the goExtensions are
+// skipped, since they are rewritten like .go files.
 // Returns the list of modified files.
-func rewriteExtraFiles(dir, oldModule, newModule string, patterns []string) ([]string, error) {
+func rewriteExtraFiles(dir string, mods []moduleInfo, patterns, assetPatterns, goExtensions []string, skip map[string]bool, maxSize int64) ([]string, error) {
 	var modifiedFiles []string
 
 	patternSet := parseFilePatterns(patterns)
+	assetSet := parseFilePatterns(assetPatterns)
 
 	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -171,18 +677,26 @@ func rewriteExtraFiles(dir, oldModule, newModule string, patterns []string) ([]s
 
 		// Skip directories
 		if d.IsDir() {
-			if d.Name() == "vendor" || d.Name() == ".git" {
+			if skip[d.Name()] {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
 		// Check if file matches by extension or name
-		if !matchesFilePattern(d.Name(), patternSet) {
+		plain := matchesFilePattern(d.Name(), patternSet)
+		if !plain && !matchesFilePattern(d.Name(), assetSet) || matchesSuffix(d.Name(), goExtensions) {
 			return nil
 		}
+		if tooLarge, err := exceedsSize(d, maxSize); err != nil || tooLarge {
+			return err
+		}
 
-		modified, err := rewriteTextFile(path, oldModule, newModule)
+		modified, err := rewriteTextFile(path, mods, !plain)
 		if err != nil {
 			return err
 		}
@@ -196,22 +710,41 @@ func rewriteExtraFiles(dir, oldModule, newModule string, patterns []string) ([]s
 	return modifiedFiles, err
 }
 
-// rewriteTextFile performs simple string replacement in a text file.
+// rewriteTextFile performs simple string replacement in a text file, or
+// replaceAssetPaths if asset is set. Longer module paths are replaced
+// first so nested modules win over the root module they are prefixed
+// with. Files containing NUL bytes are treated as binary and left alone.
 // Returns true if the file was modified.
-func rewriteTextFile(filePath, oldModule, newModule string) (bool, error) {
+func rewriteTextFile(filePath string, mods []moduleInfo, asset bool) (bool, error) {
 	cleanPath := filepath.Clean(filePath)
 	data, err := os.ReadFile(cleanPath)
 	if err != nil {
 		return false, fmt.Errorf("reading %s: %w", cleanPath, err)
 	}
 
-	// Simple string replacement
-	newData := bytes.ReplaceAll(data, []byte(oldModule), []byte(newModule))
+	// UTF-16 files are matched in UTF-8 and written back in UTF-16
+	text, enc, ok := decodeText(data)
+	if !ok || bytes.IndexByte(text, 0) != -1 {
+		return false, nil
+	}
+
+	var newText []byte
+	if asset {
+		newText = []byte(replaceAssetPaths(string(text), mods))
+	} else {
+		// Simple string replacement
+		pairs := make([]string, 0, 2*len(mods))
+		for _, m := range mods {
+			pairs = append(pairs, m.Old, m.New)
+		}
+		newText = []byte(strings.NewReplacer(pairs...).Replace(string(text)))
+	}
 
 	// Only write if changed
-	if bytes.Equal(data, newData) {
+	if bytes.Equal(text, newText) {
 		return false, nil
 	}
+	newData := encodeText(newText, enc)
 
 	info, err := os.Stat(cleanPath)
 	if err != nil {
@@ -222,6 +755,7 @@ func rewriteTextFile(filePath, oldModule, newModule string) (bool, error) {
 }
 
 // ReadModulePath reads the module path from a go.mod file.
+// It returns an error if the file is empty or has no module directive.
 func ReadModulePath(dir string) (string, error) {
 	goModPath := filepath.Clean(filepath.Join(dir, "go.mod"))
 	data, err := os.ReadFile(goModPath)
@@ -229,14 +763,42 @@ func ReadModulePath(dir string) (string, error) {
 		return "", fmt.Errorf("reading go.mod: %w", err)
 	}
 
+	if len(bytes.TrimSpace(data)) == 0 {
+		return "", fmt.Errorf("go.mod is empty")
+	}
+
 	f, err := modfile.ParseLax(goModPath, data, nil)
 	if err != nil {
 		return "", fmt.Errorf("parsing go.mod: %w", err)
 	}
 
+	if f.Module == nil || f.Module.Mod.Path == "" {
+		return "", fmt.Errorf("go.mod has no module directive")
+	}
+
 	return f.Module.Mod.Path, nil
 }
 
+// ReadGoVersion reads the go directive from go.mod in the given
+// directory. Returns an empty string if go.mod has no go directive.
+func ReadGoVersion(dir string) (string, error) {
+	goModPath := filepath.Clean(filepath.Join(dir, "go.mod"))
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return "", fmt.Errorf("reading go.mod: %w", err)
+	}
+
+	f, err := modfile.ParseLax(goModPath, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("parsing go.mod: %w", err)
+	}
+
+	if f.Go == nil {
+		return "", nil
+	}
+	return f.Go.Version, nil
+}
+
 // HasGoMod checks if the directory contains a go.mod file.
 func HasGoMod(dir string) bool {
 	_, err := os.Stat(filepath.Join(dir, "go.mod"))
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NestedScheme selects how nested modules are renamed.
+type NestedScheme string
+
+const (
+	// NestedPrefix keeps a nested module's path relative to the root
+	// module (github.com/old/app/examples → github.com/new/app/examples).
+	// Nested modules outside the root's path fall back to NestedPath.
+	NestedPrefix NestedScheme = "prefix"
+
+	// NestedPath derives a nested module's path from its directory
+	// below the new root module (examples → github.com/new/app/examples).
+	NestedPath NestedScheme = "path"
+)
+
+// ParseNestedScheme validates a scheme name. An empty name selects NestedPrefix.
+func ParseNestedScheme(name string) (NestedScheme, error) {
+	switch NestedScheme(name) {
+	case "", NestedPrefix:
+		return NestedPrefix, nil
+	case NestedPath:
+		return NestedPath, nil
+	}
+	return "", fmt.Errorf("unknown nested module scheme %q (use %q or %q)", name, NestedPrefix, NestedPath)
+}
+
+// moduleInfo describes a module found in the template and its new path.
+type moduleInfo struct {
+	Dir string // relative to the template root, "." for the root module
+	Old string
+	New string
+}
+
+// resolveModules finds all go.mod files below dir and computes the new
+// path of each module. The result is sorted by old path, longest first.
+func resolveModules(dir, newModule string, scheme NestedScheme, skip map[string]bool) ([]moduleInfo, error) {
+	rootOld, err := ReadModulePath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := findModuleDirs(dir, skip)
+	if err != nil {
+		return nil, fmt.Errorf("finding modules: %w", err)
+	}
+
+	mods := make([]moduleInfo, 0, len(dirs))
+	for _, rel := range dirs {
+		if rel == "." {
+			mods = append(mods, moduleInfo{Dir: rel, Old: rootOld, New: newModule})
+			continue
+		}
+
+		old, err := ReadModulePath(filepath.Join(dir, rel))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rel, err)
+		}
+		mods = append(mods, moduleInfo{
+			Dir: rel,
+			Old: old,
+			New: nestedModulePath(rootOld, newModule, old, rel, scheme),
+		})
+	}
+
+	sort.SliceStable(mods, func(i, j int) bool {
+		return len(mods[i].Old) > len(mods[j].Old)
+	})
+
+	return mods, nil
+}
+
+// findModuleDirs returns the directories containing a go.mod file,
+// relative to dir. Vendor and directories named in skip are skipped.
+func findModuleDirs(dir string, skip map[string]bool) ([]string, error) {
+	var dirs []string
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if d.Name() == "vendor" || skip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Name() == "go.mod" {
+			relDir, _ := filepath.Rel(dir, filepath.Dir(path))
+			dirs = append(dirs, relDir)
+		}
+		return nil
+	})
+
+	return dirs, err
+}
+
+// nestedModulePath computes the new path of a nested module.
+func nestedModulePath(rootOld, rootNew, old, relDir string, scheme NestedScheme) string {
+	if scheme != NestedPath && strings.HasPrefix(old, rootOld+"/") {
+		return rootNew + strings.TrimPrefix(old, rootOld)
+	}
+	return rootNew + "/" + filepath.ToSlash(relDir)
+}
+
+// changedModules returns the modules whose path actually changes.
+func changedModules(mods []moduleInfo) []moduleInfo {
+	changed := make([]moduleInfo, 0, len(mods))
+	for _, m := range mods {
+		if m.Old != m.New {
+			changed = append(changed, m)
+		}
+	}
+	return changed
+}
+
+// lookupModule returns the new path for a module path that exactly
+// matches one of the rewritten modules.
+func lookupModule(mods []moduleInfo, path string) (string, bool) {
+	for _, m := range mods {
+		if m.Old == path {
+			return m.New, true
+		}
+	}
+	return "", false
+}
+
+// rewriteImportPath maps an import path to the longest rewritten module
+// it belongs to. Only whole path elements match, so example.com/a/b does
+// not claim example.com/a/bb. Unrelated paths are returned unchanged.
+// mods must be ordered longest old path first.
+func rewriteImportPath(importPath string, mods []moduleInfo) string {
+	for _, m := range mods {
+		rest, ok := strings.CutPrefix(importPath, m.Old)
+		if ok && (rest == "" || rest[0] == '/') {
+			return m.New + rest
+		}
+	}
+	return importPath
+}
@@ -11,16 +11,22 @@ import (
 	"strings"
 )
 
-// RenamePaths renames directories and files containing template variables.
-// Variables use dunder-style syntax: __VariableName__ in path names.
+// RenamePaths renames directories and files containing template variables,
+// written as placeholders describes, e.g. __VariableName__ in path names.
+// Names found in renameMap are replaced by their static new name before
+// variables are substituted, e.g. dot.gitignore → .gitignore.
+// Directories named in skipDirs are skipped; nil selects DefaultSkipDirs.
 // Returns the list of renamed paths (formatted as "old → new").
-func RenamePaths(dir string, vars map[string]string) ([]string, error) {
-	if len(vars) == 0 {
+func RenamePaths(dir string, vars, renameMap map[string]string, skipDirs []string, placeholders Placeholders) ([]string, error) {
+	if len(vars) == 0 && len(renameMap) == 0 {
 		return nil, nil
 	}
+	if err := CheckRenameMap(renameMap); err != nil {
+		return nil, err
+	}
 
 	// Phase 1: Collect all paths that need renaming
-	renames, err := collectPathsToRename(dir, vars)
+	renames, err := collectPathsToRename(dir, vars, renameMap, skipDirSet(skipDirs), placeholders)
 	if err != nil {
 		return nil, fmt.Errorf("collecting paths: %w", err)
 	}
@@ -30,39 +36,140 @@ func RenamePaths(dir string, vars map[string]string) ([]string, error) {
 	}
 
 	// Phase 2: Sort by depth (deepest first) and rename
-	paths := make([]string, 0, len(renames))
-	for p := range renames {
-		paths = append(paths, p)
-	}
-	sort.Slice(paths, func(i, j int) bool {
-		return strings.Count(paths[i], string(os.PathSeparator)) >
-			strings.Count(paths[j], string(os.PathSeparator))
-	})
+	paths := sortByDepth(renames)
 
+	done := make(completedRenames, len(paths))
 	renamedPaths := make([]string, 0, len(paths))
 	for _, oldPath := range paths {
 		newPath := renames[oldPath]
 
 		// The parent directory might have been renamed already,
 		// so we need to update the paths accordingly
-		oldPath = updatePathWithRenames(oldPath, renamedPaths, dir)
-		newPath = updatePathWithRenames(newPath, renamedPaths, dir)
+		current := done.resolve(oldPath, dir)
+		target := filepath.Join(done.resolve(filepath.Dir(newPath), dir), filepath.Base(newPath))
 
-		if err := os.Rename(oldPath, newPath); err != nil {
-			return nil, fmt.Errorf("renaming %s to %s: %w", oldPath, newPath, err)
+		if err := renamePath(current, target); err != nil {
+			return nil, err
 		}
+		done[oldPath] = target
 
-		oldRel, _ := filepath.Rel(dir, oldPath)
-		newRel, _ := filepath.Rel(dir, newPath)
+		oldRel, _ := filepath.Rel(dir, current)
+		newRel, _ := filepath.Rel(dir, target)
 		renamedPaths = append(renamedPaths, oldRel+" → "+newRel)
 	}
 
 	return renamedPaths, nil
 }
 
+// renamePath renames oldPath to newPath without replacing an existing
+// file. Names differing only in case are renamed through a temporary
+// name, since a direct rename is a no-op on case-insensitive filesystems.
+func renamePath(oldPath, newPath string) error {
+	if existing, err := os.Lstat(newPath); err == nil {
+		current, err := os.Lstat(oldPath)
+		if err != nil {
+			return fmt.Errorf("renaming %s: %w", oldPath, err)
+		}
+		// On case-insensitive filesystems newPath may name oldPath itself
+		if !os.SameFile(existing, current) {
+			return fmt.Errorf("renaming %s to %s: target already exists", oldPath, newPath)
+		}
+	}
+
+	if oldPath != newPath && strings.EqualFold(oldPath, newPath) {
+		tmpPath := oldPath + ".gohatch-rename"
+		if err := os.Rename(oldPath, tmpPath); err != nil {
+			return fmt.Errorf("renaming %s to %s: %w", oldPath, newPath, err)
+		}
+		oldPath = tmpPath
+	}
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("renaming %s to %s: %w", oldPath, newPath, err)
+	}
+	return nil
+}
+
+// PlanRenames reports the renames RenamePaths would perform without
+// touching the filesystem. Entries use the same "old → new" format.
+func PlanRenames(dir string, vars, renameMap map[string]string, skipDirs []string, placeholders Placeholders) ([]string, error) {
+	if len(vars) == 0 && len(renameMap) == 0 {
+		return nil, nil
+	}
+	if err := CheckRenameMap(renameMap); err != nil {
+		return nil, err
+	}
+
+	renames, err := collectPathsToRename(dir, vars, renameMap, skipDirSet(skipDirs), placeholders)
+	if err != nil {
+		return nil, fmt.Errorf("collecting paths: %w", err)
+	}
+
+	planned := make([]string, 0, len(renames))
+	for _, oldPath := range sortByDepth(renames) {
+		oldRel, _ := filepath.Rel(dir, oldPath)
+		newRel, _ := filepath.Rel(dir, renames[oldPath])
+		planned = append(planned, oldRel+" → "+newRel)
+	}
+
+	return planned, nil
+}
+
+// sortByDepth returns the keys of renames ordered deepest first,
+// so children are renamed before their parents. Paths of equal depth
+// are ordered lexically to keep the output stable.
+func sortByDepth(renames map[string]string) []string {
+	type entry struct {
+		path  string
+		depth int
+	}
+	entries := make([]entry, 0, len(renames))
+	for p := range renames {
+		entries = append(entries, entry{p, strings.Count(p, string(os.PathSeparator))})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].depth != entries[j].depth {
+			return entries[i].depth > entries[j].depth
+		}
+		return entries[i].path < entries[j].path
+	})
+
+	paths := make([]string, len(entries))
+	for i, e := range entries {
+		paths[i] = e.path
+	}
+	return paths
+}
+
+// CheckRenameMap validates static renames: both names must be single,
+// non-empty path elements.
+func CheckRenameMap(renameMap map[string]string) error {
+	for from, to := range renameMap {
+		for _, name := range []string{from, to} {
+			if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+				return fmt.Errorf("invalid rename %q → %q: names must not be empty or contain path separators", from, to)
+			}
+		}
+	}
+	return nil
+}
+
+// NewName returns the name a file or directory is renamed to: the static
+// name from renameMap, if any, with the variable placeholders substituted.
+func NewName(name string, vars, renameMap map[string]string, placeholders Placeholders) string {
+	if static, ok := renameMap[name]; ok {
+		name = static
+	}
+	for key, value := range vars {
+		name = string(placeholders.replace([]byte(name), key, value))
+	}
+	return name
+}
+
 // collectPathsToRename walks the directory tree and collects paths that contain
-// template variables in their names.
-func collectPathsToRename(dir string, vars map[string]string) (map[string]string, error) {
+// template variables in their names or are listed in renameMap, skipping
+// directories named in skip.
+func collectPathsToRename(dir string, vars, renameMap map[string]string, skip map[string]bool, placeholders Placeholders) (map[string]string, error) {
 	renames := make(map[string]string)
 
 	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
@@ -70,19 +177,17 @@ func collectPathsToRename(dir string, vars map[string]string) (map[string]string
 			return err
 		}
 
-		// Skip special directories
-		if d.IsDir() && (d.Name() == "vendor" || d.Name() == ".git") {
+		if d.IsDir() && skip[d.Name()] {
 			return filepath.SkipDir
 		}
 
-		name := d.Name()
-		newName := name
-		for key, value := range vars {
-			placeholder := "__" + key + "__"
-			newName = strings.ReplaceAll(newName, placeholder, value)
+		// The template root itself is never renamed
+		if path == dir {
+			return nil
 		}
 
-		if newName != name {
+		name := d.Name()
+		if newName := NewName(name, vars, renameMap, placeholders); newName != name {
 			newPath := filepath.Join(filepath.Dir(path), newName)
 			renames[path] = newPath
 		}
@@ -92,24 +197,39 @@ func collectPathsToRename(dir string, vars map[string]string) (map[string]string
 	return renames, err
 }
 
-// updatePathWithRenames updates a path based on previously completed renames.
-// This handles the case where a parent directory was renamed before its children.
-func updatePathWithRenames(path string, renamedPaths []string, baseDir string) string {
-	for _, rename := range renamedPaths {
-		parts := strings.Split(rename, " → ")
-		if len(parts) != 2 {
-			continue
+// completedRenames maps the cleaned original paths of renamed files and
+// directories to the paths they were renamed to.
+type completedRenames map[string]string
+
+// resolve returns the current location of path below baseDir, following
+// renames of the path itself and of its parent directories. Each parent
+// is looked up once, so the cost grows with the depth of path rather
+// than the number of completed renames.
+func (c completedRenames) resolve(path, baseDir string) string {
+	if len(c) == 0 {
+		return path
+	}
+
+	baseDir = filepath.Clean(baseDir)
+	var names []string
+	for p := path; p != baseDir; {
+		parent := filepath.Dir(p)
+		if parent == p {
+			// Not below baseDir
+			return path
 		}
-		oldRel, newRel := parts[0], parts[1]
-		oldAbs := filepath.Join(baseDir, oldRel)
-		newAbs := filepath.Join(baseDir, newRel)
-
-		// If the path starts with the old path, replace it
-		if strings.HasPrefix(path, oldAbs+string(os.PathSeparator)) {
-			path = newAbs + strings.TrimPrefix(path, oldAbs)
-		} else if path == oldAbs {
-			path = newAbs
+		if renamed, ok := c[p]; ok {
+			names = append(names, filepath.Base(renamed))
+		} else {
+			names = append(names, filepath.Base(p))
 		}
+		p = parent
+	}
+
+	parts := make([]string, 0, len(names)+1)
+	parts = append(parts, baseDir)
+	for i := len(names) - 1; i >= 0; i-- {
+		parts = append(parts, names[i])
 	}
-	return path
+	return filepath.Join(parts...)
 }
@@ -4,6 +4,7 @@
 package rewrite
 
 import (
+	"io/fs"
 	"path/filepath"
 	"strings"
 )
@@ -36,3 +37,103 @@ func matchesFilePattern(name string, patterns map[string]bool) bool {
 	}
 	return false
 }
+
+// matchesSuffix reports whether name ends in one of the multi-part
+// extensions, such as "go.golden" for "main.go.golden". Leading dots of
+// the extensions are ignored.
+func matchesSuffix(name string, extensions []string) bool {
+	for _, ext := range extensions {
+		ext = strings.TrimPrefix(ext, ".")
+		if ext != "" && strings.HasSuffix(name, "."+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultSkipDirs lists the directory names no rewrite descends into
+// unless another list is given.
+var DefaultSkipDirs = []string{".git"}
+
+// skipDirSet returns the set of directory names to skip. Nil names
+// select DefaultSkipDirs.
+func skipDirSet(names []string) map[string]bool {
+	if names == nil {
+		names = DefaultSkipDirs
+	}
+	set := make(map[string]bool, len(names)+1)
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// moduleSkipDirSet returns the directory names skipped by the module
+// path rewrite: the given names plus vendor unless rewriteVendor is set,
+// since vendored packages belong to other modules.
+func moduleSkipDirSet(names []string, rewriteVendor bool) map[string]bool {
+	set := skipDirSet(names)
+	if !rewriteVendor {
+		set["vendor"] = true
+	}
+	return set
+}
+
+// exceedsSize reports whether the file d is larger than maxSize bytes.
+// A maxSize of 0 or less disables the limit.
+func exceedsSize(d fs.DirEntry, maxSize int64) (bool, error) {
+	if maxSize <= 0 {
+		return false, nil
+	}
+	info, err := d.Info()
+	if err != nil {
+		return false, err
+	}
+	return info.Size() > maxSize, nil
+}
+
+// LargeFiles returns the files larger than maxSize bytes that the module
+// and variable rewrites skip: .go and .proto files and files matching the
+// extra patterns. Directories named in skipDirs are skipped; nil selects
+// DefaultSkipDirs. Returns nil if maxSize is 0 or less.
+func LargeFiles(dir string, maxSize int64, extraPatterns, skipDirs []string) ([]string, error) {
+	if maxSize <= 0 {
+		return nil, nil
+	}
+
+	patternSet := parseFilePatterns(extraPatterns)
+	patternSet["go"] = true
+	patternSet["proto"] = true
+
+	skip := skipDirSet(skipDirs)
+
+	var large []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if skip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !matchesFilePattern(d.Name(), patternSet) {
+			return nil
+		}
+
+		tooLarge, err := exceedsSize(d, maxSize)
+		if err != nil {
+			return err
+		}
+		if tooLarge {
+			relPath, _ := filepath.Rel(dir, path)
+			large = append(large, relPath)
+		}
+		return nil
+	})
+
+	return large, err
+}
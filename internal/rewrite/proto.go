@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// goPackageRE matches the go_package option of a .proto file. The value
+// is an import path, optionally followed by ";name" for the package name.
+var goPackageRE = regexp.MustCompile(`(?m)^(\s*option\s+go_package\s*=\s*")([^";]*)((?:;[^"]*)?")`)
+
+// rewriteProtoFiles walks through all .proto files and rewrites the
+// import path of their go_package option, skipping directories named in
+// skip. Files matching the extra patterns are skipped, since they receive
+// plain string replacement, as are files larger than maxSize.
+// Returns the list of modified files.
+func rewriteProtoFiles(dir string, mods []moduleInfo, extraPatterns []string, skip map[string]bool, maxSize int64) ([]string, error) {
+	var modifiedFiles []string
+
+	patternSet := parseFilePatterns(extraPatterns)
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip directories
+		if d.IsDir() {
+			if skip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		// Only process .proto files
+		if !strings.HasSuffix(path, ".proto") || matchesFilePattern(d.Name(), patternSet) {
+			return nil
+		}
+		if tooLarge, err := exceedsSize(d, maxSize); err != nil || tooLarge {
+			return err
+		}
+
+		modified, err := rewriteProtoFile(path, mods)
+		if err != nil {
+			return err
+		}
+		if modified {
+			relPath, _ := filepath.Rel(dir, path)
+			modifiedFiles = append(modifiedFiles, relPath)
+		}
+		return nil
+	})
+
+	return modifiedFiles, err
+}
+
+// rewriteProtoFile rewrites the go_package option of a single .proto
+// file. Other references to the module path are left untouched.
+// Returns true if the file was modified.
+func rewriteProtoFile(filePath string, mods []moduleInfo) (bool, error) {
+	cleanPath := filepath.Clean(filePath)
+	data, err := os.ReadFile(cleanPath)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", cleanPath, err)
+	}
+
+	modified := false
+	newData := goPackageRE.ReplaceAllFunc(data, func(match []byte) []byte {
+		m := goPackageRE.FindSubmatch(match)
+		importPath := string(m[2])
+		newPath := rewriteImportPath(importPath, mods)
+		if newPath == importPath {
+			return match
+		}
+		modified = true
+		return []byte(string(m[1]) + newPath + string(m[3]))
+	})
+
+	if !modified {
+		return false, nil
+	}
+
+	info, err := os.Stat(cleanPath)
+	if err != nil {
+		return false, err
+	}
+
+	return true, os.WriteFile(cleanPath, newData, info.Mode())
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// TemplateSuffix marks files rendered with text/template.
+const TemplateSuffix = ".tmpl"
+
+// templateFuncs are available in rendered templates in addition to the
+// text/template builtins. split turns a comma-separated variable into a
+// list for range.
+var templateFuncs = template.FuncMap{
+	"split": strings.Split,
+}
+
+// RenderTemplates renders every file ending in TemplateSuffix with
+// text/template, using vars as data, and writes the result to the same
+// path without the suffix. Referencing a variable that is not set is an
+// error. Files ending in one of the exclude extensions, such as go.tmpl
+// for code generation templates, are left alone. Directories named in
+// skipDirs are skipped; nil selects DefaultSkipDirs. Returns the list of
+// rendered files without the suffix.
+func RenderTemplates(dir string, vars map[string]string, exclude, skipDirs []string) ([]string, error) {
+	var rendered []string
+
+	skip := skipDirSet(skipDirs)
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if skip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target, ok := strings.CutSuffix(path, TemplateSuffix)
+		if !ok || d.Name() == TemplateSuffix || !d.Type().IsRegular() || matchesSuffix(d.Name(), exclude) {
+			return nil
+		}
+
+		if err := renderFile(path, target, vars); err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(dir, target)
+		rendered = append(rendered, relPath)
+		return nil
+	})
+
+	return rendered, err
+}
+
+// renderFile executes the template in path and replaces it with target.
+func renderFile(path, target string, vars map[string]string) error {
+	cleanPath := filepath.Clean(path)
+	data, err := os.ReadFile(cleanPath)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", cleanPath, err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).
+		Option("missingkey=error").
+		Funcs(templateFuncs).
+		Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", cleanPath, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return fmt.Errorf("rendering %s: %w", cleanPath, err)
+	}
+
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf("rendering %s: %s already exists", cleanPath, target)
+	}
+
+	info, err := os.Stat(cleanPath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(target, buf.Bytes(), info.Mode()); err != nil {
+		return fmt.Errorf("writing %s: %w", target, err)
+	}
+	return os.Remove(cleanPath)
+}
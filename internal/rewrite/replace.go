@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Replacement is a literal text replacement of Old with New.
+type Replacement struct {
+	Old, New string
+}
+
+// ParseReplacement parses an OLD=NEW replacement. Only the first "="
+// separates the two, so NEW may contain "=".
+func ParseReplacement(s string) (Replacement, error) {
+	old, replacement, ok := strings.Cut(s, "=")
+	if !ok || old == "" {
+		return Replacement{}, fmt.Errorf("invalid replacement %q: expected OLD=NEW", s)
+	}
+	return Replacement{Old: old, New: replacement}, nil
+}
+
+// Replace performs literal text replacements in .go files and files
+// matching extraPatterns. All replacements are applied in a single pass,
+// so the result of one is never replaced by another; where several match
+// at the same position, the first one given wins. Files containing NUL
+// bytes are treated as binary and skipped, as are directories named in
+// skipDirs (nil selects DefaultSkipDirs) and files larger than maxSize
+// bytes unless maxSize is 0.
+// Returns the list of modified files.
+func Replace(dir string, replacements []Replacement, extraPatterns, skipDirs []string, maxSize int64) ([]string, error) {
+	if len(replacements) == 0 {
+		return nil, nil
+	}
+
+	oldnew := make([]string, 0, 2*len(replacements))
+	for _, r := range replacements {
+		oldnew = append(oldnew, r.Old, r.New)
+	}
+	replacer := strings.NewReplacer(oldnew...)
+
+	var modifiedFiles []string
+
+	patternSet := parseFilePatterns(extraPatterns)
+	patternSet["go"] = true
+
+	skip := skipDirSet(skipDirs)
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if skip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if !matchesFilePattern(d.Name(), patternSet) {
+			return nil
+		}
+		if tooLarge, err := exceedsSize(d, maxSize); err != nil || tooLarge {
+			return err
+		}
+
+		modified, err := replaceInFile(path, replacer)
+		if err != nil {
+			return err
+		}
+		if modified {
+			relPath, _ := filepath.Rel(dir, path)
+			modifiedFiles = append(modifiedFiles, relPath)
+		}
+		return nil
+	})
+
+	return modifiedFiles, err
+}
+
+// replaceInFile applies replacer to a text file. Returns true if the
+// file was modified.
+func replaceInFile(filePath string, replacer *strings.Replacer) (bool, error) {
+	cleanPath := filepath.Clean(filePath)
+	data, err := os.ReadFile(cleanPath)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", cleanPath, err)
+	}
+
+	text, enc, ok := decodeText(data)
+	if !ok || bytes.IndexByte(text, 0) != -1 {
+		return false, nil
+	}
+
+	newText := []byte(replacer.Replace(string(text)))
+	if bytes.Equal(text, newText) {
+		return false, nil
+	}
+
+	info, err := os.Stat(cleanPath)
+	if err != nil {
+		return false, err
+	}
+
+	return true, os.WriteFile(cleanPath, encodeText(newText, enc), info.Mode())
+}
@@ -4,10 +4,17 @@
 package rewrite
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"testing"
+	"unicode/utf16"
 )
 
 func TestModule(t *testing.T) {
@@ -42,7 +49,7 @@ func main() {
 	}
 
 	// Run Module rewrite
-	_, err := Module(tmpDir, "github.com/new/project", nil)
+	_, err := Module(tmpDir, "github.com/new/project", Options{})
 	if err != nil {
 		t.Fatalf("Module() error = %v", err)
 	}
@@ -82,7 +89,7 @@ go 1.21
 	}
 
 	// Should return nil without changes when module is the same
-	_, err := Module(tmpDir, "github.com/same/module", nil)
+	_, err := Module(tmpDir, "github.com/same/module", Options{})
 	if err != nil {
 		t.Fatalf("Module() error = %v", err)
 	}
@@ -118,7 +125,7 @@ version: 1.0.0
 	}
 
 	// Run Module rewrite with extra extensions
-	_, err := Module(tmpDir, "github.com/new/project", []string{"toml", "yaml"})
+	_, err := Module(tmpDir, "github.com/new/project", Options{ExtraExtensions: []string{"toml", "yaml"}})
 	if err != nil {
 		t.Fatalf("Module() error = %v", err)
 	}
@@ -162,7 +169,7 @@ echo "hello"
 	}
 
 	// Extensions with dot prefix should also work
-	_, err := Module(tmpDir, "github.com/new/project", []string{".sh"})
+	_, err := Module(tmpDir, "github.com/new/project", Options{ExtraExtensions: []string{".sh"}})
 	if err != nil {
 		t.Fatalf("Module() error = %v", err)
 	}
@@ -201,7 +208,7 @@ import "github.com/old/module/internal"
 		t.Fatal(err)
 	}
 
-	_, err := Module(tmpDir, "github.com/new/project", nil)
+	_, err := Module(tmpDir, "github.com/new/project", Options{})
 	if err != nil {
 		t.Fatalf("Module() error = %v", err)
 	}
@@ -245,6 +252,36 @@ func TestReadModulePathNoGoMod(t *testing.T) {
 	}
 }
 
+func TestReadModulePathEmptyGoMod(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ReadModulePath(tmpDir)
+	if err == nil || !strings.Contains(err.Error(), "go.mod is empty") {
+		t.Errorf("ReadModulePath() error = %v, want empty go.mod error", err)
+	}
+}
+
+func TestReadModulePathNoModuleDirective(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("go 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ReadModulePath(tmpDir)
+	if err == nil || !strings.Contains(err.Error(), "go.mod has no module directive") {
+		t.Errorf("ReadModulePath() error = %v, want missing module error", err)
+	}
+
+	// Module must report the same error instead of panicking
+	_, err = Module(tmpDir, "github.com/new/module", Options{})
+	if err == nil || !strings.Contains(err.Error(), "go.mod has no module directive") {
+		t.Errorf("Module() error = %v, want missing module error", err)
+	}
+}
+
 func TestHasGoMod(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -282,7 +319,7 @@ func main() {
 	// Get original mod time
 	origInfo, _ := os.Stat(filePath)
 
-	_, err := rewriteGoFile(filePath, "github.com/other/module", "github.com/new/module")
+	_, err := rewriteGoFile(filePath, []moduleInfo{{Old: "github.com/other/module", New: "github.com/new/module"}}, false)
 	if err != nil {
 		t.Fatalf("rewriteGoFile() error = %v", err)
 	}
@@ -309,7 +346,7 @@ func TestRewriteTextFileNoChanges(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err := rewriteTextFile(filePath, "github.com/old/module", "github.com/new/module")
+	_, err := rewriteTextFile(filePath, []moduleInfo{{Old: "github.com/old/module", New: "github.com/new/module"}}, false)
 	if err != nil {
 		t.Fatalf("rewriteTextFile() error = %v", err)
 	}
@@ -347,7 +384,7 @@ author = "__Author__"
 		"Author":      "Oliver Andrich",
 	}
 
-	_, err := Variables(tmpDir, vars, []string{"toml"})
+	_, err := Variables(tmpDir, vars, []string{"toml"}, nil, DefaultPlaceholders, 0)
 	if err != nil {
 		t.Fatalf("Variables() error = %v", err)
 	}
@@ -377,11 +414,403 @@ author = "__Author__"
 	}
 }
 
+func TestVariablesWordBoundary(t *testing.T) {
+	content := `NAME=app
+USERNAME=admin
+PROJECT_NAME=x
+echo "NAME" $NAME
+`
+	tests := []struct {
+		name         string
+		wordBoundary bool
+		want         string
+	}{
+		{"blind replace", false, `myapp=app
+USERmyapp=admin
+PROJECT_myapp=x
+echo "myapp" $myapp
+`},
+		{"word boundary", true, `myapp=app
+USERNAME=admin
+PROJECT_NAME=x
+echo "myapp" $myapp
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "setup.sh"), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			placeholders := Placeholders{WordBoundary: tt.wordBoundary}
+			_, err := Variables(tmpDir, map[string]string{"NAME": "myapp"}, []string{"sh"}, nil, placeholders, 0)
+			if err != nil {
+				t.Fatalf("Variables() error = %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, "setup.sh"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("unexpected content:\ngot:  %q\nwant: %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariablesDefaults(t *testing.T) {
+	content := `license = "__License|MIT__"
+author = "__Author|Anonymous__"
+name = "__ProjectName__"
+empty = "__Suffix|__"
+path = "x__y__License|BSD__"
+pipe = "__NotADefault__ | __Other__"
+`
+	tests := []struct {
+		name string
+		vars map[string]string
+		want string
+	}{
+		{"defaults when unset", map[string]string{"ProjectName": "app"}, `license = "MIT"
+author = "Anonymous"
+name = "app"
+empty = ""
+path = "x__yBSD"
+pipe = "__NotADefault__ | __Other__"
+`},
+		{"values override defaults", map[string]string{"ProjectName": "app", "License": "Apache-2.0", "Author": "Jane"}, `license = "Apache-2.0"
+author = "Jane"
+name = "app"
+empty = ""
+path = "x__yApache-2.0"
+pipe = "__NotADefault__ | __Other__"
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "app.toml"), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Variables(tmpDir, tt.vars, []string{"toml"}, nil, DefaultPlaceholders, 0)
+			if err != nil {
+				t.Fatalf("Variables() error = %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, "app.toml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("unexpected content:\ngot:  %q\nwant: %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountVariablesDefaults(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("// __License__ or __License|MIT__\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := CountVariables(tmpDir, map[string]string{"License": "MIT"}, nil, nil, DefaultPlaceholders, 0)
+	if err != nil {
+		t.Fatalf("CountVariables() error = %v", err)
+	}
+	if want := (VariableUsage{Occurrences: 2, Files: 1}); usage["License"] != want {
+		t.Errorf("License usage = %+v, want %+v", usage["License"], want)
+	}
+}
+
+func TestVariablesDefaultsCustomDelimiters(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(`const License = "{{License|MIT}}" // {{Name}}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	placeholders := Placeholders{Left: "{{", Right: "}}"}
+	if _, err := Variables(tmpDir, map[string]string{"Name": "app"}, nil, nil, placeholders, 0); err != nil {
+		t.Fatalf("Variables() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `const License = "MIT" // app` + "\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
+
+func TestExpandVariables(t *testing.T) {
+	tests := []struct {
+		name         string
+		vars         map[string]string
+		placeholders Placeholders
+		want         map[string]string
+	}{
+		{
+			"one level",
+			map[string]string{"ProjectName": "app", "Module": "github.com/me/__ProjectName__"},
+			DefaultPlaceholders,
+			map[string]string{"ProjectName": "app", "Module": "github.com/me/app"},
+		},
+		{
+			"two levels",
+			map[string]string{"Owner": "me", "Repo": "github.com/__Owner__", "Module": "__Repo__/__ProjectName__", "ProjectName": "app"},
+			DefaultPlaceholders,
+			map[string]string{"Owner": "me", "Repo": "github.com/me", "Module": "github.com/me/app", "ProjectName": "app"},
+		},
+		{
+			"defaults and unknown placeholders",
+			map[string]string{"Name": "app", "Title": "__Name__ by __Author|Anonymous__", "Raw": "__Unknown__"},
+			DefaultPlaceholders,
+			map[string]string{"Name": "app", "Title": "app by Anonymous", "Raw": "__Unknown__"},
+		},
+		{
+			"custom delimiters",
+			map[string]string{"Name": "app", "Module": "github.com/me/{{Name}}"},
+			Placeholders{Left: "{{", Right: "}}"},
+			map[string]string{"Name": "app", "Module": "github.com/me/app"},
+		},
+		{
+			"bare tokens are not expanded",
+			map[string]string{"Name": "app", "Title": "Name"},
+			Placeholders{WordBoundary: true},
+			map[string]string{"Name": "app", "Title": "Name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandVariables(tt.vars, tt.placeholders)
+			if err != nil {
+				t.Fatalf("ExpandVariables() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("ExpandVariables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandVariablesCycle(t *testing.T) {
+	tests := []struct {
+		name string
+		vars map[string]string
+		want string
+	}{
+		{"self", map[string]string{"A": "x__A__"}, "A -> A"},
+		{"indirect", map[string]string{"A": "__B__", "B": "__C__", "C": "__A__"}, "A -> B -> C -> A"},
+		{"through default", map[string]string{"A": "__B|x__", "B": "__A__"}, "A -> B -> A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExpandVariables(tt.vars, DefaultPlaceholders)
+			if err == nil {
+				t.Fatal("ExpandVariables() expected error for cyclic reference")
+			}
+			if want := "cyclic variable reference: " + tt.want; err.Error() != want {
+				t.Errorf("error = %q, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestParsePlaceholders(t *testing.T) {
+	p, err := ParsePlaceholders(nil, false)
+	if err != nil || p != DefaultPlaceholders {
+		t.Errorf("ParsePlaceholders(nil) = %+v, %v, want default", p, err)
+	}
+
+	p, err = ParsePlaceholders([]string{"{{", "}}"}, true)
+	if err != nil {
+		t.Fatalf("ParsePlaceholders() error = %v", err)
+	}
+	if want := (Placeholders{Left: "{{", Right: "}}", WordBoundary: true}); p != want {
+		t.Errorf("ParsePlaceholders() = %+v, want %+v", p, want)
+	}
+
+	if _, err := ParsePlaceholders([]string{"{{"}, false); err == nil {
+		t.Error("expected error for a single delimiter")
+	}
+}
+
+// utf16LE encodes s as UTF-16LE with a byte order mark.
+func utf16LE(s string) []byte {
+	data := []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		data = binary.LittleEndian.AppendUint16(data, u)
+	}
+	return data
+}
+
+func TestModuleRewritesUTF16File(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := `module github.com/old/mod
+
+go 1.21
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := "[app]\r\nmodule = \"github.com/old/mod\"\r\nname = \"Grüße\"\r\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "settings.ini"), utf16LE(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	modified, err := Module(tmpDir, "github.com/new/project", Options{ExtraExtensions: []string{"ini"}})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+	if !slices.Contains(modified, "settings.ini") {
+		t.Errorf("expected settings.ini to be modified, got %v", modified)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "settings.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := utf16LE(strings.Replace(config, "github.com/old/mod", "github.com/new/project", 1))
+	if !bytes.Equal(data, want) {
+		t.Errorf("UTF-16LE file not rewritten in its encoding:\ngot:  % x\nwant: % x", data, want)
+	}
+}
+
+func TestCountVariables(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"main.go":  "package main\n\nconst Name = \"NAME\" // NAME\n",
+		"setup.sh": "NAME=app\nUSERNAME=admin\n",
+		"notes.md": "NAME\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vars := map[string]string{"NAME": "myapp", "Unused": "x"}
+
+	tests := []struct {
+		name         string
+		wordBoundary bool
+		want         VariableUsage
+	}{
+		{"blind replace", false, VariableUsage{Occurrences: 4, Files: 2}},
+		{"word boundary", true, VariableUsage{Occurrences: 3, Files: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, err := CountVariables(tmpDir, vars, []string{"sh"}, nil, Placeholders{WordBoundary: tt.wordBoundary}, 0)
+			if err != nil {
+				t.Fatalf("CountVariables() error = %v", err)
+			}
+			if usage["NAME"] != tt.want {
+				t.Errorf("NAME usage = %+v, want %+v", usage["NAME"], tt.want)
+			}
+			if u, ok := usage["Unused"]; !ok || u != (VariableUsage{}) {
+				t.Errorf("Unused usage = %+v, %v; want zero entry", u, ok)
+			}
+		})
+	}
+
+	// Counting leaves the files alone
+	data, err := os.ReadFile(filepath.Join(tmpDir, "setup.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != files["setup.sh"] {
+		t.Errorf("setup.sh was modified: %q", data)
+	}
+}
+
+func TestVariablesUTF16File(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "app.ini"), utf16LE("name=__ProjectName__\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Variables(tmpDir, map[string]string{"ProjectName": "MyApp"}, []string{"ini"}, nil, DefaultPlaceholders, 0)
+	if err != nil {
+		t.Fatalf("Variables() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "app.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := utf16LE("name=MyApp\r\n"); !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+}
+
+func TestVariablesSkipsSymlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "outside.go")
+	if err := os.WriteFile(outside, []byte("const Name = \"__ProjectName__\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(tmpDir, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	modified, err := Variables(tmpDir, map[string]string{"ProjectName": "MyApp"}, nil, nil, DefaultPlaceholders, 0)
+	if err != nil {
+		t.Fatalf("Variables() error = %v", err)
+	}
+	if len(modified) != 0 {
+		t.Errorf("modified = %v, want none", modified)
+	}
+
+	data, err := os.ReadFile(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "const Name = \"__ProjectName__\"\n" {
+		t.Errorf("file outside the project was rewritten: %q", data)
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	text, enc, ok := decodeText([]byte("plain"))
+	if !ok || enc != encodingUTF8 || string(text) != "plain" {
+		t.Errorf("decodeText(UTF-8) = %q, %v, %v", text, enc, ok)
+	}
+
+	be := []byte{0xFE, 0xFF, 0x00, 'h', 0x00, 'i'}
+	text, enc, ok = decodeText(be)
+	if !ok || enc != encodingUTF16BE || string(text) != "hi" {
+		t.Errorf("decodeText(UTF-16BE) = %q, %v, %v", text, enc, ok)
+	}
+	if got := encodeText(text, enc); !bytes.Equal(got, be) {
+		t.Errorf("encodeText(UTF-16BE) = % x, want % x", got, be)
+	}
+
+	// Truncated code units and lone surrogates would not round-trip
+	if _, _, ok := decodeText([]byte{0xFF, 0xFE, 'h'}); ok {
+		t.Error("expected truncated UTF-16 to be rejected")
+	}
+	if _, _, ok := decodeText([]byte{0xFF, 0xFE, 0x00, 0xD8, 'h', 0x00}); ok {
+		t.Error("expected lone surrogate to be rejected")
+	}
+}
+
 func TestVariablesEmptyMap(t *testing.T) {
 	tmpDir := t.TempDir()
 
 	// Should return nil immediately for empty map
-	_, err := Variables(tmpDir, map[string]string{}, nil)
+	_, err := Variables(tmpDir, map[string]string{}, nil, nil, DefaultPlaceholders, 0)
 	if err != nil {
 		t.Fatalf("Variables() error = %v", err)
 	}
@@ -403,7 +832,7 @@ const Name = "test"
 		"ProjectName": "MyApp",
 	}
 
-	_, err := Variables(tmpDir, vars, nil)
+	_, err := Variables(tmpDir, vars, nil, nil, DefaultPlaceholders, 0)
 	if err != nil {
 		t.Fatalf("Variables() error = %v", err)
 	}
@@ -415,38 +844,36 @@ const Name = "test"
 	}
 }
 
-func TestVariablesSkipsVendor(t *testing.T) {
+func TestVariablesSkipDirs(t *testing.T) {
 	tmpDir := t.TempDir()
 
-	// Create vendor directory with a file containing variables
-	vendorDir := filepath.Join(tmpDir, "vendor", "pkg")
-	if err := os.MkdirAll(vendorDir, 0o755); err != nil {
-		t.Fatal(err)
-	}
-
-	vendorFile := `package pkg
-const Name = "__ProjectName__"
-`
-	if err := os.WriteFile(filepath.Join(vendorDir, "pkg.go"), []byte(vendorFile), 0o644); err != nil {
-		t.Fatal(err)
+	// Create directories with files containing variables
+	for _, dir := range []string{"vendor", "testdata", "internal"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, dir, "pkg.go"), []byte(`const Name = "__ProjectName__"`), 0o644); err != nil {
+			t.Fatal(err)
+		}
 	}
 
 	vars := map[string]string{
 		"ProjectName": "MyApp",
 	}
 
-	_, err := Variables(tmpDir, vars, nil)
+	_, err := Variables(tmpDir, vars, nil, []string{"vendor", "testdata"}, DefaultPlaceholders, 0)
 	if err != nil {
 		t.Fatalf("Variables() error = %v", err)
 	}
 
-	// Vendor file should NOT be modified
-	data, err := os.ReadFile(filepath.Join(vendorDir, "pkg.go"))
-	if err != nil {
-		t.Fatal(err)
-	}
-	if strings.Contains(string(data), "MyApp") {
-		t.Errorf("vendor file should not be modified, got: %s", data)
+	for dir, want := range map[string]bool{"vendor": false, "testdata": false, "internal": true} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, dir, "pkg.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(data), "MyApp"); got != want {
+			t.Errorf("%s rewritten = %v, want %v", dir, got, want)
+		}
 	}
 }
 
@@ -465,7 +892,7 @@ func TestRenamePaths_SimpleDirectory(t *testing.T) {
 	}
 
 	vars := map[string]string{"ProjectName": "myapp"}
-	renamed, err := RenamePaths(tmpDir, vars)
+	renamed, err := RenamePaths(tmpDir, vars, nil, nil, DefaultPlaceholders)
 	if err != nil {
 		t.Fatalf("RenamePaths() error = %v", err)
 	}
@@ -507,7 +934,7 @@ func TestRenamePaths_NestedDirectories(t *testing.T) {
 	}
 
 	vars := map[string]string{"A": "first", "B": "second"}
-	renamed, err := RenamePaths(tmpDir, vars)
+	renamed, err := RenamePaths(tmpDir, vars, nil, nil, DefaultPlaceholders)
 	if err != nil {
 		t.Fatalf("RenamePaths() error = %v", err)
 	}
@@ -524,6 +951,97 @@ func TestRenamePaths_NestedDirectories(t *testing.T) {
 	}
 }
 
+// writeDeepTree creates depth nested __Name__ directories holding a
+// __Name__.go file in the innermost one.
+func writeDeepTree(tb testing.TB, dir string, depth int) {
+	tb.Helper()
+
+	parts := make([]string, depth)
+	for i := range parts {
+		parts[i] = "__Name__"
+	}
+	deepest := filepath.Join(append([]string{dir}, parts...)...)
+	if err := os.MkdirAll(deepest, 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(deepest, "__Name__.go"), []byte("package app\n"), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestRenamePaths_DeepTree(t *testing.T) {
+	const depth = 200
+	tmpDir := t.TempDir()
+	writeDeepTree(t, tmpDir, depth)
+
+	renamed, err := RenamePaths(tmpDir, map[string]string{"Name": "app"}, nil, nil, DefaultPlaceholders)
+	if err != nil {
+		t.Fatalf("RenamePaths() error = %v", err)
+	}
+	if len(renamed) != depth+1 {
+		t.Errorf("expected %d renames, got %d", depth+1, len(renamed))
+	}
+
+	// Deepest first, so the file is renamed below its original parents
+	if !strings.HasSuffix(renamed[0], "__Name__"+string(os.PathSeparator)+"app.go") {
+		t.Errorf("first rename = %q, want the file", renamed[0])
+	}
+	if renamed[len(renamed)-1] != "__Name__ → app" {
+		t.Errorf("last rename = %q, want the top directory", renamed[len(renamed)-1])
+	}
+
+	parts := make([]string, depth)
+	for i := range parts {
+		parts[i] = "app"
+	}
+	newPath := filepath.Join(append(append([]string{tmpDir}, parts...), "app.go")...)
+	if _, err := os.Stat(newPath); err != nil {
+		t.Errorf("file should exist at the renamed path: %v", err)
+	}
+}
+
+func TestCompletedRenames_Resolve(t *testing.T) {
+	base := filepath.Join("tmp", "tmpl")
+	done := completedRenames{
+		filepath.Join(base, "__A__"):             filepath.Join(base, "a"),
+		filepath.Join(base, "__A__", "__B__"):    filepath.Join(base, "__A__", "b"),
+		filepath.Join(base, "other", "__B__"):    filepath.Join(base, "other", "b"),
+		filepath.Join("tmp", "outside", "__A__"): filepath.Join("tmp", "outside", "a"),
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{filepath.Join(base, "__A__"), filepath.Join(base, "a")},
+		{filepath.Join(base, "__A__", "__B__", "x.go"), filepath.Join(base, "a", "b", "x.go")},
+		{filepath.Join(base, "__A__", "c"), filepath.Join(base, "a", "c")},
+		{filepath.Join(base, "other", "x.go"), filepath.Join(base, "other", "x.go")},
+		{base, base},
+		{filepath.Join("tmp", "outside", "__A__"), filepath.Join("tmp", "outside", "__A__")},
+	}
+
+	for _, tt := range tests {
+		if got := done.resolve(tt.path, base+string(os.PathSeparator)); got != tt.want {
+			t.Errorf("resolve(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkRenamePaths_DeepTree(b *testing.B) {
+	vars := map[string]string{"Name": "app"}
+	for b.Loop() {
+		b.StopTimer()
+		tmpDir := b.TempDir()
+		writeDeepTree(b, tmpDir, 200)
+		b.StartTimer()
+
+		if _, err := RenamePaths(tmpDir, vars, nil, nil, DefaultPlaceholders); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func TestRenamePaths_FileRename(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -533,7 +1051,7 @@ func TestRenamePaths_FileRename(t *testing.T) {
 	}
 
 	vars := map[string]string{"ProjectName": "myapp"}
-	renamed, err := RenamePaths(tmpDir, vars)
+	renamed, err := RenamePaths(tmpDir, vars, nil, nil, DefaultPlaceholders)
 	if err != nil {
 		t.Fatalf("RenamePaths() error = %v", err)
 	}
@@ -565,7 +1083,7 @@ func TestRenamePaths_NoMatches(t *testing.T) {
 	}
 
 	vars := map[string]string{"ProjectName": "myapp"}
-	renamed, err := RenamePaths(tmpDir, vars)
+	renamed, err := RenamePaths(tmpDir, vars, nil, nil, DefaultPlaceholders)
 	if err != nil {
 		t.Fatalf("RenamePaths() error = %v", err)
 	}
@@ -579,7 +1097,7 @@ func TestRenamePaths_NoMatches(t *testing.T) {
 func TestRenamePaths_EmptyVars(t *testing.T) {
 	tmpDir := t.TempDir()
 
-	renamed, err := RenamePaths(tmpDir, map[string]string{})
+	renamed, err := RenamePaths(tmpDir, map[string]string{}, nil, nil, DefaultPlaceholders)
 	if err != nil {
 		t.Fatalf("RenamePaths() error = %v", err)
 	}
@@ -604,7 +1122,7 @@ func TestRenamePaths_DirectoryAndFileWithSameVar(t *testing.T) {
 	}
 
 	vars := map[string]string{"ProjectName": "myapp"}
-	renamed, err := RenamePaths(tmpDir, vars)
+	renamed, err := RenamePaths(tmpDir, vars, nil, nil, DefaultPlaceholders)
 	if err != nil {
 		t.Fatalf("RenamePaths() error = %v", err)
 	}
@@ -621,6 +1139,85 @@ func TestRenamePaths_DirectoryAndFileWithSameVar(t *testing.T) {
 	}
 }
 
+func TestRenamePaths_ExistingTarget(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "__ProjectName__.go"), []byte("package template"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "myapp.go"), []byte("package existing"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := RenamePaths(tmpDir, map[string]string{"ProjectName": "myapp"}, nil, nil, DefaultPlaceholders)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("RenamePaths() error = %v, want collision error", err)
+	}
+
+	// The existing file must not be overwritten
+	data, err := os.ReadFile(filepath.Join(tmpDir, "myapp.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "package existing" {
+		t.Errorf("existing file was overwritten: %q", data)
+	}
+}
+
+// isCaseInsensitive reports whether the filesystem of dir ignores case.
+func isCaseInsensitive(t *testing.T, dir string) bool {
+	t.Helper()
+
+	probe := filepath.Join(dir, "CaseProbe")
+	if err := os.WriteFile(probe, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(probe) }()
+
+	_, err := os.Stat(filepath.Join(dir, "caseprobe"))
+	return err == nil
+}
+
+func TestRenamePath_CaseOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	oldPath := filepath.Join(tmpDir, "readme.md")
+	if err := os.WriteFile(oldPath, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := renamePath(oldPath, filepath.Join(tmpDir, "README.md")); err != nil {
+		t.Fatalf("renamePath() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "README.md" {
+		t.Errorf("expected only README.md, got %v", entries)
+	}
+}
+
+func TestRenamePaths_CaseCollision(t *testing.T) {
+	tmpDir := t.TempDir()
+	if !isCaseInsensitive(t, tmpDir) {
+		t.Skip("filesystem is case-sensitive")
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "MyApp.go"), []byte("package existing"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "__ProjectName__.go"), []byte("package template"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := RenamePaths(tmpDir, map[string]string{"ProjectName": "myapp"}, nil, nil, DefaultPlaceholders)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("RenamePaths() error = %v, want collision error", err)
+	}
+}
+
 func TestParseFilePatterns(t *testing.T) {
 	tests := []struct {
 		want     map[string]bool
@@ -722,7 +1319,7 @@ build:
 	}
 
 	// Run Module rewrite with filename pattern
-	_, err := Module(tmpDir, "github.com/new/project", []string{"justfile"})
+	_, err := Module(tmpDir, "github.com/new/project", Options{ExtraExtensions: []string{"justfile"}})
 	if err != nil {
 		t.Fatalf("Module() error = %v", err)
 	}
@@ -765,7 +1362,7 @@ build:
 		"ProjectName": "myapp",
 	}
 
-	_, err := Variables(tmpDir, vars, []string{"justfile", "Makefile"})
+	_, err := Variables(tmpDir, vars, []string{"justfile", "Makefile"}, nil, DefaultPlaceholders, 0)
 	if err != nil {
 		t.Fatalf("Variables() error = %v", err)
 	}
@@ -794,3 +1391,2189 @@ build:
 		t.Errorf("Makefile: expected myapp, got: %s", data)
 	}
 }
+
+func TestWriteEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	vars := map[string]string{
+		"ProjectName": "myapp",
+		"Author":      "Oliver Andrich",
+	}
+	if err := WriteEnv(tmpDir, ".env", vars, nil); err != nil {
+		t.Fatalf("WriteEnv() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Author=\"Oliver Andrich\"\nProjectName=myapp\n"
+	if string(data) != expected {
+		t.Errorf("unexpected .env content:\ngot:  %q\nwant: %q", data, expected)
+	}
+}
+
+func TestWriteEnvSelectedKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	vars := map[string]string{
+		"ProjectName": "myapp",
+		"Author":      "Oliver",
+		"Quote":       `say "hi"`,
+	}
+	if err := WriteEnv(tmpDir, "config/.env", vars, []string{"Quote", "ProjectName", "Missing"}); err != nil {
+		t.Fatalf("WriteEnv() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "config", ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Quote=\"say \\\"hi\\\"\"\nProjectName=myapp\nMissing=\n"
+	if string(data) != expected {
+		t.Errorf("unexpected .env content:\ngot:  %q\nwant: %q", data, expected)
+	}
+}
+
+func TestWriteEnvRejectsEscapingPath(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	vars := map[string]string{"ProjectName": "myapp"}
+	if err := WriteEnv(tmpDir, "../.env", vars, nil); err == nil {
+		t.Error("expected error for path outside project")
+	}
+}
+
+func TestModuleRewritesGoGenerate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := `module github.com/old/mod
+
+go 1.21
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	goFile := `package main
+
+//go:generate go run github.com/old/mod/cmd/tool -out gen.go
+//go:generate go run github.com/old/modtools/gen
+
+// See github.com/old/mod/cmd/tool for details.
+func main() {}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(goFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	modified, err := Module(tmpDir, "github.com/new/project", Options{})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+	if len(modified) != 2 {
+		t.Errorf("expected go.mod and main.go to be modified, got %v", modified)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+
+	if !strings.Contains(content, "//go:generate go run github.com/new/project/cmd/tool -out gen.go") {
+		t.Errorf("go:generate directive not updated, got: %s", content)
+	}
+	if !strings.Contains(content, "//go:generate go run github.com/old/modtools/gen") {
+		t.Errorf("unrelated module with common prefix should not be rewritten, got: %s", content)
+	}
+	if !strings.Contains(content, "// See github.com/old/mod/cmd/tool for details.") {
+		t.Errorf("regular comments should not be rewritten, got: %s", content)
+	}
+}
+
+func TestModuleRewritesProtoGoPackage(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := `module github.com/old/mod
+
+go 1.21
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	protoDir := filepath.Join(tmpDir, "api")
+	if err := os.MkdirAll(protoDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	proto := `syntax = "proto3";
+
+// Generated code lives in github.com/old/mod/api.
+package api;
+
+import "github.com/old/mod/api/common.proto";
+
+option go_package = "github.com/old/mod/api;apipb";
+option java_package = "github.com/old/mod/api";
+
+message Ping {
+  string url = 1; // e.g. "github.com/old/mod"
+}
+`
+	if err := os.WriteFile(filepath.Join(protoDir, "ping.proto"), []byte(proto), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	other := `syntax = "proto3";
+
+option go_package = "github.com/old/modtools/api";
+`
+	if err := os.WriteFile(filepath.Join(protoDir, "other.proto"), []byte(other), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	modified, err := Module(tmpDir, "github.com/new/project", Options{})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+	if len(modified) != 2 {
+		t.Errorf("expected go.mod and api/ping.proto to be modified, got %v", modified)
+	}
+
+	data, err := os.ReadFile(filepath.Join(protoDir, "ping.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(proto, `option go_package = "github.com/old/mod/api;apipb";`,
+		`option go_package = "github.com/new/project/api;apipb";`, 1)
+	if string(data) != want {
+		t.Errorf("only go_package should be rewritten, got:\n%s", data)
+	}
+
+	data, err = os.ReadFile(filepath.Join(protoDir, "other.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != other {
+		t.Errorf("unrelated module with common prefix should not be rewritten, got:\n%s", data)
+	}
+}
+
+func TestModuleRewritesCgoPreamble(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := `module github.com/old/mod
+
+go 1.21
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	goFile := `package native
+
+// #cgo CFLAGS: -I${SRCDIR}/include
+// #include "github.com/old/mod/include/native.h"
+import "C"
+
+// See github.com/old/mod/include for the headers.
+func Version() int { return 1 }
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "native.go"), []byte(goFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Module(tmpDir, "github.com/new/project", Options{}); err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "native.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+
+	if !strings.Contains(content, `// #include "github.com/new/project/include/native.h"`) {
+		t.Errorf("cgo preamble not updated, got: %s", content)
+	}
+	if !strings.Contains(content, "// See github.com/old/mod/include for the headers.") {
+		t.Errorf("regular comments should not be rewritten, got: %s", content)
+	}
+}
+
+func TestModuleRewritesComments(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := `module github.com/old/mod
+
+go 1.21
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	goFile := `package main
+
+import "fmt"
+
+// see github.com/old/mod/pkg
+// unrelated: github.com/old/modtools
+func main() {
+	fmt.Println("github.com/old/mod/pkg") //nolint:forbidigo // github.com/old/mod
+}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(goFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Module(tmpDir, "github.com/new/project", Options{RewriteComments: true}); err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+
+	if !strings.Contains(content, "// see github.com/new/project/pkg") {
+		t.Errorf("comment not updated, got: %s", content)
+	}
+	if !strings.Contains(content, "//nolint:forbidigo // github.com/new/project") {
+		t.Errorf("trailing comment not updated, got: %s", content)
+	}
+	if !strings.Contains(content, "// unrelated: github.com/old/modtools") {
+		t.Errorf("unrelated module with common prefix should not be rewritten, got: %s", content)
+	}
+	if !strings.Contains(content, `fmt.Println("github.com/old/mod/pkg")`) {
+		t.Errorf("code should not be rewritten, got: %s", content)
+	}
+}
+
+func TestModuleFromModule(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := `module github.com/template/name
+
+go 1.21
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	goFile := `package main
+
+import (
+	"github.com/copied/from/internal/app"
+	"github.com/copied/fromother/pkg"
+	"github.com/template/name/internal/db"
+)
+
+func main() {
+	app.Run()
+	pkg.Run()
+	db.Open()
+}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(goFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Module(tmpDir, "github.com/new/project", Options{FromModule: "github.com/copied/from"})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+
+	if !strings.Contains(content, `"github.com/new/project/internal/app"`) {
+		t.Errorf("import of --from-module prefix not rewritten, got: %s", content)
+	}
+	if !strings.Contains(content, `"github.com/new/project/internal/db"`) {
+		t.Errorf("import of go.mod module not rewritten, got: %s", content)
+	}
+	if !strings.Contains(content, `"github.com/copied/fromother/pkg"`) {
+		t.Errorf("unrelated module with common prefix should not be rewritten, got: %s", content)
+	}
+
+	newPath, err := ReadModulePath(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPath != "github.com/new/project" {
+		t.Errorf("go.mod module = %q, want %q", newPath, "github.com/new/project")
+	}
+}
+
+func TestModuleFromModuleInvalid(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module github.com/old/mod\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Module(tmpDir, "github.com/new/project", Options{FromModule: "not a path"}); err == nil {
+		t.Error("Module() should reject an invalid FromModule")
+	}
+}
+
+// setupNestedModules creates a root module and an examples/ submodule
+// that requires and imports the root module.
+func setupNestedModules(t *testing.T, nestedModule string) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod": "module github.com/old/app\n\ngo 1.21\n",
+		"app.go": "package app\n",
+		"examples/go.mod": "module " + nestedModule + "\n\ngo 1.21\n\n" +
+			"require github.com/old/app v0.0.0\n\n" +
+			"replace github.com/old/app => ../\n",
+		"examples/main.go": `package main
+
+import (
+	"github.com/old/app"
+	"` + nestedModule + `/internal/demo"
+)
+
+var _ = app.X
+var _ = demo.Y
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return tmpDir
+}
+
+func TestModuleNestedPrefixScheme(t *testing.T) {
+	tmpDir := setupNestedModules(t, "github.com/old/app/examples")
+
+	modified, err := Module(tmpDir, "github.com/new/project", Options{})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+	if len(modified) != 3 {
+		t.Errorf("expected 3 modified files, got %v", modified)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "examples", "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "module github.com/new/project/examples") {
+		t.Errorf("nested module statement not updated, got: %s", content)
+	}
+	if !strings.Contains(content, "require github.com/new/project v0.0.0") {
+		t.Errorf("nested require not updated, got: %s", content)
+	}
+	if !strings.Contains(content, "replace github.com/new/project => ../") {
+		t.Errorf("nested replace not updated, got: %s", content)
+	}
+
+	data, err = os.ReadFile(filepath.Join(tmpDir, "examples", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = string(data)
+	if !strings.Contains(content, `"github.com/new/project"`) {
+		t.Errorf("root import not updated, got: %s", content)
+	}
+	if !strings.Contains(content, `"github.com/new/project/examples/internal/demo"`) {
+		t.Errorf("nested import not updated, got: %s", content)
+	}
+}
+
+func TestModuleNestedPathScheme(t *testing.T) {
+	tmpDir := setupNestedModules(t, "example.com/demo")
+
+	_, err := Module(tmpDir, "github.com/new/project", Options{Nested: NestedPath})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "examples", "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "module github.com/new/project/examples") {
+		t.Errorf("nested module statement not updated, got: %s", data)
+	}
+
+	data, err = os.ReadFile(filepath.Join(tmpDir, "examples", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"github.com/new/project/examples/internal/demo"`) {
+		t.Errorf("nested import not updated, got: %s", data)
+	}
+}
+
+func TestParseNestedScheme(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    NestedScheme
+		wantErr bool
+	}{
+		{"", NestedPrefix, false},
+		{"prefix", NestedPrefix, false},
+		{"path", NestedPath, false},
+		{"other", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseNestedScheme(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNestedScheme(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseNestedScheme(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModulePreservesModuleComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		goMod string
+		want  []string
+	}{
+		{
+			name:  "inline comment",
+			goMod: "module github.com/old/mod // internal template\n\ngo 1.21\n",
+			want:  []string{"module github.com/new/project // internal template"},
+		},
+		{
+			name:  "above-line comment",
+			goMod: "// Deprecated: use github.com/other/mod\nmodule github.com/old/mod\n\ngo 1.21\n",
+			want:  []string{"// Deprecated: use github.com/other/mod\nmodule github.com/new/project\n"},
+		},
+		{
+			name:  "block form",
+			goMod: "module (\n\t// above-line comment\n\tgithub.com/old/mod // internal template\n)\n\ngo 1.21\n",
+			want: []string{
+				"// above-line comment",
+				"github.com/new/project // internal template",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(tt.goMod), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Module(tmpDir, "github.com/new/project", Options{}); err != nil {
+				t.Fatalf("Module() error = %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("go.mod missing %q, got: %s", want, data)
+				}
+			}
+			if strings.Contains(string(data), "github.com/old/mod") {
+				t.Errorf("old module path still present, got: %s", data)
+			}
+		})
+	}
+}
+
+func TestModuleTextExtensions(t *testing.T) {
+	handler := `package handlers
+
+import "{{.Module}}/internal/db"
+import "github.com/old/module/internal/app"
+`
+
+	setup := func(t *testing.T) string {
+		tmpDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module github.com/old/module\n\ngo 1.21\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(tmpDir, "gen"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"handler.go.tmpl", "model.tpl"} {
+			if err := os.WriteFile(filepath.Join(tmpDir, "gen", name), []byte(handler), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return tmpDir
+	}
+
+	t.Run("default", func(t *testing.T) {
+		tmpDir := setup(t)
+
+		modified, err := Module(tmpDir, "github.com/new/project", Options{})
+		if err != nil {
+			t.Fatalf("Module() error = %v", err)
+		}
+
+		for _, name := range []string{"handler.go.tmpl", "model.tpl"} {
+			data, err := os.ReadFile(filepath.Join(tmpDir, "gen", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Replace(handler, "github.com/old/module", "github.com/new/project", 1)
+			if string(data) != want {
+				t.Errorf("%s:\ngot:  %q\nwant: %q", name, data, want)
+			}
+			if !slices.Contains(modified, filepath.Join("gen", name)) {
+				t.Errorf("expected %s in modified files, got %v", name, modified)
+			}
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tmpDir := setup(t)
+
+		if _, err := Module(tmpDir, "github.com/new/project", Options{TextExtensions: []string{}}); err != nil {
+			t.Fatalf("Module() error = %v", err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(tmpDir, "gen", "handler.go.tmpl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != handler {
+			t.Errorf("handler.go.tmpl should be unchanged, got: %s", data)
+		}
+	})
+}
+
+func TestModuleAssetExtensions(t *testing.T) {
+	client := `import { Client } from "./client";
+
+export const modulePath = "github.com/old/module/api";
+`
+	// Minified bundles are a single long line
+	bundle := strings.Repeat(`var a=1;`, 10000) + `fetch("/github.com/old/module/v1");` + strings.Repeat(`var b=2;`, 10000)
+	page := `<meta name="go-import" content="github.com/old/module git https://github.com/old/module">` + "\n"
+	binary := []byte("\x00\x01github.com/old/module\x00")
+
+	setup := func(t *testing.T) string {
+		tmpDir := t.TempDir()
+		files := map[string][]byte{
+			"go.mod":            []byte("module github.com/old/module\n\ngo 1.21\n"),
+			"web/client.ts":     []byte(client),
+			"web/bundle.min.js": []byte(bundle),
+			"web/index.html":    []byte(page),
+			"web/data.js":       binary,
+		}
+		for name, data := range files {
+			path := filepath.Join(tmpDir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return tmpDir
+	}
+
+	t.Run("default", func(t *testing.T) {
+		tmpDir := setup(t)
+
+		modified, err := Module(tmpDir, "github.com/new/project", Options{})
+		if err != nil {
+			t.Fatalf("Module() error = %v", err)
+		}
+
+		for name, orig := range map[string]string{"client.ts": client, "bundle.min.js": bundle, "index.html": page} {
+			data, err := os.ReadFile(filepath.Join(tmpDir, "web", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.ReplaceAll(orig, "github.com/old/module", "github.com/new/project")
+			if string(data) != want {
+				t.Errorf("%s was not rewritten as expected", name)
+			}
+			if !slices.Contains(modified, filepath.Join("web", name)) {
+				t.Errorf("expected %s in modified files, got %v", name, modified)
+			}
+		}
+
+		data, err := os.ReadFile(filepath.Join(tmpDir, "web", "data.js"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, binary) {
+			t.Errorf("data.js contains NUL bytes and should be unchanged, got: %q", data)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tmpDir := setup(t)
+
+		if _, err := Module(tmpDir, "github.com/new/project", Options{AssetExtensions: []string{}}); err != nil {
+			t.Fatalf("Module() error = %v", err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(tmpDir, "web", "client.ts"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != client {
+			t.Errorf("client.ts should be unchanged, got: %s", data)
+		}
+	})
+
+	t.Run("longer paths are kept", func(t *testing.T) {
+		tmpDir := setup(t)
+		routes := `const app = "github.com/old/module";
+const other = "github.com/old/modules/api";
+const url = "https://github.com/old/module-extras";
+`
+		if err := os.WriteFile(filepath.Join(tmpDir, "web", "routes.ts"), []byte(routes), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Module(tmpDir, "github.com/new/project", Options{}); err != nil {
+			t.Fatalf("Module() error = %v", err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(tmpDir, "web", "routes.ts"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.Replace(routes, `"github.com/old/module"`, `"github.com/new/project"`, 1)
+		if string(data) != want {
+			t.Errorf("routes.ts = %s, want %s", data, want)
+		}
+	})
+}
+
+func TestReadGoVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module github.com/old/module\n\ngo 1.23.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadGoVersion(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadGoVersion() error = %v", err)
+	}
+	if got != "1.23.0" {
+		t.Errorf("ReadGoVersion() = %q, want %q", got, "1.23.0")
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module github.com/old/module\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ReadGoVersion(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadGoVersion() error = %v", err)
+	}
+	if got != "" {
+		t.Errorf("ReadGoVersion() = %q, want empty", got)
+	}
+}
+
+func TestModuleSetsGoVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := `module github.com/old/module
+
+go 1.21
+
+require github.com/stretchr/testify v1.9.0 // indirect
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Module(tmpDir, "github.com/new/project", Options{GoVersion: "1.23"})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `module github.com/new/project
+
+go 1.23
+
+require github.com/stretchr/testify v1.9.0 // indirect
+`
+	if string(data) != expected {
+		t.Errorf("unexpected go.mod:\ngot:  %q\nwant: %q", data, expected)
+	}
+}
+
+func TestModuleSetsGoVersionSameModule(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := "module github.com/same/module\n\ngo 1.21\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	modified, err := Module(tmpDir, "github.com/same/module", Options{GoVersion: "1.23.0"})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+	if len(modified) != 1 || modified[0] != "go.mod" {
+		t.Errorf("expected go.mod to be modified, got %v", modified)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "go 1.23.0") {
+		t.Errorf("go directive not updated, got: %s", data)
+	}
+}
+
+func TestModuleUnknownDirectives(t *testing.T) {
+	const goMod = `module github.com/old/module
+
+go 1.30
+
+frobnicate all
+
+toolchain go1.30.1
+
+replace github.com/old/module v1.0.0 => ../fork
+`
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Module(tmpDir, "github.com/new/project", Options{Toolchain: "go1.30.2"}); err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Directives from newer Go versions are kept as written
+	want := strings.ReplaceAll(goMod, "github.com/old/module", "github.com/new/project")
+	want = strings.Replace(want, "go1.30.1", "go1.30.2", 1)
+	if string(data) != want {
+		t.Errorf("go.mod:\ngot:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestModuleExclude(t *testing.T) {
+	const goMod = `module github.com/old/module
+
+go 1.24.0
+EXTRA
+require github.com/old/module/lib v1.2.0
+
+exclude github.com/old/module/lib v1.1.0
+
+exclude (
+	github.com/old/module/lib v1.0.0
+	golang.org/x/text v0.3.0
+)
+`
+
+	tests := []struct {
+		name  string
+		extra string
+	}{
+		{"known directives", ""},
+		{"unknown directive", "\nfrobnicate all\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			libDir := filepath.Join(tmpDir, "lib")
+			if err := os.MkdirAll(libDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(libDir, "go.mod"), []byte("module github.com/old/module/lib\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			input := strings.Replace(goMod, "EXTRA", tt.extra, 1)
+			if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(input), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Module(tmpDir, "github.com/new/project", Options{}); err != nil {
+				t.Fatalf("Module() error = %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "github.com/old/module") {
+				t.Errorf("old module path left in go.mod:\n%s", data)
+			}
+			for _, want := range []string{
+				"exclude github.com/new/project/lib v1.1.0",
+				"github.com/new/project/lib v1.0.0",
+				"golang.org/x/text v0.3.0",
+			} {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("go.mod does not contain %q:\n%s", want, data)
+				}
+			}
+		})
+	}
+}
+
+func TestModulePreservesNewerDirectives(t *testing.T) {
+	const goMod = `module github.com/old/module
+
+go 1.24.0
+
+godebug default=go1.21
+
+godebug (
+	panicnil=1
+	asynctimerchan=0
+)
+
+tool (
+	github.com/old/module/cmd/gen
+	golang.org/x/tools/cmd/stringer
+)
+
+require golang.org/x/tools v0.30.0
+
+retract v1.0.0 // published by mistake
+
+retract [v0.1.0, v0.2.0]
+`
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Module(tmpDir, "github.com/new/project", Options{}); err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tools of the renamed module move with it; everything else is unchanged
+	want := strings.ReplaceAll(goMod, "github.com/old/module", "github.com/new/project")
+	if string(data) != want {
+		t.Errorf("go.mod:\ngot:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestModuleSameModuleFromModule(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module github.com/same/module\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "README.md"), []byte("Import github.com/legacy/app or github.com/same/module.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Extra files are still processed when FromModule is requested
+	modified, err := Module(tmpDir, "github.com/same/module", Options{
+		ExtraExtensions: []string{"md"},
+		FromModule:      "github.com/legacy/app",
+	})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+	if !slices.Equal(modified, []string{"README.md"}) {
+		t.Errorf("expected only README.md to be modified, got %v", modified)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Import github.com/same/module or github.com/same/module.\n"
+	if string(data) != want {
+		t.Errorf("README.md:\ngot:  %q\nwant: %q", data, want)
+	}
+}
+
+func TestModuleToolchain(t *testing.T) {
+	const withToolchain = `module github.com/same/module
+
+go 1.22.0
+
+toolchain go1.22.5
+
+require github.com/stretchr/testify v1.9.0 // indirect
+`
+	const withoutToolchain = `module github.com/same/module
+
+go 1.22.0
+
+require github.com/stretchr/testify v1.9.0 // indirect
+`
+
+	tests := []struct {
+		name      string
+		goMod     string
+		toolchain string
+		want      string
+	}{
+		{
+			name:      "set",
+			goMod:     withoutToolchain,
+			toolchain: "go1.23.4",
+			want: `module github.com/same/module
+
+go 1.22.0
+
+toolchain go1.23.4
+
+require github.com/stretchr/testify v1.9.0 // indirect
+`,
+		},
+		{
+			name:      "update",
+			goMod:     withToolchain,
+			toolchain: "go1.23.4",
+			want: `module github.com/same/module
+
+go 1.22.0
+
+toolchain go1.23.4
+
+require github.com/stretchr/testify v1.9.0 // indirect
+`,
+		},
+		{
+			name:      "remove",
+			goMod:     withToolchain,
+			toolchain: ToolchainNone,
+			want:      withoutToolchain,
+		},
+		{
+			name:      "remove absent",
+			goMod:     withoutToolchain,
+			toolchain: ToolchainNone,
+			want:      withoutToolchain,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(tt.goMod), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			modified, err := Module(tmpDir, "github.com/same/module", Options{Toolchain: tt.toolchain})
+			if err != nil {
+				t.Fatalf("Module() error = %v", err)
+			}
+			if wantModified := tt.goMod != tt.want; wantModified != (len(modified) == 1) {
+				t.Errorf("modified = %v, want go.mod modified: %v", modified, wantModified)
+			}
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("unexpected go.mod:\ngot:  %q\nwant: %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestModuleToolchainWithGoVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := "module github.com/old/module\n\ngo 1.21\n\ntoolchain go1.21.3\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Module(tmpDir, "github.com/new/project", Options{GoVersion: "1.23.0", Toolchain: "go1.23.4"})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "module github.com/new/project\n\ngo 1.23.0\n\ntoolchain go1.23.4\n"
+	if string(data) != expected {
+		t.Errorf("unexpected go.mod:\ngot:  %q\nwant: %q", data, expected)
+	}
+}
+
+func TestCheckToolchain(t *testing.T) {
+	for _, valid := range []string{"go1.23", "go1.23.4", "go1.24rc1", ToolchainNone} {
+		if err := CheckToolchain(valid); err != nil {
+			t.Errorf("CheckToolchain(%q) error = %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"1.23.4", "go", "default", "gofoo", "none "} {
+		if err := CheckToolchain(invalid); err == nil {
+			t.Errorf("CheckToolchain(%q) expected error", invalid)
+		}
+	}
+}
+
+func TestModuleInvalidGoVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := "module github.com/old/module\n\ngo 1.21\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Module(tmpDir, "github.com/new/project", Options{GoVersion: "go1.23"})
+	if err == nil {
+		t.Fatal("expected error for invalid go version")
+	}
+
+	// go.mod must be left untouched
+	data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != goMod {
+		t.Errorf("go.mod should not be modified, got: %s", data)
+	}
+}
+
+func TestModuleRewriteVendor(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := `module github.com/old/module
+
+go 1.21
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	vendorDir := filepath.Join(tmpDir, "vendor", "github.com", "other", "pkg")
+	if err := os.MkdirAll(vendorDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	vendorFile := `package pkg
+
+import "github.com/old/module/internal"
+`
+	if err := os.WriteFile(filepath.Join(vendorDir, "pkg.go"), []byte(vendorFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Module(tmpDir, "github.com/new/project", Options{RewriteVendor: true})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(vendorDir, "pkg.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"github.com/new/project/internal"`) {
+		t.Errorf("vendor file should be rewritten, got: %s", data)
+	}
+}
+
+func TestVariablesEmptySkipDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	vendorDir := filepath.Join(tmpDir, "vendor", "pkg")
+	if err := os.MkdirAll(vendorDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vendorDir, "pkg.go"), []byte(`const Name = "__ProjectName__"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Variables(tmpDir, map[string]string{"ProjectName": "MyApp"}, nil, []string{}, DefaultPlaceholders, 0)
+	if err != nil {
+		t.Fatalf("Variables() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(vendorDir, "pkg.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "MyApp") {
+		t.Errorf("vendor file should be rewritten, got: %s", data)
+	}
+}
+
+func TestRenamePaths_SkipDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, dir := range []string{"vendor", "testdata"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir, "__ProjectName__"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	renamed, err := RenamePaths(tmpDir, map[string]string{"ProjectName": "myapp"}, nil, []string{"testdata"}, DefaultPlaceholders)
+	if err != nil {
+		t.Fatalf("RenamePaths() error = %v", err)
+	}
+	if len(renamed) != 1 {
+		t.Errorf("expected 1 rename, got %v", renamed)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "vendor", "myapp")); err != nil {
+		t.Errorf("vendor is not in the skip list and should be renamed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "testdata", "__ProjectName__")); err != nil {
+		t.Errorf("skipped directory should not be renamed: %v", err)
+	}
+}
+
+func TestRenamePaths_RenameMap(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, name := range []string{"dot.env", filepath.Join("config", "dot.env"), "tmpl.txt"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	renameMap := map[string]string{
+		"dot.env": ".env",
+		// Static renames run first, so their result may use variables
+		"tmpl.txt": "__ProjectName__.txt",
+	}
+	renamed, err := RenamePaths(tmpDir, map[string]string{"ProjectName": "myapp"}, renameMap, nil, DefaultPlaceholders)
+	if err != nil {
+		t.Fatalf("RenamePaths() error = %v", err)
+	}
+	if len(renamed) != 3 {
+		t.Errorf("expected 3 renames, got %v", renamed)
+	}
+
+	for _, name := range []string{".env", filepath.Join("config", ".env"), "myapp.txt"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); err != nil {
+			t.Errorf("%s should exist: %v", name, err)
+		}
+	}
+	for _, name := range []string{"dot.env", "tmpl.txt", "__ProjectName__.txt"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist", name)
+		}
+	}
+}
+
+func TestNewName_Placeholders(t *testing.T) {
+	vars := map[string]string{"ProjectName": "myapp"}
+	tests := []struct {
+		name         string
+		placeholders Placeholders
+		want         string
+	}{
+		{"{{ProjectName}}.go", Placeholders{Left: "{{", Right: "}}"}, "myapp.go"},
+		{"__ProjectName__.go", Placeholders{Left: "{{", Right: "}}"}, "__ProjectName__.go"},
+		{"ProjectName_test.go", Placeholders{}, "myapp_test.go"},
+		{"ProjectName_test.go", Placeholders{WordBoundary: true}, "ProjectName_test.go"},
+		{"ProjectName.go", Placeholders{WordBoundary: true}, "myapp.go"},
+	}
+	for _, tt := range tests {
+		if got := NewName(tt.name, vars, nil, tt.placeholders); got != tt.want {
+			t.Errorf("NewName(%q, %+v) = %q, want %q", tt.name, tt.placeholders, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRenameMap(t *testing.T) {
+	tests := []struct {
+		name      string
+		renameMap map[string]string
+		wantErr   bool
+	}{
+		{"valid", map[string]string{"dot.gitignore": ".gitignore"}, false},
+		{"empty target", map[string]string{"dot.env": ""}, true},
+		{"nested target", map[string]string{"dot.env": "config/.env"}, true},
+		{"nested source", map[string]string{"a/dot.env": ".env"}, true},
+		{"parent", map[string]string{"dot.env": ".."}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRenameMap(tt.renameMap)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckRenameMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestModuleGoExtensions(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module github.com/old/mod\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := `package main
+
+import "github.com/old/mod/internal/app"
+
+// Output mentions the module path, which must stay as is
+const want = "github.com/old/mod"
+
+var _ = app.Run
+`
+	fixtureDir := filepath.Join(tmpDir, "testdata", "expected")
+	if err := os.MkdirAll(fixtureDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	goldenPath := filepath.Join(fixtureDir, "main.go.golden")
+	if err := os.WriteFile(goldenPath, []byte(golden), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// -e golden alone would replace the string constant as well
+	modified, err := Module(tmpDir, "github.com/new/project", Options{
+		ExtraExtensions: []string{"golden"},
+		GoExtensions:    []string{".go.golden"},
+	})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+	if !slices.Contains(modified, filepath.Join("testdata", "expected", "main.go.golden")) {
+		t.Errorf("golden file not reported as modified: %v", modified)
+	}
+
+	data, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if !strings.Contains(content, `import "github.com/new/project/internal/app"`) {
+		t.Errorf("import not rewritten:\n%s", content)
+	}
+	if !strings.Contains(content, `const want = "github.com/old/mod"`) {
+		t.Errorf("non-import content was rewritten:\n%s", content)
+	}
+}
+
+// writeSnippetTemplate creates Go files without a go.mod that import
+// their own packages below example.com/old.
+func writeSnippetTemplate(t *testing.T) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"main.go": `package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"example.com/old/internal/app"
+	"github.com/other/dep/app"
+)
+`,
+		filepath.Join("internal", "app", "app.go"):   "package app\n\nimport \"example.com/old/internal/util\"\n",
+		filepath.Join("internal", "util", "util.go"): "package util\n",
+		filepath.Join("http", "http.go"):             "package http\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Join(tmpDir, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tmpDir
+}
+
+func TestDetectModulePath(t *testing.T) {
+	got, err := DetectModulePath(writeSnippetTemplate(t), nil)
+	if err != nil {
+		t.Fatalf("DetectModulePath() error = %v", err)
+	}
+	// net/http is from the standard library, and no package directory
+	// matches github.com/other/dep/app
+	if got != "example.com/old" {
+		t.Errorf("DetectModulePath() = %q, want %q", got, "example.com/old")
+	}
+
+	got, err = DetectModulePath(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("DetectModulePath() error = %v", err)
+	}
+	if got != "" {
+		t.Errorf("DetectModulePath() on empty dir = %q, want empty", got)
+	}
+}
+
+func TestWriteGoMod(t *testing.T) {
+	tmpDir := writeSnippetTemplate(t)
+
+	if err := WriteGoMod(tmpDir, "github.com/new/project", "1.23"); err != nil {
+		t.Fatalf("WriteGoMod() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "module github.com/new/project\n\ngo 1.23\n"; string(data) != want {
+		t.Errorf("go.mod = %q, want %q", data, want)
+	}
+
+	// The import prefix is rewritten like --from-module
+	if _, err := Module(tmpDir, "github.com/new/project", Options{FromModule: "example.com/old"}); err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"github.com/new/project/internal/app"`) {
+		t.Errorf("import not rewritten:\n%s", data)
+	}
+
+	if err := WriteGoMod(tmpDir, "not a module", ""); err == nil {
+		t.Error("WriteGoMod() with invalid module path should fail")
+	}
+}
+
+func TestModuleSkipDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goMod := `module github.com/old/mod
+
+go 1.21
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	goFile := `package pkg
+
+import "github.com/old/mod/internal"
+`
+	for _, dir := range []string{"third_party", "vendor"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, dir, "pkg.go"), []byte(goFile), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := Module(tmpDir, "github.com/new/project", Options{
+		SkipDirs:      []string{"third_party"},
+		RewriteVendor: true,
+	})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	for dir, want := range map[string]bool{"third_party": false, "vendor": true} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, dir, "pkg.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(data), "github.com/new/project"); got != want {
+			t.Errorf("%s rewritten = %v, want %v", dir, got, want)
+		}
+	}
+}
+
+// writeSizedFiles writes small.<ext> and large.<ext> below dir, both
+// containing content; large.<ext> is padded beyond 100 bytes.
+func writeSizedFiles(t *testing.T, dir, ext, content string) {
+	t.Helper()
+
+	padding := "\n// " + strings.Repeat("x", 100) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "small."+ext), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "large."+ext), []byte(content+padding), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModuleMaxFileSize(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module github.com/old/mod\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeSizedFiles(t, tmpDir, "go", "package pkg\n\nimport \"github.com/old/mod/internal\"\n")
+	writeSizedFiles(t, tmpDir, "toml", "module = \"github.com/old/mod\"\n")
+
+	_, err := Module(tmpDir, "github.com/new/project", Options{
+		ExtraExtensions: []string{"toml"},
+		MaxFileSize:     100,
+	})
+	if err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	for _, name := range []string{"small.go", "small.toml", "large.go", "large.toml"} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.HasPrefix(name, "small")
+		if got := strings.Contains(string(data), "github.com/new/project"); got != want {
+			t.Errorf("%s rewritten = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestVariablesMaxFileSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeSizedFiles(t, tmpDir, "go", "package main\n\nconst Name = \"__ProjectName__\"\n")
+
+	_, err := Variables(tmpDir, map[string]string{"ProjectName": "MyApp"}, nil, nil, DefaultPlaceholders, 100)
+	if err != nil {
+		t.Fatalf("Variables() error = %v", err)
+	}
+
+	for name, want := range map[string]bool{"small.go": true, "large.go": false} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(data), "MyApp"); got != want {
+			t.Errorf("%s rewritten = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestLargeFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeSizedFiles(t, tmpDir, "go", "package main\n")
+	writeSizedFiles(t, tmpDir, "toml", "name = \"app\"\n")
+	writeSizedFiles(t, tmpDir, "bin", "data\n")
+
+	large, err := LargeFiles(tmpDir, 100, []string{"toml"}, nil)
+	if err != nil {
+		t.Fatalf("LargeFiles() error = %v", err)
+	}
+	if want := []string{"large.go", "large.toml"}; !slices.Equal(large, want) {
+		t.Errorf("LargeFiles() = %v, want %v", large, want)
+	}
+
+	large, err = LargeFiles(tmpDir, 0, []string{"toml"}, nil)
+	if err != nil {
+		t.Fatalf("LargeFiles() error = %v", err)
+	}
+	if large != nil {
+		t.Errorf("LargeFiles() without limit = %v, want nil", large)
+	}
+}
+
+func TestImports(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	goFile := `package main
+
+import (
+	"github.com/new/project/internal/app"
+	"os"
+	"github.com/stretchr/testify/assert"
+	"fmt"
+	"strings"
+)
+
+func main() {
+	fmt.Println(os.Args, app.Name, assert.Equal)
+}
+`
+	filePath := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(filePath, []byte(goFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	modified, err := Imports(tmpDir, "github.com/new/project", false, nil)
+	if err != nil {
+		t.Fatalf("Imports() error = %v", err)
+	}
+	if len(modified) != 1 {
+		t.Errorf("expected 1 modified file, got %v", modified)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/new/project/internal/app"
+)
+
+func main() {
+	fmt.Println(os.Args, app.Name, assert.Equal)
+}
+`
+	if string(data) != expected {
+		t.Errorf("imports not normalized:\ngot:\n%s\nwant:\n%s", data, expected)
+	}
+}
+
+func TestInclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := []string{
+		"go.mod",
+		"go.sum",
+		"main.go",
+		"README.md",
+		"cmd/app/main.go",
+		"cmd/tool/main.go",
+		"internal/app/app.go",
+		"internal/db/db.go",
+		"web/static/app.js",
+	}
+	for _, f := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := Include(tmpDir, []string{"cmd/**", "internal/app"})
+	if err != nil {
+		t.Fatalf("Include() error = %v", err)
+	}
+
+	sort.Strings(removed)
+	want := []string{"README.md", "internal/db/db.go", "main.go", "web/static/app.js"}
+	if !slices.Equal(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+
+	for _, f := range []string{"go.mod", "go.sum", "cmd/app/main.go", "cmd/tool/main.go", "internal/app/app.go"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, filepath.FromSlash(f))); err != nil {
+			t.Errorf("expected %s to be kept: %v", f, err)
+		}
+	}
+	for _, d := range []string{"internal/db", "web"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, filepath.FromSlash(d))); !os.IsNotExist(err) {
+			t.Errorf("expected emptied directory %s to be removed, got %v", d, err)
+		}
+	}
+}
+
+func TestIncludeEmptyPatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := Include(tmpDir, nil)
+	if err != nil {
+		t.Fatalf("Include() error = %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("expected nothing removed, got %v", removed)
+	}
+}
+
+func TestIncludeInvalidPattern(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pattern := range []string{"", "cmd/[", "\\"} {
+		if _, err := Include(tmpDir, []string{pattern}); err == nil {
+			t.Errorf("expected error for pattern %q", pattern)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "main.go")); err != nil {
+		t.Errorf("main.go should be kept on error: %v", err)
+	}
+}
+
+func TestExecutable(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]os.FileMode{
+		"scripts/build.sh":   0o644,
+		"scripts/private.sh": 0o600,
+		"scripts/run.sh":     0o755,
+		"scripts/README.md":  0o644,
+		"main.go":            0o644,
+	}
+	for f, mode := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changed, err := Executable(tmpDir, []string{"scripts/*.sh"})
+	if err != nil {
+		t.Fatalf("Executable() error = %v", err)
+	}
+
+	sort.Strings(changed)
+	want := []string{"scripts/build.sh", "scripts/private.sh"}
+	if !slices.Equal(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+
+	wantModes := map[string]os.FileMode{
+		"scripts/build.sh":   0o755,
+		"scripts/private.sh": 0o700,
+		"scripts/run.sh":     0o755,
+		"scripts/README.md":  0o644,
+		"main.go":            0o644,
+	}
+	for f, mode := range wantModes {
+		info, err := os.Stat(filepath.Join(tmpDir, filepath.FromSlash(f)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != mode {
+			t.Errorf("%s mode = %v, want %v", f, got, mode)
+		}
+	}
+}
+
+func TestExecutableInvalidPattern(t *testing.T) {
+	if _, err := Executable(t.TempDir(), []string{"scripts/["}); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"cmd/**", "cmd", true},
+		{"cmd/**", "cmd/app/main.go", true},
+		{"cmd/**", "cmdx/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "internal/app/app.go", true},
+		{"**/*.go", "README.md", false},
+		{"internal/*/doc.go", "internal/app/doc.go", true},
+		{"internal/*/doc.go", "internal/app/sub/doc.go", false},
+		{"internal/**/doc.go", "internal/app/sub/doc.go", true},
+		{"*.md", "docs/guide.md", false},
+		{"go.mod", "go.mod", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tmpDir := t.TempDir()
+	binary := []byte("foo\x00\x01foo")
+	files := map[string][]byte{
+		"main.go":     []byte("package main\n\nconst name = \"foo\"\n"),
+		"config.yaml": []byte("name: foo\n"),
+		"notes.txt":   []byte("foo\n"),
+		"data.yaml":   binary,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	modified, err := Replace(tmpDir, []Replacement{{Old: "foo", New: "bar"}}, []string{"yaml"}, nil, 0)
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	sort.Strings(modified)
+	if want := []string{"config.yaml", "main.go"}; !slices.Equal(modified, want) {
+		t.Errorf("modified = %v, want %v", modified, want)
+	}
+
+	want := map[string]string{
+		"main.go":     "package main\n\nconst name = \"bar\"\n",
+		"config.yaml": "name: bar\n",
+		"notes.txt":   "foo\n",
+		"data.yaml":   string(binary),
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+}
+
+func TestReplaceSinglePass(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("a b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Swapping must not chain a→b→a
+	_, err := Replace(tmpDir, []Replacement{{Old: "a", New: "b"}, {Old: "b", New: "a"}}, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "b a" {
+		t.Errorf("got %q, want %q", data, "b a")
+	}
+}
+
+func TestParseReplacement(t *testing.T) {
+	r, err := ParseReplacement("key=a=b")
+	if err != nil {
+		t.Fatalf("ParseReplacement() error = %v", err)
+	}
+	if r != (Replacement{Old: "key", New: "a=b"}) {
+		t.Errorf("ParseReplacement() = %+v", r)
+	}
+
+	r, err = ParseReplacement("remove=")
+	if err != nil || r != (Replacement{Old: "remove"}) {
+		t.Errorf("ParseReplacement(%q) = %+v, %v", "remove=", r, err)
+	}
+
+	for _, invalid := range []string{"", "novalue", "=new"} {
+		if _, err := ParseReplacement(invalid); err == nil {
+			t.Errorf("ParseReplacement(%q) expected error", invalid)
+		}
+	}
+}
+
+func TestRenderTemplates(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpl := `package main
+{{if eq .Database "sqlite"}}
+import _ "modernc.org/sqlite"
+{{end}}
+var commands = []string{ {{- range split .Commands ","}}"{{.}}", {{end -}} }
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go.tmpl"), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "README.md"), []byte("{{.Database}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".git", "HEAD.tmpl"), []byte("{{.Missing}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	vars := map[string]string{"Database": "sqlite", "Commands": "serve,migrate"}
+	rendered, err := RenderTemplates(tmpDir, vars, nil, nil)
+	if err != nil {
+		t.Fatalf("RenderTemplates() error = %v", err)
+	}
+	if len(rendered) != 1 || rendered[0] != "main.go" {
+		t.Errorf("rendered = %v, want [main.go]", rendered)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package main\n\nimport _ \"modernc.org/sqlite\"\n\nvar commands = []string{\"serve\", \"migrate\", }\n"
+	if string(data) != want {
+		t.Errorf("main.go = %q, want %q", data, want)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "main.go.tmpl")); !os.IsNotExist(err) {
+		t.Error("main.go.tmpl should be removed")
+	}
+
+	// Files without the suffix are left to placeholder substitution
+	data, err = os.ReadFile(filepath.Join(tmpDir, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "{{.Database}}\n" {
+		t.Errorf("README.md = %q, want it unchanged", data)
+	}
+}
+
+func TestRenderTemplates_Exclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"handler.go.tmpl": "package gen // {{.Module}}\n",
+		"page.html.tmpl":  "<h1>{{.ProjectName}}</h1>\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rendered, err := RenderTemplates(tmpDir, map[string]string{"ProjectName": "myapp"}, []string{"go.tmpl"}, nil)
+	if err != nil {
+		t.Fatalf("RenderTemplates() error = %v", err)
+	}
+	if len(rendered) != 1 || rendered[0] != "page.html" {
+		t.Errorf("rendered = %v, want [page.html]", rendered)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "handler.go.tmpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != files["handler.go.tmpl"] {
+		t.Errorf("handler.go.tmpl = %q, want it unchanged", data)
+	}
+}
+
+func TestRenderTemplates_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"missing key", map[string]string{"main.go.tmpl": "{{.Missing}}"}, "map has no entry for key"},
+		{"parse error", map[string]string{"main.go.tmpl": "{{if}}"}, "parsing"},
+		{"existing target", map[string]string{"main.go.tmpl": "package main", "main.go": "package main"}, "already exists"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := RenderTemplates(tmpDir, map[string]string{"ProjectName": "myapp"}, nil, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("RenderTemplates() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestModuleSimilarModulePaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module github.com/a/b\n\ngo 1.21\n",
+		"main.go": "package main\n\n" +
+			"//go:generate go run github.com/a/bb/cmd/gen github.com/a/b/cmd/gen\n\n" +
+			"import (\n" +
+			"\t_ \"example.com/github.com/a/b\"\n" +
+			"\t_ \"github.com/a/b\"\n" +
+			"\t_ \"github.com/a/b.v2\"\n" +
+			"\t_ \"github.com/a/b/pkg\"\n" +
+			"\t_ `github.com/a/b/raw`\n" +
+			"\t_ \"github.com/a/bb/pkg\"\n" +
+			")\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := Module(tmpDir, "github.com/a/b/v2", Options{}); err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package main\n\n" +
+		"//go:generate go run github.com/a/bb/cmd/gen github.com/a/b/v2/cmd/gen\n\n" +
+		"import (\n" +
+		"\t_ \"example.com/github.com/a/b\"\n" +
+		"\t_ \"github.com/a/b.v2\"\n" +
+		"\t_ \"github.com/a/b/v2\"\n" +
+		"\t_ \"github.com/a/b/v2/pkg\"\n" +
+		"\t_ \"github.com/a/b/v2/raw\"\n" +
+		"\t_ \"github.com/a/bb/pkg\"\n" +
+		")\n"
+	if string(data) != want {
+		t.Errorf("main.go =\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestRewriteImportPath(t *testing.T) {
+	mods := []moduleInfo{
+		{Old: "github.com/a/b/tools", New: "github.com/x/y/tools"},
+		{Old: "github.com/a/b", New: "github.com/x/y"},
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"github.com/a/b", "github.com/x/y"},
+		{"github.com/a/b/pkg", "github.com/x/y/pkg"},
+		{"github.com/a/b/tools", "github.com/x/y/tools"},
+		{"github.com/a/b/tools/cmd", "github.com/x/y/tools/cmd"},
+		{"github.com/a/b/toolsx", "github.com/x/y/toolsx"},
+		{"github.com/a/bb", "github.com/a/bb"},
+		{"github.com/a/bb/pkg", "github.com/a/bb/pkg"},
+		{"github.com/a", "github.com/a"},
+		{"example.com/github.com/a/b", "example.com/github.com/a/b"},
+	}
+
+	for _, tt := range tests {
+		if got := rewriteImportPath(tt.path, mods); got != tt.want {
+			t.Errorf("rewriteImportPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceModulePaths(t *testing.T) {
+	// The new paths extend the old ones, as in a major version bump
+	mods := []moduleInfo{
+		{Old: "github.com/a/b/tools", New: "github.com/a/b/v2/tools"},
+		{Old: "github.com/a/b", New: "github.com/a/b/v2"},
+	}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"//go:generate go run github.com/a/b/tools/gen", "//go:generate go run github.com/a/b/v2/tools/gen"},
+		{"// github.com/a/b and github.com/a/b/pkg.", "// github.com/a/b/v2 and github.com/a/b/v2/pkg."},
+		{"// github.com/a/bb github.com/a/b-x github.com/a/b.v2", "// github.com/a/bb github.com/a/b-x github.com/a/b.v2"},
+		{"// example.com/github.com/a/b", "// example.com/github.com/a/b"},
+		{"// nothing to do", "// nothing to do"},
+	}
+
+	for _, tt := range tests {
+		if got := replaceModulePaths(tt.text, mods); got != tt.want {
+			t.Errorf("replaceModulePaths(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestModuleDropsSelfRequire(t *testing.T) {
+	tmpDir := t.TempDir()
+	goMod := `module github.com/old/mod
+
+go 1.21
+
+require (
+	github.com/old/mod v0.0.0
+	golang.org/x/text v0.3.0
+)
+
+replace github.com/old/mod => ./
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := SelfRequires(tmpDir, nil, false)
+	if err != nil {
+		t.Fatalf("SelfRequires() error = %v", err)
+	}
+	if len(found) != 1 || found[0] != "go.mod" {
+		t.Errorf("SelfRequires() = %v, want [go.mod]", found)
+	}
+
+	if _, err := Module(tmpDir, "github.com/new/mod", Options{}); err != nil {
+		t.Fatalf("Module() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if strings.Contains(content, "github.com/old/mod") {
+		t.Errorf("old module path left in go.mod:\n%s", content)
+	}
+	if strings.Contains(content, "github.com/new/mod v0.0.0") {
+		t.Errorf("self-require carried over to the new module:\n%s", content)
+	}
+	if !strings.Contains(content, "golang.org/x/text v0.3.0") {
+		t.Errorf("unrelated require dropped:\n%s", content)
+	}
+	if !strings.Contains(content, "replace github.com/new/mod => ./") {
+		t.Errorf("replace not updated:\n%s", content)
+	}
+
+	found, err = SelfRequires(tmpDir, nil, false)
+	if err != nil {
+		t.Fatalf("SelfRequires() error = %v", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("SelfRequires() after rewrite = %v, want none", found)
+	}
+}
+
+func TestModuleKeepsNestedRequireOfRoot(t *testing.T) {
+	tmpDir := setupNestedModules(t, "github.com/old/app/examples")
+
+	found, err := SelfRequires(tmpDir, nil, false)
+	if err != nil {
+		t.Fatalf("SelfRequires() error = %v", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("SelfRequires() = %v, a nested module requiring the root is not a self-require", found)
+	}
+}
+
+func TestApplyRewriters(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"package.json":          `{"name": "github.com/old/mod"}`,
+		"web/app.json":          `{"api": "github.com/old/mod/api"}`,
+		"README.md":             "github.com/old/mod",
+		".git/config.json":      "{}",
+		"static/unchanged.json": "{}",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var seen []string
+	rewriter := Rewriter{
+		Pattern: "*.json",
+		Rewrite: func(path string, content []byte) ([]byte, error) {
+			rel, _ := filepath.Rel(tmpDir, path)
+			seen = append(seen, filepath.ToSlash(rel))
+			return bytes.ReplaceAll(content, []byte("github.com/old/mod"), []byte("github.com/new/mod")), nil
+		},
+	}
+
+	modified, err := ApplyRewriters(tmpDir, []Rewriter{rewriter}, nil, 0)
+	if err != nil {
+		t.Fatalf("ApplyRewriters() error = %v", err)
+	}
+	sort.Strings(modified)
+	if want := []string{"package.json", filepath.Join("web", "app.json")}; !slices.Equal(modified, want) {
+		t.Errorf("modified = %v, want %v", modified, want)
+	}
+	sort.Strings(seen)
+	if want := []string{"package.json", "static/unchanged.json", "web/app.json"}; !slices.Equal(seen, want) {
+		t.Errorf("rewriter saw %v, want %v", seen, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "web", "app.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"api": "github.com/new/mod/api"}` {
+		t.Errorf("app.json = %s", data)
+	}
+	data, err = os.ReadFile(filepath.Join(tmpDir, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "github.com/old/mod" {
+		t.Errorf("README.md should not be rewritten, got %s", data)
+	}
+}
+
+func TestApplyRewritersErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ApplyRewriters(tmpDir, []Rewriter{{Pattern: "[", Rewrite: nil}}, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "invalid rewriter pattern") {
+		t.Errorf("expected invalid pattern error, got %v", err)
+	}
+
+	failing := Rewriter{Pattern: "*.json", Rewrite: func(string, []byte) ([]byte, error) {
+		return nil, errors.New("boom")
+	}}
+	_, err = ApplyRewriters(tmpDir, []Rewriter{failing}, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "a.json: boom") {
+		t.Errorf("expected rewriter error naming the file, got %v", err)
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	env := CommandEnv("github.com/old/mod", "github.com/new/mod", map[string]string{"Author": "Jane"})
+	sort.Strings(env)
+	want := []string{"GOHATCH_VAR_Author=Jane", "NEW_MODULE=github.com/new/mod", "OLD_MODULE=github.com/old/mod"}
+	if !slices.Equal(env, want) {
+		t.Errorf("CommandEnv() = %v, want %v", env, want)
+	}
+}
+
+func TestConfusableChars(t *testing.T) {
+	if got := ConfusableChars("github.com/me/my-app_v2.0~x"); got != nil {
+		t.Errorf("ConfusableChars(ASCII) = %v, want nil", got)
+	}
+
+	// The first a is Cyrillic U+0430
+	got := ConfusableChars("github.com/me/аppа")
+	want := []string{`'а' (U+0430 Cyrillic)`}
+	if !slices.Equal(got, want) {
+		t.Errorf("ConfusableChars() = %v, want %v", got, want)
+	}
+
+	got = ConfusableChars("example.com/café/⁄x")
+	want = []string{`'é' (U+00E9 Latin)`, `'⁄' (U+2044 symbol)`}
+	if !slices.Equal(got, want) {
+		t.Errorf("ConfusableChars() = %v, want %v", got, want)
+	}
+}
@@ -6,76 +6,373 @@ package rewrite
 import (
 	"bytes"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 )
 
+// Placeholders describes how variables are written in template files.
+type Placeholders struct {
+	// Left and Right enclose the variable name. Both may be empty for
+	// bare tokens such as NAME.
+	Left, Right string
+
+	// WordBoundary only replaces placeholders that are not adjacent to
+	// letters, digits, or underscores, so NAME does not match in USERNAME.
+	WordBoundary bool
+}
+
+// DefaultPlaceholders uses dunder-style syntax: __VariableName__.
+var DefaultPlaceholders = Placeholders{Left: "__", Right: "__"}
+
+// ParsePlaceholders builds Placeholders from a [left, right] delimiter
+// pair. Nil delimiters select the dunder-style default.
+func ParsePlaceholders(delimiters []string, wordBoundary bool) (Placeholders, error) {
+	p := DefaultPlaceholders
+	if delimiters != nil {
+		if len(delimiters) != 2 {
+			return Placeholders{}, fmt.Errorf("delimiters must be a [left, right] pair, got %d entries", len(delimiters))
+		}
+		p.Left, p.Right = delimiters[0], delimiters[1]
+	}
+	p.WordBoundary = wordBoundary
+	return p, nil
+}
+
+// DefaultSeparator separates a variable name from the value used when the
+// variable is not set, as in __License|MIT__.
+const DefaultSeparator = "|"
+
+// DefaultVariableFiles lists extensionless dotfiles whose variables are
+// replaced in addition to .go files, so ignore entries such as
+// __ProjectName__/bin are filled in.
+var DefaultVariableFiles = []string{".gitignore", ".dockerignore"}
+
+// replace substitutes every placeholder of key in data with value.
+func (p Placeholders) replace(data []byte, key, value string) []byte {
+	placeholder := []byte(p.Left + key + p.Right)
+	if !p.WordBoundary {
+		return bytes.ReplaceAll(data, placeholder, []byte(value))
+	}
+
+	var buf bytes.Buffer
+	for {
+		idx := bytes.Index(data, placeholder)
+		if idx == -1 {
+			buf.Write(data)
+			return buf.Bytes()
+		}
+
+		end := idx + len(placeholder)
+		before := idx == 0 || !isWordChar(data[idx-1])
+		after := end == len(data) || !isWordChar(data[end])
+
+		buf.Write(data[:idx])
+		if before && after {
+			buf.WriteString(value)
+		} else {
+			buf.Write(placeholder)
+		}
+		data = data[end:]
+	}
+}
+
+// replaceDefaults substitutes every placeholder with a default value,
+// such as __License|MIT__, with the value of its variable in vars, or
+// with the default if the variable is not set.
+func (p Placeholders) replaceDefaults(data []byte, vars map[string]string) []byte {
+	var buf bytes.Buffer
+	last := 0
+	p.eachDefault(data, func(start, end int, name, def string) {
+		value, ok := vars[name]
+		if !ok {
+			value = def
+		}
+		buf.Write(data[last:start])
+		buf.WriteString(value)
+		last = end
+	})
+	if last == 0 {
+		return data
+	}
+	buf.Write(data[last:])
+	return buf.Bytes()
+}
+
+// eachDefault calls fn with the position, variable name, and default
+// value of every placeholder with a default value in data. The name
+// consists of letters, digits, and underscores; the default ends at the
+// first right delimiter and must not span lines. Placeholders without
+// delimiters have no default syntax.
+func (p Placeholders) eachDefault(data []byte, fn func(start, end int, name, def string)) {
+	if p.Left == "" || p.Right == "" {
+		return
+	}
+	left, right := []byte(p.Left), []byte(p.Right)
+
+	for i := 0; ; {
+		idx := bytes.Index(data[i:], left)
+		if idx == -1 {
+			return
+		}
+		start := i + idx
+		i = start + 1
+
+		nameEnd := start + len(left)
+		for nameEnd < len(data) && isWordChar(data[nameEnd]) {
+			nameEnd++
+		}
+		name := string(data[start+len(left) : nameEnd])
+		if name == "" || strings.Contains(name, p.Left) || strings.Contains(name, p.Right) ||
+			!bytes.HasPrefix(data[nameEnd:], []byte(DefaultSeparator)) {
+			continue
+		}
+
+		defStart := nameEnd + len(DefaultSeparator)
+		defLen := bytes.Index(data[defStart:], right)
+		if defLen == -1 {
+			return
+		}
+		def := data[defStart : defStart+defLen]
+		if bytes.ContainsAny(def, "\r\n") {
+			continue
+		}
+
+		end := defStart + defLen + len(right)
+		if p.WordBoundary && (start > 0 && isWordChar(data[start-1]) || end < len(data) && isWordChar(data[end])) {
+			continue
+		}
+
+		fn(start, end, name, string(def))
+		i = end
+	}
+}
+
+// count returns how many placeholders of key replace and replaceDefaults
+// would substitute in data.
+func (p Placeholders) count(data []byte, key string) int {
+	n := 0
+	p.eachDefault(data, func(_, _ int, name, _ string) {
+		if name == key {
+			n++
+		}
+	})
+
+	placeholder := []byte(p.Left + key + p.Right)
+	if !p.WordBoundary {
+		return n + bytes.Count(data, placeholder)
+	}
+
+	for {
+		idx := bytes.Index(data, placeholder)
+		if idx == -1 {
+			return n
+		}
+
+		end := idx + len(placeholder)
+		before := idx == 0 || !isWordChar(data[idx-1])
+		after := end == len(data) || !isWordChar(data[end])
+		if before && after {
+			n++
+		}
+		data = data[end:]
+	}
+}
+
+// isWordChar reports whether c is an ASCII letter, digit, or underscore.
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
 // Variables replaces template variables in all files.
-// Variables use dunder-style syntax: __VariableName__ → value.
+// Placeholders default to dunder-style syntax: __VariableName__ → value.
+// A placeholder may carry a default used when the variable is not in
+// vars: __License|MIT__.
+// Directories named in skipDirs are skipped; nil selects DefaultSkipDirs.
+// Files larger than maxSize bytes are skipped unless maxSize is 0.
 // Returns the list of modified files.
-func Variables(dir string, vars map[string]string, extraPatterns []string) ([]string, error) {
+func Variables(dir string, vars map[string]string, extraPatterns []string, skipDirs []string, placeholders Placeholders, maxSize int64) ([]string, error) {
 	if len(vars) == 0 {
 		return nil, nil
 	}
 
 	var modifiedFiles []string
 
+	err := walkVariableFiles(dir, extraPatterns, skipDirs, maxSize, func(path string) error {
+		modified, err := replaceVariablesInFile(path, vars, placeholders)
+		if err != nil {
+			return err
+		}
+		if modified {
+			relPath, _ := filepath.Rel(dir, path)
+			modifiedFiles = append(modifiedFiles, relPath)
+		}
+		return nil
+	})
+
+	return modifiedFiles, err
+}
+
+// ExpandVariables resolves placeholders of other variables in the values
+// of vars, so Module=github.com/me/__ProjectName__ takes the value of
+// ProjectName. References are expanded recursively; a variable that
+// refers to itself, directly or through others, is an error. Placeholders
+// without delimiters are not expanded, since any word could match.
+func ExpandVariables(vars map[string]string, placeholders Placeholders) (map[string]string, error) {
+	if placeholders.Left == "" || placeholders.Right == "" {
+		return maps.Clone(vars), nil
+	}
+
+	keys := slices.Sorted(maps.Keys(vars))
+	result := make(map[string]string, len(vars))
+
+	var expand func(key string, chain []string) error
+	expand = func(key string, chain []string) error {
+		if _, ok := result[key]; ok {
+			return nil
+		}
+		if i := slices.Index(chain, key); i != -1 {
+			return fmt.Errorf("cyclic variable reference: %s", strings.Join(append(chain[i:], key), " -> "))
+		}
+		chain = append(chain, key)
+
+		value := []byte(vars[key])
+		refs := make(map[string]string)
+		for _, ref := range keys {
+			if placeholders.count(value, ref) == 0 {
+				continue
+			}
+			if err := expand(ref, chain); err != nil {
+				return err
+			}
+			refs[ref] = result[ref]
+			value = placeholders.replace(value, ref, result[ref])
+		}
+		result[key] = string(placeholders.replaceDefaults(value, refs))
+		return nil
+	}
+
+	for _, key := range keys {
+		if err := expand(key, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// VariableUsage counts the placeholders of a variable in file contents.
+type VariableUsage struct {
+	Occurrences int
+	Files       int
+}
+
+// CountVariables reports how many placeholders of each variable
+// Variables would replace, and in how many files, without modifying
+// anything. Every variable has an entry, so unused ones count zero.
+func CountVariables(dir string, vars map[string]string, extraPatterns []string, skipDirs []string, placeholders Placeholders, maxSize int64) (map[string]VariableUsage, error) {
+	usage := make(map[string]VariableUsage, len(vars))
+	for key := range vars {
+		usage[key] = VariableUsage{}
+	}
+	if len(vars) == 0 {
+		return usage, nil
+	}
+
+	err := walkVariableFiles(dir, extraPatterns, skipDirs, maxSize, func(path string) error {
+		cleanPath := filepath.Clean(path)
+		data, err := os.ReadFile(cleanPath)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", cleanPath, err)
+		}
+		text, _, ok := decodeText(data)
+		if !ok {
+			return nil
+		}
+
+		for key := range vars {
+			if n := placeholders.count(text, key); n > 0 {
+				u := usage[key]
+				u.Occurrences += n
+				u.Files++
+				usage[key] = u
+			}
+		}
+		return nil
+	})
+
+	return usage, err
+}
+
+// walkVariableFiles calls fn for every file whose variables are replaced:
+// .go files and files matching extraPatterns, outside directories named
+// in skipDirs (nil selects DefaultSkipDirs) and up to maxSize bytes
+// unless maxSize is 0. Symlinks are skipped, so nothing is written
+// through a link that points outside dir.
+func walkVariableFiles(dir string, extraPatterns, skipDirs []string, maxSize int64, fn func(path string) error) error {
 	// Build pattern set: go + extra patterns
 	patternSet := parseFilePatterns(extraPatterns)
 	patternSet["go"] = true
 
-	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+	skip := skipDirSet(skipDirs)
+
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		// Skip directories
 		if d.IsDir() {
-			if d.Name() == "vendor" || d.Name() == ".git" {
+			if skip[d.Name()] {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
 		// Check if file matches by extension or name
 		if !matchesFilePattern(d.Name(), patternSet) {
 			return nil
 		}
-
-		modified, err := replaceVariablesInFile(path, vars)
-		if err != nil {
+		if tooLarge, err := exceedsSize(d, maxSize); err != nil || tooLarge {
 			return err
 		}
-		if modified {
-			relPath, _ := filepath.Rel(dir, path)
-			modifiedFiles = append(modifiedFiles, relPath)
-		}
-		return nil
-	})
 
-	return modifiedFiles, err
+		return fn(path)
+	})
 }
 
-// replaceVariablesInFile replaces __Key__ with Value for all variables.
-// Returns true if the file was modified.
-func replaceVariablesInFile(filePath string, vars map[string]string) (bool, error) {
+// replaceVariablesInFile replaces the placeholders of all variables with
+// their values. Returns true if the file was modified.
+func replaceVariablesInFile(filePath string, vars map[string]string, placeholders Placeholders) (bool, error) {
 	cleanPath := filepath.Clean(filePath)
 	data, err := os.ReadFile(cleanPath)
 	if err != nil {
 		return false, fmt.Errorf("reading %s: %w", cleanPath, err)
 	}
 
-	// Replace each variable
-	newData := data
+	// UTF-16 files are matched in UTF-8 and written back in UTF-16
+	text, enc, ok := decodeText(data)
+	if !ok {
+		return false, nil
+	}
+
+	// Replace each variable, then placeholders with defaults
+	newText := text
 	for key, value := range vars {
-		placeholder := "__" + key + "__"
-		newData = bytes.ReplaceAll(newData, []byte(placeholder), []byte(value))
+		newText = placeholders.replace(newText, key, value)
 	}
+	newText = placeholders.replaceDefaults(newText, vars)
 
 	// Only write if changed
-	if bytes.Equal(data, newData) {
+	if bytes.Equal(text, newText) {
 		return false, nil
 	}
+	newData := encodeText(newText, enc)
 
 	info, err := os.Stat(cleanPath)
 	if err != nil {
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package source
+
+// Source kinds reported by Kind.
+const (
+	KindGit   = "git"
+	KindLocal = "local"
+	KindProxy = "proxy"
+)
+
+// Format describes an input syntax recognized by Parse.
+type Format struct {
+	Name     string
+	Syntax   string
+	Examples []string
+}
+
+// Formats lists the input syntaxes recognized by Parse. Each example is
+// meant to be run through Parse, so the resolved kind and location
+// reflect the actual parsing logic.
+var Formats = []Format{
+	{Name: "GitHub shorthand", Syntax: "user/repo[@version]", Examples: []string{"user/repo"}},
+	{Name: "Full URL", Syntax: "host/user/repo[@version]", Examples: []string{"github.com/user/repo", "codeberg.org/user/repo"}},
+	{Name: "HTTPS URL", Syntax: "https://host/user/repo[@version]", Examples: []string{"https://github.com/user/repo.git"}},
+	{Name: "SSH URL", Syntax: "git@host:user/repo[@version]", Examples: []string{"git@github.com:user/repo", "ssh://git@github.com/user/repo.git"}},
+	{Name: "Specific version", Syntax: "source@tag|branch|commit", Examples: []string{"user/repo@v1.0.0", "user/repo@main", "git@github.com:user/repo@abc1234"}},
+	{Name: "Local directory", Syntax: "./path | /path | existing directory", Examples: []string{"./my-template", "/srv/templates/go-cli"}},
+	{Name: "Go module proxy", Syntax: ProxyPrefix + "module[@version]", Examples: []string{ProxyPrefix + "github.com/user/repo@v1.0.0", ProxyPrefix + "github.com/user/repo"}},
+}
+
+// Kind returns the kind of src: KindGit, KindLocal, or KindProxy.
+// Unknown sources return an empty string.
+func Kind(src Source) string {
+	switch src.(type) {
+	case *GitSource:
+		return KindGit
+	case *LocalSource:
+		return KindLocal
+	case *ProxySource:
+		return KindProxy
+	default:
+		return ""
+	}
+}
+
+// Location returns where src is fetched from and the requested version,
+// if any: the URL of a Git repository, the path of a local directory,
+// or the module path for the module proxy.
+func Location(src Source) (location, version string) {
+	switch s := src.(type) {
+	case *GitSource:
+		return s.URL, s.Version
+	case *LocalSource:
+		return s.Path, ""
+	case *ProxySource:
+		return s.Module, s.Version
+	default:
+		return "", ""
+	}
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package source
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// loadIgnoreMatcher combines the user's global excludes file with the
+// .gitignore files and .git/info/exclude found below root. Patterns from
+// the template take precedence over global ones.
+func loadIgnoreMatcher(root string) (gitignore.Matcher, error) {
+	global, err := globalIgnorePatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := gitignore.ReadPatterns(osfs.New(root), nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading .gitignore files: %w", err)
+	}
+
+	return gitignore.NewMatcher(append(global, local...)), nil
+}
+
+// globalIgnorePatterns reads the patterns of the global excludes file.
+// A missing file yields no patterns.
+func globalIgnorePatterns() ([]gitignore.Pattern, error) {
+	path, err := globalExcludesFile()
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading global excludes file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var ps []gitignore.Pattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		ps = append(ps, gitignore.ParsePattern(line, nil))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading global excludes file: %w", err)
+	}
+
+	return ps, nil
+}
+
+// globalExcludesFile locates the global excludes file using git's rules:
+// core.excludesFile from the global git config, falling back to
+// $XDG_CONFIG_HOME/git/ignore or ~/.config/git/ignore.
+func globalExcludesFile() (string, error) {
+	cfg, err := config.LoadConfig(config.GlobalScope)
+	if err != nil {
+		return "", fmt.Errorf("loading global git config: %w", err)
+	}
+
+	path := cfg.Raw.Section("core").Option("excludesfile")
+	if path != "" {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			path = filepath.Join(home, rest)
+		}
+		return path, nil
+	}
+
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "git", "ignore"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", nil //nolint:nilerr // no home directory means no default excludes file
+	}
+	return filepath.Join(home, ".config", "git", "ignore"), nil
+}
@@ -0,0 +1,325 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// lfsPointerVersion is the first line of every Git LFS pointer file.
+const lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+// lfsPointerMaxSize bounds the size of files inspected as LFS pointers.
+const lfsPointerMaxSize = 1024
+
+// LFSPointer identifies a Git LFS object referenced by a pointer file.
+type LFSPointer struct {
+	OID  string
+	Size int64
+}
+
+// LFSFetcher downloads the content of Git LFS objects.
+type LFSFetcher interface {
+	Open(ctx context.Context, p LFSPointer) (io.ReadCloser, error)
+}
+
+// parseLFSPointer parses a Git LFS pointer file.
+// Returns false if data is not a valid pointer.
+func parseLFSPointer(data []byte) (LFSPointer, bool) {
+	if len(data) > lfsPointerMaxSize || !bytes.HasPrefix(data, []byte(lfsPointerVersion+"\n")) {
+		return LFSPointer{}, false
+	}
+
+	var p LFSPointer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		switch key {
+		case "oid":
+			p.OID = strings.TrimPrefix(value, "sha256:")
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return LFSPointer{}, false
+			}
+			p.Size = size
+		}
+	}
+
+	if len(p.OID) != sha256.Size*2 {
+		return LFSPointer{}, false
+	}
+	return p, true
+}
+
+// hasLFSFilters reports whether any .gitattributes file below dir
+// routes paths through the LFS filter.
+func hasLFSFilters(dir string) (bool, error) {
+	found := false
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != ".gitattributes" {
+			return nil
+		}
+
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte("filter=lfs")) {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found, err
+}
+
+// resolveLFS replaces LFS pointer files below dir with their content.
+// Downloaded objects are verified against the pointer's OID and size.
+// Returns the list of resolved files.
+func resolveLFS(ctx context.Context, dir string, fetcher LFSFetcher) ([]string, error) {
+	found, err := hasLFSFilters(dir)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var resolved []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil || info.Size() > lfsPointerMaxSize {
+			return err
+		}
+
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		pointer, ok := parseLFSPointer(data)
+		if !ok {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(dir, path)
+		if err := downloadLFSObject(ctx, fetcher, pointer, path, info.Mode()); err != nil {
+			return fmt.Errorf("resolving LFS object %s: %w", relPath, err)
+		}
+		resolved = append(resolved, relPath)
+		return nil
+	})
+
+	return resolved, err
+}
+
+// downloadLFSObject streams the object content to a temporary file next
+// to path and replaces path with it once it is verified.
+func downloadLFSObject(ctx context.Context, fetcher LFSFetcher, p LFSPointer, path string, mode fs.FileMode) error {
+	rc, err := fetcher.Open(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rc.Close() }()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gohatch-lfs-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(rc, p.Size+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if n != p.Size {
+		return fmt.Errorf("size mismatch: got %d bytes, want %d", n, p.Size)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != p.OID {
+		return fmt.Errorf("checksum mismatch for %s", p.OID)
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// httpLFSFetcher downloads LFS objects using the Git LFS batch API.
+type httpLFSFetcher struct {
+	endpoint string
+	auth     *githttp.BasicAuth
+	client   *http.Client
+}
+
+// newHTTPLFSFetcher returns a fetcher for the LFS server of a repository URL.
+// Non-nil credentials are sent as basic auth with batch requests.
+func newHTTPLFSFetcher(repoURL string, auth *githttp.BasicAuth) (*httpLFSFetcher, error) {
+	endpoint, err := lfsEndpoint(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	return &httpLFSFetcher{
+		endpoint: endpoint,
+		auth:     auth,
+		client:   http.DefaultClient,
+	}, nil
+}
+
+// lfsEndpoint derives the LFS server URL of a repository as git-lfs does:
+// the repository's HTTPS URL followed by .git/info/lfs. SSH remotes, also
+// written scp-style as git@host:user/repo, map to HTTPS on the same host.
+func lfsEndpoint(repoURL string) (string, error) {
+	if host, path, ok := splitSCPURL(repoURL); ok {
+		repoURL = "ssh://" + host + "/" + path
+	}
+
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("deriving LFS endpoint of %s: %w", repoURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "ssh", "git+ssh", "ssh+git":
+		u = &url.URL{Scheme: "https", Host: u.Hostname(), Path: u.Path}
+	default:
+		return "", fmt.Errorf("LFS is not supported for %s remotes: %s", u.Scheme, repoURL)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(u.Path, ".git") {
+		u.Path += ".git"
+	}
+	u.Path += "/info/lfs"
+	return u.String(), nil
+}
+
+// splitSCPURL splits an scp-style Git URL such as git@host:user/repo into
+// its host and path.
+func splitSCPURL(repoURL string) (host, path string, ok bool) {
+	if strings.Contains(repoURL, "://") {
+		return "", "", false
+	}
+	host, path, ok = strings.Cut(repoURL, ":")
+	if !ok || host == "" || strings.Contains(host, "/") {
+		return "", "", false
+	}
+	if _, h, found := strings.Cut(host, "@"); found {
+		host = h
+	}
+	return host, strings.TrimPrefix(path, "/"), true
+}
+
+// lfsBatchResponse is the subset of the batch API response used here.
+type lfsBatchResponse struct {
+	Objects []struct {
+		OID     string `json:"oid"`
+		Actions struct {
+			Download *struct {
+				Href   string            `json:"href"`
+				Header map[string]string `json:"header"`
+			} `json:"download"`
+		} `json:"actions"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"objects"`
+}
+
+// Open requests a download action for p and returns the object body.
+func (f *httpLFSFetcher) Open(ctx context.Context, p LFSPointer) (io.ReadCloser, error) {
+	body, err := json.Marshal(map[string]any{
+		"operation": "download",
+		"transfers": []string{"basic"},
+		"objects":   []map[string]any{{"oid": p.OID, "size": p.Size}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.git-lfs+json")
+	req.Header.Set("Content-Type", "application/vnd.git-lfs+json")
+	if f.auth != nil {
+		req.SetBasicAuth(f.auth.Username, f.auth.Password)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("batch request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("batch request: %s", resp.Status)
+	}
+
+	var batch lfsBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, fmt.Errorf("decoding batch response: %w", err)
+	}
+	if len(batch.Objects) == 0 {
+		return nil, fmt.Errorf("batch response contains no objects")
+	}
+
+	obj := batch.Objects[0]
+	if obj.Error != nil {
+		return nil, fmt.Errorf("server error: %s", obj.Error.Message)
+	}
+	if obj.Actions.Download == nil {
+		return nil, fmt.Errorf("no download action for %s", p.OID)
+	}
+
+	dl, err := http.NewRequestWithContext(ctx, http.MethodGet, obj.Actions.Download.Href, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range obj.Actions.Download.Header {
+		dl.Header.Set(k, v)
+	}
+
+	dlResp, err := f.client.Do(dl)
+	if err != nil {
+		return nil, fmt.Errorf("downloading object: %w", err)
+	}
+	if dlResp.StatusCode != http.StatusOK {
+		_ = dlResp.Body.Close()
+		return nil, fmt.Errorf("downloading object: %s", dlResp.Status)
+	}
+
+	return dlResp.Body, nil
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// netrcEntry holds the credentials of a netrc machine. An empty Machine
+// marks the default entry.
+type netrcEntry struct {
+	Machine  string
+	Login    string
+	Password string
+}
+
+// NetrcPath returns the netrc file consulted for credentials: $NETRC if
+// set, otherwise ~/.netrc. Returns an empty string if neither is known.
+func NetrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// parseNetrc parses the machine, default, login, and password tokens of
+// a netrc file. Macro definitions are skipped up to the next empty line.
+func parseNetrc(data []byte) []netrcEntry {
+	var entries []netrcEntry
+	current := -1
+	inMacro := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if inMacro {
+			inMacro = len(fields) > 0
+			continue
+		}
+
+		for i := 0; i < len(fields); i++ {
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+
+			switch fields[i] {
+			case "machine":
+				entries = append(entries, netrcEntry{Machine: value})
+				current = len(entries) - 1
+				i++
+			case "default":
+				entries = append(entries, netrcEntry{})
+				current = len(entries) - 1
+			case "login":
+				if current >= 0 {
+					entries[current].Login = value
+				}
+				i++
+			case "password":
+				if current >= 0 {
+					entries[current].Password = value
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	return entries
+}
+
+// lookupNetrc returns the credentials for host: the first entry for
+// that machine, or else the default entry.
+func lookupNetrc(entries []netrcEntry, host string) (netrcEntry, bool) {
+	var def *netrcEntry
+	for i, e := range entries {
+		if e.Machine == host {
+			return e, true
+		}
+		if e.Machine == "" && def == nil {
+			def = &entries[i]
+		}
+	}
+	if def != nil {
+		return *def, true
+	}
+	return netrcEntry{}, false
+}
+
+// netrcAuth returns basic auth credentials for an HTTP(S) repository URL
+// from the netrc file at path. URLs carrying their own user info, SSH
+// URLs, and unreadable netrc files yield no credentials.
+func netrcAuth(path, repoURL string) *githttp.BasicAuth {
+	u, err := url.Parse(repoURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // path is the user's own netrc file
+	if err != nil {
+		return nil
+	}
+
+	e, ok := lookupNetrc(parseNetrc(data), u.Hostname())
+	if !ok || e.Password == "" {
+		return nil
+	}
+	return &githttp.BasicAuth{Username: e.Login, Password: e.Password}
+}
@@ -0,0 +1,373 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package source
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+	modzip "golang.org/x/mod/zip"
+)
+
+// ProxyPrefix marks a source that is fetched through the Go module proxy.
+const ProxyPrefix = "mod:"
+
+const (
+	defaultGoProxy = "https://proxy.golang.org,direct"
+	defaultSumDB   = "sum.golang.org"
+)
+
+// errNotFound is returned for proxy responses that allow falling back
+// to the next proxy in a comma-separated GOPROXY list.
+var errNotFound = errors.New("not found")
+
+// ProxySource represents a module downloaded as zip from a Go module proxy.
+type ProxySource struct {
+	Module  string
+	Version string
+
+	// Proxy is the GOPROXY list of proxy URLs.
+	Proxy string
+
+	// SumDB is the URL of the checksum database used to verify the
+	// downloaded zip. Empty disables verification.
+	SumDB string
+
+	// Client performs the HTTP requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// newProxySource configures a ProxySource from the Go environment:
+// GOPROXY, GOSUMDB, GONOSUMDB, GONOSUMCHECK, and GOPRIVATE. Unlike the go
+// command, GONOSUMCHECK=1 disables verification just like a module
+// matching GONOSUMDB or GOPRIVATE.
+func newProxySource(modPath, version string) *ProxySource {
+	return &ProxySource{
+		Module:  modPath,
+		Version: version,
+		Proxy:   getenvDefault("GOPROXY", defaultGoProxy),
+		SumDB:   sumDBURL(modPath),
+	}
+}
+
+// getenvDefault returns the environment variable key or def if unset.
+func getenvDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// sumDBURL returns the checksum database URL for modPath, or "" if
+// verification is disabled for it.
+func sumDBURL(modPath string) string {
+	if os.Getenv("GONOSUMCHECK") == "1" {
+		return ""
+	}
+
+	noSum := getenvDefault("GONOSUMDB", os.Getenv("GOPRIVATE"))
+	if noSum != "" && module.MatchPrefixPatterns(noSum, modPath) {
+		return ""
+	}
+
+	// GOSUMDB is "off", "name", "name+key", or "name+key url"
+	fields := strings.Fields(getenvDefault("GOSUMDB", defaultSumDB))
+	switch {
+	case len(fields) == 0 || fields[0] == "off":
+		return ""
+	case len(fields) > 1:
+		return strings.TrimSuffix(fields[1], "/")
+	default:
+		name, _, _ := strings.Cut(fields[0], "+")
+		return "https://" + name
+	}
+}
+
+// proxyURLs splits a GOPROXY list into its entries. Each entry reports
+// whether any error (|) or only not found responses (,) fall through.
+func proxyURLs(list string) (urls []string, fallThrough []bool) {
+	for list != "" {
+		idx := strings.IndexAny(list, ",|")
+		entry, sep := list, byte(0)
+		if idx != -1 {
+			entry, sep = list[:idx], list[idx]
+			list = list[idx+1:]
+		} else {
+			list = ""
+		}
+
+		entry = strings.TrimSuffix(strings.TrimSpace(entry), "/")
+		if entry == "" {
+			continue
+		}
+		urls = append(urls, entry)
+		fallThrough = append(fallThrough, sep == '|')
+	}
+	return urls, fallThrough
+}
+
+// zipURL returns the download URL of the module zip on proxy.
+func zipURL(proxy, modPath, version string) (string, error) {
+	escPath, err := module.EscapePath(modPath)
+	if err != nil {
+		return "", err
+	}
+	escVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return proxy + "/" + escPath + "/@v/" + escVersion + ".zip", nil
+}
+
+// latestURL returns the URL resolving the latest module version on proxy.
+func latestURL(proxy, modPath string) (string, error) {
+	escPath, err := module.EscapePath(modPath)
+	if err != nil {
+		return "", err
+	}
+	return proxy + "/" + escPath + "/@latest", nil
+}
+
+// Fetch downloads the module zip and extracts it to the destination.
+// An empty version or "latest" resolves the latest version first.
+func (s *ProxySource) Fetch(ctx context.Context, dest string) error {
+	if err := module.CheckPath(s.Module); err != nil {
+		return fmt.Errorf("invalid module path: %w", err)
+	}
+
+	if s.Version == "" || s.Version == "latest" {
+		version, err := s.resolveLatest(ctx)
+		if err != nil {
+			return fmt.Errorf("resolving latest version of %s: %w", s.Module, err)
+		}
+		s.Version = version
+	}
+
+	data, err := s.fromProxies(ctx, func(proxy string) (string, error) {
+		return zipURL(proxy, s.Module, s.Version)
+	})
+	if err != nil {
+		return fmt.Errorf("downloading %s@%s: %w", s.Module, s.Version, err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("reading module zip: %w", err)
+	}
+
+	if s.SumDB != "" {
+		if err := s.verify(ctx, zr); err != nil {
+			return err
+		}
+	}
+
+	return extractModuleZip(zr, s.Module+"@"+s.Version+"/", dest)
+}
+
+// resolveLatest queries the proxy for the latest module version.
+func (s *ProxySource) resolveLatest(ctx context.Context) (string, error) {
+	data, err := s.fromProxies(ctx, func(proxy string) (string, error) {
+		return latestURL(proxy, s.Module)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var info struct{ Version string }
+	if err := json.Unmarshal(data, &info); err != nil {
+		return "", err
+	}
+	if info.Version == "" {
+		return "", fmt.Errorf("proxy returned no version")
+	}
+	return info.Version, nil
+}
+
+// fromProxies requests the URL built by urlFor from each proxy of the
+// GOPROXY list in turn, following the list's fallback rules.
+func (s *ProxySource) fromProxies(ctx context.Context, urlFor func(proxy string) (string, error)) ([]byte, error) {
+	urls, fallThrough := proxyURLs(s.Proxy)
+
+	lastErr := errors.New("no usable proxy in GOPROXY")
+	for i, proxy := range urls {
+		switch proxy {
+		case "off":
+			return nil, errors.New("module lookup disabled by GOPROXY=off")
+		case "direct":
+			lastErr = errors.New("GOPROXY=direct is not supported, use a git source instead")
+			continue
+		}
+
+		u, err := urlFor(proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := s.get(ctx, u)
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+		if !fallThrough[i] && !errors.Is(err, errNotFound) {
+			return nil, err
+		}
+	}
+
+	return nil, lastErr
+}
+
+// maxZipSize limits both the download and the extracted size of a
+// module zip. It is a variable so tests can lower it.
+var maxZipSize int64 = modzip.MaxZipFile
+
+// get performs a GET request and returns the response body, which may
+// not exceed the size limit of module zips.
+func (s *ProxySource) get(ctx context.Context, url string) ([]byte, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, fmt.Errorf("%s: %w", url, errNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxZipSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxZipSize {
+		return nil, fmt.Errorf("%s: response exceeds %d bytes", url, maxZipSize)
+	}
+	return data, nil
+}
+
+// verify compares the hash of the module zip with the checksum database.
+// Only the lookup record is checked; neither the signature of the
+// database nor the transparency log proof is verified.
+func (s *ProxySource) verify(ctx context.Context, zr *zip.Reader) error {
+	files := make([]string, 0, len(zr.File))
+	entries := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files = append(files, f.Name)
+		entries[f.Name] = f
+	}
+	sum, err := dirhash.Hash1(files, func(name string) (io.ReadCloser, error) {
+		return entries[name].Open()
+	})
+	if err != nil {
+		return fmt.Errorf("hashing module zip: %w", err)
+	}
+
+	escPath, err := module.EscapePath(s.Module)
+	if err != nil {
+		return err
+	}
+	escVersion, err := module.EscapeVersion(s.Version)
+	if err != nil {
+		return err
+	}
+	data, err := s.get(ctx, s.SumDB+"/lookup/"+escPath+"@"+escVersion)
+	if err != nil {
+		return fmt.Errorf("looking up checksum: %w", err)
+	}
+
+	want := s.Module + " " + s.Version + " "
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if expected, ok := strings.CutPrefix(scanner.Text(), want); ok {
+			if expected != sum {
+				return fmt.Errorf("checksum mismatch for %s@%s: downloaded %s, checksum database has %s",
+					s.Module, s.Version, sum, expected)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("checksum database has no entry for %s@%s", s.Module, s.Version)
+}
+
+// extractModuleZip writes the files below prefix in zr to dest. Like the
+// go command, it rejects zips whose files add up to more than
+// maxZipSize bytes once extracted.
+func extractModuleZip(zr *zip.Reader, prefix, dest string) error {
+	remaining := maxZipSize
+	for _, f := range zr.File {
+		name, ok := strings.CutPrefix(f.Name, prefix)
+		if !ok || name == "" {
+			return fmt.Errorf("unexpected file %s in module zip", f.Name)
+		}
+		if strings.HasSuffix(name, "/") {
+			continue // directory entry
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid file %s in module zip", f.Name)
+		}
+
+		n, err := extractZipFile(f, filepath.Join(dest, filepath.FromSlash(name)), remaining)
+		if err != nil {
+			return fmt.Errorf("extracting %s: %w", name, err)
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// extractZipFile writes a single zip entry to path and returns its size.
+// Entries larger than limit bytes are an error.
+func extractZipFile(f *zip.File, path string, limit int64) (int64, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return 0, fmt.Errorf("module zip exceeds %d bytes when extracted", maxZipSize)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rc.Close() }()
+
+	out, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	// The size in the zip header is not trusted.
+	n, err := io.Copy(out, io.LimitReader(rc, limit+1))
+	if err == nil && n > limit {
+		err = fmt.Errorf("module zip exceeds %d bytes when extracted", maxZipSize)
+	}
+	if err != nil {
+		_ = out.Close()
+		return 0, err
+	}
+	return n, out.Close()
+}
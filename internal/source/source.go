@@ -5,8 +5,12 @@ package source
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +18,9 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/storage/memory"
 )
 
@@ -33,35 +40,78 @@ type Source interface {
 // LocalSource represents a local directory.
 type LocalSource struct {
 	Path string
+
+	// SkipUnreadable skips files and directories that cannot be read
+	// due to missing permissions instead of aborting the copy.
+	SkipUnreadable bool
+
+	// RespectGitignore skips files ignored by the template's .gitignore
+	// files or the user's global excludes file.
+	RespectGitignore bool
+
+	// NoEscape rejects symlinks that point outside the template
+	// directory instead of copying them.
+	NoEscape bool
+
+	// MaxFiles aborts Fetch with ErrTooManyFiles before anything is
+	// copied if the template has more files than this. 0 disables the
+	// limit.
+	MaxFiles int
+
+	// Skipped lists the template-relative paths skipped during Fetch.
+	Skipped []string
 }
 
-// Fetch copies the local directory to the destination.
+// Fetch copies the local directory to the destination. Symlinks are
+// copied as links, never followed.
 func (s *LocalSource) Fetch(_ context.Context, dest string) error {
 	src := filepath.Clean(s.Path)
 
-	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+	var ignore gitignore.Matcher
+	if s.RespectGitignore {
+		m, err := loadIgnoreMatcher(src)
 		if err != nil {
 			return err
 		}
+		ignore = m
+	}
 
-		// Skip .git directory
-		if d.IsDir() && d.Name() == ".git" {
-			return filepath.SkipDir
+	if s.MaxFiles > 0 {
+		if err := s.checkFileCount(src, ignore); err != nil {
+			return err
+		}
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		relPath, relErr := filepath.Rel(src, path)
+		if relErr != nil {
+			return relErr
 		}
 
-		relPath, err := filepath.Rel(src, path)
 		if err != nil {
-			return err
+			return s.handleUnreadable(relPath, d, err)
 		}
+
+		if skipEntry(relPath, d, ignore) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		destPath := filepath.Join(dest, relPath)
 
 		if d.IsDir() {
 			return os.MkdirAll(destPath, 0o750)
 		}
 
+		if d.Type()&fs.ModeSymlink != 0 && relPath != "." {
+			return s.copySymlink(src, path, relPath, destPath, d)
+		}
+
 		data, err := os.ReadFile(filepath.Clean(path))
 		if err != nil {
-			return err
+			return s.handleUnreadable(relPath, d, err)
 		}
 
 		info, err := d.Info()
@@ -73,6 +123,115 @@ func (s *LocalSource) Fetch(_ context.Context, dest string) error {
 	})
 }
 
+// DefaultMaxFiles is the file limit gohatch applies to local templates
+// unless told otherwise, large enough for any real template but small
+// enough to stop a mistaken copy of a huge directory early.
+const DefaultMaxFiles = 10000
+
+// ErrTooManyFiles reports a local template with more files than
+// LocalSource.MaxFiles.
+var ErrTooManyFiles = errors.New("template has too many files")
+
+// skipEntry reports whether Fetch leaves out the entry at relPath: the
+// .git directory and, if ignore is set, ignored paths.
+func skipEntry(relPath string, d fs.DirEntry, ignore gitignore.Matcher) bool {
+	if d.IsDir() && d.Name() == ".git" {
+		return true
+	}
+	return ignore != nil && relPath != "." && ignore.Match(strings.Split(filepath.ToSlash(relPath), "/"), d.IsDir())
+}
+
+// checkFileCount counts the files Fetch would copy from src and stops
+// with ErrTooManyFiles as soon as there are more than MaxFiles.
+// Unreadable entries are left to the copy to report.
+func (s *LocalSource) checkFileCount(src string, ignore gitignore.Matcher) error {
+	n := 0
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && path != src {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if skipEntry(relPath, d, ignore) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.IsDir() {
+			if n++; n > s.MaxFiles {
+				return fmt.Errorf("%w: more than %d in %s", ErrTooManyFiles, s.MaxFiles, src)
+			}
+		}
+		return nil
+	})
+}
+
+// copySymlink recreates the symlink at path verbatim instead of following
+// it, so links to an ancestor directory cannot make the copy loop. With
+// NoEscape, links whose target lies outside root are an error.
+func (s *LocalSource) copySymlink(root, path, relPath, destPath string, d fs.DirEntry) error {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return s.handleUnreadable(relPath, d, err)
+	}
+
+	if s.NoEscape {
+		escapes, err := escapesRoot(root, path, target)
+		if err != nil {
+			return err
+		}
+		if escapes {
+			return fmt.Errorf("template symlink %s points outside the template: %s", relPath, target)
+		}
+	}
+
+	return os.Symlink(target, destPath)
+}
+
+// escapesRoot reports whether the symlink at path with the given target
+// points outside root. The target is resolved lexically; links chained
+// through other symlinks are caught when those are copied.
+func escapesRoot(root, path, target string) (bool, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return false, err
+	}
+	if !filepath.IsAbs(target) {
+		if path, err = filepath.Abs(path); err != nil {
+			return false, err
+		}
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+
+	rel, err := filepath.Rel(root, filepath.Clean(target))
+	if err != nil {
+		return true, nil
+	}
+	return !filepath.IsLocal(rel), nil
+}
+
+// handleUnreadable records permission errors as skipped when
+// SkipUnreadable is set and otherwise returns an error naming the path.
+func (s *LocalSource) handleUnreadable(relPath string, d fs.DirEntry, err error) error {
+	if !s.SkipUnreadable || !errors.Is(err, fs.ErrPermission) || relPath == "." {
+		return fmt.Errorf("reading template file %s: %w", relPath, err)
+	}
+
+	s.Skipped = append(s.Skipped, relPath)
+	if d != nil && d.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
 // =============================================================================
 // GitSource
 // =============================================================================
@@ -81,6 +240,97 @@ func (s *LocalSource) Fetch(_ context.Context, dest string) error {
 type GitSource struct {
 	URL     string
 	Version string
+
+	// Ref is a full reference name such as refs/pull/42/head. It is
+	// cloned verbatim and takes the place of Version.
+	Ref string
+
+	// Commit is the resolved commit hash, set by Fetch.
+	Commit string
+
+	// ExpectCommit is the full or abbreviated hash the resolved commit
+	// must match. On a mismatch Fetch removes dest and fails.
+	ExpectCommit string
+
+	// LFS resolves Git LFS pointer files after cloning.
+	LFS bool
+
+	// LFSFetcher downloads LFS objects. Defaults to the batch API
+	// of the repository's LFS server.
+	LFSFetcher LFSFetcher
+
+	// Tokens maps Git hosts to access tokens authenticating HTTPS
+	// requests. A token is only sent to its own host, so it never
+	// reaches arbitrary URLs or repositories named by a vanity import.
+	Tokens map[string]string
+
+	// Netrc is the path of a netrc file whose entry for the Git host
+	// authenticates HTTPS requests if Token is empty. Empty disables
+	// netrc lookup.
+	Netrc string
+
+	// Depth limits the history fetched for default branch, branch, tag,
+	// and Ref clones; 0 fetches the full history. Commit clones are
+	// always full.
+	Depth int
+
+	// ImportPath is a vanity import path such as go.example.com/app.
+	// If set, Fetch clones the repository named by its go-import meta
+	// tag instead of URL.
+	ImportPath string
+
+	// Client performs the go-import lookup. Defaults to a client with
+	// a 10 second timeout.
+	Client *http.Client
+
+	// Logger receives debug messages, such as why a go-import lookup
+	// fell back to URL. Nil discards them.
+	Logger *slog.Logger
+}
+
+// CheckCommitHash validates a full or abbreviated commit hash of at
+// least 7 hex digits.
+func CheckCommitHash(hash string) error {
+	if len(hash) < 7 || len(hash) > 64 || strings.Trim(strings.ToLower(hash), "0123456789abcdef") != "" {
+		return fmt.Errorf("invalid commit hash %q: expected 7 to 64 hex digits", hash)
+	}
+	return nil
+}
+
+// DefaultDepth is the clone depth used by Parse.
+const DefaultDepth = 1
+
+// cloneRepo clones a repository; replaced in tests to inspect options.
+var cloneRepo = git.PlainCloneContext
+
+// credentials returns the basic auth credentials for the repository:
+// the token configured for its host, or else the netrc entry for it.
+func (s *GitSource) credentials() *githttp.BasicAuth {
+	if token := s.token(); token != "" {
+		// Git hosts accept access tokens as the password of any non-empty user
+		return &githttp.BasicAuth{Username: "gohatch", Password: token}
+	}
+	if s.Netrc != "" {
+		return netrcAuth(s.Netrc, s.URL)
+	}
+	return nil
+}
+
+// token returns the access token for the host of an HTTPS repository URL.
+func (s *GitSource) token() string {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil {
+		return ""
+	}
+	return s.Tokens[strings.ToLower(u.Hostname())]
+}
+
+// auth returns the transport authentication for the repository.
+func (s *GitSource) auth() transport.AuthMethod {
+	if c := s.credentials(); c != nil {
+		return c
+	}
+	return nil
 }
 
 // refType represents the type of a git reference.
@@ -93,13 +343,13 @@ const (
 )
 
 // resolveRefType queries the remote to determine if version is a tag or branch.
-func resolveRefType(url, version string) refType {
+func resolveRefType(url, version string, auth transport.AuthMethod) refType {
 	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
 		Name: "origin",
 		URLs: []string{url},
 	})
 
-	refs, err := remote.List(&git.ListOptions{})
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
 	if err != nil {
 		return refTypeUnknown
 	}
@@ -120,37 +370,77 @@ func resolveRefType(url, version string) refType {
 }
 
 // Fetch clones the Git repository to the destination directory.
+// With LFS enabled, pointer files are replaced by their content.
 func (s *GitSource) Fetch(ctx context.Context, dest string) error {
+	if s.ImportPath != "" {
+		if err := s.resolveImport(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Reject remotes without an LFS endpoint before cloning
+	fetcher := s.LFSFetcher
+	if s.LFS && fetcher == nil {
+		f, err := newHTTPLFSFetcher(s.URL, s.credentials())
+		if err != nil {
+			return err
+		}
+		fetcher = f
+	}
+
+	if err := s.clone(ctx, dest); err != nil {
+		return err
+	}
+
+	if !s.LFS {
+		return nil
+	}
+
+	if _, err := resolveLFS(ctx, dest, fetcher); err != nil {
+		return fmt.Errorf("resolving LFS files: %w", err)
+	}
+
+	return nil
+}
+
+// clone checks out the requested version into dest without .git.
+func (s *GitSource) clone(ctx context.Context, dest string) error {
 	cloneOpts := &git.CloneOptions{
 		URL:      s.URL,
+		Auth:     s.auth(),
 		Progress: nil,
 	}
 
-	// No version specified: shallow clone of default branch
-	if s.Version == "" {
-		cloneOpts.Depth = 1
-		_, err := git.PlainCloneContext(ctx, dest, false, cloneOpts)
+	// Full reference name: fetch it as given
+	if s.Ref != "" {
+		return s.fetchRef(ctx, dest)
+	}
+
+	// No version specified: clone of default branch
+	if s.Version == "" || s.Version == HeadVersion {
+		cloneOpts.Depth = s.Depth
+		repo, err := cloneRepo(ctx, dest, false, cloneOpts)
 		if err != nil {
 			return fmt.Errorf("cloning repository: %w", err)
 		}
-		return os.RemoveAll(filepath.Join(dest, ".git"))
+		return s.finishClone(repo, dest)
 	}
 
 	// Query remote to determine reference type
-	switch resolveRefType(s.URL, s.Version) {
+	switch resolveRefType(s.URL, s.Version, s.auth()) {
 	case refTypeTag:
-		cloneOpts.Depth = 1
+		cloneOpts.Depth = s.Depth
 		cloneOpts.SingleBranch = true
 		cloneOpts.ReferenceName = plumbing.NewTagReferenceName(s.Version)
 
 	case refTypeBranch:
-		cloneOpts.Depth = 1
+		cloneOpts.Depth = s.Depth
 		cloneOpts.SingleBranch = true
 		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(s.Version)
 
 	case refTypeUnknown:
 		// Unknown ref type: assume commit hash, need full clone
-		repo, err := git.PlainCloneContext(ctx, dest, false, cloneOpts)
+		repo, err := cloneRepo(ctx, dest, false, cloneOpts)
 		if err != nil {
 			return fmt.Errorf("cloning repository: %w", err)
 		}
@@ -167,14 +457,82 @@ func (s *GitSource) Fetch(ctx context.Context, dest string) error {
 			return fmt.Errorf("checking out %s: %w", s.Version, err)
 		}
 
-		return os.RemoveAll(filepath.Join(dest, ".git"))
+		return s.finishClone(repo, dest)
 	}
 
-	_, err := git.PlainCloneContext(ctx, dest, false, cloneOpts)
+	repo, err := cloneRepo(ctx, dest, false, cloneOpts)
 	if err != nil {
 		return fmt.Errorf("cloning repository: %w", err)
 	}
 
+	return s.finishClone(repo, dest)
+}
+
+// fetchRef checks out the full reference name Ref into dest. A clone
+// would map it to a branch, so refs/pull/42/head became
+// refs/heads/pull/42/head; the ref is fetched with a refspec naming it
+// verbatim instead.
+func (s *GitSource) fetchRef(ctx context.Context, dest string) error {
+	ref := plumbing.ReferenceName(s.Ref)
+	if !strings.HasPrefix(s.Ref, "refs/") || ref.Validate() != nil {
+		return fmt.Errorf("invalid ref %q: expected a full reference name such as refs/pull/42/head", s.Ref)
+	}
+
+	repo, err := git.PlainInit(dest, false)
+	if err != nil {
+		return fmt.Errorf("cloning %s: %w", s.Ref, err)
+	}
+	remote, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{s.URL}})
+	if err != nil {
+		return fmt.Errorf("cloning %s: %w", s.Ref, err)
+	}
+	err = remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec("+" + s.Ref + ":" + s.Ref)},
+		Depth:    s.Depth,
+		Auth:     s.auth(),
+	})
+	if err != nil {
+		return fmt.Errorf("cloning %s: %w", s.Ref, err)
+	}
+
+	fetched, err := repo.Reference(ref, true)
+	if err != nil {
+		return fmt.Errorf("cloning %s: %w", s.Ref, err)
+	}
+	hash := fetched.Hash()
+	if tag, err := repo.TagObject(hash); err == nil {
+		// Annotated tags point to a tag object rather than the commit
+		commit, err := tag.Commit()
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", s.Ref, err)
+		}
+		hash = commit.Hash
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("getting worktree: %w", err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: hash}); err != nil {
+		return fmt.Errorf("checking out %s: %w", s.Ref, err)
+	}
+
+	return s.finishClone(repo, dest)
+}
+
+// finishClone records the checked out commit, verifies it against
+// ExpectCommit, and removes the .git directory.
+func (s *GitSource) finishClone(repo *git.Repository, dest string) error {
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("resolving HEAD: %w", err)
+	}
+	s.Commit = head.Hash().String()
+
+	if s.ExpectCommit != "" && !strings.HasPrefix(s.Commit, strings.ToLower(s.ExpectCommit)) {
+		_ = os.RemoveAll(dest)
+		return fmt.Errorf("commit mismatch: expected %s, got %s", s.ExpectCommit, s.Commit)
+	}
+
 	return os.RemoveAll(filepath.Join(dest, ".git"))
 }
 
@@ -182,8 +540,26 @@ func (s *GitSource) Fetch(ctx context.Context, dest string) error {
 // Parse
 // =============================================================================
 
+// DefaultHost is the Git host used for user/repo shorthands.
+const DefaultHost = "github.com"
+
 // Parse analyzes the input string and returns the appropriate Source.
 func Parse(input string) (Source, error) {
+	return ParseWithHost(input, DefaultHost)
+}
+
+// ParseWithHost is like Parse but resolves user/repo shorthands
+// against host instead of DefaultHost.
+func ParseWithHost(input, host string) (Source, error) {
+	// Go module proxy: mod:module[@version]
+	if modPath, ok := strings.CutPrefix(input, ProxyPrefix); ok {
+		modPath, version := splitVersion(modPath)
+		if modPath == "" {
+			return nil, fmt.Errorf("missing module path after %s", ProxyPrefix)
+		}
+		return newProxySource(modPath, version), nil
+	}
+
 	path, version := splitVersion(input)
 
 	// Local path: starts with ./, /, or exists as directory
@@ -203,23 +579,106 @@ func Parse(input string) (Source, error) {
 	}
 
 	// Git URL handling
-	url := buildGitURL(path)
-	return &GitSource{URL: url, Version: version}, nil
+	if version == HeadVersion {
+		version = ""
+	}
+	url := buildGitURL(path, host)
+	return &GitSource{URL: url, Version: version, Depth: DefaultDepth, ImportPath: vanityImportPath(path, host)}, nil
+}
+
+// HeadVersion selects the remote's default branch, like giving no
+// version at all: user/repo@HEAD.
+const HeadVersion = "HEAD"
+
+// NamedPrefix marks a template name resolved against a templates
+// directory instead of a git host, e.g. @mytemplate.
+const NamedPrefix = "@"
+
+// DefaultTemplateDir returns the templates directory for named
+// templates, ~/.gohatch/templates.
+func DefaultTemplateDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".gohatch", "templates"), nil
+}
+
+// ParseNamed resolves a named template to the directory of that name
+// inside root. The name may contain subdirectories but must stay
+// within root.
+func ParseNamed(name, root string) (*LocalSource, error) {
+	path, err := NamedPath(name, root)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("template %q not found in %s", name, root)
+		}
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("template %q in %s is not a directory", name, root)
+	}
+
+	return &LocalSource{Path: path}, nil
+}
+
+// NamedPath returns the directory a named template resolves to inside
+// root, without checking that it exists.
+func NamedPath(name, root string) (string, error) {
+	if name == "" || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid template name %q", NamedPrefix+name)
+	}
+	return filepath.Join(root, filepath.FromSlash(name)), nil
 }
 
 // splitVersion splits "path@version" into path and version components.
+// The user of SSH URLs such as git@host:user/repo is not a version.
 func splitVersion(input string) (path, version string) {
-	if idx := strings.LastIndex(input, "@"); idx != -1 {
+	start := 0
+	if i := strings.Index(input, "://"); i != -1 {
+		start = i + len("://")
+	}
+
+	// Skip a user@ prefix that is followed by a host
+	rest := input[start:]
+	if at := strings.Index(rest, "@"); at != -1 &&
+		!strings.ContainsAny(rest[:at], "/:") && strings.ContainsAny(rest[at+1:], "/:") {
+		start += at + 1
+	}
+
+	if idx := strings.LastIndex(input[start:], "@"); idx != -1 {
+		idx += start
 		return input[:idx], input[idx+1:]
 	}
 	return input, ""
 }
 
+// isFullGitURL reports whether path is already a Git URL, either with a
+// scheme (ssh://, https://) or in scp-like form (git@host:user/repo).
+func isFullGitURL(path string) bool {
+	if strings.Contains(path, "://") {
+		return true
+	}
+	colon := strings.Index(path, ":")
+	return colon > 0 && !strings.Contains(path[:colon], "/")
+}
+
 // buildGitURL converts a path to a full HTTPS Git URL.
-func buildGitURL(path string) string {
+// Paths without a domain are resolved against host; full Git URLs,
+// including SSH ones, are returned unchanged.
+func buildGitURL(path, host string) string {
+	if isFullGitURL(path) {
+		return path
+	}
+
 	parts := strings.SplitN(path, "/", 2)
 	if len(parts) < 2 {
-		return "https://github.com/" + path
+		return "https://" + host + "/" + path
 	}
 
 	// Check if first part contains a dot (domain)
@@ -227,6 +686,6 @@ func buildGitURL(path string) string {
 		return "https://" + path
 	}
 
-	// Shorthand: user/repo -> github.com/user/repo
-	return "https://github.com/" + path
+	// Shorthand: user/repo -> host/user/repo
+	return "https://" + host + "/" + path
 }
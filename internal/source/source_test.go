@@ -4,17 +4,32 @@
 package source
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/sumdb/dirhash"
 )
 
 // =============================================================================
@@ -248,6 +263,12 @@ func TestSplitVersion(t *testing.T) {
 		{"github.com/user/repo", "github.com/user/repo", ""},
 		{"user/repo@latest", "user/repo", "latest"},
 		{"user/repo@abc1234", "user/repo", "abc1234"},
+		{"git@github.com:user/repo", "git@github.com:user/repo", ""},
+		{"git@github.com:user/repo@v1.0.0", "git@github.com:user/repo", "v1.0.0"},
+		{"ssh://git@github.com/user/repo@main", "ssh://git@github.com/user/repo", "main"},
+		{"ssh://git@git.example.com:2222/user/repo", "ssh://git@git.example.com:2222/user/repo", ""},
+		{"ssh://git@git.example.com:2222/user/repo@v2.0.0", "ssh://git@git.example.com:2222/user/repo", "v2.0.0"},
+		{"https://git.example.com:8443/user/repo@v1.0.0", "https://git.example.com:8443/user/repo", "v1.0.0"},
 	}
 
 	for _, tt := range tests {
@@ -270,11 +291,14 @@ func TestBuildGitURL(t *testing.T) {
 		{"gitlab.com/user/repo", "https://gitlab.com/user/repo"},
 		{"user/repo/subdir", "https://github.com/user/repo/subdir"},
 		{"singlepart", "https://github.com/singlepart"},
+		{"git@github.com:user/repo", "git@github.com:user/repo"},
+		{"ssh://git@git.example.com:2222/user/repo", "ssh://git@git.example.com:2222/user/repo"},
+		{"https://git.example.com/user/repo", "https://git.example.com/user/repo"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
-			got := buildGitURL(tt.input)
+			got := buildGitURL(tt.input, DefaultHost)
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -303,6 +327,18 @@ func TestParse(t *testing.T) {
 			wantURL:     "https://github.com/user/repo",
 			wantVersion: "v1.0.0",
 		},
+		{
+			name:     "shorthand with HEAD",
+			input:    "user/repo@HEAD",
+			wantType: "git",
+			wantURL:  "https://github.com/user/repo",
+		},
+		{
+			name:     "shorthand with empty version",
+			input:    "user/repo@",
+			wantType: "git",
+			wantURL:  "https://github.com/user/repo",
+		},
 		{
 			name:     "full github URL",
 			input:    "github.com/user/repo",
@@ -316,6 +352,13 @@ func TestParse(t *testing.T) {
 			wantURL:     "https://github.com/user/repo",
 			wantVersion: "v2.0.0",
 		},
+		{
+			name:        "scp-like SSH URL with version",
+			input:       "git@github.com:user/repo@v1.0.0",
+			wantType:    "git",
+			wantURL:     "git@github.com:user/repo",
+			wantVersion: "v1.0.0",
+		},
 		{
 			name:     "codeberg URL",
 			input:    "codeberg.org/user/repo",
@@ -452,6 +495,92 @@ func TestLocalSourceFetchPreservesPermissions(t *testing.T) {
 	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
 }
 
+func TestLocalSourceFetchSelfReferentialSymlinks(t *testing.T) {
+	srcDir := t.TempDir()
+	destDir := filepath.Join(t.TempDir(), "dest")
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "README.md"), []byte("# Template\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(srcDir, "sub"), 0o755))
+	links := map[string]string{
+		"sub/up":    "..",
+		"sub/root":  srcDir,
+		"self":      "self",
+		"readme.md": "README.md",
+	}
+	for name, target := range links {
+		require.NoError(t, os.Symlink(target, filepath.Join(srcDir, filepath.FromSlash(name))))
+	}
+
+	ls := &LocalSource{Path: srcDir, NoEscape: true}
+	require.NoError(t, ls.Fetch(context.Background(), destDir))
+
+	for name, target := range links {
+		got, err := os.Readlink(filepath.Join(destDir, filepath.FromSlash(name)))
+		require.NoError(t, err, name)
+		assert.Equal(t, target, got, "%s is copied verbatim", name)
+	}
+	assert.FileExists(t, filepath.Join(destDir, "README.md"))
+}
+
+func TestLocalSourceFetchEscapingSymlink(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("secret\n"), 0o644))
+
+	for _, target := range []string{outside, "../secret.txt", "sub/../../x"} {
+		t.Run(target, func(t *testing.T) {
+			srcDir := t.TempDir()
+			require.NoError(t, os.Symlink(target, filepath.Join(srcDir, "link")))
+
+			destDir := filepath.Join(t.TempDir(), "dest")
+			ls := &LocalSource{Path: srcDir}
+			require.NoError(t, ls.Fetch(context.Background(), destDir))
+			got, err := os.Readlink(filepath.Join(destDir, "link"))
+			require.NoError(t, err)
+			assert.Equal(t, target, got)
+
+			destDir = filepath.Join(t.TempDir(), "dest")
+			ls = &LocalSource{Path: srcDir, NoEscape: true}
+			err = ls.Fetch(context.Background(), destDir)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "template symlink link points outside the template")
+		})
+	}
+}
+
+func TestLocalSourceFetchMaxFiles(t *testing.T) {
+	srcDir := t.TempDir()
+	for _, f := range []string{"a.go", "b.go", "sub/c.go", ".git/HEAD", "ignored.log"} {
+		path := filepath.Join(srcDir, filepath.FromSlash(f))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(f), 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, ".gitignore"), []byte("*.log\n"), 0o644))
+
+	t.Run("over the limit", func(t *testing.T) {
+		destDir := filepath.Join(t.TempDir(), "dest")
+		ls := &LocalSource{Path: srcDir, MaxFiles: 4}
+		err := ls.Fetch(context.Background(), destDir)
+		require.ErrorIs(t, err, ErrTooManyFiles)
+		assert.Contains(t, err.Error(), "more than 4")
+		assert.NoDirExists(t, destDir, "nothing is written")
+	})
+
+	t.Run("at the limit", func(t *testing.T) {
+		// .git is never copied, so it does not count
+		destDir := filepath.Join(t.TempDir(), "dest")
+		ls := &LocalSource{Path: srcDir, MaxFiles: 5}
+		require.NoError(t, ls.Fetch(context.Background(), destDir))
+		assert.FileExists(t, filepath.Join(destDir, "sub", "c.go"))
+	})
+
+	t.Run("ignored files do not count", func(t *testing.T) {
+		destDir := filepath.Join(t.TempDir(), "dest")
+		ls := &LocalSource{Path: srcDir, MaxFiles: 4, RespectGitignore: true}
+		require.NoError(t, ls.Fetch(context.Background(), destDir))
+		assert.NoFileExists(t, filepath.Join(destDir, "ignored.log"))
+	})
+}
+
 func TestLocalSourceFetchWithDotFiles(t *testing.T) {
 	srcDir := t.TempDir()
 	destDir := filepath.Join(t.TempDir(), "dest")
@@ -473,6 +602,121 @@ func TestLocalSourceFetchWithDotFiles(t *testing.T) {
 	}
 }
 
+// setupIgnoreTemplate creates a template with a .gitignore and a fake
+// home directory whose global excludes file ignores *.swp.
+func setupIgnoreTemplate(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	excludes := filepath.Join(home, "global-ignore")
+	require.NoError(t, os.WriteFile(excludes, []byte("# editor files\n*.swp\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"),
+		[]byte("[core]\n\texcludesFile = ~/global-ignore\n"), 0o644))
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, ".gitignore"), []byte("build/\n*.log\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "main.go"), []byte("package main"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "debug.log"), []byte("log"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "main.go.swp"), []byte("swap"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "build"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "build", "app"), []byte("bin"), 0o644))
+
+	return srcDir
+}
+
+func TestLocalSourceFetchRespectGitignore(t *testing.T) {
+	srcDir := setupIgnoreTemplate(t)
+	destDir := filepath.Join(t.TempDir(), "dest")
+
+	ls := &LocalSource{Path: srcDir, RespectGitignore: true}
+	require.NoError(t, ls.Fetch(context.Background(), destDir))
+
+	assert.FileExists(t, filepath.Join(destDir, "main.go"))
+	assert.FileExists(t, filepath.Join(destDir, ".gitignore"))
+	assert.NoFileExists(t, filepath.Join(destDir, "debug.log"))
+	assert.NoDirExists(t, filepath.Join(destDir, "build"))
+
+	// Excluded by the global excludes file
+	assert.NoFileExists(t, filepath.Join(destDir, "main.go.swp"))
+}
+
+func TestLocalSourceFetchIgnoresGitignoreByDefault(t *testing.T) {
+	srcDir := setupIgnoreTemplate(t)
+	destDir := filepath.Join(t.TempDir(), "dest")
+
+	ls := &LocalSource{Path: srcDir}
+	require.NoError(t, ls.Fetch(context.Background(), destDir))
+
+	assert.FileExists(t, filepath.Join(destDir, "debug.log"))
+	assert.FileExists(t, filepath.Join(destDir, "main.go.swp"))
+}
+
+func TestGlobalExcludesFileXDGDefault(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+
+	path, err := globalExcludesFile()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(xdg, "git", "ignore"), path)
+}
+
+// setupUnreadableTemplate creates a template containing a file without
+// read permission. Skips the test where permissions cannot be enforced.
+func setupUnreadableTemplate(t *testing.T) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not enforced on Windows")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("root can read files regardless of permissions")
+	}
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "readable.txt"), []byte("ok"), 0o644))
+
+	secret := filepath.Join(srcDir, "secret.txt")
+	require.NoError(t, os.WriteFile(secret, []byte("secret"), 0o644))
+	require.NoError(t, os.Chmod(secret, 0o000))
+	t.Cleanup(func() { _ = os.Chmod(secret, 0o644) })
+
+	return srcDir
+}
+
+func TestLocalSourceFetchUnreadableFails(t *testing.T) {
+	srcDir := setupUnreadableTemplate(t)
+	destDir := filepath.Join(t.TempDir(), "dest")
+
+	ls := &LocalSource{Path: srcDir}
+	err := ls.Fetch(context.Background(), destDir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "secret.txt")
+	assert.ErrorIs(t, err, os.ErrPermission)
+}
+
+func TestLocalSourceFetchSkipUnreadable(t *testing.T) {
+	srcDir := setupUnreadableTemplate(t)
+	destDir := filepath.Join(t.TempDir(), "dest")
+
+	ls := &LocalSource{Path: srcDir, SkipUnreadable: true}
+	err := ls.Fetch(context.Background(), destDir)
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(destDir, "readable.txt"))
+	assert.NoFileExists(t, filepath.Join(destDir, "secret.txt"))
+	assert.Equal(t, []string{"secret.txt"}, ls.Skipped)
+}
+
+func TestLocalSourceFetchMissingPathNamesPath(t *testing.T) {
+	ls := &LocalSource{Path: filepath.Join(t.TempDir(), "missing"), SkipUnreadable: true}
+	err := ls.Fetch(context.Background(), t.TempDir())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reading template file")
+}
+
 // =============================================================================
 // GitSource Tests - Real Bare Repos
 // =============================================================================
@@ -492,6 +736,36 @@ func TestGitSourceFetch_DefaultBranch(t *testing.T) {
 	assert.NoDirExists(t, filepath.Join(destDir, ".git"))
 }
 
+func TestGitSourceFetch_Head(t *testing.T) {
+	repoURL := "file://" + setupBareRepo(t)
+
+	// @HEAD and a bare @ select the default branch like no version
+	for _, input := range []string{repoURL, repoURL + "@HEAD", repoURL + "@"} {
+		t.Run(input, func(t *testing.T) {
+			src, err := Parse(input)
+			require.NoError(t, err)
+			gs, ok := src.(*GitSource)
+			require.True(t, ok)
+			assert.Equal(t, repoURL, gs.URL)
+			assert.Empty(t, gs.Version)
+
+			destDir := filepath.Join(t.TempDir(), "dest")
+			require.NoError(t, gs.Fetch(context.Background(), destDir))
+			content, err := os.ReadFile(filepath.Join(destDir, "README.md"))
+			require.NoError(t, err)
+			assert.Equal(t, "# Test Template\n", string(content))
+			assert.NoDirExists(t, filepath.Join(destDir, ".git"))
+		})
+	}
+
+	t.Run("GitSource with HEAD", func(t *testing.T) {
+		destDir := filepath.Join(t.TempDir(), "dest")
+		gs := &GitSource{URL: repoURL, Version: HeadVersion, Depth: DefaultDepth}
+		require.NoError(t, gs.Fetch(context.Background(), destDir))
+		assert.FileExists(t, filepath.Join(destDir, "README.md"))
+	})
+}
+
 func TestGitSourceFetch_Tag(t *testing.T) {
 	repoURL := setupBareRepoWithTag(t, "v1.0.0")
 	destDir := filepath.Join(t.TempDir(), "dest")
@@ -507,6 +781,53 @@ func TestGitSourceFetch_Tag(t *testing.T) {
 	assert.NoDirExists(t, filepath.Join(destDir, ".git"))
 }
 
+// recordCloneDepth replaces the cloner for the test and returns a
+// pointer to the depth of the last clone.
+func recordCloneDepth(t *testing.T) *int {
+	t.Helper()
+
+	old := cloneRepo
+	t.Cleanup(func() { cloneRepo = old })
+
+	depth := -1
+	cloneRepo = func(ctx context.Context, path string, isBare bool, o *git.CloneOptions) (*git.Repository, error) {
+		depth = o.Depth
+		return old(ctx, path, isBare, o)
+	}
+	return &depth
+}
+
+func TestGitSourceFetch_Depth(t *testing.T) {
+	repoURL := setupBareRepoWithTag(t, "v1.0.0")
+
+	tests := []struct {
+		name    string
+		version string
+		depth   int
+	}{
+		{"default branch depth 5", "", 5},
+		{"default branch full", "", 0},
+		{"tag depth 5", "v1.0.0", 5},
+		{"tag full", "v1.0.0", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := recordCloneDepth(t)
+
+			gs := &GitSource{URL: repoURL, Version: tt.version, Depth: tt.depth}
+			require.NoError(t, gs.Fetch(context.Background(), filepath.Join(t.TempDir(), "dest")))
+			assert.Equal(t, tt.depth, *got)
+		})
+	}
+}
+
+func TestParseDefaultDepth(t *testing.T) {
+	src, err := Parse("user/repo")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultDepth, src.(*GitSource).Depth)
+}
+
 func TestGitSourceFetch_Branch(t *testing.T) {
 	repoURL := setupBareRepoWithBranch(t, "feature")
 	destDir := filepath.Join(t.TempDir(), "dest")
@@ -536,6 +857,9 @@ func TestGitSourceFetch_CommitHash(t *testing.T) {
 	// Verify second commit's file does NOT exist (we checked out first commit)
 	assert.NoFileExists(t, filepath.Join(destDir, "v2.txt"))
 
+	// Verify the resolved commit is recorded
+	assert.Equal(t, firstCommitHash, gs.Commit)
+
 	// Verify .git was removed
 	assert.NoDirExists(t, filepath.Join(destDir, ".git"))
 }
@@ -569,3 +893,806 @@ func TestGitSourceFetch_ShortCommitHash(t *testing.T) {
 		assert.FileExists(t, filepath.Join(destDir, "v1.txt"))
 	}
 }
+
+// =============================================================================
+// Git LFS Tests
+// =============================================================================
+
+// mockLFSFetcher serves LFS objects from memory keyed by OID.
+type mockLFSFetcher struct {
+	objects map[string][]byte
+}
+
+func (m *mockLFSFetcher) Open(_ context.Context, p LFSPointer) (io.ReadCloser, error) {
+	data, ok := m.objects[p.OID]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", p.OID)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// lfsPointerFor returns the pointer file content and OID for data.
+func lfsPointerFor(data []byte) (string, string) {
+	sum := sha256.Sum256(data)
+	oid := hex.EncodeToString(sum[:])
+	return fmt.Sprintf("%s\noid sha256:%s\nsize %d\n", lfsPointerVersion, oid, len(data)), oid
+}
+
+func TestParseLFSPointer(t *testing.T) {
+	pointer, oid := lfsPointerFor([]byte("binary content"))
+
+	p, ok := parseLFSPointer([]byte(pointer))
+	require.True(t, ok)
+	assert.Equal(t, oid, p.OID)
+	assert.Equal(t, int64(len("binary content")), p.Size)
+
+	_, ok = parseLFSPointer([]byte("just a regular file\n"))
+	assert.False(t, ok)
+}
+
+func TestResolveLFS(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("real image bytes")
+	pointer, oid := lfsPointerFor(content)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("*.png filter=lfs diff=lfs merge=lfs -text\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "logo.png"), []byte(pointer), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Readme\n"), 0o644))
+
+	fetcher := &mockLFSFetcher{objects: map[string][]byte{oid: content}}
+	resolved, err := resolveLFS(context.Background(), dir, fetcher)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"logo.png"}, resolved)
+
+	data, err := os.ReadFile(filepath.Join(dir, "logo.png"))
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func TestResolveLFS_NoFilters(t *testing.T) {
+	dir := t.TempDir()
+	pointer, _ := lfsPointerFor([]byte("content"))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "logo.png"), []byte(pointer), 0o644))
+
+	resolved, err := resolveLFS(context.Background(), dir, &mockLFSFetcher{})
+	require.NoError(t, err)
+	assert.Empty(t, resolved)
+}
+
+func TestResolveLFS_ChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	pointer, oid := lfsPointerFor([]byte("expected"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("*.bin filter=lfs\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.bin"), []byte(pointer), 0o644))
+
+	fetcher := &mockLFSFetcher{objects: map[string][]byte{oid: []byte("tampered")}}
+	_, err := resolveLFS(context.Background(), dir, fetcher)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "data.bin")
+
+	// The pointer is kept and the partial download removed
+	data, err := os.ReadFile(filepath.Join(dir, "data.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, pointer, string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestHTTPLFSFetcher(t *testing.T) {
+	content := []byte("served by lfs")
+	pointer, oid := lfsPointerFor(content)
+	p, ok := parseLFSPointer([]byte(pointer))
+	require.True(t, ok)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repo.git/info/lfs/objects/batch":
+			w.Header().Set("Content-Type", "application/vnd.git-lfs+json")
+			fmt.Fprintf(w, `{"objects":[{"oid":%q,"size":%d,"actions":{"download":{"href":%q}}}]}`,
+				oid, len(content), server.URL+"/objects/"+oid)
+		case "/objects/" + oid:
+			_, _ = w.Write(content)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fetcher, err := newHTTPLFSFetcher(server.URL+"/repo", nil)
+	require.NoError(t, err)
+	rc, err := fetcher.Open(context.Background(), p)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func TestLFSEndpoint(t *testing.T) {
+	tests := []struct {
+		repoURL string
+		want    string
+	}{
+		{"https://github.com/user/repo", "https://github.com/user/repo.git/info/lfs"},
+		{"https://github.com/user/repo.git/", "https://github.com/user/repo.git/info/lfs"},
+		{"http://git.example.com:8080/user/repo", "http://git.example.com:8080/user/repo.git/info/lfs"},
+		{"ssh://git@github.com/user/repo.git", "https://github.com/user/repo.git/info/lfs"},
+		{"ssh://git@git.example.com:2222/user/repo", "https://git.example.com/user/repo.git/info/lfs"},
+		{"git@github.com:user/repo.git", "https://github.com/user/repo.git/info/lfs"},
+		{"github.com:user/repo", "https://github.com/user/repo.git/info/lfs"},
+	}
+	for _, tt := range tests {
+		got, err := lfsEndpoint(tt.repoURL)
+		require.NoError(t, err, tt.repoURL)
+		assert.Equal(t, tt.want, got, tt.repoURL)
+	}
+
+	_, err := lfsEndpoint("file:///srv/git/repo.git")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "LFS is not supported for file remotes")
+}
+
+func TestParseWithHost(t *testing.T) {
+	src, err := ParseWithHost("user/repo@v1.0.0", "codeberg.org")
+	require.NoError(t, err)
+	gitSrc, ok := src.(*GitSource)
+	require.True(t, ok)
+	assert.Equal(t, "https://codeberg.org/user/repo", gitSrc.URL)
+	assert.Equal(t, "v1.0.0", gitSrc.Version)
+
+	// Explicit hosts are kept
+	src, err = ParseWithHost("gitlab.com/user/repo", "codeberg.org")
+	require.NoError(t, err)
+	assert.Equal(t, "https://gitlab.com/user/repo", src.(*GitSource).URL)
+}
+
+func TestFormats(t *testing.T) {
+	kinds := make(map[string]bool)
+	for _, f := range Formats {
+		require.NotEmpty(t, f.Examples, f.Name)
+
+		// All examples of a format resolve to the same kind of source
+		first, err := Parse(f.Examples[0])
+		require.NoError(t, err, f.Examples[0])
+		for _, input := range f.Examples {
+			src, err := Parse(input)
+			require.NoError(t, err, input)
+			assert.Equal(t, Kind(first), Kind(src), input)
+
+			location, _ := Location(src)
+			assert.NotEmpty(t, location, input)
+		}
+		kinds[Kind(first)] = true
+	}
+
+	assert.Equal(t, map[string]bool{KindGit: true, KindLocal: true, KindProxy: true}, kinds)
+}
+
+func TestLocation(t *testing.T) {
+	src, err := Parse("git@github.com:user/repo@v1.0.0")
+	require.NoError(t, err)
+	location, version := Location(src)
+	assert.Equal(t, "git@github.com:user/repo", location)
+	assert.Equal(t, "v1.0.0", version)
+
+	src, err = Parse("mod:github.com/user/repo@v1.0.0")
+	require.NoError(t, err)
+	location, version = Location(src)
+	assert.Equal(t, "github.com/user/repo", location)
+	assert.Equal(t, "v1.0.0", version)
+
+	assert.Empty(t, Kind(nil))
+}
+
+func TestParseNamed(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "mytemplate"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "go", "cli"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "notes.txt"), nil, 0o644))
+
+	t.Run("resolves name", func(t *testing.T) {
+		src, err := ParseNamed("mytemplate", root)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "mytemplate"), src.Path)
+	})
+
+	t.Run("resolves nested name", func(t *testing.T) {
+		src, err := ParseNamed("go/cli", root)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "go", "cli"), src.Path)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := ParseNamed("missing", root)
+		require.Error(t, err)
+		assert.Equal(t, fmt.Sprintf("template %q not found in %s", "missing", root), err.Error())
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		_, err := ParseNamed("notes.txt", root)
+		assert.ErrorContains(t, err, "is not a directory")
+	})
+
+	for _, name := range []string{"", "../mytemplate", "/etc"} {
+		t.Run("invalid "+name, func(t *testing.T) {
+			_, err := ParseNamed(name, root)
+			assert.ErrorContains(t, err, "invalid template name")
+		})
+	}
+}
+
+func TestDefaultTemplateDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	dir, err := DefaultTemplateDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".gohatch", "templates"), dir)
+}
+
+// =============================================================================
+// ProxySource Tests
+// =============================================================================
+
+func TestZipURL(t *testing.T) {
+	u, err := zipURL("https://proxy.golang.org", "github.com/User/Template", "v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "https://proxy.golang.org/github.com/!user/!template/@v/v1.0.0.zip", u)
+
+	u, err = latestURL("https://proxy.example.com", "github.com/user/template")
+	require.NoError(t, err)
+	assert.Equal(t, "https://proxy.example.com/github.com/user/template/@latest", u)
+}
+
+func TestProxyURLs(t *testing.T) {
+	urls, fallThrough := proxyURLs("https://a.example.com/,https://b.example.com|direct")
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com", "direct"}, urls)
+	assert.Equal(t, []bool{false, true, false}, fallThrough)
+}
+
+func TestSumDBURL(t *testing.T) {
+	t.Setenv("GONOSUMCHECK", "")
+	t.Setenv("GONOSUMDB", "")
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GOSUMDB", "")
+	assert.Equal(t, "https://sum.golang.org", sumDBURL("github.com/user/template"))
+
+	t.Setenv("GOSUMDB", "sum.example.com+key https://sumdb.example.com/")
+	assert.Equal(t, "https://sumdb.example.com", sumDBURL("github.com/user/template"))
+
+	t.Setenv("GOPRIVATE", "github.com/corp/*")
+	assert.Empty(t, sumDBURL("github.com/corp/template"))
+	assert.NotEmpty(t, sumDBURL("github.com/user/template"))
+
+	t.Setenv("GONOSUMCHECK", "1")
+	assert.Empty(t, sumDBURL("github.com/user/template"))
+
+	t.Setenv("GONOSUMCHECK", "")
+	t.Setenv("GOSUMDB", "off")
+	assert.Empty(t, sumDBURL("github.com/user/template"))
+}
+
+func TestParseProxySource(t *testing.T) {
+	t.Setenv("GOPROXY", "https://proxy.example.com")
+
+	src, err := Parse("mod:github.com/user/template@v1.2.0")
+	require.NoError(t, err)
+
+	ps, ok := src.(*ProxySource)
+	require.True(t, ok)
+	assert.Equal(t, "github.com/user/template", ps.Module)
+	assert.Equal(t, "v1.2.0", ps.Version)
+	assert.Equal(t, "https://proxy.example.com", ps.Proxy)
+
+	_, err = Parse("mod:")
+	assert.Error(t, err)
+}
+
+// moduleZip builds a module zip for modPath@version and returns it
+// together with its h1 checksum.
+func moduleZip(t *testing.T, modPath, version string, files map[string]string) ([]byte, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(modPath + "@" + version + "/" + name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	names := make([]string, 0, len(zr.File))
+	entries := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		entries[f.Name] = f
+	}
+	sum, err := dirhash.Hash1(names, func(name string) (io.ReadCloser, error) {
+		return entries[name].Open()
+	})
+	require.NoError(t, err)
+
+	return buf.Bytes(), sum
+}
+
+// proxyServer serves a single module version as proxy and checksum database.
+func proxyServer(t *testing.T, data []byte, sum string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/github.com/!user/template/@latest":
+			fmt.Fprint(w, `{"Version":"v1.2.0"}`)
+		case "/github.com/!user/template/@v/v1.2.0.zip":
+			_, _ = w.Write(data)
+		case "/lookup/github.com/!user/template@v1.2.0":
+			fmt.Fprintf(w, "42\ngithub.com/User/template v1.2.0 %s\ngithub.com/User/template v1.2.0/go.mod h1:x\n", sum)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxySourceFetch(t *testing.T) {
+	data, sum := moduleZip(t, "github.com/User/template", "v1.2.0", map[string]string{
+		"go.mod":      "module github.com/User/template\n",
+		"cmd/main.go": "package main\n",
+	})
+	server := proxyServer(t, data, sum)
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	destDir := filepath.Join(t.TempDir(), "dest")
+	ps := &ProxySource{
+		Module: "github.com/User/template",
+		Proxy:  missing.URL + "," + server.URL,
+		SumDB:  server.URL,
+	}
+	require.NoError(t, ps.Fetch(context.Background(), destDir))
+
+	assert.Equal(t, "v1.2.0", ps.Version)
+	content, err := os.ReadFile(filepath.Join(destDir, "cmd", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(content))
+	assert.FileExists(t, filepath.Join(destDir, "go.mod"))
+}
+
+func TestProxySourceFetchChecksumMismatch(t *testing.T) {
+	data, _ := moduleZip(t, "github.com/User/template", "v1.2.0", map[string]string{"go.mod": "module x\n"})
+	server := proxyServer(t, data, "h1:tampered=")
+
+	ps := &ProxySource{
+		Module:  "github.com/User/template",
+		Version: "v1.2.0",
+		Proxy:   server.URL,
+		SumDB:   server.URL,
+	}
+	err := ps.Fetch(context.Background(), filepath.Join(t.TempDir(), "dest"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+}
+
+func TestProxySourceFetchRejectsEscapingPaths(t *testing.T) {
+	data, _ := moduleZip(t, "github.com/User/template", "v1.2.0", map[string]string{"../evil": "x"})
+	server := proxyServer(t, data, "")
+
+	ps := &ProxySource{Module: "github.com/User/template", Version: "v1.2.0", Proxy: server.URL}
+	err := ps.Fetch(context.Background(), filepath.Join(t.TempDir(), "dest"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid file")
+}
+
+func TestProxySourceFetchSizeLimit(t *testing.T) {
+	data, _ := moduleZip(t, "github.com/User/template", "v1.2.0", map[string]string{
+		"go.mod":   "module github.com/User/template\n",
+		"data.txt": strings.Repeat("0", 4096),
+	})
+	server := proxyServer(t, data, "")
+
+	limit := maxZipSize
+	t.Cleanup(func() { maxZipSize = limit })
+
+	t.Run("oversized response", func(t *testing.T) {
+		maxZipSize = int64(len(data)) - 1
+		ps := &ProxySource{Module: "github.com/User/template", Version: "v1.2.0", Proxy: server.URL}
+		err := ps.Fetch(context.Background(), filepath.Join(t.TempDir(), "dest"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "response exceeds")
+	})
+
+	t.Run("oversized contents", func(t *testing.T) {
+		maxZipSize = int64(len(data))
+		require.Less(t, maxZipSize, int64(4096), "the zip compresses its contents")
+		ps := &ProxySource{Module: "github.com/User/template", Version: "v1.2.0", Proxy: server.URL}
+		err := ps.Fetch(context.Background(), filepath.Join(t.TempDir(), "dest"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds")
+		assert.Contains(t, err.Error(), "when extracted")
+	})
+}
+
+func TestProxySourceFetchOff(t *testing.T) {
+	ps := &ProxySource{Module: "github.com/user/template", Version: "v1.0.0", Proxy: "off"}
+	err := ps.Fetch(context.Background(), t.TempDir())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "GOPROXY=off")
+}
+
+func TestGitSourceFetch_Ref(t *testing.T) {
+	repoURL := setupBareRepoWithTag(t, "release/2024")
+
+	dest := filepath.Join(t.TempDir(), "dest")
+	gs := &GitSource{URL: repoURL, Ref: "refs/tags/release/2024", Depth: DefaultDepth}
+	require.NoError(t, gs.Fetch(context.Background(), dest))
+
+	assert.NotEmpty(t, gs.Commit)
+	assert.FileExists(t, filepath.Join(dest, "README.md"))
+}
+
+func TestGitSourceFetch_ExpectCommit(t *testing.T) {
+	repoURL := setupBareRepoWithTag(t, "v1.0.0")
+
+	gs := &GitSource{URL: repoURL, Depth: DefaultDepth}
+	require.NoError(t, gs.Fetch(context.Background(), filepath.Join(t.TempDir(), "dest")))
+	commit := gs.Commit
+
+	t.Run("full hash", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "dest")
+		gs := &GitSource{URL: repoURL, Depth: DefaultDepth, ExpectCommit: commit}
+		require.NoError(t, gs.Fetch(context.Background(), dest))
+		assert.FileExists(t, filepath.Join(dest, "README.md"))
+	})
+
+	t.Run("abbreviated uppercase hash", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "dest")
+		gs := &GitSource{URL: repoURL, Version: "v1.0.0", Depth: DefaultDepth, ExpectCommit: strings.ToUpper(commit[:7])}
+		require.NoError(t, gs.Fetch(context.Background(), dest))
+	})
+
+	t.Run("mismatch removes dest", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "dest")
+		gs := &GitSource{URL: repoURL, Depth: DefaultDepth, ExpectCommit: "deadbeef"}
+		err := gs.Fetch(context.Background(), dest)
+		require.ErrorContains(t, err, "commit mismatch: expected deadbeef, got "+commit)
+		assert.NoDirExists(t, dest)
+	})
+}
+
+func TestCheckCommitHash(t *testing.T) {
+	for _, valid := range []string{"abc1234", "ABCDEF0", strings.Repeat("a", 40), strings.Repeat("f", 64)} {
+		assert.NoError(t, CheckCommitHash(valid), valid)
+	}
+	for _, invalid := range []string{"", "abc123", "main", "v1.0.0", strings.Repeat("a", 65), "abc123g"} {
+		assert.Error(t, CheckCommitHash(invalid), invalid)
+	}
+}
+
+func TestParseNetrc(t *testing.T) {
+	data := []byte(`machine github.com login alice password s3cret
+machine gitlab.example.com
+  login bob
+  password hunter2
+  account ignored
+
+macdef init
+machine evil.example.com login mallory password x
+
+default login anon password guest
+`)
+
+	assert.Equal(t, []netrcEntry{
+		{Machine: "github.com", Login: "alice", Password: "s3cret"},
+		{Machine: "gitlab.example.com", Login: "bob", Password: "hunter2"},
+		{Login: "anon", Password: "guest"},
+	}, parseNetrc(data))
+}
+
+func TestNetrcAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".netrc")
+	require.NoError(t, os.WriteFile(path, []byte(`machine github.com login alice password s3cret
+machine gitlab.example.com login bob password hunter2
+`), 0o600))
+
+	auth := netrcAuth(path, "https://gitlab.example.com:8443/team/repo.git")
+	require.NotNil(t, auth)
+	assert.Equal(t, "bob", auth.Username)
+	assert.Equal(t, "hunter2", auth.Password)
+
+	auth = netrcAuth(path, "https://github.com/user/repo")
+	require.NotNil(t, auth)
+	assert.Equal(t, "alice", auth.Username)
+
+	assert.Nil(t, netrcAuth(path, "https://codeberg.org/user/repo"), "no entry for host")
+	assert.Nil(t, netrcAuth(path, "git@github.com:user/repo"), "SSH URL")
+	assert.Nil(t, netrcAuth(path, "https://me:pw@github.com/user/repo"), "URL with user info")
+	assert.Nil(t, netrcAuth(filepath.Join(t.TempDir(), "missing"), "https://github.com/user/repo"))
+}
+
+func TestGitSourceFetch_Netrc(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".netrc")
+	require.NoError(t, os.WriteFile(path, []byte("machine example.com login alice password s3cret\n"), 0o600))
+
+	old := cloneRepo
+	t.Cleanup(func() { cloneRepo = old })
+
+	var got transport.AuthMethod
+	cloneRepo = func(_ context.Context, _ string, _ bool, o *git.CloneOptions) (*git.Repository, error) {
+		got = o.Auth
+		return nil, errors.New("stop")
+	}
+
+	fetch := func(gs *GitSource) transport.AuthMethod {
+		got = nil
+		_ = gs.Fetch(context.Background(), t.TempDir())
+		return got
+	}
+
+	assert.Equal(t, &githttp.BasicAuth{Username: "alice", Password: "s3cret"},
+		fetch(&GitSource{URL: "https://example.com/user/repo", Netrc: path}))
+	assert.Nil(t, fetch(&GitSource{URL: "https://other.com/user/repo", Netrc: path}), "host not in netrc")
+	assert.Nil(t, fetch(&GitSource{URL: "https://example.com/user/repo"}), "netrc disabled")
+	tokens := map[string]string{"example.com": "tok"}
+	assert.Equal(t, &githttp.BasicAuth{Username: "gohatch", Password: "tok"},
+		fetch(&GitSource{URL: "https://example.com/user/repo", Netrc: path, Tokens: tokens}), "token takes precedence")
+	assert.Equal(t, &githttp.BasicAuth{Username: "gohatch", Password: "tok"},
+		fetch(&GitSource{URL: "https://Example.COM/user/repo", Tokens: tokens}), "host is case-insensitive")
+}
+
+func TestGitSourceFetch_TokenScopedToHost(t *testing.T) {
+	old := cloneRepo
+	t.Cleanup(func() { cloneRepo = old })
+
+	var got transport.AuthMethod
+	cloneRepo = func(_ context.Context, _ string, _ bool, o *git.CloneOptions) (*git.Repository, error) {
+		got = o.Auth
+		return nil, errors.New("stop")
+	}
+
+	tokens := map[string]string{"github.com": "secret"}
+	for _, u := range []string{
+		"https://evil.example/user/repo",
+		"https://github.com.evil.example/user/repo",
+		"http://localhost:8080/user/repo",
+		"git@github.com:user/repo.git",
+	} {
+		got = nil
+		_ = (&GitSource{URL: u, Tokens: tokens}).Fetch(context.Background(), t.TempDir())
+		assert.Nil(t, got, u)
+	}
+}
+
+func TestNetrcPath(t *testing.T) {
+	t.Setenv("NETRC", "/etc/gohatch-netrc")
+	assert.Equal(t, "/etc/gohatch-netrc", NetrcPath())
+
+	home := t.TempDir()
+	t.Setenv("NETRC", "")
+	t.Setenv("HOME", home)
+	assert.Equal(t, filepath.Join(home, ".netrc"), NetrcPath())
+}
+
+func TestGitSourceFetch_RefVerbatim(t *testing.T) {
+	workDir := t.TempDir()
+	repo, err := git.PlainInit(workDir, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	commit := func(content string) plumbing.Hash {
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, "README.md"), []byte(content), 0o644))
+		_, err := worktree.Add("README.md")
+		require.NoError(t, err)
+		hash, err := worktree.Commit(content, &git.CommitOptions{
+			Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+		})
+		require.NoError(t, err)
+		return hash
+	}
+
+	// The pull request head exists only under refs/pull, not as a branch
+	base := commit("main\n")
+	pr := commit("pull request\n")
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/pull/42/head", pr)))
+	head, err := repo.Head()
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), base)))
+
+	for _, depth := range []int{0, DefaultDepth} {
+		dest := t.TempDir()
+		gs := &GitSource{URL: "file://" + workDir, Ref: "refs/pull/42/head", Depth: depth}
+		require.NoError(t, gs.Fetch(context.Background(), dest))
+
+		assert.Equal(t, pr.String(), gs.Commit)
+		data, err := os.ReadFile(filepath.Join(dest, "README.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "pull request\n", string(data))
+		assert.NoDirExists(t, filepath.Join(dest, ".git"))
+	}
+
+	// Annotated tags resolve to their commit
+	_, err = repo.CreateTag("review", pr, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+		Message: "review",
+	})
+	require.NoError(t, err)
+	gs := &GitSource{URL: "file://" + workDir, Ref: "refs/tags/review"}
+	require.NoError(t, gs.Fetch(context.Background(), t.TempDir()))
+	assert.Equal(t, pr.String(), gs.Commit)
+
+	gs = &GitSource{URL: "file://" + workDir, Ref: "refs/pull/7/head"}
+	assert.ErrorContains(t, gs.Fetch(context.Background(), t.TempDir()), "cloning refs/pull/7/head")
+}
+
+func TestGitSourceFetch_InvalidRef(t *testing.T) {
+	for _, ref := range []string{"pull/42/head", "refs/heads/bad..name"} {
+		gs := &GitSource{URL: "https://example.com/user/repo", Ref: ref}
+		err := gs.Fetch(context.Background(), t.TempDir())
+		assert.ErrorContains(t, err, "invalid ref", ref)
+	}
+}
+
+func TestVanityImportPath(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"go.example.com/app", "go.example.com/app"},
+		{"go.example.com/app.git", ""},
+		{"github.com/user/repo", ""},
+		{"codeberg.org/user/repo", ""},
+		{"git.example.com/user/repo", ""},
+		{"Git.Example.com/user/repo", ""},
+		{"user/repo", ""},
+		{"https://go.example.com/app", ""},
+		{"git@go.example.com:app", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			src, err := ParseWithHost(tt.input, "git.example.com")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, src.(*GitSource).ImportPath)
+		})
+	}
+}
+
+func TestParseMetaGoImports(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="go-import" content="go.example.com/app git https://git.example.com/team/app">
+<meta name="go-source" content="go.example.com/app _ _ _">
+<meta name="go-import" content="go.example.com/app/tools mod https://proxy.example.com">
+</head>
+<body><meta name="go-import" content="ignored git https://example.com/ignored"></body>
+</html>`
+
+	imports, err := parseMetaGoImports(strings.NewReader(page))
+	require.NoError(t, err)
+	assert.Equal(t, []metaImport{
+		{Prefix: "go.example.com/app", VCS: "git", RepoRoot: "https://git.example.com/team/app"},
+		{Prefix: "go.example.com/app/tools", VCS: "mod", RepoRoot: "https://proxy.example.com"},
+	}, imports)
+
+	imp, ok := matchMetaImport(imports, "go.example.com/app/tools")
+	require.True(t, ok)
+	assert.Equal(t, "go.example.com/app/tools", imp.Prefix)
+
+	_, ok = matchMetaImport(imports, "go.example.com/application")
+	assert.False(t, ok)
+}
+
+// vanityServer serves content as go-import meta tag for every go-get
+// request and returns the import path of the served app.
+func vanityServer(t *testing.T, content func(host string) string) (*httptest.Server, string) {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("go-get") != "1" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `<html><head><meta name="go-import" content="%s"></head></html>`, content(r.Host))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, strings.TrimPrefix(srv.URL, "https://") + "/app"
+}
+
+func TestGitSourceFetch_VanityImport(t *testing.T) {
+	repoURL := "file://" + setupBareRepo(t)
+
+	t.Run("uses the announced repository", func(t *testing.T) {
+		for _, root := range []string{"https://git.example.com/team/app", "ssh://git@git.example.com/team/app", "git@git.example.com:team/app"} {
+			srv, importPath := vanityServer(t, func(host string) string {
+				return host + "/app git " + root
+			})
+
+			gs := &GitSource{URL: "https://" + importPath, ImportPath: importPath, Client: srv.Client()}
+			require.NoError(t, gs.resolveImport(context.Background()))
+			assert.Equal(t, root, gs.URL)
+		}
+	})
+
+	t.Run("rejects other transports", func(t *testing.T) {
+		for _, root := range []string{repoURL, "/srv/git/app", "ext::/bin/false", "http://git.example.com/team/app", "git.example.com:team/app"} {
+			srv, importPath := vanityServer(t, func(host string) string {
+				return host + "/app git " + root
+			})
+
+			gs := &GitSource{URL: "https://" + importPath, ImportPath: importPath, Client: srv.Client()}
+			destDir := filepath.Join(t.TempDir(), "dest")
+			err := gs.Fetch(context.Background(), destDir)
+			require.Error(t, err, root)
+			assert.Contains(t, err.Error(), "unsupported repository root", root)
+			assert.NoDirExists(t, destDir)
+		}
+	})
+
+	t.Run("other vcs", func(t *testing.T) {
+		srv, importPath := vanityServer(t, func(host string) string {
+			return host + "/app hg https://hg.example.com/app"
+		})
+
+		gs := &GitSource{URL: "https://" + importPath, ImportPath: importPath, Client: srv.Client()}
+		err := gs.Fetch(context.Background(), filepath.Join(t.TempDir(), "dest"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "repository uses hg, only git is supported")
+	})
+
+	t.Run("subdirectory of a repository", func(t *testing.T) {
+		srv, importPath := vanityServer(t, func(host string) string {
+			return host + " git " + repoURL
+		})
+
+		gs := &GitSource{URL: "https://" + importPath, ImportPath: importPath, Client: srv.Client()}
+		err := gs.Fetch(context.Background(), filepath.Join(t.TempDir(), "dest"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is a subdirectory of")
+	})
+
+	t.Run("no meta tag keeps the URL", func(t *testing.T) {
+		srv, importPath := vanityServer(t, func(string) string { return "" })
+
+		gs := &GitSource{URL: repoURL, ImportPath: importPath, Client: srv.Client()}
+		require.NoError(t, gs.resolveImport(context.Background()))
+		assert.Equal(t, repoURL, gs.URL)
+	})
+
+	t.Run("failed lookups are logged", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}))
+		t.Cleanup(srv.Close)
+		importPath := strings.TrimPrefix(srv.URL, "https://") + "/app"
+
+		var logs bytes.Buffer
+		gs := &GitSource{
+			URL:        repoURL,
+			ImportPath: importPath,
+			Client:     srv.Client(),
+			Logger:     slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		}
+		require.NoError(t, gs.resolveImport(context.Background()))
+		assert.Equal(t, repoURL, gs.URL)
+		assert.Contains(t, logs.String(), `msg="go-import lookup failed, cloning URL"`)
+		assert.Contains(t, logs.String(), `status="404 Not Found"`)
+
+		logs.Reset()
+		srv.Close()
+		require.NoError(t, gs.resolveImport(context.Background()))
+		assert.Contains(t, logs.String(), `msg="go-import lookup failed, cloning URL"`)
+		assert.Contains(t, logs.String(), "error=")
+	})
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: EUPL-1.2
+// Copyright (c) 2025 Oliver Andrich
+
+package source
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// knownGitHosts serve repositories at their import paths, so sources on
+// them are cloned without looking up a go-import meta tag first.
+var knownGitHosts = []string{"github.com", "gitlab.com", "bitbucket.org", "codeberg.org"}
+
+// importClient performs go-import lookups unless GitSource.Client is set.
+// The timeout keeps an unresponsive server from stalling every fetch.
+var importClient = &http.Client{Timeout: 10 * time.Second}
+
+// metaImport is the content of a go-import meta tag:
+// "import-prefix vcs repo-root".
+type metaImport struct {
+	Prefix, VCS, RepoRoot string
+}
+
+// vanityImportPath returns the import path whose go-import meta tag
+// names the repository of a source path such as go.example.com/app, or
+// "" for shorthands, Git URLs, paths ending in .git, which already name
+// a repository, and paths on a known Git host or host.
+func vanityImportPath(path, host string) string {
+	if isFullGitURL(path) || strings.HasSuffix(path, ".git") {
+		return ""
+	}
+	domain, _, _ := strings.Cut(path, "/")
+	domain = strings.ToLower(domain)
+	if !strings.Contains(domain, ".") || domain == strings.ToLower(host) || slices.Contains(knownGitHosts, domain) {
+		return ""
+	}
+	return path
+}
+
+// resolveImport replaces URL with the repository root announced by the
+// go-import meta tag of ImportPath, as the go command does for vanity
+// import paths such as go.example.com/app. Without a matching tag the URL
+// is kept, so plain Git servers still work; the reason is logged.
+func (s *GitSource) resolveImport(ctx context.Context) error {
+	client := s.Client
+	if client == nil {
+		client = importClient
+	}
+
+	lookupURL := "https://" + s.ImportPath + "?go-get=1"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lookupURL, nil)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", s.ImportPath, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		s.debug("go-import lookup failed, cloning URL", "url", lookupURL, "error", err)
+		return nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		s.debug("go-import lookup failed, cloning URL", "url", lookupURL, "status", resp.Status)
+		return nil
+	}
+
+	imports, err := parseMetaGoImports(resp.Body)
+	if err != nil {
+		s.debug("go-import lookup failed, cloning URL", "url", lookupURL, "error", err)
+		return nil
+	}
+	imp, ok := matchMetaImport(imports, s.ImportPath)
+	if !ok {
+		s.debug("no go-import meta tag, cloning URL", "url", lookupURL)
+		return nil
+	}
+
+	switch {
+	case imp.VCS != "git":
+		return fmt.Errorf("resolving %s: repository uses %s, only git is supported", s.ImportPath, imp.VCS)
+	case imp.Prefix != s.ImportPath:
+		return fmt.Errorf("resolving %s: template is a subdirectory of %s, which is not supported", s.ImportPath, imp.RepoRoot)
+	}
+	if err := checkRepoRoot(imp.RepoRoot); err != nil {
+		return fmt.Errorf("resolving %s: %w", s.ImportPath, err)
+	}
+	s.debug("resolved go-import meta tag", "import", s.ImportPath, "repo", imp.RepoRoot)
+	s.URL = imp.RepoRoot
+	return nil
+}
+
+// checkRepoRoot accepts the repository roots the go command clones git
+// repositories from: https and ssh URLs, and scp-style user@host:path.
+// A remote page can thus not point the clone at a local path or at
+// another transport such as file:// or ext::.
+func checkRepoRoot(root string) error {
+	userHost, path, ok := strings.Cut(root, ":")
+	if user, host, found := strings.Cut(userHost, "@"); ok && found && user != "" && host != "" &&
+		!strings.ContainsAny(userHost, "/\\ ") && path != "" {
+		return nil
+	}
+	if u, err := url.Parse(root); err == nil && (u.Scheme == "https" || u.Scheme == "ssh") && u.Host != "" {
+		return nil
+	}
+	return fmt.Errorf("unsupported repository root %q: only https, ssh, and user@host:path are allowed", root)
+}
+
+// debug logs a debug message to Logger, if set.
+func (s *GitSource) debug(msg string, args ...any) {
+	if s.Logger != nil {
+		s.Logger.Debug(msg, args...)
+	}
+}
+
+// matchMetaImport returns the import whose prefix is path or one of its
+// parents, preferring the longest.
+func matchMetaImport(imports []metaImport, path string) (metaImport, bool) {
+	var match metaImport
+	found := false
+	for _, imp := range imports {
+		if imp.Prefix != path && !strings.HasPrefix(path, imp.Prefix+"/") {
+			continue
+		}
+		if !found || len(imp.Prefix) > len(match.Prefix) {
+			match, found = imp, true
+		}
+	}
+	return match, found
+}
+
+// parseMetaGoImports reads the go-import meta tags from the head of an
+// HTML page, tolerating the sloppy HTML served by most hosts.
+func parseMetaGoImports(r io.Reader) ([]metaImport, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "ascii") {
+			return input, nil
+		}
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+
+	var imports []metaImport
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			if errors.Is(err, io.EOF) || len(imports) > 0 {
+				return imports, nil
+			}
+			return nil, err
+		}
+
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return imports, nil
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return imports, nil
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") || attrValue(e.Attr, "name") != "go-import" {
+			continue
+		}
+		if f := strings.Fields(attrValue(e.Attr, "content")); len(f) == 3 {
+			imports = append(imports, metaImport{Prefix: f[0], VCS: f[1], RepoRoot: f[2]})
+		}
+	}
+}
+
+// attrValue returns the value of the attribute name, or "" if missing.
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}